@@ -0,0 +1,174 @@
+package apierror
+
+import "net/http"
+
+// Code is a stable, machine-readable error identifier. Clients should switch on
+// the code; the detail text is for humans and may change.
+type Code string
+
+// Request shape
+const (
+	CodeMalformedRequest Code = "malformed_request" // Body is not valid JSON or has the wrong types
+	CodeValidationFailed Code = "validation_failed" // Body parsed but a field breaks a rule, see errors[]
+	CodeInvalidParameter Code = "invalid_parameter" // Query or path parameter is out of range or unparseable
+	CodeRequestTooLarge  Code = "request_too_large" // Body is over the size limit for the endpoint
+)
+
+// Authentication and authorization
+const (
+	CodeMissingToken       Code = "missing_token"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidCredentials Code = "invalid_credentials"
+	CodeIncorrectPassword  Code = "incorrect_password"
+	CodeAccountDeactivated Code = "account_deactivated"
+	CodeRoleNotAllowed     Code = "role_not_allowed"
+	CodeLibraryAccess      Code = "library_access_denied" // Caller does not manage or belong to the library
+	CodeForbidden          Code = "forbidden"
+)
+
+// Missing resources
+const (
+	CodeNotFound              Code = "not_found"
+	CodeLibraryNotFound       Code = "library_not_found"
+	CodeBookNotFound          Code = "book_not_found"
+	CodeUserNotFound          Code = "user_not_found"
+	CodeIssueRequestNotFound  Code = "issue_request_not_found"
+	CodeMembershipNotFound    Code = "membership_not_found"
+	CodeAttachmentNotFound    Code = "attachment_not_found"
+	CodeMetadataNotFound      Code = "metadata_not_found" // No enrichment source has a record for the ISBN
+	CodeEnrichmentJobNotFound Code = "enrichment_job_not_found"
+	CodeLocationNotFound      Code = "location_not_found"
+	CodeTransferNotFound      Code = "transfer_not_found"
+	CodeRouteNotFound         Code = "route_not_found"
+	CodeMethodNotAllowed      Code = "method_not_allowed"
+)
+
+// Business rules
+const (
+	CodeAlreadyProcessed   Code = "request_already_processed"
+	CodeAlreadyInState     Code = "already_in_state"
+	CodeSelfModification   Code = "self_modification"
+	CodeNoCopiesAvailable  Code = "no_copies_available"
+	CodeCopiesIssued       Code = "copies_issued"
+	CodeBookAlreadyIssued  Code = "book_already_issued"
+	CodeEmailTaken         Code = "email_taken"
+	CodeDuplicateRequest   Code = "duplicate_request"
+	CodeLastOwner          Code = "last_owner"
+	CodeLibraryNotEmpty    Code = "library_not_empty"
+	CodeActiveLoans        Code = "active_loans"
+	CodeLocationNotEmpty   Code = "location_not_empty" // Location still has sub-locations or books assigned
+	CodeTransferClosed     Code = "transfer_closed"    // Transfer was already received or cancelled
+	CodeConflict           Code = "conflict"
+	CodePreconditionFailed Code = "precondition_failed" // If-Match no longer matches, reload and retry
+)
+
+// Uploads
+const (
+	CodeUploadTooLarge       Code = "upload_too_large"       // File is over the size limit for its kind
+	CodeUnsupportedMediaType Code = "unsupported_media_type" // File content is not a type accepted for its kind
+)
+
+// Idempotent retries
+const (
+	CodeIdempotencyKeyReused  Code = "idempotency_key_reused"  // Same Idempotency-Key sent with a different request
+	CodeIdempotencyInProgress Code = "idempotency_in_progress" // The first request with this key has not finished yet
+)
+
+// Abuse protection
+const (
+	CodeRateLimited Code = "rate_limited" // Too many requests from this client, see Retry-After
+)
+
+// Server side
+const (
+	CodeInternal Code = "internal_error"
+)
+
+type entry struct {
+	status int
+	title  string
+}
+
+// catalog fixes the HTTP status and title of every code
+var catalog = map[Code]entry{
+	CodeMalformedRequest: {http.StatusBadRequest, "Malformed request body"},
+	CodeValidationFailed: {http.StatusBadRequest, "Validation failed"},
+	CodeInvalidParameter: {http.StatusBadRequest, "Invalid parameter"},
+	CodeRequestTooLarge:  {http.StatusRequestEntityTooLarge, "Request too large"},
+
+	CodeMissingToken:       {http.StatusUnauthorized, "Missing token"},
+	CodeInvalidToken:       {http.StatusUnauthorized, "Invalid or expired token"},
+	CodeUnauthorized:       {http.StatusUnauthorized, "Unauthorized"},
+	CodeInvalidCredentials: {http.StatusUnauthorized, "Invalid credentials"},
+	CodeIncorrectPassword:  {http.StatusUnauthorized, "Incorrect password"},
+	CodeAccountDeactivated: {http.StatusForbidden, "Account deactivated"},
+	CodeRoleNotAllowed:     {http.StatusForbidden, "Access denied"},
+	CodeLibraryAccess:      {http.StatusForbidden, "Library access denied"},
+	CodeForbidden:          {http.StatusForbidden, "Forbidden"},
+
+	CodeNotFound:              {http.StatusNotFound, "Not found"},
+	CodeLibraryNotFound:       {http.StatusNotFound, "Library not found"},
+	CodeBookNotFound:          {http.StatusNotFound, "Book not found"},
+	CodeUserNotFound:          {http.StatusNotFound, "User not found"},
+	CodeIssueRequestNotFound:  {http.StatusNotFound, "Issue request not found"},
+	CodeMembershipNotFound:    {http.StatusNotFound, "Membership not found"},
+	CodeAttachmentNotFound:    {http.StatusNotFound, "Attachment not found"},
+	CodeMetadataNotFound:      {http.StatusNotFound, "Metadata not found"},
+	CodeEnrichmentJobNotFound: {http.StatusNotFound, "Enrichment job not found"},
+	CodeLocationNotFound:      {http.StatusNotFound, "Location not found"},
+	CodeTransferNotFound:      {http.StatusNotFound, "Transfer not found"},
+	CodeRouteNotFound:         {http.StatusNotFound, "Route not found"},
+	CodeMethodNotAllowed:      {http.StatusMethodNotAllowed, "Method not allowed"},
+
+	CodeAlreadyProcessed:   {http.StatusBadRequest, "Request already processed"},
+	CodeAlreadyInState:     {http.StatusBadRequest, "Already in that state"},
+	CodeSelfModification:   {http.StatusBadRequest, "Cannot modify own account"},
+	CodeNoCopiesAvailable:  {http.StatusBadRequest, "No copies available"},
+	CodeCopiesIssued:       {http.StatusBadRequest, "Copies are issued"},
+	CodeBookAlreadyIssued:  {http.StatusBadRequest, "Book already issued"},
+	CodeEmailTaken:         {http.StatusConflict, "Email already registered"},
+	CodeDuplicateRequest:   {http.StatusConflict, "Duplicate request"},
+	CodeLastOwner:          {http.StatusConflict, "Last owner"},
+	CodeLibraryNotEmpty:    {http.StatusConflict, "Library not empty"},
+	CodeActiveLoans:        {http.StatusConflict, "Active loans"},
+	CodeLocationNotEmpty:   {http.StatusConflict, "Location not empty"},
+	CodeTransferClosed:     {http.StatusConflict, "Transfer closed"},
+	CodeConflict:           {http.StatusConflict, "Conflict"},
+	CodePreconditionFailed: {http.StatusPreconditionFailed, "Precondition failed"},
+
+	CodeUploadTooLarge:       {http.StatusRequestEntityTooLarge, "Upload too large"},
+	CodeUnsupportedMediaType: {http.StatusUnsupportedMediaType, "Unsupported media type"},
+
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, "Idempotency key reused"},
+	CodeIdempotencyInProgress: {http.StatusConflict, "Request still in progress"},
+
+	CodeRateLimited: {http.StatusTooManyRequests, "Too many requests"},
+
+	CodeInternal: {http.StatusInternalServerError, "Internal server error"},
+}
+
+// Status returns the HTTP status a code is always sent with
+func (code Code) Status() int {
+	if e, ok := catalog[code]; ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title returns the short, fixed summary of a code
+func (code Code) Title() string {
+	if e, ok := catalog[code]; ok {
+		return e.title
+	}
+	return http.StatusText(code.Status())
+}
+
+// Catalog lists every known code, for documentation
+func Catalog() map[Code]int {
+	codes := make(map[Code]int, len(catalog))
+	for code, e := range catalog {
+		codes[code] = e.status
+	}
+	return codes
+}
@@ -0,0 +1,146 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Field errors name what the client sent, the JSON key, rather than the Go field
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			switch name {
+			case "-":
+				return ""
+			case "":
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// ContentType is the RFC 7807 media type for error responses
+const ContentType = "application/problem+json"
+
+// typeBase prefixes the code to form the problem type URI
+const typeBase = "/problems/"
+
+// Problem is an RFC 7807 problem details body with the error code, request ID and
+// per-field validation errors as extension members
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError explains why one field of the request failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// New builds the problem for a code as seen by the current request
+func New(c *gin.Context, code Code, detail string) Problem {
+	return Problem{
+		Type:      typeBase + string(code),
+		Title:     code.Title(),
+		Status:    code.Status(),
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      code,
+		RequestID: c.GetString("requestID"),
+	}
+}
+
+// Respond writes a problem+json response and aborts the handler chain
+func Respond(c *gin.Context, code Code, detail string) {
+	Write(c, New(c, code, detail))
+}
+
+// Write sends an already built problem
+func Write(c *gin.Context, problem Problem) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+// RespondBinding turns an error from ShouldBindJSON or ShouldBindQuery into a
+// problem. Validator errors become field details; anything else is reported
+// without echoing decoder internals.
+func RespondBinding(c *gin.Context, err error) {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		problem := New(c, CodeValidationFailed, "One or more fields are invalid")
+		for _, fieldErr := range validationErrors {
+			problem.Errors = append(problem.Errors, fieldError(fieldErr))
+		}
+		Write(c, problem)
+		return
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.Is(err, io.EOF):
+		Respond(c, CodeMalformedRequest, "Request body is empty")
+	case errors.As(err, &typeErr):
+		problem := New(c, CodeValidationFailed, "One or more fields have the wrong type")
+		problem.Errors = []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Param:   typeErr.Type.String(),
+			Message: fmt.Sprintf("must be of type %s", typeErr.Type),
+		}}
+		Write(c, problem)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		Respond(c, CodeMalformedRequest, "Request body is not valid JSON")
+	default:
+		Respond(c, CodeMalformedRequest, "Request body could not be read")
+	}
+}
+
+func fieldError(err validator.FieldError) FieldError {
+	field := err.Namespace()
+	// Drop the Go struct name, clients only know the field path
+	if i := strings.Index(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+
+	var message string
+	switch err.Tag() {
+	case "required":
+		message = "is required"
+	case "email":
+		message = "must be a valid email address"
+	case "min":
+		message = "must be at least " + err.Param()
+	case "max":
+		message = "must be at most " + err.Param()
+	case "oneof":
+		message = "must be one of " + err.Param()
+	case "gt":
+		message = "must be greater than " + err.Param()
+	case "gte":
+		message = "must be greater than or equal to " + err.Param()
+	default:
+		message = "failed the " + err.Tag() + " rule"
+	}
+
+	return FieldError{Field: field, Rule: err.Tag(), Param: err.Param(), Message: message}
+}
@@ -0,0 +1,107 @@
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/books/:isbn", func(c *gin.Context) {
+		c.Set("requestID", "req-1")
+		Respond(c, CodeBookNotFound, "Book not found in this library")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/123", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+
+	var problem Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, Problem{
+		Type:      "/problems/book_not_found",
+		Title:     "Book not found",
+		Status:    http.StatusNotFound,
+		Detail:    "Book not found in this library",
+		Instance:  "/books/123",
+		Code:      CodeBookNotFound,
+		RequestID: "req-1",
+	}, problem)
+}
+
+func TestRespondBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/users", func(c *gin.Context) {
+		var input struct {
+			Email string `json:"email" binding:"required,email"`
+			Age   int    `json:"age" binding:"min=18"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			RespondBinding(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+
+	post := func(body string) (*httptest.ResponseRecorder, Problem) {
+		req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		var problem Problem
+		_ = json.Unmarshal(w.Body.Bytes(), &problem)
+		return w, problem
+	}
+
+	t.Run("Field Rules", func(t *testing.T) {
+		w, problem := post(`{"email":"not-an-email","age":12}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, CodeValidationFailed, problem.Code)
+		assert.Equal(t, []FieldError{
+			{Field: "email", Rule: "email", Message: "must be a valid email address"},
+			{Field: "age", Rule: "min", Param: "18", Message: "must be at least 18"},
+		}, problem.Errors)
+	})
+
+	t.Run("Wrong Type", func(t *testing.T) {
+		_, problem := post(`{"email":"reader@example.com","age":"old"}`)
+
+		assert.Equal(t, CodeValidationFailed, problem.Code)
+		assert.Equal(t, "age", problem.Errors[0].Field)
+		assert.Equal(t, "type", problem.Errors[0].Rule)
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, problem := post(`{ invalid`)
+
+		assert.Equal(t, CodeMalformedRequest, problem.Code)
+		assert.Equal(t, "Request body is not valid JSON", problem.Detail)
+	})
+
+	t.Run("Empty Body", func(t *testing.T) {
+		_, problem := post(``)
+
+		assert.Equal(t, CodeMalformedRequest, problem.Code)
+		assert.Equal(t, "Request body is empty", problem.Detail)
+	})
+}
+
+func TestCatalogStatuses(t *testing.T) {
+	for code, status := range Catalog() {
+		assert.GreaterOrEqual(t, status, 400, code)
+		assert.NotEmpty(t, code.Title(), code)
+	}
+	assert.Equal(t, http.StatusInternalServerError, Code("unknown").Status())
+}
@@ -0,0 +1,134 @@
+package authority
+
+import (
+	"library-management/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kind describes one authority table and the table linking books to it
+type Kind struct {
+	Table  string // e.g. "authors"
+	Link   string // e.g. "book_authors"
+	Column string // Column in Link holding the record's id
+}
+
+var (
+	Authors    = Kind{Table: "authors", Link: "book_authors", Column: "author_id"}
+	Publishers = Kind{Table: "publishers", Link: "book_publishers", Column: "publisher_id"}
+	Subjects   = Kind{Table: "subjects", Link: "book_subjects", Column: "subject_id"}
+)
+
+// Matching selects the ids of books linked to a record of kind whose name
+// contains text. Both sides are compared by Key, so punctuation, case and the
+// order of an inverted author name do not matter. Text without letters or
+// digits matches nothing.
+func Matching(db *gorm.DB, kind Kind, text string) *gorm.DB {
+	if kind == Authors {
+		text = DisplayName(text)
+	}
+	query := db.Table(kind.Link).Select(kind.Link + ".book_id").
+		Joins("JOIN " + kind.Table + " ON " + kind.Table + ".id = " + kind.Link + "." + kind.Column)
+	key := Key(text)
+	if key == "" {
+		return query.Where("FALSE")
+	}
+	// Keys hold only letters, digits and spaces, nothing LIKE treats specially
+	return query.Where(kind.Table+".key LIKE ?", "%"+key+"%")
+}
+
+// Link replaces a book's author, publisher and subject links with the ones its
+// Authors, Publisher and Subjects text describe, creating missing records. The
+// book must already have an ID. Names without letters or digits have no key
+// and are left unlinked.
+func Link(db *gorm.DB, book models.Book) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, link := range []interface{}{&models.BookAuthor{}, &models.BookPublisher{}, &models.BookSubject{}} {
+			if err := tx.Where("book_id = ?", book.ID).Delete(link).Error; err != nil {
+				return err
+			}
+		}
+
+		credited := map[models.BookAuthor]bool{}
+		for position, credit := range ParseCredits(book.Authors) {
+			author := models.Author{Name: credit.Name, Key: Key(credit.Name)}
+			if author.Key == "" {
+				continue
+			}
+			if err := resolve(tx, &author, author.Key); err != nil {
+				return err
+			}
+			link := models.BookAuthor{BookID: book.ID, AuthorID: author.ID, Role: credit.Role}
+			if credited[link] {
+				continue
+			}
+			credited[link] = true
+			link.Position = position
+			if err := tx.Create(&link).Error; err != nil {
+				return err
+			}
+		}
+
+		linked := map[uint]bool{}
+		for _, name := range SplitList(book.Publisher) {
+			publisher := models.Publisher{Name: name, Key: Key(name)}
+			if publisher.Key == "" {
+				continue
+			}
+			if err := resolve(tx, &publisher, publisher.Key); err != nil {
+				return err
+			}
+			if linked[publisher.ID] {
+				continue
+			}
+			linked[publisher.ID] = true
+			if err := tx.Create(&models.BookPublisher{BookID: book.ID, PublisherID: publisher.ID}).Error; err != nil {
+				return err
+			}
+		}
+
+		linked = map[uint]bool{}
+		for _, name := range SplitList(book.Subjects) {
+			subject := models.Subject{Name: name, Key: Key(name)}
+			if subject.Key == "" {
+				continue
+			}
+			if err := resolve(tx, &subject, subject.Key); err != nil {
+				return err
+			}
+			if linked[subject.ID] {
+				continue
+			}
+			linked[subject.ID] = true
+			if err := tx.Create(&models.BookSubject{BookID: book.ID, SubjectID: subject.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// resolve inserts record unless one with the same key exists, in which case
+// record is loaded from it. The first spelling seen becomes the display name.
+func resolve(tx *gorm.DB, record interface{}, key string) error {
+	created := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).Create(record)
+	if created.Error != nil || created.RowsAffected == 1 {
+		return created.Error
+	}
+	return tx.Where("key = ?", key).First(record).Error
+}
+
+// Backfill links every existing book, for databases created before books had
+// linked authors, publishers and subjects
+func Backfill(db *gorm.DB) error {
+	var books []models.Book
+	return db.Select("id, authors, publisher, subjects").FindInBatches(&books, 500, func(_ *gorm.DB, _ int) error {
+		for _, book := range books {
+			if err := Link(db, book); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,104 @@
+package authority
+
+import (
+	"regexp"
+	"testing"
+
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLink(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	for _, table := range []string{"book_authors", "book_publishers", "book_subjects"} {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE book_id = $1`)).
+			WithArgs(5).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	// A new author is inserted, a known one is looked up by key
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "authors" ("name","key") VALUES ($1,$2) ON CONFLICT ("key") DO NOTHING RETURNING "id"`)).
+		WithArgs("Leo Tolstoy", "leo tolstoy").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "book_authors" ("book_id","author_id","role","position") VALUES ($1,$2,$3,$4)`)).
+		WithArgs(5, 1, RoleAuthor, 0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "authors"`)).
+		WithArgs("Richard Pevear", "richard pevear").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "authors" WHERE key = $1 ORDER BY "authors"."id" LIMIT $2`)).
+		WithArgs("richard pevear", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key"}).AddRow(2, "Pevear, Richard", "richard pevear"))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "book_authors"`)).
+		WithArgs(5, 2, RoleTranslator, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "publishers"`)).
+		WithArgs("Vintage Classics", "vintage classics").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "book_publishers" ("book_id","publisher_id") VALUES ($1,$2)`)).
+		WithArgs(5, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = Link(db, models.Book{ID: 5, Authors: "Tolstoy, Leo; Pevear, Richard (trans.)", Publisher: "Vintage Classics"})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkSkipsNamesWithoutKey(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	for _, table := range []string{"book_authors", "book_publishers", "book_subjects"} {
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE book_id = $1`)).
+			WithArgs(5).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	// Only the real author is looked up; "?!", "--" and "..." are not
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "authors"`)).
+		WithArgs("Leo Tolstoy", "leo tolstoy").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "book_authors"`)).
+		WithArgs(5, 1, RoleAuthor, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = Link(db, models.Book{ID: 5, Authors: "?!; Tolstoy, Leo", Publisher: "--", Subjects: "..."})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMatching(t *testing.T) {
+	sqlDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{DryRun: true})
+	require.NoError(t, err)
+
+	t.Run("By Key", func(t *testing.T) {
+		var ids []uint
+		stmt := Matching(db, Authors, "Rowling, J.K.").Find(&ids).Statement
+		assert.Equal(t, `SELECT book_authors.book_id FROM "book_authors" JOIN authors ON authors.id = book_authors.author_id WHERE authors.key LIKE $1`, stmt.SQL.String())
+		assert.Equal(t, []interface{}{"%jk rowling%"}, stmt.Vars)
+	})
+
+	t.Run("Punctuation Matches Nothing", func(t *testing.T) {
+		var ids []uint
+		stmt := Matching(db, Subjects, "?!").Find(&ids).Statement
+		assert.Equal(t, `SELECT book_subjects.book_id FROM "book_subjects" JOIN subjects ON subjects.id = book_subjects.subject_id WHERE FALSE`, stmt.SQL.String())
+		assert.Empty(t, stmt.Vars)
+	})
+}
@@ -0,0 +1,170 @@
+// Package authority turns the free-text Authors, Publisher and Subjects of a book
+// into shared author, publisher and subject records, so every spelling of a name
+// such as "J.K. Rowling" and "Rowling, J. K." resolves to the same one.
+package authority
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Roles a person can be credited in, RoleAuthor unless the text says otherwise
+const (
+	RoleAuthor      = "author"
+	RoleEditor      = "editor"
+	RoleTranslator  = "translator"
+	RoleIllustrator = "illustrator"
+)
+
+// Roles lists every credit role
+var Roles = []string{RoleAuthor, RoleEditor, RoleTranslator, RoleIllustrator}
+
+// qualifiers maps the parenthesised suffixes found in catalog data to roles
+var qualifiers = map[string]string{
+	"author":      RoleAuthor,
+	"ed":          RoleEditor,
+	"eds":         RoleEditor,
+	"editor":      RoleEditor,
+	"editors":     RoleEditor,
+	"tr":          RoleTranslator,
+	"trans":       RoleTranslator,
+	"translator":  RoleTranslator,
+	"ill":         RoleIllustrator,
+	"illus":       RoleIllustrator,
+	"illustrator": RoleIllustrator,
+}
+
+// Credit is one name from an Authors string with its role
+type Credit struct {
+	Name string
+	Role string
+}
+
+// ParseCredits splits an Authors string such as
+// "Herbert, Frank; Brian Herbert & Kevin J. Anderson (eds.)" into credits.
+// Names are separated by ";", "&", " and " or commas, a single comma between a
+// surname and given names is read as an inverted name, and a trailing
+// "(ed.)", "(trans.)" or "(ill.)" sets the role.
+func ParseCredits(authors string) []Credit {
+	var credits []Credit
+	for _, segment := range splitAny(authors, ";", "&", " and ", " AND ", " And ") {
+		role := RoleAuthor
+		if open := strings.LastIndex(segment, "("); open >= 0 && strings.HasSuffix(segment, ")") {
+			qualifier := strings.Trim(strings.ToLower(segment[open+1:len(segment)-1]), " .")
+			if r, ok := qualifiers[qualifier]; ok {
+				role = r
+				segment = strings.TrimSpace(segment[:open])
+			}
+		}
+		for _, name := range splitNames(segment) {
+			credits = append(credits, Credit{Name: name, Role: role})
+		}
+	}
+	return credits
+}
+
+// splitNames reads one segment that may hold a single inverted name or a comma
+// separated list of names
+func splitNames(segment string) []string {
+	parts := splitAny(segment, ",")
+	switch {
+	case len(parts) == 0:
+		return nil
+	case len(parts) == 1:
+		return []string{collapse(parts[0])}
+	case len(parts) == 2 && (len(strings.Fields(parts[0])) == 1 || hasInitial(parts[1])):
+		// "Rowling, J. K." or "Le Guin, Ursula K."
+		return []string{DisplayName(segment)}
+	}
+
+	// "Gaiman, Neil, Pratchett, Terry" pairs up surnames and given names
+	paired := len(parts)%2 == 0
+	for _, part := range parts {
+		if len(strings.Fields(part)) > 1 && !hasInitial(part) {
+			paired = false
+		}
+	}
+	var names []string
+	if paired {
+		for i := 0; i < len(parts); i += 2 {
+			names = append(names, DisplayName(parts[i]+", "+parts[i+1]))
+		}
+		return names
+	}
+	for _, part := range parts {
+		names = append(names, collapse(part))
+	}
+	return names
+}
+
+// DisplayName turns an inverted "Surname, Given" name into "Given Surname"; other
+// names only have their spacing tidied
+func DisplayName(name string) string {
+	name = collapse(name)
+	surname, given, found := strings.Cut(name, ",")
+	if !found || strings.Contains(given, ",") {
+		return name
+	}
+	return collapse(given + " " + surname)
+}
+
+// SplitList splits a Publisher or Subjects string on ";", the only separator
+// that never appears inside a name like "Farrar, Straus and Giroux"
+func SplitList(text string) []string {
+	var items []string
+	for _, item := range splitAny(text, ";") {
+		items = append(items, collapse(item))
+	}
+	return items
+}
+
+// Key folds a name for matching: case, punctuation and spacing are ignored and
+// runs of initials are joined, so "J.K. Rowling" and "J. K. Rowling" both give
+// "jk rowling". Inverted names should go through DisplayName first.
+func Key(name string) string {
+	tokens := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var words []string
+	for i, token := range tokens {
+		if i > 0 && len([]rune(token)) == 1 && len([]rune(tokens[i-1])) == 1 {
+			words[len(words)-1] += token
+			continue
+		}
+		words = append(words, token)
+	}
+	return strings.Join(words, " ")
+}
+
+// hasInitial reports whether text contains a one-letter word such as "K."
+func hasInitial(text string) bool {
+	for _, word := range strings.Fields(text) {
+		if len([]rune(strings.Trim(word, "."))) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// splitAny splits text on every separator and drops empty parts
+func splitAny(text string, separators ...string) []string {
+	parts := []string{text}
+	for _, separator := range separators {
+		var next []string
+		for _, part := range parts {
+			next = append(next, strings.Split(part, separator)...)
+		}
+		parts = next
+	}
+	var kept []string
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return kept
+}
+
+func collapse(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,47 @@
+package authority
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCredits(t *testing.T) {
+	tests := []struct {
+		name    string
+		authors string
+		want    []Credit
+	}{
+		{"Empty", "  ", nil},
+		{"Single Name", "Frank Herbert", []Credit{{"Frank Herbert", RoleAuthor}}},
+		{"Inverted Name", "Rowling, J. K.", []Credit{{"J. K. Rowling", RoleAuthor}}},
+		{"Inverted Compound Surname", "Le Guin, Ursula K.", []Credit{{"Ursula K. Le Guin", RoleAuthor}}},
+		{"Comma List", "Frank Herbert, Brian Herbert", []Credit{{"Frank Herbert", RoleAuthor}, {"Brian Herbert", RoleAuthor}}},
+		{"Inverted Pairs", "Gaiman, Neil, Pratchett, Terry", []Credit{{"Neil Gaiman", RoleAuthor}, {"Terry Pratchett", RoleAuthor}}},
+		{"Separators", "Herbert, Frank; Brian Herbert & Kevin J. Anderson and Bill Ransom", []Credit{
+			{"Frank Herbert", RoleAuthor}, {"Brian Herbert", RoleAuthor}, {"Kevin J. Anderson", RoleAuthor}, {"Bill Ransom", RoleAuthor},
+		}},
+		{"Roles", "Tolstoy, Leo; Pevear, Richard (trans.); Quentin Blake (Illustrator)", []Credit{
+			{"Leo Tolstoy", RoleAuthor}, {"Richard Pevear", RoleTranslator}, {"Quentin Blake", RoleIllustrator},
+		}},
+		{"Unknown Qualifier Is Kept", "Prince (musician)", []Credit{{"Prince (musician)", RoleAuthor}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ParseCredits(tt.authors))
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	for _, name := range []string{"J.K. Rowling", "J. K. Rowling", "j k  rowling", DisplayName("Rowling, J.K.")} {
+		assert.Equal(t, "jk rowling", Key(name), name)
+	}
+	assert.Equal(t, "farrar straus and giroux", Key("Farrar, Straus and Giroux"))
+	assert.Equal(t, "gabriel garcía márquez", Key("Gabriel García Márquez"))
+}
+
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"Farrar, Straus and Giroux", "Picador"}, SplitList("Farrar, Straus and Giroux;  Picador ;"))
+	assert.Nil(t, SplitList(""))
+}
@@ -0,0 +1,157 @@
+// Package callnumber validates Dewey Decimal and Library of Congress call
+// numbers and derives keys that sort them in shelf order. Keys compare as plain
+// strings, so the database can order by them.
+package callnumber
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Classification schemes
+const (
+	Dewey = "ddc"
+	LCC   = "lcc"
+)
+
+// ErrInvalid is wrapped by every validation error
+var ErrInvalid = errors.New("invalid call number")
+
+// Number is a validated call number
+type Number struct {
+	Scheme string
+	Text   string // As given, whitespace collapsed
+	Key    string // Sorts in shelf order against keys of the same scheme
+}
+
+var (
+	deweyPattern = regexp.MustCompile(`^(\d{3})(?:\.(\d+))?(?:\s+(.+))?$`)
+	lccPattern   = regexp.MustCompile(`^([A-Z]{1,3})\s*(\d{1,4})(?:\.(\d+))?(.*)$`)
+	cutterToken  = regexp.MustCompile(`^[A-Z]\d+[A-Z]*$`)
+)
+
+// lccUnused are the letters no LC class starts with
+const lccUnused = "IOWXY"
+
+// Detect guesses the scheme from the first character: Dewey numbers start with
+// a digit, LC class letters with a letter. It returns "" for anything else.
+func Detect(text string) string {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "":
+		return ""
+	case text[0] >= '0' && text[0] <= '9':
+		return Dewey
+	case (text[0] >= 'A' && text[0] <= 'Z') || (text[0] >= 'a' && text[0] <= 'z'):
+		return LCC
+	}
+	return ""
+}
+
+// Parse validates text as a call number of scheme, Detect's guess when scheme
+// is empty
+func Parse(scheme, text string) (Number, error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return Number{}, fmt.Errorf("%w: call number is empty", ErrInvalid)
+	}
+	if scheme == "" {
+		scheme = Detect(text)
+	}
+	switch scheme {
+	case Dewey:
+		return parseDewey(text)
+	case LCC:
+		return parseLCC(text)
+	}
+	return Number{}, fmt.Errorf("%w: scheme must be %s or %s", ErrInvalid, Dewey, LCC)
+}
+
+// parseDewey reads a three digit class, optional decimals and a cutter or
+// other suffix, as in "823.914 TOL 2004". The key is the number itself, so
+// 823 files before 823.1 and 823.1 before 823.12.
+func parseDewey(text string) (Number, error) {
+	match := deweyPattern.FindStringSubmatch(text)
+	if match == nil {
+		return Number{}, fmt.Errorf("%w: a Dewey number starts with three digits, as in 823.914 TOL", ErrInvalid)
+	}
+	key := match[1]
+	if match[2] != "" {
+		key += "." + match[2]
+	}
+	if match[3] != "" {
+		key += " " + strings.ToUpper(match[3])
+	}
+	return Number{Scheme: Dewey, Text: text, Key: key}, nil
+}
+
+// parseLCC reads class letters, a class number with optional decimals, then
+// cutters and anything else such as a year or volume, as in
+// "QA76.73.G63 D66 2016". Class letters are padded and the class number
+// zero-filled, so QA76 files before QA100 and Q before QA; cutters compare as
+// decimals, so .G63 files before .G7.
+func parseLCC(text string) (Number, error) {
+	upper := strings.ToUpper(text)
+	match := lccPattern.FindStringSubmatch(upper)
+	if match == nil {
+		return Number{}, fmt.Errorf("%w: an LC number starts with class letters and a number, as in QA76.73", ErrInvalid)
+	}
+	if strings.ContainsRune(lccUnused, rune(match[1][0])) {
+		return Number{}, fmt.Errorf("%w: no LC class starts with %c", ErrInvalid, match[1][0])
+	}
+
+	key := fmt.Sprintf("%-3s", match[1]) + strings.Repeat("0", 4-len(match[2])) + match[2]
+	if match[3] != "" {
+		key += "." + match[3]
+	}
+	for i, token := range lccTokens(match[4]) {
+		if i == 0 && !cutterToken.MatchString(token) && !isYear(token) {
+			return Number{}, fmt.Errorf("%w: %q is not a cutter such as .G63", ErrInvalid, token)
+		}
+		key += " " + token
+	}
+	return Number{Scheme: LCC, Text: text, Key: key}, nil
+}
+
+// lccTokens splits what follows the class number into cutters and other
+// parts. A cutter's leading dot is dropped and cutters run together, as in
+// ".G63D66", are separated.
+func lccTokens(rest string) []string {
+	var tokens []string
+	for _, field := range strings.Fields(strings.ReplaceAll(rest, ".", " .")) {
+		field = strings.TrimPrefix(field, ".")
+		if field == "" {
+			continue
+		}
+		tokens = append(tokens, splitCutters(field)...)
+	}
+	return tokens
+}
+
+// splitCutters breaks "G63D66" into "G63" and "D66"; other tokens are kept whole
+func splitCutters(token string) []string {
+	var parts []string
+	start := 0
+	for i := 1; i < len(token); i++ {
+		if isLetter(token[i]) && isDigit(token[i-1]) && i+1 < len(token) && isDigit(token[i+1]) {
+			parts = append(parts, token[start:i])
+			start = i
+		}
+	}
+	parts = append(parts, token[start:])
+	for _, part := range parts {
+		if !cutterToken.MatchString(part) {
+			return []string{token}
+		}
+	}
+	return parts
+}
+
+func isYear(token string) bool {
+	return len(token) >= 4 && isDigit(token[0]) && isDigit(token[1]) && isDigit(token[2]) && isDigit(token[3])
+}
+
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+func isLetter(c byte) bool { return c >= 'A' && c <= 'Z' }
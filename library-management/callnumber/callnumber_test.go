@@ -0,0 +1,69 @@
+package callnumber
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		scheme, text string
+		expected     Number
+	}{
+		{"", "823.914  TOL", Number{Dewey, "823.914 TOL", "823.914 TOL"}},
+		{Dewey, "005.133 P999p 2016", Number{Dewey, "005.133 P999p 2016", "005.133 P999P 2016"}},
+		{"", "qa76.73.g63 d66 2016", Number{LCC, "qa76.73.g63 d66 2016", "QA 0076.73 G63 D66 2016"}},
+		{LCC, "PS3545.I345 Z46", Number{LCC, "PS3545.I345 Z46", "PS 3545 I345 Z46"}},
+		{LCC, "KF 801 .A72", Number{LCC, "KF 801 .A72", "KF 0801 A72"}},
+		{LCC, "Q335 1998", Number{LCC, "Q335 1998", "Q  0335 1998"}},
+	}
+	for _, tt := range tests {
+		number, err := Parse(tt.scheme, tt.text)
+		require.NoError(t, err, tt.text)
+		assert.Equal(t, tt.expected, number, tt.text)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, tt := range []struct{ scheme, text string }{
+		{"", ""},
+		{"", "#42"},
+		{Dewey, "82.3"},
+		{Dewey, "823."},
+		{Dewey, "QA76"},
+		{LCC, "823.9"},
+		{LCC, "QA"},
+		{LCC, "IA76"},
+		{LCC, "QABC76.2"},
+		{LCC, "QA76 ???"},
+		{"udc", "821.111"},
+	} {
+		_, err := Parse(tt.scheme, tt.text)
+		assert.True(t, errors.Is(err, ErrInvalid), "%s %q", tt.scheme, tt.text)
+	}
+}
+
+func TestShelfOrder(t *testing.T) {
+	sortKeys := func(scheme string, shelved []string) []string {
+		keys := make(map[string]string, len(shelved))
+		for _, text := range shelved {
+			number, err := Parse(scheme, text)
+			require.NoError(t, err, text)
+			keys[text] = number.Key
+		}
+		shuffled := append([]string(nil), shelved...)
+		sort.Sort(sort.Reverse(sort.StringSlice(shuffled)))
+		sort.Slice(shuffled, func(i, j int) bool { return keys[shuffled[i]] < keys[shuffled[j]] })
+		return shuffled
+	}
+
+	dewey := []string{"005.1 ABC", "005.133 A", "005.2", "020 B", "823 TOL", "823.1", "823.12", "823.9 A", "823.91", "823.914 TOL"}
+	assert.Equal(t, dewey, sortKeys(Dewey, dewey))
+
+	lcc := []string{"Q335", "QA9 .C1", "QA76 .G63", "QA76.7", "QA76.73.G63", "QA76.73.G7", "QA100", "QA100.5 .B2 1999", "QA100.5 .B2 2005", "QB1"}
+	assert.Equal(t, lcc, sortKeys(LCC, lcc))
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// Attachment is a book's cover or supplementary file. The server sniffs the
+// content type and makes thumbnails of images.
+type Attachment struct {
+	ID           uint   `json:"id"`
+	BookID       uint   `json:"book_id"`
+	Kind         string `json:"kind"` // cover or file
+	FileName     string `json:"file_name"`
+	ContentType  string `json:"content_type"`
+	Size         int64  `json:"size"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+}
+
+// SetCover uploads a JPEG, PNG or GIF as the cover of a library's copy of a
+// book, replacing any cover it had
+func (c *Client) SetCover(ctx context.Context, libraryID uint, isbn, fileName string, image []byte) (*Attachment, error) {
+	return c.upload(ctx, http.MethodPut, attachmentPath(isbn, "/cover"), libraryID, fileName, image)
+}
+
+// DeleteCover removes a book's cover
+func (c *Client) DeleteCover(ctx context.Context, libraryID uint, isbn string) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: attachmentPath(isbn, "/cover"), query: libraryQuery(libraryID), auth: true}, nil)
+}
+
+// ListAttachments returns a book's cover and files
+func (c *Client) ListAttachments(ctx context.Context, libraryID uint, isbn string) ([]Attachment, error) {
+	var result struct {
+		Attachments []Attachment `json:"attachments"`
+	}
+	err := c.do(ctx, call{method: http.MethodGet, path: attachmentPath(isbn, "/attachments"), query: libraryQuery(libraryID), auth: true}, &result)
+	return result.Attachments, err
+}
+
+// AddAttachment uploads a supplementary file for a book
+func (c *Client) AddAttachment(ctx context.Context, libraryID uint, isbn, fileName string, content []byte) (*Attachment, error) {
+	return c.upload(ctx, http.MethodPost, attachmentPath(isbn, "/attachments"), libraryID, fileName, content)
+}
+
+// ReplaceAttachment uploads a new version of a file. Its URL changes.
+func (c *Client) ReplaceAttachment(ctx context.Context, libraryID uint, isbn string, id uint, fileName string, content []byte) (*Attachment, error) {
+	return c.upload(ctx, http.MethodPut, attachmentPath(isbn, "/attachments/"+formatID(id)), libraryID, fileName, content)
+}
+
+// DeleteAttachment removes a supplementary file
+func (c *Client) DeleteAttachment(ctx context.Context, libraryID uint, isbn string, id uint) error {
+	path := attachmentPath(isbn, "/attachments/"+formatID(id))
+	return c.do(ctx, call{method: http.MethodDelete, path: path, query: libraryQuery(libraryID), auth: true}, nil)
+}
+
+// upload sends content as the multipart "file" field
+func (c *Client) upload(ctx context.Context, method, path string, libraryID uint, fileName string, content []byte) (*Attachment, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", fileName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Attachment Attachment `json:"attachment"`
+	}
+	err = c.do(ctx, call{
+		method:      method,
+		path:        path,
+		query:       libraryQuery(libraryID),
+		body:        body.Bytes(),
+		contentType: form.FormDataContentType(),
+		auth:        true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Attachment, nil
+}
+
+func attachmentPath(isbn, suffix string) string {
+	return "/api/book/" + url.PathEscape(isbn) + suffix
+}
+
+func libraryQuery(libraryID uint) url.Values {
+	return url.Values{"library_id": {formatID(libraryID)}}
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// LoginResult is what the server returns for a successful login
+type LoginResult struct {
+	Role  string `json:"role"`
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
+
+// Login exchanges credentials for a JWT, which the client then uses for every
+// authenticated call
+func (c *Client) Login(ctx context.Context, email, password string) (*LoginResult, error) {
+	var result LoginResult
+	err := c.do(ctx, call{
+		method: http.MethodPost,
+		path:   "/auth/login",
+		body:   map[string]string{"email": email, "password": password},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.setToken(result.Token)
+	c.mu.Unlock()
+	return &result, nil
+}
+
+// Me returns the logged-in user's profile
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	var result struct {
+		User User `json:"user"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/me", auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+// ChangePassword replaces the logged-in user's password. Credentials given with
+// WithCredentials are updated so the client can keep logging in.
+func (c *Client) ChangePassword(ctx context.Context, current, next string) error {
+	err := c.do(ctx, call{
+		method: http.MethodPut,
+		path:   "/api/me/password",
+		body:   map[string]string{"current_password": current, "new_password": next},
+		auth:   true,
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.email != "" && c.password == current {
+		c.password = next
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// MyLoans lists the books the logged-in user currently holds
+func (c *Client) MyLoans(ctx context.Context) ([]Loan, error) {
+	return c.loans(ctx, "/api/me/loans")
+}
+
+// MyLoanHistory lists the books the logged-in user has returned
+func (c *Client) MyLoanHistory(ctx context.Context) ([]Loan, error) {
+	return c.loans(ctx, "/api/me/loans/history")
+}
+
+func (c *Client) loans(ctx context.Context, path string) ([]Loan, error) {
+	var result struct {
+		Loans []Loan `json:"loans"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: path, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return result.Loans, nil
+}
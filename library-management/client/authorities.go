@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// AuthorityKind picks which records Authorities and AuthorityBooks work on
+type AuthorityKind string
+
+const (
+	Authors    AuthorityKind = "authors"
+	Publishers AuthorityKind = "publishers"
+	Subjects   AuthorityKind = "subjects"
+)
+
+// AuthorityFilter narrows Authorities; Name matches any part of the name. Sort
+// is name or id, prefixed with "-" for descending.
+type AuthorityFilter struct {
+	Name  string
+	Sort  string
+	Limit int
+}
+
+// AuthorityPage is one page of Authorities
+type AuthorityPage struct {
+	Page
+	Records []Authority
+}
+
+// Authorities fetches one page of authors, publishers or subjects. Pass "" for
+// the first page and the previous page's NextCursor after that.
+func (c *Client) Authorities(ctx context.Context, kind AuthorityKind, filter AuthorityFilter, cursor string) (*AuthorityPage, error) {
+	query := url.Values{}
+	setQuery(query, "name", filter.Name)
+	setCursor(query, cursor, filter.Sort, filter.Limit)
+
+	var result struct {
+		Page
+		Authors    []Authority `json:"authors"`
+		Publishers []Authority `json:"publishers"`
+		Subjects   []Authority `json:"subjects"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/" + string(kind), query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	page := &AuthorityPage{Page: result.Page}
+	switch kind {
+	case Authors:
+		page.Records = result.Authors
+	case Publishers:
+		page.Records = result.Publishers
+	case Subjects:
+		page.Records = result.Subjects
+	}
+	return page, nil
+}
+
+// AuthorityBookOptions narrows AuthorityBooks; empty fields are ignored. Role
+// only applies to authors and is author, editor, translator or illustrator.
+// Sort is title, isbn or available_copies, prefixed with "-" for descending.
+type AuthorityBookOptions struct {
+	Role      string
+	LibraryID uint
+	Sort      string
+	Limit     int
+}
+
+// AuthorityBookPage is one page of AuthorityBooks
+type AuthorityBookPage struct {
+	Page
+	Books []AuthorityBook `json:"books"`
+}
+
+// AuthorityBooks fetches one page of the books linked to an author, publisher
+// or subject, from the libraries the caller can access
+func (c *Client) AuthorityBooks(ctx context.Context, kind AuthorityKind, id uint, opts AuthorityBookOptions, cursor string) (*AuthorityBookPage, error) {
+	query := url.Values{}
+	setQuery(query, "role", opts.Role)
+	if opts.LibraryID != 0 {
+		query.Set("library_id", strconv.FormatUint(uint64(opts.LibraryID), 10))
+	}
+	setCursor(query, cursor, opts.Sort, opts.Limit)
+
+	var result AuthorityBookPage
+	path := "/api/" + string(kind) + "/" + formatID(id) + "/books"
+	if err := c.do(ctx, call{method: http.MethodGet, path: path, query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
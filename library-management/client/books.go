@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// AddBook adds a book to a library, or adds copies when the library already
+// holds the ISBN
+func (c *Client) AddBook(ctx context.Context, book Book) (*Book, error) {
+	return c.bookCall(ctx, call{method: http.MethodPost, path: "/api/book", body: book})
+}
+
+// GetBook returns one library's copy of a book with its ETag
+func (c *Client) GetBook(ctx context.Context, isbn string, libraryID uint) (*Book, error) {
+	query := url.Values{"library_id": {formatID(libraryID)}}
+	return c.bookCall(ctx, call{method: http.MethodGet, path: "/api/book/" + url.PathEscape(isbn), query: query})
+}
+
+// BookDetail returns a book's copy counts, hold queue and next due date in every
+// library the caller can access, plus copies and loans for owners and admins
+func (c *Client) BookDetail(ctx context.Context, isbn string) (*BookDetail, error) {
+	var result BookDetail
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/books/" + url.PathEscape(isbn), auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateBook changes a book's details and copy counts; book.LibraryID picks the
+// copy. When book.ETag is set the update only applies to that revision.
+func (c *Client) UpdateBook(ctx context.Context, isbn string, book Book) (*Book, error) {
+	return c.bookCall(ctx, call{method: http.MethodPut, path: "/api/book/" + url.PathEscape(isbn), body: book, ifMatch: book.ETag})
+}
+
+func (c *Client) bookCall(ctx context.Context, req call) (*Book, error) {
+	var result struct {
+		Book Book `json:"book"`
+	}
+	var etag string
+	req.auth = true
+	req.etag = &etag
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	result.Book.ETag = etag
+	return &result.Book, nil
+}
+
+// RemoveBook removes one available copy, or the book when it is the last one.
+// A non-empty etag makes the removal conditional on that revision.
+func (c *Client) RemoveBook(ctx context.Context, isbn string, libraryID uint, etag string) error {
+	return c.do(ctx, call{
+		method:  http.MethodDelete,
+		path:    "/api/book/" + url.PathEscape(isbn),
+		body:    map[string]uint{"libraryid": libraryID},
+		auth:    true,
+		ifMatch: etag,
+	}, nil)
+}
+
+// SearchOptions narrows SearchBooks; empty fields are ignored. A work matches
+// when one of its editions matches every field. Sort is title or id, prefixed
+// with "-" for descending.
+type SearchOptions struct {
+	Title     string
+	Author    string
+	Publisher string
+	Subject   string
+	LibraryID uint
+	Sort      string
+	Limit     int
+}
+
+// SearchPage is one page of SearchBooks
+type SearchPage struct {
+	Page
+	Works []WorkResult `json:"works"`
+}
+
+// SearchBooks fetches one page of works from the catalogues of the reader's
+// approved libraries, each with its editions nested. Pass "" for the first page
+// and the previous page's NextCursor after that.
+func (c *Client) SearchBooks(ctx context.Context, opts SearchOptions, cursor string) (*SearchPage, error) {
+	query := url.Values{}
+	setQuery(query, "title", opts.Title)
+	setQuery(query, "author", opts.Author)
+	setQuery(query, "publisher", opts.Publisher)
+	setQuery(query, "subject", opts.Subject)
+	if opts.LibraryID != 0 {
+		query.Set("library_id", strconv.FormatUint(uint64(opts.LibraryID), 10))
+	}
+	setCursor(query, cursor, opts.Sort, opts.Limit)
+
+	var result SearchPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/books/search", query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// SearchResults iterates over every matching work, fetching pages as needed.
+// Iteration stops after yielding the first error.
+func (c *Client) SearchResults(ctx context.Context, opts SearchOptions) iter.Seq2[WorkResult, error] {
+	return follow(func(cursor string) ([]WorkResult, Page, error) {
+		result, err := c.SearchBooks(ctx, opts, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Works, result.Page, nil
+	})
+}
+
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// PublicLibraryPage is one page of PublicLibraries
+type PublicLibraryPage struct {
+	Page
+	Libraries []PublicLibrary `json:"libraries"`
+}
+
+// PublicLibraries fetches one page of the libraries whose catalog is public. It
+// needs no login. Pass "" for the first page and the previous page's NextCursor
+// after that.
+func (c *Client) PublicLibraries(ctx context.Context, filter LibraryFilter, cursor string) (*PublicLibraryPage, error) {
+	query := url.Values{}
+	setQuery(query, "name", filter.Name)
+	setQuery(query, "city", filter.City)
+	setCursor(query, cursor, filter.Sort, filter.Limit)
+
+	var result PublicLibraryPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/public/libraries", query: query}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PublicSearchPage is one page of SearchPublicCatalog
+type PublicSearchPage struct {
+	Page
+	Books []PublicBook `json:"books"`
+}
+
+// SearchPublicCatalog searches a library's public catalog without logging in.
+// opts.LibraryID is ignored in favour of libraryID.
+func (c *Client) SearchPublicCatalog(ctx context.Context, libraryID uint, opts SearchOptions, cursor string) (*PublicSearchPage, error) {
+	query := url.Values{}
+	setQuery(query, "title", opts.Title)
+	setQuery(query, "author", opts.Author)
+	setQuery(query, "publisher", opts.Publisher)
+	setCursor(query, cursor, opts.Sort, opts.Limit)
+
+	var result PublicSearchPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/public/libraries/" + formatID(libraryID) + "/books", query: query}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PublicBook returns a book's availability in a library's public catalog
+func (c *Client) PublicBook(ctx context.Context, libraryID uint, isbn string) (*PublicBook, error) {
+	var result struct {
+		Book PublicBook `json:"book"`
+	}
+	path := "/public/libraries/" + formatID(libraryID) + "/books/" + url.PathEscape(isbn)
+	if err := c.do(ctx, call{method: http.MethodGet, path: path}, &result); err != nil {
+		return nil, err
+	}
+	return &result.Book, nil
+}
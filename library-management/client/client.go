@@ -0,0 +1,366 @@
+// Package client is a typed Go client for the library management API. It logs in
+// on demand, refreshes the token before it expires, retries transient failures
+// with backoff and walks paginated listings with iterators. Authenticated POST
+// and PUT calls carry an Idempotency-Key, so retrying them is safe.
+package client
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Client talks to one API server. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	mu       sync.Mutex
+	token    string
+	expires  time.Time
+	email    string
+	password string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces http.DefaultClient
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken starts with an existing JWT
+func WithToken(token string) Option {
+	return func(c *Client) { c.setToken(token) }
+}
+
+// WithCredentials lets the client log in by itself, and log in again whenever
+// the token is about to expire or is rejected
+func WithCredentials(email, password string) Option {
+	return func(c *Client) { c.email, c.password = email, password }
+}
+
+// WithRetry sets how many times a request is retried after a transient failure
+// and the bounds of the exponential backoff between attempts
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(c *Client) { c.maxRetries, c.minBackoff, c.maxBackoff = maxRetries, minBackoff, maxBackoff }
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	parsed, err := url.Parse(strings.TrimRight(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q needs a scheme and host", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    parsed,
+		httpClient: http.DefaultClient,
+		userAgent:  "library-management-client/1",
+		maxRetries: 3,
+		minBackoff: 200 * time.Millisecond,
+		maxBackoff: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Token returns the current JWT, empty before the first login
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// Refresh within this window of expiry rather than racing the server's clock
+const refreshBefore = time.Minute
+
+func (c *Client) setToken(token string) {
+	c.token = token
+	c.expires = tokenExpiry(token)
+}
+
+// tokenExpiry reads the exp claim without verifying the signature, which only
+// the server can do. A zero time means unknown.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// authorization returns a usable token, logging in first when there is none or
+// it is about to expire and credentials are available
+func (c *Client) authorization(ctx context.Context, forceLogin bool) (string, error) {
+	c.mu.Lock()
+	token, expires, email, password := c.token, c.expires, c.email, c.password
+	c.mu.Unlock()
+
+	stale := token == "" || (!expires.IsZero() && time.Until(expires) < refreshBefore)
+	if email == "" || (!forceLogin && !stale) {
+		return token, nil
+	}
+
+	result, err := c.Login(ctx, email, password)
+	if err != nil {
+		return "", err
+	}
+	return result.Token, nil
+}
+
+// call describes one API request
+type call struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	auth   bool
+
+	// contentType, when set, sends body, a []byte, as is instead of as JSON;
+	// accept replaces the JSON Accept header and out may then be a *[]byte
+	contentType string
+	accept      string
+
+	// idempotencyKey is sent with every attempt so the server applies the
+	// request at most once
+	idempotencyKey string
+
+	// ifMatch is sent as If-Match when set; etag, when not nil, receives the
+	// response's ETag
+	ifMatch string
+	etag    *string
+}
+
+// do sends the request, decoding a 2xx body into out when it is not nil
+func (c *Client) do(ctx context.Context, req call, out interface{}) error {
+	var payload []byte
+	if raw, ok := req.body.([]byte); ok && req.contentType != "" {
+		payload = raw
+	} else if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("client: encoding request: %w", err)
+		}
+	}
+	if req.auth && (req.method == http.MethodPost || req.method == http.MethodPut) {
+		req.idempotencyKey = newIdempotencyKey()
+	}
+
+	refreshed := false
+	for {
+		token := ""
+		if req.auth {
+			var err error
+			if token, err = c.authorization(ctx, false); err != nil {
+				return err
+			}
+		}
+
+		err := c.send(ctx, req, payload, token, out)
+
+		// A token the server no longer accepts is replaced once
+		var apiErr *Error
+		if req.auth && !refreshed && errors.As(err, &apiErr) && apiErr.Code == "invalid_token" && c.hasCredentials() {
+			refreshed = true
+			if _, loginErr := c.authorization(ctx, true); loginErr != nil {
+				return loginErr
+			}
+			continue
+		}
+		return err
+	}
+}
+
+func (c *Client) hasCredentials() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.email != ""
+}
+
+// send performs one logical request, retrying transient failures
+func (c *Client) send(ctx context.Context, req call, payload []byte, token string, out interface{}) error {
+	target := *c.baseURL
+	target.Path += req.path
+	if len(req.query) > 0 {
+		target.RawQuery = req.query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		httpReq, err := http.NewRequestWithContext(ctx, req.method, target.String(), bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("client: building request: %w", err)
+		}
+		httpReq.Header.Set("Accept", "application/json")
+		if req.accept != "" {
+			httpReq.Header.Set("Accept", req.accept)
+		}
+		httpReq.Header.Set("User-Agent", c.userAgent)
+		if req.contentType != "" {
+			httpReq.Header.Set("Content-Type", req.contentType)
+		} else if payload != nil {
+			httpReq.Header.Set("Content-Type", "application/json")
+		}
+		if token != "" {
+			httpReq.Header.Set("Authorization", "Bearer "+token)
+		}
+		if req.idempotencyKey != "" {
+			httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+		}
+		if req.ifMatch != "" {
+			httpReq.Header.Set("If-Match", req.ifMatch)
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
+		if err == nil && resp.StatusCode < 300 {
+			defer resp.Body.Close()
+			if req.etag != nil {
+				*req.etag = resp.Header.Get("ETag")
+			}
+			if out == nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				return nil
+			}
+			if raw, ok := out.(*[]byte); ok {
+				if *raw, err = io.ReadAll(resp.Body); err != nil {
+					return fmt.Errorf("client: reading %s %s response: %w", req.method, req.path, err)
+				}
+				return nil
+			}
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return fmt.Errorf("client: decoding %s %s response: %w", req.method, req.path, err)
+			}
+			return nil
+		}
+
+		var retryAfter time.Duration
+		if err == nil {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			err = decodeError(resp)
+			resp.Body.Close()
+		}
+
+		if attempt >= c.maxRetries || !retryable(req, err) {
+			return err
+		}
+
+		wait := c.backoff(attempt)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether another attempt could succeed. Requests that may
+// have been applied are only retried when the method is idempotent or the
+// request carries an Idempotency-Key.
+func retryable(req call, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	safe := req.idempotencyKey != "" || idempotent(req.method)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		// Transport failure, the server may or may not have seen the request
+		return safe
+	}
+	switch apiErr.Status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return safe
+	case http.StatusConflict:
+		// An earlier attempt with the same key is still running
+		return req.idempotencyKey != "" && apiErr.Code == "idempotency_in_progress"
+	}
+	return false
+}
+
+// newIdempotencyKey returns a random key for one logical request
+func newIdempotencyKey() string {
+	var raw [16]byte
+	if _, err := cryptorand.Read(raw[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(raw[:])
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// backoff doubles from minBackoff up to maxBackoff with full jitter
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.minBackoff << attempt
+	if wait <= 0 || wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(wait)) + 1)
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
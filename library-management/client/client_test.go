@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"library-management/enrich"
+	"library-management/routes"
+	"library-management/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// newServer runs the real router over a mocked database
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) (*httptest.Server, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router, err := routes.SetupRouter(gormDB, storage.NewLocal(t.TempDir(), "/files/"), enrich.Chain{})
+	require.NoError(t, err)
+	var handler http.Handler = router
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server, mock
+}
+
+func expectOwnerLogin(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery(`SELECT \* FROM "users" WHERE \(email = \$1`).
+		WithArgs("owner@example.com", 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "status", "password"}).
+			AddRow(1, "Owner", "owner@example.com", "owner", "active", "password123"))
+}
+
+func expectArchive(mock sqlmock.Sqlmock) {
+	// The token's account is checked, then the client's Idempotency-Key is
+	// claimed first and the response stored last
+	mock.ExpectQuery(`SELECT "id","role","status" FROM "users"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "role", "status"}).AddRow(1, "owner", "active"))
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "idempotency_keys"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "idempotency_keys"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT \* FROM "libraries" WHERE "libraries"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "archived"}).AddRow(4, "Central", false))
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "libraries" SET "archived"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE "idempotency_keys"`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestLoginAndPaginate(t *testing.T) {
+	server, mock := newServer(t, nil)
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	expectOwnerLogin(mock)
+	result, err := c.Login(context.Background(), "owner@example.com", "password123")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", result.Role)
+	assert.Equal(t, result.Token, c.Token())
+
+	// The first page fetches a look-ahead row, the second continues after its cursor
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "libraries"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "libraries" WHERE archived = \$1 ORDER BY name ASC,id ASC LIMIT \$2`).
+		WithArgs(false, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central").AddRow(2, "Riverside"))
+	mock.ExpectQuery(`SELECT count\(\*\) FROM "libraries"`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT \* FROM "libraries" WHERE archived = \$1 AND \(\(name > \$2\) OR \(name = \$3 AND id > \$4\)\) ORDER BY name ASC,id ASC LIMIT \$5`).
+		WithArgs(false, "Central", "Central", 1, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Riverside"))
+
+	var names []string
+	for library, err := range c.Libraries(context.Background(), LibraryFilter{Limit: 1}) {
+		require.NoError(t, err)
+		names = append(names, library.Name)
+	}
+	assert.Equal(t, []string{"Central", "Riverside"}, names)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTokenRefresh(t *testing.T) {
+	t.Run("Expired Token Is Replaced Before Sending", func(t *testing.T) {
+		server, mock := newServer(t, nil)
+		expired := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1}`)) + ".sig"
+		c, err := New(server.URL, WithToken(expired), WithCredentials("owner@example.com", "password123"))
+		require.NoError(t, err)
+
+		expectOwnerLogin(mock)
+		expectArchive(mock)
+
+		library, err := c.ArchiveLibrary(context.Background(), 4)
+		require.NoError(t, err)
+		assert.True(t, library.Archived)
+		assert.NotEqual(t, expired, c.Token())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rejected Token Is Replaced Once", func(t *testing.T) {
+		server, mock := newServer(t, nil)
+		c, err := New(server.URL, WithToken("not-a-jwt"), WithCredentials("owner@example.com", "password123"))
+		require.NoError(t, err)
+
+		expectOwnerLogin(mock)
+		expectArchive(mock)
+
+		_, err = c.ArchiveLibrary(context.Background(), 4)
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Without Credentials The Error Is Returned", func(t *testing.T) {
+		server, _ := newServer(t, nil)
+		c, err := New(server.URL, WithToken("not-a-jwt"))
+		require.NoError(t, err)
+
+		_, err = c.ArchiveLibrary(context.Background(), 4)
+		assert.True(t, IsCode(err, "invalid_token"))
+	})
+}
+
+// flaky answers the first failures requests with 503 before passing through
+func flaky(failures int32, calls *int32) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(calls, 1) <= failures {
+				w.Header().Set("Content-Type", "application/problem+json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				_, _ = w.Write([]byte(`{"status":503,"code":"unavailable","title":"Service Unavailable"}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	retry := WithRetry(3, time.Millisecond, 5*time.Millisecond)
+
+	t.Run("Idempotent Request Is Retried", func(t *testing.T) {
+		var calls int32
+		server, mock := newServer(t, flaky(2, &calls))
+		c, err := New(server.URL, retry)
+		require.NoError(t, err)
+
+		mock.ExpectQuery(`SELECT \* FROM "libraries" WHERE "libraries"."id" = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(4, "Central"))
+		mock.ExpectQuery(`SELECT \* FROM "library_closures"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id"}))
+		mock.ExpectQuery(`SELECT \* FROM "opening_hours"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id"}))
+
+		library, err := c.GetLibrary(context.Background(), 4)
+		require.NoError(t, err)
+		assert.Equal(t, "Central", library.Name)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Post Is Not Retried After A 503", func(t *testing.T) {
+		var calls int32
+		server, _ := newServer(t, flaky(1, &calls))
+		c, err := New(server.URL, retry)
+		require.NoError(t, err)
+
+		_, err = c.Login(context.Background(), "owner@example.com", "password123")
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, http.StatusServiceUnavailable, apiErr.Status)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Keyed Post Is Retried With The Same Key", func(t *testing.T) {
+		var keys []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			if len(keys) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"library":{"id":9,"name":"Central"}}`))
+		}))
+		defer server.Close()
+		c, err := New(server.URL, retry, WithToken("token"))
+		require.NoError(t, err)
+
+		library, err := c.CreateLibrary(context.Background(), Library{Name: "Central"})
+		require.NoError(t, err)
+		assert.Equal(t, uint(9), library.ID)
+		require.Len(t, keys, 2)
+		assert.NotEmpty(t, keys[0])
+		assert.Equal(t, keys[0], keys[1])
+	})
+
+	t.Run("Gives Up After Max Retries", func(t *testing.T) {
+		var calls int32
+		server, _ := newServer(t, flaky(10, &calls))
+		c, err := New(server.URL, retry)
+		require.NoError(t, err)
+
+		_, err = c.GetLibrary(context.Background(), 4)
+		assert.True(t, IsCode(err, "unavailable"))
+		assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestProblemErrors(t *testing.T) {
+	server, mock := newServer(t, nil)
+	c, err := New(server.URL)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT \* FROM "libraries" WHERE "libraries"."id" = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = c.GetLibrary(context.Background(), 99)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.Status)
+	assert.Equal(t, "library_not_found", apiErr.Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	token := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000000}`)) + ".sig"
+	assert.Equal(t, time.Unix(1700000000, 0), tokenExpiry(token))
+	assert.True(t, tokenExpiry("garbage").IsZero())
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+)
+
+// AddBookByISBN adds a book from little more than its ISBN, LibraryID and
+// TotalCopies. The server normalises the ISBN to ISBN-13 and fills the fields
+// left blank from its enrichment sources; an ISBN none of them knows fails
+// with metadata_not_found unless a Title is given.
+func (c *Client) AddBookByISBN(ctx context.Context, book Book) (*Book, error) {
+	return c.bookCall(ctx, call{method: http.MethodPost, path: "/api/book", query: url.Values{"enrich": {"true"}}, body: book})
+}
+
+// LookupMetadata previews what enrichment finds for an ISBN-10 or ISBN-13
+func (c *Client) LookupMetadata(ctx context.Context, isbn string) (*Metadata, error) {
+	var result struct {
+		Metadata Metadata `json:"metadata"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/enrichment/" + url.PathEscape(isbn), auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result.Metadata, nil
+}
+
+// StartEnrichment queues a job re-enriching every book of a library. Blank
+// fields are filled; overwrite replaces fields that have a value too.
+func (c *Client) StartEnrichment(ctx context.Context, libraryID uint, overwrite bool) (*EnrichmentJob, error) {
+	return c.enrichmentJobCall(ctx, call{
+		method: http.MethodPost,
+		path:   enrichmentPath(libraryID),
+		body:   map[string]bool{"overwrite": overwrite},
+	})
+}
+
+// GetEnrichmentJob reports the progress of one job
+func (c *Client) GetEnrichmentJob(ctx context.Context, libraryID, jobID uint) (*EnrichmentJob, error) {
+	return c.enrichmentJobCall(ctx, call{method: http.MethodGet, path: enrichmentPath(libraryID) + "/" + formatID(jobID)})
+}
+
+// EnrichmentJobPage is one page of ListEnrichmentJobs
+type EnrichmentJobPage struct {
+	Page
+	Jobs []EnrichmentJob `json:"jobs"`
+}
+
+// ListEnrichmentJobs fetches one page of a library's jobs, newest first. Pass
+// "" for the first page and the previous page's NextCursor after that.
+func (c *Client) ListEnrichmentJobs(ctx context.Context, libraryID uint, cursor string) (*EnrichmentJobPage, error) {
+	query := url.Values{}
+	setCursor(query, cursor, "", 0)
+
+	var result EnrichmentJobPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: enrichmentPath(libraryID), query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// EnrichmentJobs iterates over every job of a library, newest first
+func (c *Client) EnrichmentJobs(ctx context.Context, libraryID uint) iter.Seq2[EnrichmentJob, error] {
+	return follow(func(cursor string) ([]EnrichmentJob, Page, error) {
+		result, err := c.ListEnrichmentJobs(ctx, libraryID, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Jobs, result.Page, nil
+	})
+}
+
+func (c *Client) enrichmentJobCall(ctx context.Context, req call) (*EnrichmentJob, error) {
+	var result struct {
+		Job EnrichmentJob `json:"job"`
+	}
+	req.auth = true
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.Job, nil
+}
+
+func enrichmentPath(libraryID uint) string {
+	return "/api/library/" + formatID(libraryID) + "/enrichment"
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Error is a problem+json response from the API. Branch on Code, which is
+// stable; Detail is meant for people.
+type Error struct {
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Title     string       `json:"title"`
+	Detail    string       `json:"detail"`
+	RequestID string       `json:"request_id"`
+	Errors    []FieldError `json:"errors"`
+}
+
+// FieldError explains why one field of the request was rejected
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	message := e.Detail
+	if message == "" {
+		message = e.Title
+	}
+	if e.Code == "" {
+		return fmt.Sprintf("api: %d %s", e.Status, message)
+	}
+	return fmt.Sprintf("api: %d %s: %s", e.Status, e.Code, message)
+}
+
+// IsCode reports whether err is an API error with the given code
+func IsCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+// decodeError reads an error response, tolerating bodies that are not problems
+func decodeError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	apiErr := &Error{}
+	if json.Unmarshal(body, apiErr) != nil || apiErr.Code == "" {
+		apiErr = &Error{Title: http.StatusText(resp.StatusCode)}
+	}
+	apiErr.Status = resp.StatusCode
+	if apiErr.RequestID == "" {
+		apiErr.RequestID = resp.Header.Get("X-Request-ID")
+	}
+	return apiErr
+}
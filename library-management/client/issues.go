@@ -0,0 +1,176 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RequestIssue asks to borrow a book from one of the reader's libraries
+func (c *Client) RequestIssue(ctx context.Context, isbn string, libraryID uint) (*IssueRequest, error) {
+	var result struct {
+		Request IssueRequest `json:"request"`
+	}
+	err := c.do(ctx, call{
+		method: http.MethodPost,
+		path:   "/api/issue",
+		body:   map[string]interface{}{"isbn": isbn, "libraryid": libraryID},
+		auth:   true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Request, nil
+}
+
+// HoldWork asks to borrow any edition of a work from one of the reader's
+// libraries. The request is fulfilled when staff issue any of its editions.
+func (c *Client) HoldWork(ctx context.Context, workID, libraryID uint) (*IssueRequest, error) {
+	var result struct {
+		Request IssueRequest `json:"request"`
+	}
+	err := c.do(ctx, call{
+		method: http.MethodPost,
+		path:   "/api/issue",
+		body:   map[string]interface{}{"work_id": workID, "libraryid": libraryID},
+		auth:   true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Request, nil
+}
+
+// IssueFilter narrows IssueStatus and ListIssueRequests; zero values are
+// ignored. ISBN and UserID only apply to ListIssueRequests. From and To compare
+// whole days of the request date. Sort is request_date, status or id, prefixed
+// with "-" for descending; the server defaults to newest first.
+type IssueFilter struct {
+	Status      string
+	RequestType string
+	LibraryID   uint
+	ISBN        string
+	UserID      uint
+	From        time.Time
+	To          time.Time
+	Sort        string
+	Limit       int
+}
+
+func (f IssueFilter) query(cursor string, admin bool) url.Values {
+	query := url.Values{}
+	setQuery(query, "status", f.Status)
+	setQuery(query, "request_type", f.RequestType)
+	if f.LibraryID != 0 {
+		query.Set("library_id", formatID(f.LibraryID))
+	}
+	if admin {
+		setQuery(query, "isbn", f.ISBN)
+		if f.UserID != 0 {
+			query.Set("user_id", formatID(f.UserID))
+		}
+	}
+	if !f.From.IsZero() {
+		query.Set("from", f.From.Format(time.DateOnly))
+	}
+	if !f.To.IsZero() {
+		query.Set("to", f.To.Format(time.DateOnly))
+	}
+	setCursor(query, cursor, f.Sort, f.Limit)
+	return query
+}
+
+// IssueStatusPage is one page of IssueStatus
+type IssueStatusPage struct {
+	Page
+	Requests []IssueStatus `json:"requests"`
+}
+
+// IssueStatus fetches one page of the reader's own requests. Pass "" for the
+// first page and the previous page's NextCursor after that.
+func (c *Client) IssueStatus(ctx context.Context, filter IssueFilter, cursor string) (*IssueStatusPage, error) {
+	var result IssueStatusPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/issue/status", query: filter.query(cursor, false), auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// IssueStatuses iterates over every matching request of the reader, fetching
+// pages as needed. Iteration stops after yielding the first error.
+func (c *Client) IssueStatuses(ctx context.Context, filter IssueFilter) iter.Seq2[IssueStatus, error] {
+	return follow(func(cursor string) ([]IssueStatus, Page, error) {
+		result, err := c.IssueStatus(ctx, filter, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Requests, result.Page, nil
+	})
+}
+
+// IssueRequestPage is one page of ListIssueRequests
+type IssueRequestPage struct {
+	Page
+	Requests []PendingIssue `json:"requests"`
+}
+
+// ListIssueRequests fetches one page of the requests for the admin's
+// libraries. Pass "" for the first page and the previous page's NextCursor
+// after that.
+func (c *Client) ListIssueRequests(ctx context.Context, filter IssueFilter, cursor string) (*IssueRequestPage, error) {
+	var result IssueRequestPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/issues", query: filter.query(cursor, true), auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// IssueRequests iterates over every matching request for the admin's
+// libraries, fetching pages as needed. Iteration stops after yielding the
+// first error.
+func (c *Client) IssueRequests(ctx context.Context, filter IssueFilter) iter.Seq2[PendingIssue, error] {
+	return follow(func(cursor string) ([]PendingIssue, Page, error) {
+		result, err := c.ListIssueRequests(ctx, filter, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Requests, result.Page, nil
+	})
+}
+
+// ApproveIssue approves a pending request and returns its new status
+func (c *Client) ApproveIssue(ctx context.Context, requestID uint) (string, error) {
+	return c.decideIssue(ctx, "/api/issue/approve/", requestID)
+}
+
+// DisapproveIssue rejects a pending request and returns its new status
+func (c *Client) DisapproveIssue(ctx context.Context, requestID uint) (string, error) {
+	return c.decideIssue(ctx, "/api/issue/disapprove/", requestID)
+}
+
+func (c *Client) decideIssue(ctx context.Context, prefix string, requestID uint) (string, error) {
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(ctx, call{method: http.MethodPut, path: prefix + formatID(requestID), auth: true}, &result); err != nil {
+		return "", err
+	}
+	return result.Status, nil
+}
+
+// IssueBook hands a copy of the book to a reader at the counter
+func (c *Client) IssueBook(ctx context.Context, isbn string, userID, libraryID uint) error {
+	return c.do(ctx, call{
+		method: http.MethodPost,
+		path:   "/api/issue/book/" + url.PathEscape(isbn),
+		body:   map[string]uint{"user_id": userID, "library_id": libraryID},
+		auth:   true,
+	}, nil)
+}
+
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
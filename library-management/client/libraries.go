@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// LibraryFilter narrows ListLibraries; Archived is "true", "false" (the server
+// default) or "all". Sort is name, city or id, prefixed with "-" for descending.
+type LibraryFilter struct {
+	Name     string
+	City     string
+	Archived string
+	Sort     string
+	Limit    int
+}
+
+// LibraryPage is one page of ListLibraries
+type LibraryPage struct {
+	Page
+	Libraries []Library `json:"libraries"`
+}
+
+// ListLibraries fetches one page of libraries. Pass "" for the first page and
+// the previous page's NextCursor after that.
+func (c *Client) ListLibraries(ctx context.Context, filter LibraryFilter, cursor string) (*LibraryPage, error) {
+	query := url.Values{}
+	setQuery(query, "name", filter.Name)
+	setQuery(query, "city", filter.City)
+	setQuery(query, "archived", filter.Archived)
+	setCursor(query, cursor, filter.Sort, filter.Limit)
+
+	var result LibraryPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/libraries", query: query}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Libraries iterates over every matching library, fetching pages as needed.
+// Iteration stops after yielding the first error.
+func (c *Client) Libraries(ctx context.Context, filter LibraryFilter) iter.Seq2[Library, error] {
+	return follow(func(cursor string) ([]Library, Page, error) {
+		result, err := c.ListLibraries(ctx, filter, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Libraries, result.Page, nil
+	})
+}
+
+// GetLibrary returns a library with its opening hours and closures
+func (c *Client) GetLibrary(ctx context.Context, id uint) (*Library, error) {
+	var result struct {
+		Library Library `json:"library"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/libraries/" + formatID(id)}, &result); err != nil {
+		return nil, err
+	}
+	return &result.Library, nil
+}
+
+// CreateLibrary creates a library
+func (c *Client) CreateLibrary(ctx context.Context, library Library) (*Library, error) {
+	return c.libraryCall(ctx, http.MethodPost, "", library)
+}
+
+// UpdateLibrary replaces a library's details. Sending OpeningHours or Closures
+// replaces the whole schedule.
+func (c *Client) UpdateLibrary(ctx context.Context, id uint, library Library) (*Library, error) {
+	return c.libraryCall(ctx, http.MethodPut, "/"+formatID(id), library)
+}
+
+// SetPublicCatalog lists or unlists a library in the anonymous catalog
+func (c *Client) SetPublicCatalog(ctx context.Context, id uint, enabled bool) (*Library, error) {
+	return c.libraryCall(ctx, http.MethodPut, "/"+formatID(id), map[string]bool{"PublicCatalog": enabled})
+}
+
+// ArchiveLibrary hides a library from listings
+func (c *Client) ArchiveLibrary(ctx context.Context, id uint) (*Library, error) {
+	return c.libraryCall(ctx, http.MethodPut, "/"+formatID(id)+"/archive", nil)
+}
+
+// UnarchiveLibrary restores an archived library
+func (c *Client) UnarchiveLibrary(ctx context.Context, id uint) (*Library, error) {
+	return c.libraryCall(ctx, http.MethodPut, "/"+formatID(id)+"/unarchive", nil)
+}
+
+// DeleteLibrary removes a library that holds no books
+func (c *Client) DeleteLibrary(ctx context.Context, id uint) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/api/library/" + formatID(id), auth: true}, nil)
+}
+
+func (c *Client) libraryCall(ctx context.Context, method, suffix string, body interface{}) (*Library, error) {
+	var result struct {
+		Library Library `json:"library"`
+	}
+	if err := c.do(ctx, call{method: method, path: "/api/library" + suffix, body: body, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result.Library, nil
+}
+
+func setPage(query url.Values, page, limit int) {
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+func setCursor(query url.Values, cursor, sort string, limit int) {
+	setQuery(query, "cursor", cursor)
+	setQuery(query, "sort", sort)
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+}
+
+// paginate turns a page fetcher into an iterator over every item
+func paginate[T any](fetch func(page int) ([]T, Page, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page := 1; ; page++ {
+			items, info, err := fetch(page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(items) == 0 || !info.hasNext() {
+				return
+			}
+		}
+	}
+}
+
+// follow turns a cursor fetcher into an iterator over every item, starting
+// with an empty cursor and following NextCursor until it runs out
+func follow[T any](fetch func(cursor string) ([]T, Page, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor := ""
+		for {
+			items, info, err := fetch(cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if info.NextCursor == "" {
+				return
+			}
+			cursor = info.NextCursor
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Shelf is where a library keeps its copies of a book. Scheme is ddc or lcc,
+// detected from CallNumber when empty. An empty CallNumber or LocationID 0
+// clears it.
+type Shelf struct {
+	CallNumber string `json:"call_number"`
+	Scheme     string `json:"scheme,omitempty"`
+	LocationID uint   `json:"location_id"`
+}
+
+// ShelveBook sets a book's call number and location in one library. A
+// non-empty etag makes the change conditional on that revision.
+func (c *Client) ShelveBook(ctx context.Context, isbn string, libraryID uint, shelf Shelf, etag string) (*Book, error) {
+	return c.bookCall(ctx, call{
+		method:  http.MethodPut,
+		path:    "/api/book/" + url.PathEscape(isbn) + "/shelf",
+		query:   url.Values{"library_id": {formatID(libraryID)}},
+		body:    shelf,
+		ifMatch: etag,
+	})
+}
+
+// Locations lists a library's floors, sections and shelves in walking order,
+// each after its parent
+func (c *Client) Locations(ctx context.Context, libraryID uint) ([]ShelfLocation, error) {
+	var result struct {
+		Locations []ShelfLocation `json:"locations"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: locationsPath(libraryID), auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return result.Locations, nil
+}
+
+// CreateLocation adds a floor, a section with the ParentID of a floor or a
+// shelf with the ParentID of a section
+func (c *Client) CreateLocation(ctx context.Context, libraryID uint, location ShelfLocation) (*ShelfLocation, error) {
+	return c.locationCall(ctx, call{
+		method: http.MethodPost,
+		path:   locationsPath(libraryID),
+		body: map[string]interface{}{
+			"level":     location.Level,
+			"name":      location.Name,
+			"parent_id": location.ParentID,
+			"position":  location.Position,
+		},
+	})
+}
+
+// UpdateLocation renames a location or moves it among its siblings; the paths
+// of the locations under it follow
+func (c *Client) UpdateLocation(ctx context.Context, libraryID, locationID uint, name string, position int) (*ShelfLocation, error) {
+	return c.locationCall(ctx, call{
+		method: http.MethodPut,
+		path:   locationsPath(libraryID) + "/" + formatID(locationID),
+		body:   map[string]interface{}{"name": name, "position": position},
+	})
+}
+
+// DeleteLocation removes a location with nothing under it and no books,
+// failing with location_not_empty otherwise
+func (c *Client) DeleteLocation(ctx context.Context, libraryID, locationID uint) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: locationsPath(libraryID) + "/" + formatID(locationID), auth: true}, nil)
+}
+
+// PickList lists the holds waiting in a library in shelf order. Status is
+// Pending or Approved, or "" for both.
+func (c *Client) PickList(ctx context.Context, libraryID uint, status string) ([]PickListItem, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	var result struct {
+		Items []PickListItem `json:"items"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/library/" + formatID(libraryID) + "/picklist", query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return result.Items, nil
+}
+
+func (c *Client) locationCall(ctx context.Context, req call) (*ShelfLocation, error) {
+	var result struct {
+		Location ShelfLocation `json:"location"`
+	}
+	req.auth = true
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.Location, nil
+}
+
+func locationsPath(libraryID uint) string {
+	return "/api/library/" + formatID(libraryID) + "/locations"
+}
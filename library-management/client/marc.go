@@ -0,0 +1,62 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MARCFormat is a serialisation of MARC bibliographic records
+type MARCFormat string
+
+const (
+	MARCXML MARCFormat = "marcxml"
+	MARC21  MARCFormat = "marc"
+)
+
+func (f MARCFormat) contentType() string {
+	if f == MARC21 {
+		return "application/marc"
+	}
+	return "application/marcxml+xml"
+}
+
+// ImportMARC sends MARC records to a library. New ISBNs get copies copies each,
+// ISBNs the library holds have their details replaced. Records the server
+// could not use are reported, not returned as an error.
+func (c *Client) ImportMARC(ctx context.Context, libraryID uint, format MARCFormat, records []byte, copies int) (*MARCImportReport, error) {
+	query := url.Values{}
+	if copies > 0 {
+		query.Set("copies", strconv.Itoa(copies))
+	}
+	var report MARCImportReport
+	err := c.do(ctx, call{
+		method:      http.MethodPost,
+		path:        "/api/library/" + formatID(libraryID) + "/marc",
+		query:       query,
+		body:        records,
+		contentType: format.contentType(),
+		auth:        true,
+	}, &report)
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ExportMARC returns every book of a library as MARC records
+func (c *Client) ExportMARC(ctx context.Context, libraryID uint, format MARCFormat) ([]byte, error) {
+	var records []byte
+	err := c.do(ctx, call{
+		method: http.MethodGet,
+		path:   "/api/library/" + formatID(libraryID) + "/marc",
+		query:  url.Values{"format": {string(format)}},
+		accept: format.contentType(),
+		auth:   true,
+	}, &records)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
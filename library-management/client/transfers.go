@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+)
+
+// TransferRequest names the copies SendTransfer moves
+type TransferRequest struct {
+	ISBN        string `json:"isbn"`
+	ToLibraryID uint   `json:"to_library_id"`
+	Copies      int    `json:"copies"`
+	Note        string `json:"note,omitempty"`
+}
+
+// SendTransfer puts available copies of a book in transit from fromLibraryID
+// to another library. They leave the source's counts at once and join the
+// destination's when it calls ReceiveTransfer.
+func (c *Client) SendTransfer(ctx context.Context, fromLibraryID uint, req TransferRequest) (*Transfer, error) {
+	return c.transferCall(ctx, call{method: http.MethodPost, path: transfersPath(fromLibraryID), body: req})
+}
+
+// ReceiveTransfer adds a transfer's copies to its destination, libraryID
+func (c *Client) ReceiveTransfer(ctx context.Context, libraryID, transferID uint) (*Transfer, error) {
+	return c.transferCall(ctx, call{method: http.MethodPost, path: transfersPath(libraryID) + "/" + formatID(transferID) + "/receive"})
+}
+
+// CancelTransfer returns copies still in transit to their source, libraryID
+func (c *Client) CancelTransfer(ctx context.Context, libraryID, transferID uint) (*Transfer, error) {
+	return c.transferCall(ctx, call{method: http.MethodPost, path: transfersPath(libraryID) + "/" + formatID(transferID) + "/cancel"})
+}
+
+// GetTransfer fetches one transfer a library sent or received
+func (c *Client) GetTransfer(ctx context.Context, libraryID, transferID uint) (*Transfer, error) {
+	return c.transferCall(ctx, call{method: http.MethodGet, path: transfersPath(libraryID) + "/" + formatID(transferID)})
+}
+
+// TransferFilter narrows ListTransfers; empty fields are ignored
+type TransferFilter struct {
+	Status        string // in_transit, received or cancelled
+	ISBN          string
+	FromLibraryID uint
+	ToLibraryID   uint
+}
+
+// TransferPage is one page of ListTransfers
+type TransferPage struct {
+	Page
+	Transfers []Transfer `json:"transfers"`
+}
+
+// ListTransfers fetches one page of the transfers a library sent or received,
+// newest first. Pass "" for the first page and the previous page's NextCursor
+// after that.
+func (c *Client) ListTransfers(ctx context.Context, libraryID uint, filter TransferFilter, cursor string) (*TransferPage, error) {
+	query := url.Values{}
+	setQuery(query, "status", filter.Status)
+	setQuery(query, "isbn", filter.ISBN)
+	if filter.FromLibraryID != 0 {
+		query.Set("from_library_id", formatID(filter.FromLibraryID))
+	}
+	if filter.ToLibraryID != 0 {
+		query.Set("to_library_id", formatID(filter.ToLibraryID))
+	}
+	setCursor(query, cursor, "", 0)
+
+	var result TransferPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: transfersPath(libraryID), query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Transfers iterates over every transfer of a library matching filter, newest
+// first
+func (c *Client) Transfers(ctx context.Context, libraryID uint, filter TransferFilter) iter.Seq2[Transfer, error] {
+	return follow(func(cursor string) ([]Transfer, Page, error) {
+		result, err := c.ListTransfers(ctx, libraryID, filter, cursor)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Transfers, result.Page, nil
+	})
+}
+
+func (c *Client) transferCall(ctx context.Context, req call) (*Transfer, error) {
+	var result struct {
+		Transfer Transfer `json:"transfer"`
+	}
+	req.auth = true
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.Transfer, nil
+}
+
+func transfersPath(libraryID uint) string {
+	return "/api/library/" + formatID(libraryID) + "/transfers"
+}
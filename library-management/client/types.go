@@ -0,0 +1,391 @@
+package client
+
+import "time"
+
+// User is an account as returned by login, profile and user administration
+type User struct {
+	ID          uint         `json:"ID"`
+	Name        string       `json:"Name"`
+	Email       string       `json:"Email"`
+	Contact     string       `json:"Contact"`
+	Role        string       `json:"Role"`
+	Status      string       `json:"Status,omitempty"`
+	Libraries   []Library    `json:"Libraries,omitempty"`
+	Memberships []Membership `json:"Memberships,omitempty"`
+}
+
+// Membership links a user to a library; reader memberships go through review
+type Membership struct {
+	UserID      uint   `json:"user_id"`
+	LibraryID   uint   `json:"library_id"`
+	Status      string `json:"status"`
+	RequestedAt int64  `json:"requested_at"`
+	ReviewerID  *uint  `json:"reviewer_id"`
+	ReviewedAt  *int64 `json:"reviewed_at"`
+	Reason      string `json:"reason"`
+}
+
+// Library is a branch with its weekly schedule and closure days
+type Library struct {
+	ID            uint             `json:"ID,omitempty"`
+	Name          string           `json:"Name"`
+	Address       string           `json:"Address,omitempty"`
+	City          string           `json:"City,omitempty"`
+	Phone         string           `json:"Phone,omitempty"`
+	Email         string           `json:"Email,omitempty"`
+	Timezone      string           `json:"Timezone,omitempty"`
+	Archived      bool             `json:"Archived,omitempty"`
+	PublicCatalog bool             `json:"PublicCatalog,omitempty"`
+	OpeningHours  []OpeningHours   `json:"OpeningHours,omitempty"`
+	Closures      []LibraryClosure `json:"Closures,omitempty"`
+}
+
+// OpeningHours is one opening window, Weekday 0 is Sunday
+type OpeningHours struct {
+	Weekday time.Weekday `json:"Weekday"`
+	Opens   string       `json:"Opens"`
+	Closes  string       `json:"Closes"`
+}
+
+// LibraryClosure is a day the library stays shut, Date is YYYY-MM-DD
+type LibraryClosure struct {
+	Date   string `json:"Date"`
+	Reason string `json:"Reason,omitempty"`
+}
+
+// Book is one title held by one library
+type Book struct {
+	ID              uint   `json:"ID,omitempty"`
+	ISBN            string `json:"ISBN"`
+	Title           string `json:"Title"`
+	Authors         string `json:"Authors"`
+	Publisher       string `json:"Publisher"` // Several are separated by ";"
+	Subjects        string `json:"Subjects"`  // Subject headings separated by ";"
+	Version         string `json:"Version"`
+	TotalCopies     uint   `json:"TotalCopies"`
+	AvailableCopies uint   `json:"AvailableCopies,omitempty"`
+	LibraryID       uint   `json:"LibraryID"`
+	WorkID          uint   `json:"WorkID,omitempty"` // Set by the server, see MergeEditions
+	Revision        uint   `json:"Revision,omitempty"`
+
+	// Where the copies are shelved. AddBook reads them, after that they
+	// change only through ShelveBook.
+	CallNumber       string `json:"CallNumber,omitempty"`
+	CallNumberScheme string `json:"CallNumberScheme,omitempty"` // ddc or lcc
+	LocationID       uint   `json:"LocationID,omitempty"`
+
+	// ETag is the server's validator for this revision. UpdateBook sends it as
+	// If-Match, so the update fails with precondition_failed if someone else
+	// changed the book first.
+	ETag string `json:"-"`
+}
+
+// Work groups the editions of one title; each edition is an ISBN
+type Work struct {
+	ID      uint   `json:"id"`
+	Title   string `json:"title"`
+	Authors string `json:"authors"`
+}
+
+// WorkEdition is one library's copies of an edition, as listed by GetWork
+type WorkEdition struct {
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	Publisher       string `json:"publisher"`
+	Version         string `json:"version"`
+	TotalCopies     uint   `json:"total_copies"`
+	AvailableCopies uint   `json:"available_copies"`
+	LibraryID       uint   `json:"library_id"`
+}
+
+// WorkResult is a work found by SearchBooks with its editions in the reader's
+// libraries
+type WorkResult struct {
+	WorkID          uint           `json:"work_id"`
+	Title           string         `json:"title"`
+	Authors         string         `json:"authors"`
+	AvailableCopies uint           `json:"available_copies"` // Summed over Editions
+	CoverURL        string         `json:"cover_url"`        // From the first edition with a cover
+	ThumbnailURL    string         `json:"thumbnail_url"`
+	Editions        []SearchResult `json:"editions"`
+}
+
+// SearchResult is one library's copies of an edition found by SearchBooks
+type SearchResult struct {
+	ISBN              string `json:"isbn"`
+	Title             string `json:"title"`
+	Author            string `json:"author"`
+	Publisher         string `json:"publisher"`
+	Version           string `json:"version"`
+	AvailableCopies   uint   `json:"available_copies"`
+	LibraryID         uint   `json:"library_id"`
+	CallNumber        string `json:"call_number"`
+	Location          string `json:"location"` // Path of the location, empty when unassigned
+	NextAvailableDate string `json:"next_available_date"`
+	CoverURL          string `json:"cover_url"` // Empty without a cover
+	ThumbnailURL      string `json:"thumbnail_url"`
+}
+
+// Authority is an author, publisher or subject shared by every book that names
+// it, whichever spelling the book used
+type Authority struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// AuthorityBook is a book listed by AuthorityBooks
+type AuthorityBook struct {
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	Publisher       string `json:"publisher"`
+	Subjects        string `json:"subjects"`
+	AvailableCopies uint   `json:"available_copies"`
+	LibraryID       uint   `json:"library_id"`
+}
+
+// MARCImportReport is what ImportMARC did with each record it was sent
+type MARCImportReport struct {
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Rejected  int                `json:"rejected"`
+	Records   []MARCImportResult `json:"records"`
+}
+
+// MARCImportResult is one record of an import. Status is created, updated,
+// unchanged or rejected, Error saying why for the last.
+type MARCImportResult struct {
+	Index    int            `json:"index"`
+	ISBN     string         `json:"isbn"`
+	Title    string         `json:"title"`
+	Status   string         `json:"status"`
+	Unmapped []MARCUnmapped `json:"unmapped"`
+	Error    string         `json:"error"`
+}
+
+// MARCUnmapped is a field, or with Code a subfield, the import had no place for
+type MARCUnmapped struct {
+	Tag    string `json:"tag"`
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// Metadata is what the enrichment sources know about an ISBN
+type Metadata struct {
+	Source    string `json:"source"` // openlibrary or loc
+	ISBN      string `json:"isbn"`   // ISBN-13
+	Title     string `json:"title"`
+	Authors   string `json:"authors"`
+	Publisher string `json:"publisher"`
+	Edition   string `json:"edition"` // Becomes the book's Version
+	Subjects  string `json:"subjects"`
+}
+
+// EnrichmentJob re-enriches every book of a library. Status is queued, running,
+// done or failed; the counts grow as it runs.
+type EnrichmentJob struct {
+	ID          uint       `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	LibraryID   uint       `json:"library_id"`
+	RequestedBy uint       `json:"requested_by"`
+	Overwrite   bool       `json:"overwrite"`
+	Status      string     `json:"status"`
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Updated     int        `json:"updated"`
+	NotFound    int        `json:"not_found"`
+	Failed      int        `json:"failed"`
+	Error       string     `json:"error"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// ShelfLocation is a floor, a section on a floor or a shelf in a section.
+// Level is floor, section or shelf; ParentID is 0 for floors.
+type ShelfLocation struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LibraryID uint      `json:"library_id"`
+	ParentID  uint      `json:"parent_id"`
+	Level     string    `json:"level"`
+	Name      string    `json:"name"`
+	Position  int       `json:"position"` // Walking order among its siblings
+	Path      string    `json:"path"`     // e.g. "Floor 2 / Science / Shelf 4"
+}
+
+// PickListItem is a hold to pull from the shelves
+type PickListItem struct {
+	RequestID       uint   `json:"request_id"`
+	ReaderID        uint   `json:"reader_id"`
+	Status          string `json:"status"`
+	RequestDate     int64  `json:"request_date"` // Unix time
+	WorkID          uint   `json:"work_id"`
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	CallNumber      string `json:"call_number"`
+	AvailableCopies uint   `json:"available_copies"`
+	LocationID      uint   `json:"location_id"`
+	Location        string `json:"location"` // Empty when unassigned
+}
+
+// Transfer moves copies of a book between libraries. Status is in_transit,
+// received or cancelled; ClosedBy and ClosedAt are set once it is not in
+// transit any more.
+type Transfer struct {
+	ID            uint       `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ISBN          string     `json:"isbn"`
+	FromLibraryID uint       `json:"from_library_id"`
+	ToLibraryID   uint       `json:"to_library_id"`
+	Copies        int        `json:"copies"`
+	Status        string     `json:"status"`
+	Note          string     `json:"note"`
+	SentBy        uint       `json:"sent_by"`
+	SentAt        time.Time  `json:"sent_at"`
+	ClosedBy      uint       `json:"closed_by"`
+	ClosedAt      *time.Time `json:"closed_at"`
+}
+
+// BookDetail is a book's availability across the libraries the caller can access
+type BookDetail struct {
+	Book struct {
+		ISBN      string `json:"isbn"`
+		Title     string `json:"title"`
+		Authors   string `json:"authors"`
+		Publisher string `json:"publisher"`
+	} `json:"book"`
+	TotalCopies     uint               `json:"total_copies"`
+	AvailableCopies uint               `json:"available_copies"`
+	Libraries       []BookAvailability `json:"libraries"`
+}
+
+// BookAvailability is one library's share of a BookDetail. Copy and Loans are
+// only filled in for owners and admins.
+type BookAvailability struct {
+	LibraryID       uint       `json:"library_id"`
+	TotalCopies     uint       `json:"total_copies"`
+	AvailableCopies uint       `json:"available_copies"`
+	HoldQueue       uint       `json:"hold_queue"`
+	NextDueDate     string     `json:"next_due_date"`
+	CallNumber      string     `json:"call_number"`
+	Location        string     `json:"location"` // Path of the location, empty when unassigned
+	Copy            *BookCopy  `json:"copy,omitempty"`
+	Loans           []BookLoan `json:"loans,omitempty"`
+}
+
+// BookCopy is a library's record of a book
+type BookCopy struct {
+	ID        uint      `json:"id"`
+	Version   string    `json:"version"`
+	Revision  uint      `json:"revision"`
+	ETag      string    `json:"etag"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BookLoan is an active loan of a book
+type BookLoan struct {
+	ID                 uint   `json:"id"`
+	ReaderID           uint   `json:"reader_id"`
+	IssueStatus        string `json:"issue_status"`
+	IssueDate          string `json:"issue_date"`
+	ExpectedReturnDate string `json:"expected_return_date"`
+	Overdue            bool   `json:"overdue"`
+}
+
+// PublicLibrary is a library listed in the anonymous catalog
+type PublicLibrary struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	City     string `json:"city"`
+	Phone    string `json:"phone"`
+	Email    string `json:"email"`
+	Timezone string `json:"timezone"`
+}
+
+// PublicBook is a book in a library's anonymous catalog. Version and
+// NextAvailableDate are only filled in by PublicBook, not by searches.
+type PublicBook struct {
+	ISBN              string `json:"isbn"`
+	Title             string `json:"title"`
+	Authors           string `json:"authors"`
+	Publisher         string `json:"publisher"`
+	Version           string `json:"version,omitempty"`
+	TotalCopies       uint   `json:"total_copies"`
+	AvailableCopies   uint   `json:"available_copies"`
+	Available         bool   `json:"available"`
+	NextAvailableDate string `json:"next_available_date,omitempty"`
+}
+
+// IssueRequest is a reader's request to borrow a book, as created by RequestIssue
+type IssueRequest struct {
+	ID           uint   `json:"ID"`
+	ISBN         string `json:"isbn"`
+	LibraryID    uint   `json:"libraryid"`
+	WorkID       uint   `json:"work_id"` // Set for holds on a work
+	ReaderID     uint   `json:"ReaderID"`
+	RequestDate  int64  `json:"RequestDate"`
+	ApprovalDate *int64 `json:"ApprovalDate"`
+	ApproverID   *uint  `json:"ApproverID"`
+	RequestType  string `json:"RequestType"`
+	Status       string `json:"status"`
+}
+
+// IssueStatus is one of the reader's own requests
+type IssueStatus struct {
+	RequestID    uint   `json:"request_id"`
+	BookID       string `json:"book_id"`
+	WorkID       uint   `json:"work_id"`
+	LibraryID    uint   `json:"library_id"`
+	ReaderID     uint   `json:"reader_id"`
+	RequestDate  int64  `json:"request_date"`
+	ApprovalDate *int64 `json:"approval_date"`
+	Status       string `json:"status"`
+}
+
+// PendingIssue is an issue request as seen by an admin
+type PendingIssue struct {
+	ID           uint   `json:"id"`
+	BookID       string `json:"book_id"`
+	WorkID       uint   `json:"work_id"`
+	UserID       uint   `json:"user_id"`
+	RequestType  string `json:"request_type"`
+	Status       string `json:"status"`
+	RequestDate  int64  `json:"request_date"`
+	ApprovalDate *int64 `json:"approval_date"`
+	ApproverID   *uint  `json:"approver_id"`
+}
+
+// Loan is a book the caller holds or has returned; dates are formatted by the
+// server, "N/A" when unset
+type Loan struct {
+	ID                 uint   `json:"id"`
+	ISBN               string `json:"isbn"`
+	IssueStatus        string `json:"issue_status"`
+	IssueDate          string `json:"issue_date"`
+	ExpectedReturnDate string `json:"expected_return_date"`
+	ReturnDate         string `json:"return_date"`
+	Overdue            bool   `json:"overdue"`
+}
+
+// Page describes where a listing page sits in the full result. Page is zero
+// when the listing was fetched with a cursor; NextCursor is empty on the last
+// page and for listings that only page by number.
+type Page struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// hasNext reports whether another numbered page follows this one
+func (p Page) hasNext() bool {
+	return p.Limit > 0 && int64(p.Page*p.Limit) < p.Total
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// UserFilter narrows ListUsers; zero values are ignored
+type UserFilter struct {
+	Role      string
+	Status    string
+	LibraryID uint
+	Limit     int
+}
+
+// UserPage is one page of ListUsers
+type UserPage struct {
+	Page
+	Users []User `json:"users"`
+}
+
+// ListUsers fetches one page of users, page numbers start at 1
+func (c *Client) ListUsers(ctx context.Context, filter UserFilter, page int) (*UserPage, error) {
+	query := url.Values{}
+	setQuery(query, "role", filter.Role)
+	setQuery(query, "status", filter.Status)
+	if filter.LibraryID != 0 {
+		query.Set("library_id", strconv.FormatUint(uint64(filter.LibraryID), 10))
+	}
+	setPage(query, page, filter.Limit)
+
+	var result UserPage
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/users", query: query, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Users iterates over every matching user, fetching pages as needed.
+// Iteration stops after yielding the first error.
+func (c *Client) Users(ctx context.Context, filter UserFilter) iter.Seq2[User, error] {
+	return paginate(func(page int) ([]User, Page, error) {
+		result, err := c.ListUsers(ctx, filter, page)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return result.Users, result.Page, nil
+	})
+}
+
+// GetUser returns a user with their memberships
+func (c *Client) GetUser(ctx context.Context, id uint) (*User, error) {
+	return c.userCall(ctx, http.MethodGet, "/"+formatID(id), nil)
+}
+
+// UserUpdate changes a user's details; nil fields are left alone
+type UserUpdate struct {
+	Name    *string `json:"name,omitempty"`
+	Email   *string `json:"email,omitempty"`
+	Contact *string `json:"contact,omitempty"`
+}
+
+// UpdateUser changes a user's name, email or contact
+func (c *Client) UpdateUser(ctx context.Context, id uint, update UserUpdate) (*User, error) {
+	return c.userCall(ctx, http.MethodPut, "/"+formatID(id), update)
+}
+
+// DeactivateUser blocks a user from logging in
+func (c *Client) DeactivateUser(ctx context.Context, id uint) (*User, error) {
+	return c.userCall(ctx, http.MethodPut, "/"+formatID(id)+"/deactivate", nil)
+}
+
+// ReactivateUser lets a deactivated user log in again
+func (c *Client) ReactivateUser(ctx context.Context, id uint) (*User, error) {
+	return c.userCall(ctx, http.MethodPut, "/"+formatID(id)+"/reactivate", nil)
+}
+
+// ChangeUserRole promotes or demotes a user to owner, admin or user
+func (c *Client) ChangeUserRole(ctx context.Context, id uint, role string) (*User, error) {
+	return c.userCall(ctx, http.MethodPut, "/"+formatID(id)+"/role", map[string]string{"role": role})
+}
+
+// AssignUserLibraries replaces a user's library memberships
+func (c *Client) AssignUserLibraries(ctx context.Context, id uint, libraryIDs []uint) (*User, error) {
+	return c.userCall(ctx, http.MethodPut, "/"+formatID(id)+"/libraries", map[string][]uint{"library_ids": libraryIDs})
+}
+
+// DeleteUser soft deletes a user without outstanding loans
+func (c *Client) DeleteUser(ctx context.Context, id uint) error {
+	return c.do(ctx, call{method: http.MethodDelete, path: "/api/users/" + formatID(id), auth: true}, nil)
+}
+
+func (c *Client) userCall(ctx context.Context, method, suffix string, body interface{}) (*User, error) {
+	var result struct {
+		User User `json:"user"`
+	}
+	if err := c.do(ctx, call{method: method, path: "/api/users" + suffix, body: body, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+// Registration creates an account; LibraryIDs are the libraries to join
+type Registration struct {
+	Name       string `json:"name"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Contact    string `json:"contact,omitempty"`
+	LibraryIDs []uint `json:"library_ids"`
+}
+
+// RegisterAdmin creates an admin for the given libraries - owner only
+func (c *Client) RegisterAdmin(ctx context.Context, registration Registration) (*User, error) {
+	return c.register(ctx, "/api/admin", "admin", registration, true)
+}
+
+// RegisterUser signs up a reader, whose memberships start pending review
+func (c *Client) RegisterUser(ctx context.Context, registration Registration) (*User, error) {
+	return c.register(ctx, "/api/user", "user", registration, false)
+}
+
+func (c *Client) register(ctx context.Context, path, key string, registration Registration, auth bool) (*User, error) {
+	type created struct {
+		ID      uint      `json:"ID"`
+		Name    string    `json:"Name"`
+		Email   string    `json:"Email"`
+		Role    string    `json:"Role"`
+		Contact string    `json:"Contact"`
+		Library []Library `json:"Library"`
+	}
+	var result struct {
+		Admin created `json:"admin"`
+		User  created `json:"user"`
+	}
+	if err := c.do(ctx, call{method: http.MethodPost, path: path, body: registration, auth: auth}, &result); err != nil {
+		return nil, err
+	}
+	user := result.User
+	if key == "admin" {
+		user = result.Admin
+	}
+	return &User{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role, Contact: user.Contact, Libraries: user.Library}, nil
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// GetWork returns a work with its editions in the libraries the caller can access
+func (c *Client) GetWork(ctx context.Context, id uint) (*Work, []WorkEdition, error) {
+	var result struct {
+		Work     Work          `json:"work"`
+		Editions []WorkEdition `json:"editions"`
+	}
+	if err := c.do(ctx, call{method: http.MethodGet, path: "/api/works/" + formatID(id), auth: true}, &result); err != nil {
+		return nil, nil, err
+	}
+	return &result.Work, result.Editions, nil
+}
+
+// MergeEditions makes every library's copies of the ISBNs editions of a work.
+// Admins may only move ISBNs their libraries hold.
+func (c *Client) MergeEditions(ctx context.Context, workID uint, isbns ...string) (*Work, error) {
+	var result struct {
+		Work Work `json:"work"`
+	}
+	err := c.do(ctx, call{
+		method: http.MethodPost,
+		path:   "/api/works/" + formatID(workID) + "/editions",
+		body:   map[string][]string{"isbns": isbns},
+		auth:   true,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Work, nil
+}
+
+// SplitEdition moves an edition out of a work and returns the new work it
+// now belongs to
+func (c *Client) SplitEdition(ctx context.Context, workID uint, isbn string) (*Work, error) {
+	var result struct {
+		Work Work `json:"work"`
+	}
+	path := "/api/works/" + formatID(workID) + "/editions/" + url.PathEscape(isbn)
+	if err := c.do(ctx, call{method: http.MethodDelete, path: path, auth: true}, &result); err != nil {
+		return nil, err
+	}
+	return &result.Work, nil
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"library-management/client"
+	"library-management/config"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+// backend is implemented once against the database and once against the API,
+// for the commands that work both ways
+type backend interface {
+	CreateLibrary(ctx context.Context, library client.Library) (*client.Library, error)
+	ListLibraries(ctx context.Context, archived string) ([]client.Library, error)
+	CreateAdmin(ctx context.Context, registration client.Registration) (*client.User, error)
+	// AddBook creates the book or adds copies to it, as POST /api/book does
+	AddBook(ctx context.Context, book client.Book) (added bool, err error)
+}
+
+// open returns the backend for -mode, connecting on first use
+func (e *env) open() (backend, error) {
+	if e.backend != nil {
+		return e.backend, nil
+	}
+	if e.mode == "http" {
+		if e.email == "" || e.password == "" {
+			return nil, errors.New("-mode http needs -email and -password (or LMS_EMAIL and LMS_PASSWORD)")
+		}
+		c, err := client.New(e.apiURL, client.WithCredentials(e.email, e.password), client.WithUserAgent("lmsctl"))
+		if err != nil {
+			return nil, err
+		}
+		e.backend = &httpBackend{client: c}
+		return e.backend, nil
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return nil, err
+	}
+	e.backend = &dbBackend{db: db}
+	return e.backend, nil
+}
+
+// database returns the connection for commands that only work in db mode
+func (e *env) database() (*gorm.DB, error) {
+	if e.mode != "db" {
+		return nil, errors.New("this command needs -mode db, the API has no endpoint for it")
+	}
+	if db, ok := e.backend.(*dbBackend); ok {
+		return db.db.WithContext(e.ctx), nil
+	}
+	db, err := config.OpenDatabase()
+	if err != nil {
+		return nil, err
+	}
+	e.backend = &dbBackend{db: db}
+	return db.WithContext(e.ctx), nil
+}
+
+type httpBackend struct {
+	client *client.Client
+}
+
+func (b *httpBackend) CreateLibrary(ctx context.Context, library client.Library) (*client.Library, error) {
+	return b.client.CreateLibrary(ctx, library)
+}
+
+func (b *httpBackend) ListLibraries(ctx context.Context, archived string) ([]client.Library, error) {
+	var libraries []client.Library
+	for library, err := range b.client.Libraries(ctx, client.LibraryFilter{Archived: archived, Limit: 100}) {
+		if err != nil {
+			return nil, err
+		}
+		libraries = append(libraries, library)
+	}
+	return libraries, nil
+}
+
+func (b *httpBackend) CreateAdmin(ctx context.Context, registration client.Registration) (*client.User, error) {
+	return b.client.RegisterAdmin(ctx, registration)
+}
+
+func (b *httpBackend) AddBook(ctx context.Context, book client.Book) (bool, error) {
+	_, err := b.client.AddBook(ctx, book)
+	return err == nil, err
+}
+
+// dbBackend writes directly, recording the same audit actions the handlers do
+// with "cli" as the actor role
+type dbBackend struct {
+	db *gorm.DB
+}
+
+func (b *dbBackend) CreateLibrary(ctx context.Context, library client.Library) (*client.Library, error) {
+	record := models.Library{
+		Name:     library.Name,
+		Address:  library.Address,
+		City:     library.City,
+		Phone:    library.Phone,
+		Email:    library.Email,
+		Timezone: library.Timezone,
+	}
+	if record.Timezone == "" {
+		record.Timezone = "UTC"
+	}
+
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		return audit(tx, "library.create", "library", record.ID, record.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toClientLibrary(record), nil
+}
+
+func (b *dbBackend) ListLibraries(ctx context.Context, archived string) ([]client.Library, error) {
+	query := b.db.WithContext(ctx).Order("name ASC")
+	switch archived {
+	case "", "false":
+		query = query.Where("archived = ?", false)
+	case "true":
+		query = query.Where("archived = ?", true)
+	case "all":
+	default:
+		return nil, fmt.Errorf("archived must be one of true, false or all")
+	}
+
+	var records []models.Library
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	libraries := make([]client.Library, len(records))
+	for i, record := range records {
+		libraries[i] = *toClientLibrary(record)
+	}
+	return libraries, nil
+}
+
+func (b *dbBackend) CreateAdmin(ctx context.Context, registration client.Registration) (*client.User, error) {
+	db := b.db.WithContext(ctx)
+
+	var libraries []models.Library
+	if err := db.Where("id IN ?", registration.LibraryIDs).Find(&libraries).Error; err != nil {
+		return nil, err
+	}
+	if len(libraries) != len(registration.LibraryIDs) {
+		return nil, fmt.Errorf("one or more of libraries %v do not exist", registration.LibraryIDs)
+	}
+
+	user := models.User{
+		Name:     registration.Name,
+		Email:    registration.Email,
+		Password: registration.Password,
+		Contact:  registration.Contact,
+		Role:     "admin",
+		Library:  libraries,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return fmt.Errorf("email %s is already registered", user.Email)
+		}
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return audit(tx, "user.create_admin", "user", user.ID, 0)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &client.User{ID: user.ID, Name: user.Name, Email: user.Email, Role: user.Role, Contact: user.Contact}, nil
+}
+
+func (b *dbBackend) AddBook(ctx context.Context, book client.Book) (bool, error) {
+	if book.TotalCopies == 0 {
+		return false, errors.New("number of copies must be greater than zero")
+	}
+
+	added := false
+	err := b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Book
+		err := tx.Where("isbn = ? AND library_id = ?", book.ISBN, book.LibraryID).First(&existing).Error
+		if err == nil {
+			// Counts are added in place so loans made meanwhile are kept
+			err := tx.Model(&existing).UpdateColumns(map[string]interface{}{
+				"total_copies":     gorm.Expr("total_copies + ?", book.TotalCopies),
+				"available_copies": gorm.Expr("available_copies + ?", book.TotalCopies),
+				"revision":         gorm.Expr("revision + 1"),
+				"updated_at":       time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+			return audit(tx, "book.add_copies", "book", existing.ISBN, existing.LibraryID)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		record := models.Book{
+			ISBN:            book.ISBN,
+			Title:           book.Title,
+			Authors:         book.Authors,
+			Publisher:       book.Publisher,
+			Version:         book.Version,
+			TotalCopies:     int(book.TotalCopies),
+			AvailableCopies: int(book.TotalCopies),
+			LibraryID:       book.LibraryID,
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			return err
+		}
+		added = true
+		return audit(tx, "book.create", "book", record.ISBN, record.LibraryID)
+	})
+	return added, err
+}
+
+// audit appends an entry attributed to the command line
+func audit(tx *gorm.DB, action, entityType string, entityID interface{}, libraryID uint) error {
+	entry := models.AuditLog{
+		ActorRole:  "cli",
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+	}
+	if libraryID != 0 {
+		entry.LibraryID = &libraryID
+	}
+	return tx.Create(&entry).Error
+}
+
+func toClientLibrary(record models.Library) *client.Library {
+	return &client.Library{
+		ID:       record.ID,
+		Name:     record.Name,
+		Address:  record.Address,
+		City:     record.City,
+		Phone:    record.Phone,
+		Email:    record.Email,
+		Timezone: record.Timezone,
+		Archived: record.Archived,
+	}
+}
+
+// parseIDs reads a comma separated list such as "1,2,5"
+func parseIDs(list string) ([]uint, error) {
+	var ids []uint
+	for _, part := range splitList(list) {
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid library ID %q", part)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"library-management/client"
+	"library-management/models"
+)
+
+// catalogColumns is the CSV layout for import and export. Import reads copies as
+// the number to add and ignores available.
+var catalogColumns = []string{"isbn", "title", "authors", "publisher", "version", "copies", "available"}
+
+func runCatalogImport(e *env, args []string) error {
+	fs := e.flags("catalog import")
+	libraryID := fs.Uint("library", 0, "library to add the books to")
+	file := fs.String("file", "-", "CSV file, - for stdin")
+	dryRun := fs.Bool("dry-run", false, "check the file without importing")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *libraryID == 0 {
+		fmt.Fprintln(e.stderr, "-library is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	in, closeIn, err := openInput(e, *file)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+
+	books, err := readCatalog(in, *libraryID)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Fprintf(e.stdout, "%d rows are valid\n", len(books))
+		return nil
+	}
+
+	b, err := e.open()
+	if err != nil {
+		return err
+	}
+
+	// Rows are independent, like the same number of POST /api/book calls
+	var created, updated, failed int
+	for i, book := range books {
+		added, err := b.AddBook(e.ctx, book)
+		switch {
+		case err != nil:
+			failed++
+			fmt.Fprintf(e.stderr, "row %d (%s): %v\n", i+2, book.ISBN, err)
+		case added:
+			created++
+		default:
+			updated++
+		}
+	}
+
+	fmt.Fprintf(e.stdout, "imported %d rows: %d new books, %d with copies added, %d failed\n", len(books), created, updated, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d rows failed", failed)
+	}
+	return nil
+}
+
+// readCatalog parses and validates the whole file before anything is written
+func readCatalog(in io.Reader, libraryID uint) ([]client.Book, error) {
+	reader := csv.NewReader(in)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("catalog file is empty")
+		}
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, column := range header {
+		index[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range []string{"isbn", "title", "copies"} {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("catalog header is missing the %q column", column)
+		}
+	}
+	field := func(record []string, column string) string {
+		if i, ok := index[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var books []client.Book
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		book := client.Book{
+			ISBN:      field(record, "isbn"),
+			Title:     field(record, "title"),
+			Authors:   field(record, "authors"),
+			Publisher: field(record, "publisher"),
+			Version:   field(record, "version"),
+			LibraryID: libraryID,
+		}
+		if book.ISBN == "" || book.Title == "" {
+			return nil, fmt.Errorf("row %d: isbn and title are required", row)
+		}
+		copies, err := strconv.ParseUint(field(record, "copies"), 10, 32)
+		if err != nil || copies == 0 {
+			return nil, fmt.Errorf("row %d: copies must be a positive number", row)
+		}
+		book.TotalCopies = uint(copies)
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+func runCatalogExport(e *env, args []string) error {
+	fs := e.flags("catalog export")
+	libraryID := fs.Uint("library", 0, "library whose books to export")
+	file := fs.String("file", "-", "CSV file to write, - for stdout")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *libraryID == 0 {
+		fmt.Fprintln(e.stderr, "-library is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	var books []models.Book
+	if err := db.Where("library_id = ?", *libraryID).Order("title ASC, isbn ASC").Find(&books).Error; err != nil {
+		return err
+	}
+
+	out := e.stdout
+	if *file != "-" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := writeCatalog(out, books); err != nil {
+		return err
+	}
+	if *file != "-" {
+		fmt.Fprintf(e.stderr, "exported %d books to %s\n", len(books), *file)
+	}
+	return nil
+}
+
+func writeCatalog(out io.Writer, books []models.Book) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write(catalogColumns); err != nil {
+		return err
+	}
+	for _, book := range books {
+		if err := writer.Write([]string{
+			book.ISBN, book.Title, book.Authors, book.Publisher, book.Version,
+			strconv.Itoa(book.TotalCopies), strconv.Itoa(book.AvailableCopies),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func openInput(e *env, file string) (io.Reader, func(), error) {
+	if file == "-" {
+		return e.stdin, func() {}, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, func() { f.Close() }, nil
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"library-management/client"
+	"library-management/config"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+func runMigrate(e *env, args []string) error {
+	if err := parse(e.flags("migrate"), args); err != nil {
+		return err
+	}
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+	if err := config.Migrate(db); err != nil {
+		return err
+	}
+
+	var applied uint
+	if err := db.Model(&models.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&applied).Error; err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "schema is at version %d\n", applied)
+	return nil
+}
+
+// runBootstrapOwner creates the first owner. POST /api/owner needs an owner
+// already, so this only works against the database and refuses once one exists.
+func runBootstrapOwner(e *env, args []string) error {
+	fs := e.flags("bootstrap-owner")
+	name := fs.String("name", "", "owner name")
+	email := fs.String("email", "", "owner email")
+	password := fs.String("password", "", "owner password, generated when empty")
+	contact := fs.String("contact", "", "owner contact")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "email"); err != nil {
+		return err
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		if *password, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+
+	owner := models.User{Name: *name, Email: *email, Password: *password, Contact: *contact, Role: "owner"}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var owners int64
+		if err := tx.Model(&models.User{}).Where("role = ?", "owner").Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners > 0 {
+			return errors.New("an owner already exists, use POST /api/owner to add more")
+		}
+		if err := tx.Create(&owner).Error; err != nil {
+			return err
+		}
+		return audit(tx, "user.bootstrap_owner", "user", owner.ID, 0)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.stdout, "created owner %d <%s>\n", owner.ID, owner.Email)
+	if generated {
+		fmt.Fprintf(e.stdout, "password: %s\n", owner.Password)
+	}
+	return nil
+}
+
+func runLibraryCreate(e *env, args []string) error {
+	fs := e.flags("library create")
+	var library client.Library
+	fs.StringVar(&library.Name, "name", "", "library name")
+	fs.StringVar(&library.Address, "address", "", "street address")
+	fs.StringVar(&library.City, "city", "", "city")
+	fs.StringVar(&library.Phone, "phone", "", "phone number")
+	fs.StringVar(&library.Email, "email", "", "contact email")
+	fs.StringVar(&library.Timezone, "timezone", "", "IANA timezone, UTC when empty")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "name"); err != nil {
+		return err
+	}
+	if library.Timezone != "" {
+		if _, err := time.LoadLocation(library.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone %q", library.Timezone)
+		}
+	}
+
+	b, err := e.open()
+	if err != nil {
+		return err
+	}
+	created, err := b.CreateLibrary(e.ctx, library)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "created library %d %q\n", created.ID, created.Name)
+	return nil
+}
+
+func runLibraryList(e *env, args []string) error {
+	fs := e.flags("library list")
+	archived := fs.String("archived", "false", "true, false or all")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	b, err := e.open()
+	if err != nil {
+		return err
+	}
+	libraries, err := b.ListLibraries(e.ctx, *archived)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tCITY\tTIMEZONE\tARCHIVED")
+	for _, library := range libraries {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%t\n", library.ID, library.Name, library.City, library.Timezone, library.Archived)
+	}
+	return w.Flush()
+}
+
+func runAdminCreate(e *env, args []string) error {
+	fs := e.flags("admin create")
+	var registration client.Registration
+	fs.StringVar(&registration.Name, "name", "", "admin name")
+	fs.StringVar(&registration.Email, "email", "", "admin email")
+	fs.StringVar(&registration.Password, "password", "", "admin password, generated when empty")
+	fs.StringVar(&registration.Contact, "contact", "", "admin contact")
+	libraries := fs.String("libraries", "", "comma separated library IDs")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "name", "email", "libraries"); err != nil {
+		return err
+	}
+
+	var err error
+	if registration.LibraryIDs, err = parseIDs(*libraries); err != nil {
+		return err
+	}
+	generated := registration.Password == ""
+	if generated {
+		if registration.Password, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+
+	b, err := e.open()
+	if err != nil {
+		return err
+	}
+	admin, err := b.CreateAdmin(e.ctx, registration)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.stdout, "created admin %d <%s> for libraries %v\n", admin.ID, admin.Email, registration.LibraryIDs)
+	if generated {
+		fmt.Fprintf(e.stdout, "password: %s\n", registration.Password)
+	}
+	return nil
+}
+
+func runResetPassword(e *env, args []string) error {
+	fs := e.flags("user reset-password")
+	email := fs.String("email", "", "email of the account")
+	password := fs.String("password", "", "new password, generated when empty")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "email"); err != nil {
+		return err
+	}
+	if *password != "" && len(*password) < 8 {
+		return errors.New("password must be at least 8 characters")
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		if *password, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+
+	var user models.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("email = ?", *email).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("no user with email %s", *email)
+			}
+			return err
+		}
+		if err := tx.Model(&user).Update("password", *password).Error; err != nil {
+			return err
+		}
+		return audit(tx, "user.reset_password", "user", user.ID, 0)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(e.stdout, "password reset for user %d <%s>\n", user.ID, user.Email)
+	if generated {
+		fmt.Fprintf(e.stdout, "password: %s\n", *password)
+	}
+	return nil
+}
+
+func runLoansList(e *env, args []string) error {
+	fs := e.flags("loans list")
+	email := fs.String("email", "", "only loans of this reader")
+	isbn := fs.String("isbn", "", "only loans of this book")
+	state := fs.String("state", "open", "open, overdue, returned or all")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	query := db.Model(&models.IssueRegistry{}).
+		Select("issue_registries.*, users.email AS reader_email").
+		Joins("JOIN users ON users.id = issue_registries.reader_id")
+	if *email != "" {
+		query = query.Where("users.email = ?", *email)
+	}
+	if *isbn != "" {
+		query = query.Where("issue_registries.isbn = ?", *isbn)
+	}
+	now := time.Now().Unix()
+	switch *state {
+	case "open":
+		query = query.Where("issue_registries.return_date = 0")
+	case "overdue":
+		query = query.Where("issue_registries.return_date = 0 AND issue_registries.expected_return_date < ?", now)
+	case "returned":
+		query = query.Where("issue_registries.return_date <> 0")
+	case "all":
+	default:
+		return fmt.Errorf("state must be one of open, overdue, returned or all")
+	}
+
+	var loans []struct {
+		models.IssueRegistry
+		ReaderEmail string
+	}
+	if err := query.Order("issue_registries.expected_return_date ASC").Scan(&loans).Error; err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tISBN\tREADER\tISSUED\tDUE\tRETURNED\tSTATUS")
+	for _, loan := range loans {
+		status := loan.IssueStatus
+		if loan.ReturnDate == 0 && loan.ExpectedReturnDate < now {
+			status = "overdue"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", loan.ID, loan.ISBN, loan.ReaderEmail,
+			formatDay(loan.IssueDate), formatDay(loan.ExpectedReturnDate), formatDay(loan.ReturnDate), status)
+	}
+	return w.Flush()
+}
+
+func formatDay(unix int64) string {
+	if unix == 0 {
+		return "-"
+	}
+	return time.Unix(unix, 0).Format("2006-01-02")
+}
+
+// generatePassword returns 16 random URL-safe characters
+func generatePassword() (string, error) {
+	raw := make([]byte, 12)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func splitList(list string) []string {
+	var parts []string
+	for _, part := range strings.Split(list, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"strings"
+
+	"library-management/enrich"
+	"library-management/marc"
+	"library-management/models"
+)
+
+// runEnrichLoad indexes a bibliographic dump for enrichment. Dumps run to tens
+// of gigabytes, so they are streamed, gzipped or not, and never go through the API.
+func runEnrichLoad(e *env, args []string) error {
+	fs := e.flags("enrich load")
+	source := fs.String("source", "", "openlibrary or loc")
+	file := fs.String("file", "-", "dump file, - for stdin; .gz files are decompressed")
+	format := fs.String("format", string(marc.XML), "marcxml or marc, for -source loc")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if err := required(fs, "source"); err != nil {
+		return err
+	}
+	if *source != enrich.DumpOpenLibrary && *source != enrich.DumpLOC {
+		return fmt.Errorf("source must be %s or %s", enrich.DumpOpenLibrary, enrich.DumpLOC)
+	}
+	if *format != string(marc.XML) && *format != string(marc.Binary) {
+		return fmt.Errorf("format must be marcxml or marc")
+	}
+
+	in, closeIn, err := openInput(e, *file)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+	if strings.HasSuffix(*file, ".gz") {
+		gz, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		in = gz
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+
+	var stats enrich.LoadStats
+	if *source == enrich.DumpOpenLibrary {
+		stats, err = enrich.LoadOpenLibrary(e.ctx, db, in)
+	} else {
+		stats, err = enrich.LoadMARC(e.ctx, db, marc.Format(*format), in)
+	}
+	fmt.Fprintf(e.stdout, "loaded %d records from %s, skipped %d\n", stats.Records, *source, stats.Skipped)
+	return err
+}
+
+// runEnrichRun re-enriches a library's books in the foreground, as a job the
+// server's worker would run, and prints its counts
+func runEnrichRun(e *env, args []string) error {
+	fs := e.flags("enrich run")
+	libraryID := fs.Uint("library", 0, "library whose books to enrich")
+	overwrite := fs.Bool("overwrite", false, "replace fields that already have a value")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if *libraryID == 0 {
+		fmt.Fprintln(e.stderr, "-library is required")
+		fs.Usage()
+		return errUsage
+	}
+
+	db, err := e.database()
+	if err != nil {
+		return err
+	}
+	source, err := enrich.SourceFromEnv(db)
+	if err != nil {
+		return err
+	}
+
+	// Created running so the server's worker leaves it alone
+	job := models.EnrichmentJob{LibraryID: *libraryID, Overwrite: *overwrite, Status: enrich.JobRunning}
+	if err := db.Create(&job).Error; err != nil {
+		return err
+	}
+	err = enrich.Run(e.ctx, db, source, &job)
+	fmt.Fprintf(e.stdout, "job %d %s: %d of %d books, %d updated, %d not found, %d failed\n",
+		job.ID, job.Status, job.Processed, job.Total, job.Updated, job.NotFound, job.Failed)
+	return err
+}
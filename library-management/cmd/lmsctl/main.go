@@ -0,0 +1,168 @@
+// Command lmsctl operates a library management deployment from the shell:
+// bootstrapping the first owner, creating libraries and admins, moving catalogs
+// in and out, loading metadata dumps and re-enriching books, running
+// migrations, resetting passwords and inspecting loans.
+//
+// Commands run against the database by default (DATABASE_URL). With -mode http
+// the ones the API supports go through it instead, authenticated as -email.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// env carries what every command needs
+type env struct {
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	mode     string
+	apiURL   string
+	email    string
+	password string
+
+	// Opened lazily, only for the commands that need one
+	backend backend
+}
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"migrate":             {"Create or update tables and record the schema version (db)", runMigrate},
+	"bootstrap-owner":     {"Create the first owner account (db)", runBootstrapOwner},
+	"library create":      {"Create a library", runLibraryCreate},
+	"library list":        {"List libraries", runLibraryList},
+	"admin create":        {"Create an admin for one or more libraries", runAdminCreate},
+	"catalog import":      {"Add the books of a CSV file to a library", runCatalogImport},
+	"catalog export":      {"Write a library's books as CSV (db)", runCatalogExport},
+	"enrich load":         {"Index an Open Library or Library of Congress dump for enrichment (db)", runEnrichLoad},
+	"enrich run":          {"Re-enrich a library's books now, without waiting for the worker (db)", runEnrichRun},
+	"user reset-password": {"Set a new password for a user (db)", runResetPassword},
+	"loans list":          {"List loans by reader, book or state (db)", runLoansList},
+}
+
+// errUsage means the arguments were wrong; the usage text has been printed
+var errUsage = errors.New("usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	e := &env{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	global := flag.NewFlagSet("lmsctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.StringVar(&e.mode, "mode", envOr("LMSCTL_MODE", "db"), "db or http")
+	global.StringVar(&e.apiURL, "api", envOr("LMS_API_URL", "http://localhost:8080"), "API base URL for -mode http")
+	global.StringVar(&e.email, "email", os.Getenv("LMS_EMAIL"), "login email for -mode http")
+	global.StringVar(&e.password, "password", os.Getenv("LMS_PASSWORD"), "login password for -mode http")
+	global.Usage = func() { printUsage(stderr, global) }
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+	if e.mode != "db" && e.mode != "http" {
+		fmt.Fprintf(stderr, "lmsctl: -mode must be db or http, got %q\n", e.mode)
+		return 2
+	}
+
+	name, cmd, rest, ok := lookup(global.Args())
+	if !ok {
+		printUsage(stderr, global)
+		return 2
+	}
+
+	if err := cmd.run(e, rest); err != nil {
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		fmt.Fprintf(stderr, "lmsctl %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// lookup matches one or two word commands
+func lookup(args []string) (string, command, []string, bool) {
+	if len(args) >= 2 {
+		if cmd, ok := commands[args[0]+" "+args[1]]; ok {
+			return args[0] + " " + args[1], cmd, args[2:], true
+		}
+	}
+	if len(args) >= 1 {
+		if cmd, ok := commands[args[0]]; ok {
+			return args[0], cmd, args[1:], true
+		}
+	}
+	return "", command{}, nil, false
+}
+
+func printUsage(w io.Writer, global *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: lmsctl [global flags] <command> [flags]")
+	fmt.Fprintln(w, "\nCommands (db = database mode only):")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-22s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	global.PrintDefaults()
+}
+
+// flags returns a flag set for a command that prints errors and usage to stderr
+func (e *env) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("lmsctl "+name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	return fs
+}
+
+// parse wraps FlagSet.Parse so bad flags end up as errUsage
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return errUsage
+	}
+	return nil
+}
+
+// required reports the first empty flag
+func required(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if fs.Lookup(name).Value.String() == "" {
+			fmt.Fprintf(fs.Output(), "-%s is required\n", name)
+			fs.Usage()
+			return errUsage
+		}
+	}
+	return nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
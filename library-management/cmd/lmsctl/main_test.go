@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func newTestEnv(t *testing.T) (*env, sqlmock.Sqlmock, *bytes.Buffer) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	require.NoError(t, err)
+
+	stdout := &bytes.Buffer{}
+	return &env{
+		ctx:     context.Background(),
+		stdout:  stdout,
+		stderr:  &bytes.Buffer{},
+		mode:    "db",
+		backend: &dbBackend{db: gormDB},
+	}, mock, stdout
+}
+
+func TestRunUsage(t *testing.T) {
+	stderr := &bytes.Buffer{}
+	assert.Equal(t, 2, run(context.Background(), []string{"frobnicate"}, nil, &bytes.Buffer{}, stderr))
+	assert.Contains(t, stderr.String(), "bootstrap-owner")
+
+	stderr.Reset()
+	assert.Equal(t, 2, run(context.Background(), []string{"-mode", "ftp", "migrate"}, nil, &bytes.Buffer{}, stderr))
+	assert.Contains(t, stderr.String(), "-mode must be db or http")
+}
+
+func TestBootstrapOwner(t *testing.T) {
+	t.Run("Creates The First Owner", func(t *testing.T) {
+		e, mock, stdout := newTestEnv(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+			WithArgs("owner").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(`INSERT INTO "users"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		err := runBootstrapOwner(e, []string{"-name", "Root", "-email", "root@example.com"})
+		require.NoError(t, err)
+		assert.Contains(t, stdout.String(), "created owner 1 <root@example.com>")
+		assert.Contains(t, stdout.String(), "password: ")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Refuses When An Owner Exists", func(t *testing.T) {
+		e, mock, _ := newTestEnv(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(`SELECT count\(\*\) FROM "users" WHERE role = \$1`).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectRollback()
+
+		err := runBootstrapOwner(e, []string{"-name", "Root", "-email", "root@example.com", "-password", "password123"})
+		assert.ErrorContains(t, err, "an owner already exists")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Needs Database Mode", func(t *testing.T) {
+		e, _, _ := newTestEnv(t)
+		e.mode = "http"
+		err := runBootstrapOwner(e, []string{"-name", "Root", "-email", "root@example.com"})
+		assert.ErrorContains(t, err, "needs -mode db")
+	})
+}
+
+func TestReadCatalog(t *testing.T) {
+	t.Run("Valid File", func(t *testing.T) {
+		books, err := readCatalog(strings.NewReader("isbn,title,authors,copies\n9780140449136, The Odyssey, Homer, 3\n"), 2)
+		require.NoError(t, err)
+		require.Len(t, books, 1)
+		assert.Equal(t, "The Odyssey", books[0].Title)
+		assert.Equal(t, uint(3), books[0].TotalCopies)
+		assert.Equal(t, uint(2), books[0].LibraryID)
+	})
+
+	t.Run("Missing Column", func(t *testing.T) {
+		_, err := readCatalog(strings.NewReader("isbn,title\n1,Book\n"), 2)
+		assert.ErrorContains(t, err, `missing the "copies" column`)
+	})
+
+	t.Run("Bad Row Stops Before Import", func(t *testing.T) {
+		_, err := readCatalog(strings.NewReader("isbn,title,copies\n1,Book,2\n2,Other,none\n"), 2)
+		assert.ErrorContains(t, err, "row 3: copies must be a positive number")
+	})
+}
+
+func TestCatalogImport(t *testing.T) {
+	e, mock, stdout := newTestEnv(t)
+	e.stdin = strings.NewReader("isbn,title,copies\n111,First,2\n222,Second,1\n")
+
+	// First row adds copies to an existing book, the second creates one
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "books" WHERE \(isbn = \$1 AND library_id = \$2\)`).
+		WithArgs("111", 5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "total_copies", "available_copies", "library_id"}).AddRow(9, "111", "First", 1, 1, 5))
+	mock.ExpectExec(`UPDATE "books" SET`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT \* FROM "books" WHERE \(isbn = \$1 AND library_id = \$2\)`).
+		WithArgs("222", 5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(`INSERT INTO "books"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+	mock.ExpectQuery(`INSERT INTO "audit_logs"`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectCommit()
+
+	require.NoError(t, runCatalogImport(e, []string{"-library", "5"}))
+	assert.Contains(t, stdout.String(), "1 new books, 1 with copies added, 0 failed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
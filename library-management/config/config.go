@@ -1,16 +1,36 @@
 package config
 
 import (
+	"library-management/authority"
 	"library-management/models"
-	"log"
+	"library-management/works"
+	"log/slog"
+	"os"
+	"time"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // DB here is connection instance
 var DB *gorm.DB
 
+// SchemaVersion is the schema this build expects, readiness fails until it is applied
+const SchemaVersion uint = 3
+
+// migrations are the data changes AutoMigrate cannot make, applied once each in
+// version order. The last version must equal SchemaVersion.
+var migrations = []struct {
+	version uint
+	name    string
+	apply   func(*gorm.DB) error
+}{
+	{1, "baseline", nil},
+	{2, "link books to authors, publishers and subjects", authority.Backfill},
+	{3, "group books into works", works.Backfill},
+}
+
 // ConnectDatabase function initializes the database connection
 func ConnectDatabase(isTest bool) (*gorm.DB, error) {
 	if isTest {
@@ -18,32 +38,101 @@ func ConnectDatabase(isTest bool) (*gorm.DB, error) {
 		return DB, nil 
 	}
 
-	// Actual database connection for normal use
-	dsn := "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable"
-	database, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	database, err := OpenDatabase()
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, err
+	}
+
+	if err := Migrate(database); err != nil {
+		return nil, err
+	}
+
+	DB = database
+	slog.Info("database connected and migrated")
+	return DB, nil
+}
+
+// DSN returns the Postgres connection string, DATABASE_URL when set
+func DSN() string {
+	if dsn := os.Getenv("DATABASE_URL"); dsn != "" {
+		return dsn
+	}
+	return "host=localhost user=postgres password=postgres dbname=library_management sslmode=disable"
+}
+
+// OpenDatabase connects without touching the schema
+func OpenDatabase() (*gorm.DB, error) {
+	database, err := gorm.Open(postgres.Open(DSN()), &gorm.Config{})
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
 		return nil, err
 	}
 
 	// To test the connection
 	database.Exec("SELECT 1")
+	return database, nil
+}
 
+// Migrate creates or updates every table and records the schema version
+func Migrate(database *gorm.DB) error {
 	// Auto-migrate database tables
-	err = database.AutoMigrate(
+	err := database.AutoMigrate(
 		&models.Library{},
+		&models.OpeningHours{},
+		&models.LibraryClosure{},
 		&models.User{},
+		&models.Work{},
 		&models.Book{},
 		&models.RequestEvent{},
 		&models.IssueRegistry{},
 		&models.UserLibrary{},
+		&models.AuditLog{},
+		&models.IdempotencyKey{},
+		&models.Author{},
+		&models.Publisher{},
+		&models.Subject{},
+		&models.BookAuthor{},
+		&models.BookPublisher{},
+		&models.BookSubject{},
+		&models.Attachment{},
+		&models.BibRecord{},
+		&models.BibAuthor{},
+		&models.EnrichmentJob{},
+		&models.ShelfLocation{},
+		&models.Transfer{},
+		&models.SchemaMigration{},
 	)
 	if err != nil {
-		log.Fatalf("Failed to migrate database: %v", err)
-		return nil, err
+		slog.Error("failed to migrate database", "error", err)
+		return err
 	}
 
-	DB = database
-	log.Println("Database connected and migrated successfully!")
-	return DB, nil
+	var applied uint
+	if err := database.Model(&models.SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&applied).Error; err != nil {
+		slog.Error("failed to read schema version", "error", err)
+		return err
+	}
+	for _, migration := range migrations {
+		if migration.version <= applied {
+			continue
+		}
+		err := database.Transaction(func(tx *gorm.DB) error {
+			if migration.apply != nil {
+				if err := migration.apply(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SchemaMigration{
+				Version:   migration.version,
+				Name:      migration.name,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			slog.Error("failed to apply schema migration", "version", migration.version, "error", err)
+			return err
+		}
+		slog.Info("applied schema migration", "version", migration.version, "name", migration.name)
+	}
+	return nil
 }
@@ -67,3 +67,10 @@ func TestConnectDatabaseMigrations(t *testing.T) {
 	assert.NotNil(t, DB)
 
 }
+
+func TestMigrationsEndAtSchemaVersion(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		assert.Greater(t, migrations[i].version, migrations[i-1].version, migrations[i].name)
+	}
+	assert.Equal(t, SchemaVersion, migrations[len(migrations)-1].version)
+}
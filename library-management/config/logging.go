@@ -0,0 +1,20 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// SetupLogger installs a JSON slog logger as the default. LOG_LEVEL picks the
+// minimum level (debug, info, warn, error); info when unset.
+func SetupLogger() *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(os.Getenv("LOG_LEVEL")))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	return logger
+}
@@ -0,0 +1,466 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"library-management/apierror"
+	"library-management/media"
+	"library-management/middleware"
+	"library-management/models"
+	"library-management/storage"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxCoverUpload bounds a cover image
+	maxCoverUpload = 5 << 20
+	// maxAttachmentUpload bounds a supplementary file
+	maxAttachmentUpload = 20 << 20
+	// thumbnailSize is the longest side of generated thumbnails, in pixels
+	thumbnailSize = 300
+)
+
+// attachmentView is an attachment as clients see it, with the URLs its files
+// are served from
+type attachmentView struct {
+	models.Attachment
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+}
+
+func viewAttachment(store storage.Store, attachment models.Attachment) attachmentView {
+	view := attachmentView{Attachment: attachment, URL: store.URL(attachment.Key)}
+	if attachment.ThumbnailKey != "" {
+		view.ThumbnailURL = store.URL(attachment.ThumbnailKey)
+	}
+	return view
+}
+
+// upload is a checked file from a multipart "file" field
+type upload struct {
+	data      []byte
+	typ       media.Type
+	name      string
+	thumbnail []byte // JPEG, images only
+	width     int
+	height    int
+}
+
+// readUpload reads the "file" field of a multipart request, sniffs its type and
+// makes a thumbnail of images. Covers must be images. It writes the error
+// response and returns false when the file is missing, too large or of a type
+// the kind does not accept.
+func readUpload(c *gin.Context, kind string) (upload, bool) {
+	limit := int64(maxAttachmentUpload)
+	if kind == models.AttachmentCover {
+		limit = maxCoverUpload
+	}
+
+	// Leave room for the multipart headers around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit+64<<10)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Respond(c, apierror.CodeUploadTooLarge, fmt.Sprintf("The %s must be at most %d MB", kind, limit>>20))
+		} else {
+			apierror.Respond(c, apierror.CodeMalformedRequest, "Send the file as multipart/form-data in a field named file")
+		}
+		return upload{}, false
+	}
+	if header.Size > limit {
+		apierror.Respond(c, apierror.CodeUploadTooLarge, fmt.Sprintf("The %s must be at most %d MB", kind, limit>>20))
+		return upload{}, false
+	}
+	if header.Size == 0 {
+		apierror.Respond(c, apierror.CodeValidationFailed, "The file is empty")
+		return upload{}, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		apierror.Respond(c, apierror.CodeMalformedRequest, "Could not read the uploaded file")
+		return upload{}, false
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		apierror.Respond(c, apierror.CodeMalformedRequest, "Could not read the uploaded file")
+		return upload{}, false
+	}
+
+	typ, ok := media.Sniff(data[:min(len(data), 512)])
+	if !ok || (kind == models.AttachmentCover && !typ.Image) {
+		accepted := "JPEG, PNG, GIF, PDF, ZIP or plain text"
+		if kind == models.AttachmentCover {
+			accepted = "JPEG, PNG or GIF"
+		}
+		apierror.Respond(c, apierror.CodeUnsupportedMediaType, "The "+kind+" must be "+accepted)
+		return upload{}, false
+	}
+
+	result := upload{data: data, typ: typ, name: cleanFileName(header.Filename, typ.Extension)}
+	if typ.Image {
+		result.thumbnail, result.width, result.height, err = media.Thumbnail(bytes.NewReader(data), thumbnailSize)
+		if errors.Is(err, media.ErrTooManyPixels) {
+			apierror.Respond(c, apierror.CodeUploadTooLarge, fmt.Sprintf("Images must be at most %d megapixels", media.MaxPixels/1_000_000))
+			return upload{}, false
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeUnsupportedMediaType, "The image could not be decoded")
+			return upload{}, false
+		}
+	}
+	return result, true
+}
+
+// cleanFileName keeps the base name a client sent, for downloads only. Files
+// are stored under generated keys, never under this name.
+func cleanFileName(name, extension string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	if name == "" {
+		name = "file" + extension
+	}
+	return name
+}
+
+// storeUpload puts an upload and its thumbnail under new keys and fills in the
+// attachment's file fields. Nothing is left in storage when it fails.
+func storeUpload(ctx context.Context, store storage.Store, file upload, attachment *models.Attachment) error {
+	key := storage.NewKey(file.typ.Extension)
+	if err := store.Put(ctx, key, file.typ.ContentType, bytes.NewReader(file.data), int64(len(file.data))); err != nil {
+		return err
+	}
+	thumbnailKey := ""
+	if file.thumbnail != nil {
+		thumbnailKey = storage.NewKey(".jpg")
+		if err := store.Put(ctx, thumbnailKey, "image/jpeg", bytes.NewReader(file.thumbnail), int64(len(file.thumbnail))); err != nil {
+			_ = store.Delete(ctx, key)
+			return err
+		}
+	}
+
+	attachment.Key = key
+	attachment.ThumbnailKey = thumbnailKey
+	attachment.FileName = file.name
+	attachment.ContentType = file.typ.ContentType
+	attachment.Size = int64(len(file.data))
+	attachment.Width, attachment.Height = file.width, file.height
+	return nil
+}
+
+// deleteFiles removes an attachment's files once its row no longer points at
+// them. Failures only leave unreachable files behind, so they are logged.
+func deleteFiles(c *gin.Context, store storage.Store, attachment models.Attachment) {
+	for _, key := range []string{attachment.Key, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := store.Delete(c.Request.Context(), key); err != nil {
+			middleware.Logger(c).Error("stored file not deleted", "key", key, "attachment_id", attachment.ID, "error", err)
+		}
+	}
+}
+
+// saveAttachment stores an upload as attachment, inserting it or replacing the
+// files of an existing one, and then deletes the files it replaced. audit runs
+// in the transaction saving the row, once attachment holds the new files.
+func saveAttachment(db *gorm.DB, c *gin.Context, store storage.Store, file upload, attachment *models.Attachment, audit func(tx *gorm.DB) error) error {
+	previous := *attachment
+	if err := storeUpload(c.Request.Context(), store, file, attachment); err != nil {
+		return err
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(attachment).Error; err != nil {
+			return err
+		}
+		return audit(tx)
+	})
+	if err != nil {
+		deleteFiles(c, store, *attachment)
+		return err
+	}
+	if previous.ID != 0 {
+		deleteFiles(c, store, previous)
+	}
+	return nil
+}
+
+// libraryBookForAdmin finds the ?library_id= library's copy of the :isbn book,
+// checking the calling admin runs that library. It writes the error response
+// and returns false when it cannot.
+func libraryBookForAdmin(db *gorm.DB, c *gin.Context) (models.Book, bool) {
+	libraryID, err := strconv.ParseUint(c.Query("library_id"), 10, 64)
+	if err != nil || libraryID == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "library_id must be a positive number")
+		return models.Book{}, false
+	}
+
+	var admin models.UserLibrary
+	if err := db.Where("user_id = ? AND library_id = ?", c.GetUint("userID"), libraryID).First(&admin).Error; err != nil {
+		apierror.Respond(c, apierror.CodeLibraryAccess, "You are not assigned as an admin for this library")
+		return models.Book{}, false
+	}
+
+	var book models.Book
+	if err := db.Where("isbn = ? AND library_id = ?", c.Param("isbn"), libraryID).First(&book).Error; err != nil {
+		apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in the specified library")
+		return models.Book{}, false
+	}
+	return book, true
+}
+
+// ListAttachments lists a library's cover and files for a book - Only Admin
+func ListAttachments(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+
+		var attachments []models.Attachment
+		if err := db.Where("book_id = ?", book.ID).Order("kind ASC, id ASC").Find(&attachments).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch attachments")
+			return
+		}
+		views := make([]attachmentView, len(attachments))
+		for i, attachment := range attachments {
+			views[i] = viewAttachment(store, attachment)
+		}
+		c.JSON(http.StatusOK, gin.H{"attachments": views})
+	}
+}
+
+// PutCover sets or replaces a book's cover image, made into a thumbnail as
+// well - Only Admin
+func PutCover(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+		file, ok := readUpload(c, models.AttachmentCover)
+		if !ok {
+			return
+		}
+
+		cover := models.Attachment{BookID: book.ID, Kind: models.AttachmentCover}
+		err := db.Where("book_id = ? AND kind = ?", book.ID, models.AttachmentCover).First(&cover).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch the current cover")
+			return
+		}
+		before := cover
+		status, action := http.StatusOK, "book.cover_replace"
+		var previous interface{} = viewAttachment(store, before)
+		if before.ID == 0 {
+			status, action, previous = http.StatusCreated, "book.cover_set", nil
+		}
+		err = saveAttachment(db, c, store, file, &cover, func(tx *gorm.DB) error {
+			return recordAudit(tx, c, action, "book", book.ISBN, book.LibraryID, previous, viewAttachment(store, cover))
+		})
+		if err != nil {
+			middleware.Logger(c).Error("cover upload failed", "book_id", book.ID, "error", err)
+			apierror.Respond(c, apierror.CodeInternal, "Could not save the cover")
+			return
+		}
+
+		c.JSON(status, gin.H{"message": "Cover saved", "attachment": viewAttachment(store, cover)})
+	}
+}
+
+// DeleteCover removes a book's cover image and its thumbnail - Only Admin
+func DeleteCover(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+
+		var cover models.Attachment
+		if err := db.Where("book_id = ? AND kind = ?", book.ID, models.AttachmentCover).First(&cover).Error; err != nil {
+			apierror.Respond(c, apierror.CodeAttachmentNotFound, "The book has no cover")
+			return
+		}
+		removeAttachment(db, c, store, book, cover, "book.cover_delete")
+	}
+}
+
+// AddAttachment uploads a supplementary file for a book, such as a table of
+// contents or sample chapter - Only Admin
+func AddAttachment(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+		file, ok := readUpload(c, models.AttachmentFile)
+		if !ok {
+			return
+		}
+
+		attachment := models.Attachment{BookID: book.ID, Kind: models.AttachmentFile}
+		err := saveAttachment(db, c, store, file, &attachment, func(tx *gorm.DB) error {
+			return recordAudit(tx, c, "book.attachment_add", "book", book.ISBN, book.LibraryID, nil, viewAttachment(store, attachment))
+		})
+		if err != nil {
+			middleware.Logger(c).Error("attachment upload failed", "book_id", book.ID, "error", err)
+			apierror.Respond(c, apierror.CodeInternal, "Could not save the attachment")
+			return
+		}
+
+		view := viewAttachment(store, attachment)
+		c.JSON(http.StatusCreated, gin.H{"message": "Attachment added", "attachment": view})
+	}
+}
+
+// ReplaceAttachment uploads a new version of a supplementary file. It gets a new
+// URL, the old one stops working. - Only Admin
+func ReplaceAttachment(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+		attachment, ok := bookAttachment(db, c, book)
+		if !ok {
+			return
+		}
+		file, ok := readUpload(c, models.AttachmentFile)
+		if !ok {
+			return
+		}
+
+		before := attachment
+		err := saveAttachment(db, c, store, file, &attachment, func(tx *gorm.DB) error {
+			return recordAudit(tx, c, "book.attachment_replace", "book", book.ISBN, book.LibraryID, viewAttachment(store, before), viewAttachment(store, attachment))
+		})
+		if err != nil {
+			middleware.Logger(c).Error("attachment upload failed", "attachment_id", attachment.ID, "error", err)
+			apierror.Respond(c, apierror.CodeInternal, "Could not save the attachment")
+			return
+		}
+
+		view := viewAttachment(store, attachment)
+		c.JSON(http.StatusOK, gin.H{"message": "Attachment replaced", "attachment": view})
+	}
+}
+
+// DeleteAttachment removes a supplementary file - Only Admin
+func DeleteAttachment(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+		attachment, ok := bookAttachment(db, c, book)
+		if !ok {
+			return
+		}
+		removeAttachment(db, c, store, book, attachment, "book.attachment_delete")
+	}
+}
+
+// bookAttachment finds the :id file of a book; covers have their own routes
+func bookAttachment(db *gorm.DB, c *gin.Context, book models.Book) (models.Attachment, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Attachment id must be a positive number")
+		return models.Attachment{}, false
+	}
+	var attachment models.Attachment
+	if err := db.Where("id = ? AND book_id = ? AND kind = ?", id, book.ID, models.AttachmentFile).First(&attachment).Error; err != nil {
+		apierror.Respond(c, apierror.CodeAttachmentNotFound, "Attachment not found for this book")
+		return models.Attachment{}, false
+	}
+	return attachment, true
+}
+
+// removeAttachment deletes an attachment's row, then its files
+func removeAttachment(db *gorm.DB, c *gin.Context, store storage.Store, book models.Book, attachment models.Attachment, action string) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&attachment).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, action, "book", book.ISBN, book.LibraryID, viewAttachment(store, attachment), nil)
+	})
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not delete the attachment")
+		return
+	}
+	deleteFiles(c, store, attachment)
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment deleted"})
+}
+
+// ServeFile sends a stored file or thumbnail. Keys are random and never reused,
+// so responses are cached for a year. Files other than images are downloaded
+// rather than shown, and browsers are told not to second-guess their type.
+func ServeFile(db *gorm.DB, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		key := c.Param("key")
+		if !storage.ValidKey(key) {
+			apierror.Respond(c, apierror.CodeNotFound, "File not found")
+			return
+		}
+		var attachment models.Attachment
+		if err := db.Where("key = ? OR thumbnail_key = ?", key, key).First(&attachment).Error; err != nil {
+			apierror.Respond(c, apierror.CodeNotFound, "File not found")
+			return
+		}
+
+		file, err := store.Open(c.Request.Context(), key)
+		if errors.Is(err, storage.ErrNotFound) {
+			apierror.Respond(c, apierror.CodeNotFound, "File not found")
+			return
+		}
+		if err != nil {
+			middleware.Logger(c).Error("stored file not readable", "key", key, "error", err)
+			apierror.Respond(c, apierror.CodeInternal, "Could not read the file")
+			return
+		}
+		defer file.Close()
+
+		contentType, size := attachment.ContentType, attachment.Size
+		headers := map[string]string{
+			"Cache-Control":          "public, max-age=31536000, immutable",
+			"X-Content-Type-Options": "nosniff",
+		}
+		if key == attachment.ThumbnailKey {
+			contentType, size = "image/jpeg", -1
+		} else if !strings.HasPrefix(contentType, "image/") {
+			headers["Content-Disposition"] = fmt.Sprintf("attachment; filename=%q", attachment.FileName)
+		}
+		c.DataFromReader(http.StatusOK, size, contentType, file, headers)
+	}
+}
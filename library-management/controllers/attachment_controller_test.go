@@ -0,0 +1,169 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"testing"
+
+	"library-management/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAttachments(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	store := storage.NewLocal(dir, "/files/")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+	})
+	r.PUT("/book/:isbn/cover", PutCover(gormDB, store))
+	r.DELETE("/book/:isbn/attachments/:id", DeleteAttachment(gormDB, store))
+	r.GET("/files/:key", ServeFile(gormDB, store))
+
+	upload := func(target, name string, content []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		part, err := form.CreateFormFile("file", name)
+		require.NoError(t, err)
+		part.Write(content)
+		require.NoError(t, form.Close())
+
+		req := httptest.NewRequest(http.MethodPut, target, &body)
+		req.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectBook := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("111", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id"}).AddRow(5, "111", 2))
+	}
+
+	var cover bytes.Buffer
+	require.NoError(t, png.Encode(&cover, image.NewRGBA(image.Rect(0, 0, 600, 900))))
+
+	t.Run("Set Cover", func(t *testing.T) {
+		expectBook()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "attachments" WHERE book_id = $1 AND kind = $2`)).
+			WithArgs(5, "cover", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "attachments"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := upload("/book/111/cover?library_id=2", "front.png", cover.Bytes())
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var response struct {
+			Attachment attachmentView `json:"attachment"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "image/png", response.Attachment.ContentType)
+		assert.Equal(t, 600, response.Attachment.Width)
+		assert.Regexp(t, `^/files/[0-9a-f]{32}\.png$`, response.Attachment.URL)
+		assert.Regexp(t, `^/files/[0-9a-f]{32}\.jpg$`, response.Attachment.ThumbnailURL)
+
+		files, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, 2, "the cover and its thumbnail")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cover Must Be An Image", func(t *testing.T) {
+		expectBook()
+		w := upload("/book/111/cover?library_id=2", "cover.pdf", []byte("%PDF-1.7\n"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), "unsupported_media_type")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("HTML Disguised As An Image", func(t *testing.T) {
+		expectBook()
+		w := upload("/book/111/cover?library_id=2", "cover.png", []byte("<html><script>alert(1)</script></html>"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cover Too Large", func(t *testing.T) {
+		expectBook()
+		w := upload("/book/111/cover?library_id=2", "huge.png", make([]byte, maxCoverUpload+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "upload_too_large")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Missing Library", func(t *testing.T) {
+		w := upload("/book/111/cover", "front.png", cover.Bytes())
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "library_id")
+	})
+
+	t.Run("Serve File", func(t *testing.T) {
+		key := storage.NewKey(".pdf")
+		require.NoError(t, store.Put(context.Background(), key, "application/pdf", bytes.NewReader([]byte("%PDF-1.7\n")), 9))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "attachments" WHERE key = $1 OR thumbnail_key = $2`)).
+			WithArgs(key, key, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "key", "file_name", "content_type", "size"}).
+				AddRow(4, key, "contents.pdf", "application/pdf", 9))
+
+		req := httptest.NewRequest(http.MethodGet, "/files/"+key, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="contents.pdf"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		body, _ := io.ReadAll(w.Body)
+		assert.Equal(t, "%PDF-1.7\n", string(body))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown File", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/files/..%2Fsecret", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Delete Unknown Attachment", func(t *testing.T) {
+		expectBook()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "attachments" WHERE id = $1 AND book_id = $2 AND kind = $3`)).
+			WithArgs(8, 5, "file", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		req := httptest.NewRequest(http.MethodDelete, "/book/111/attachments/8?library_id=2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "attachment_not_found")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"library-management/apierror"
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Fields that never belong in the audit trail, or only as a "changed" marker
+var (
+	auditIgnoredFields  = map[string]bool{"CreatedAt": true, "UpdatedAt": true, "Library": true}
+	auditRedactedFields = map[string]bool{"Password": true}
+)
+
+// recordAudit appends an entry to the audit log. before and after are snapshots of
+// the entity, nil for creations and deletions; only the fields that differ are kept.
+// Call it with the transaction applying the change, so the change is only kept
+// along with its entry.
+func recordAudit(tx *gorm.DB, c *gin.Context, action, entityType string, entityID interface{}, libraryID uint, before, after interface{}) error {
+	changes, err := auditDiff(before, after)
+	if err != nil {
+		return fmt.Errorf("audit diff for %s: %w", action, err)
+	}
+
+	entry := models.AuditLog{
+		ActorID:    c.GetUint("userID"),
+		ActorRole:  c.GetString("userRole"),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   fmt.Sprint(entityID),
+		Changes:    changes,
+		IP:         c.ClientIP(),
+		RequestID:  middleware.RequestIDFromContext(c.Request.Context()),
+	}
+	if libraryID != 0 {
+		entry.LibraryID = &libraryID
+	}
+	return tx.Create(&entry).Error
+}
+
+// auditDiff flattens two snapshots into {"field": {"before": x, "after": y}} for every
+// field whose value differs
+func auditDiff(before, after interface{}) (json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]map[string]interface{}{}
+	for field := range mergeKeys(beforeFields, afterFields) {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		oldValue, newValue := beforeFields[field], afterFields[field]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if auditRedactedFields[field] {
+			oldValue, newValue = "[redacted]", "[redacted]"
+		}
+		changes[field] = map[string]interface{}{"before": redactNested(oldValue), "after": redactNested(newValue)}
+	}
+
+	return json.Marshal(changes)
+}
+
+func auditFields(snapshot interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if snapshot == nil {
+		return fields, nil
+	}
+	raw, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// redactNested masks sensitive fields inside nested snapshots
+func redactNested(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if auditRedactedFields[key] {
+				v[key] = "[redacted]"
+			} else {
+				v[key] = redactNested(nested)
+			}
+		}
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = redactNested(nested)
+		}
+	}
+	return value
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+// ListAuditLogs lists audit entries, newest first - Owner and Admin.
+// Admins only see entries for the libraries they manage.
+func ListAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		query, ok := auditQuery(db, c)
+		if !ok {
+			return
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Page must be a positive number")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 500 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Limit must be between 1 and 500")
+			return
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch audit log")
+			return
+		}
+
+		var entries []models.AuditLog
+		if err := query.Order("id DESC").Offset((page - 1) * limit).Limit(limit).Find(&entries).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch audit log")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"entries": entries, "page": page, "limit": limit, "total": total})
+	}
+}
+
+// ExportAuditLogs streams every matching audit entry as JSON Lines, oldest first - Owner and Admin
+func ExportAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		query, ok := auditQuery(db, c)
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-%s.jsonl"`, time.Now().Format("20060102-150405")))
+		c.Status(http.StatusOK)
+
+		encoder := json.NewEncoder(c.Writer)
+		var batch []models.AuditLog
+		err := query.FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, entry := range batch {
+				if err := encoder.Encode(entry); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		}).Error
+		if err != nil {
+			// Headers are already sent, all we can do is stop and log
+			middleware.Logger(c).Error("audit export aborted", "error", err)
+		}
+	}
+}
+
+// auditQuery builds the filtered audit log query shared by listing and export
+func auditQuery(db *gorm.DB, c *gin.Context) (*gorm.DB, bool) {
+	query := db.Model(&models.AuditLog{})
+
+	for _, column := range []string{"actor_id", "action", "entity_type", "entity_id", "library_id"} {
+		if value := c.Query(column); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	if from := c.Query("from"); from != "" {
+		day, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "from must be a date in YYYY-MM-DD format")
+			return nil, false
+		}
+		query = query.Where("created_at >= ?", day)
+	}
+	if to := c.Query("to"); to != "" {
+		day, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "to must be a date in YYYY-MM-DD format")
+			return nil, false
+		}
+		// "to" covers the whole day
+		query = query.Where("created_at < ?", day.AddDate(0, 0, 1))
+	}
+
+	if c.GetString("userRole") == "admin" {
+		query = query.Where("library_id IN (?)", db.Table("user_libraries").Select("library_id").Where("user_id = ?", c.GetUint("userID")))
+	}
+
+	return query, true
+}
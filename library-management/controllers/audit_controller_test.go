@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAuditDiff(t *testing.T) {
+	before := models.User{ID: 3, Name: "Old Name", Email: "reader@example.com", Password: "password123"}
+	after := before
+	after.Name = "New Name"
+	after.Password = "newpassword456"
+
+	raw, err := auditDiff(before, after)
+	assert.NoError(t, err)
+
+	var changes map[string]map[string]interface{}
+	assert.NoError(t, json.Unmarshal(raw, &changes))
+
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "Old Name", changes["Name"]["before"])
+	assert.Equal(t, "New Name", changes["Name"]["after"])
+	assert.Equal(t, "[redacted]", changes["Password"]["before"])
+	assert.NotContains(t, string(raw), "password123")
+	assert.NotContains(t, string(raw), "newpassword456")
+
+	t.Run("Creation Redacts Nested Passwords", func(t *testing.T) {
+		raw, err := auditDiff(nil, gin.H{"User": before})
+		assert.NoError(t, err)
+		assert.Contains(t, string(raw), "Old Name")
+		assert.NotContains(t, string(raw), "password123")
+	})
+}
+
+func TestListAuditLogs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/audit", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", "admin")
+		ListAuditLogs(gormDB)(c)
+	})
+
+	t.Run("Admin Sees Own Libraries", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "audit_logs" WHERE action = $1 AND library_id IN (SELECT library_id FROM "user_libraries" WHERE user_id = $2)`)).
+			WithArgs("book.update", 2).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "audit_logs" WHERE action = $1 AND library_id IN (SELECT library_id FROM "user_libraries" WHERE user_id = $2) ORDER BY id DESC LIMIT $3`)).
+			WithArgs("book.update", 2, 50).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "action", "entity_type", "entity_id", "changes"}).
+				AddRow(7, 2, "book.update", "book", "123456789", []byte(`{"Title":{"before":"Old","after":"New"}}`)))
+
+		req := httptest.NewRequest(http.MethodGet, "/audit?action=book.update", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"entity_id":"123456789"`)
+		assert.Contains(t, w.Body.String(), `"total":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Date", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/audit?from=yesterday", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "YYYY-MM-DD")
+	})
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	// The hooks refuse before any statement reaches the database
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	entry := models.AuditLog{ID: 1}
+	assert.ErrorIs(t, gormDB.Model(&entry).Update("action", "tampered").Error, models.ErrAuditLogAppendOnly)
+	assert.ErrorIs(t, gormDB.Delete(&entry).Error, models.ErrAuditLogAppendOnly)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
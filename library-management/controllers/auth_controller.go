@@ -1,6 +1,8 @@
 package controllers
 
 import (
+	"library-management/apierror"
+	"library-management/metrics"
 	"library-management/models"
 	"library-management/utils"
 	"net/http"
@@ -11,6 +13,8 @@ import (
 
 func Login(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var input struct {
 			Email    string `json:"email" binding:"required"`
 			Password string `json:"password" binding:"required"`
@@ -18,30 +22,38 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 
 		// Validation of the incoming JSON-data
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
 			return
 		}
 
 		var user models.User
 		if err := db.Where("email = ? AND deleted_at IS NULL", input.Email).First(&user).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				metrics.LoginFailures.WithLabelValues("unknown_email").Inc()
+				apierror.Respond(c, apierror.CodeInvalidCredentials, "Invalid credentials")
 			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				apierror.Respond(c, apierror.CodeInternal, "Database error")
 			}
 			return
 		}
 
 		// Compare passwords
 		if input.Password != user.Password {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			metrics.LoginFailures.WithLabelValues("wrong_password").Inc()
+			apierror.Respond(c, apierror.CodeInvalidCredentials, "Invalid credentials")
+			return
+		}
+
+		if user.Status == "deactivated" {
+			metrics.LoginFailures.WithLabelValues("deactivated").Inc()
+			apierror.Respond(c, apierror.CodeAccountDeactivated, "Account is deactivated")
 			return
 		}
 
 		// JWT token generation
 		token, err := utils.GenerateJWT(user.ID, user.Role)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+			apierror.Respond(c, apierror.CodeInternal, "Could not generate token")
 			return
 		}
 
@@ -56,7 +68,7 @@ func Login(db *gorm.DB) gin.HandlerFunc {
 			// Load user's libraries
 			var libraries []models.Library
 			if err := db.Model(&user).Association("Library").Find(&libraries); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching libraries"})
+				apierror.Respond(c, apierror.CodeInternal, "Error fetching libraries")
 				return
 			}
 
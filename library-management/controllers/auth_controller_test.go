@@ -41,6 +41,13 @@ func TestLogin(t *testing.T) {
 		Role:     "admin",
 	}
 
+	// Admins get the libraries they run with their token
+	expectLibraries := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`FROM "libraries" JOIN "user_libraries" ON "user_libraries"."library_id" = "libraries"."id" AND "user_libraries"."user_id" = $1`)).
+			WithArgs(mockUser.ID).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Central"))
+	}
+
 	// Define test cases
 	tests := []struct {
 		name           string
@@ -57,6 +64,7 @@ func TestLogin(t *testing.T) {
 					WithArgs(mockUser.Email, 1).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
 						AddRow(mockUser.ID, mockUser.Email, mockUser.Password, mockUser.Role))
+				expectLibraries()
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  "",
@@ -89,7 +97,7 @@ func TestLogin(t *testing.T) {
 			input:          `{}`,
 			mockQuery:      func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  "Field validation for 'Email' failed",
+			expectedError:  `{"field":"email","rule":"required"`,
 		},
 		{
 			name:  "Database error",
@@ -108,21 +116,21 @@ func TestLogin(t *testing.T) {
 			input:          `{"password": "password123"}`,
 			mockQuery:      func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  "Field validation for 'Email' failed",
+			expectedError:  `{"field":"email","rule":"required"`,
 		},
 		{
 			name:           "Missing password field",
 			input:          `{"email": "testuser@example.com"}`,
 			mockQuery:      func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  "Field validation for 'Password' failed",
+			expectedError:  `{"field":"password","rule":"required"`,
 		},
 		{
 			name:           "Empty password",
 			input:          `{"email": "testuser@example.com", "password": ""}`,
 			mockQuery:      func() {},
 			expectedStatus: http.StatusBadRequest,
-			expectedError:  "Field validation for 'Password' failed",
+			expectedError:  `{"field":"password","rule":"required"`,
 		},
 
 		{
@@ -134,6 +142,7 @@ func TestLogin(t *testing.T) {
 					WillDelayFor(2 * time.Second).
 					WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).
 						AddRow(mockUser.ID, mockUser.Email, mockUser.Password, mockUser.Role))
+				expectLibraries()
 			},
 			expectedStatus: http.StatusOK,
 			expectedError:  "",
@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"library-management/apierror"
+	"library-management/authority"
+	"library-management/listquery"
+	"library-management/models"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// authorityListing is what the author, publisher and subject listings accept
+var authorityListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"name": "name", "id": "id"},
+	DefaultSort:  "name",
+	TieBreaker:   "id",
+	Filters: map[string]listquery.Filter{
+		"name": {Column: "name", Op: listquery.Contains},
+	},
+}
+
+// authorityBookListing is what the books of an author, publisher or subject accept
+var authorityBookListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: map[string]string{
+		"title":            "title",
+		"isbn":             "isbn",
+		"available_copies": "available_copies",
+	},
+	DefaultSort: "title",
+	TieBreaker:  "id",
+	Filters: map[string]listquery.Filter{
+		"library_id": {Column: "library_id", Kind: listquery.Int},
+	},
+}
+
+// ListAuthors lists authors by name
+func ListAuthors(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorities[models.Author](db, "authors")
+}
+
+// ListPublishers lists publishers by name
+func ListPublishers(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorities[models.Publisher](db, "publishers")
+}
+
+// ListSubjects lists subjects by name
+func ListSubjects(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorities[models.Subject](db, "subjects")
+}
+
+// ListAuthorBooks lists an author's books in the caller's libraries, optionally
+// only those credited in ?role=
+func ListAuthorBooks(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorityBooks(db, authority.Authors, "Author")
+}
+
+// ListPublisherBooks lists a publisher's books in the caller's libraries
+func ListPublisherBooks(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorityBooks(db, authority.Publishers, "Publisher")
+}
+
+// ListSubjectBooks lists the books on a subject in the caller's libraries
+func ListSubjectBooks(db *gorm.DB) gin.HandlerFunc {
+	return listAuthorityBooks(db, authority.Subjects, "Subject")
+}
+
+func listAuthorities[T any](db *gorm.DB, key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		params, ok := listquery.Parse(c, authorityListing)
+		if !ok {
+			return
+		}
+
+		var records []T
+		result, err := params.Find(db.Model(new(T)), &records)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch "+key)
+			return
+		}
+
+		c.JSON(http.StatusOK, params.Envelope(key, records, result))
+	}
+}
+
+// listAuthorityBooks lists the books linked to one record of kind. Owners see
+// every library, admins the libraries they run and readers their approved
+// memberships.
+func listAuthorityBooks(db *gorm.DB, kind authority.Kind, noun string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || id == 0 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, noun+" id must be a positive number")
+			return
+		}
+
+		params, ok := listquery.Parse(c, authorityBookListing)
+		if !ok {
+			return
+		}
+
+		linked := db.Table(kind.Link).Select("book_id").Where(kind.Column+" = ?", id)
+		if role := c.Query("role"); role != "" && kind == authority.Authors {
+			if !slices.Contains(authority.Roles, role) {
+				apierror.Respond(c, apierror.CodeInvalidParameter, "role must be one of "+strings.Join(authority.Roles, ", "))
+				return
+			}
+			linked = linked.Where("role = ?", role)
+		}
+
+		var found int64
+		if err := db.Table(kind.Table).Where("id = ?", id).Count(&found).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch "+strings.ToLower(noun))
+			return
+		}
+		if found == 0 {
+			apierror.Respond(c, apierror.CodeNotFound, noun+" not found")
+			return
+		}
+
+		query := db.Model(&models.Book{}).Where("id IN (?)", linked).
+			Select("id, isbn, title, authors, publisher, subjects, available_copies, library_id")
+		if c.GetString("userRole") != "owner" {
+			libraryIDs, err := accessibleLibraries(db, c)
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Could not fetch user libraries")
+				return
+			}
+			if len(libraryIDs) == 0 {
+				c.JSON(http.StatusOK, params.Envelope("books", []gin.H{}, listquery.Result{}))
+				return
+			}
+			query = query.Where("library_id IN (?)", libraryIDs)
+		}
+
+		var books []models.Book
+		result, err := params.Find(query, &books)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch books")
+			return
+		}
+
+		formattedBooks := make([]gin.H, len(books))
+		for i, book := range books {
+			formattedBooks[i] = gin.H{
+				"isbn":             book.ISBN,
+				"title":            book.Title,
+				"authors":          book.Authors,
+				"publisher":        book.Publisher,
+				"subjects":         book.Subjects,
+				"available_copies": book.AvailableCopies,
+				"library_id":       book.LibraryID,
+			}
+		}
+
+		c.JSON(http.StatusOK, params.Envelope("books", formattedBooks, result))
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListAuthors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/authors", ListAuthors(gormDB))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "authors" WHERE name ILIKE $1`)).
+		WithArgs("%rowling%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "authors" WHERE name ILIKE $1 ORDER BY name ASC,id ASC`)).
+		WithArgs("%rowling%", 51).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "key"}).AddRow(3, "J. K. Rowling", "jk rowling"))
+
+	req := httptest.NewRequest(http.MethodGet, "/authors?name=rowling", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"authors":[{"id":3,"name":"J. K. Rowling"}]`)
+	assert.NotContains(t, w.Body.String(), "jk rowling")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAuthorBooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/authors/:id/books", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "user")
+		ListAuthorBooks(gormDB)(c)
+	})
+
+	expectAuthor := func(count int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "authors" WHERE id = $1`)).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+
+	t.Run("Books In Approved Libraries", func(t *testing.T) {
+		expectAuthor(1)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(1, "Approved").
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+
+		linked := `id IN (SELECT book_id FROM "book_authors" WHERE author_id = $1 AND role = $2) AND library_id IN ($3)`
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE `+linked)).
+			WithArgs(3, "illustrator", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isbn, title, authors, publisher, subjects, available_copies, library_id FROM "books" WHERE `+linked)).
+			WithArgs(3, "illustrator", 1, 51).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "publisher", "subjects", "available_copies", "library_id"}).
+				AddRow(5, "123", "The BFG", "Roald Dahl; Quentin Blake (ill.)", "Jonathan Cape", "Giants", 1, 1))
+
+		req := httptest.NewRequest(http.MethodGet, "/authors/3/books?role=illustrator", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"The BFG"`)
+		assert.Contains(t, w.Body.String(), `"subjects":"Giants"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Author", func(t *testing.T) {
+		expectAuthor(0)
+
+		req := httptest.NewRequest(http.MethodGet, "/authors/3/books", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"not_found"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Role", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/authors/3/books?role=narrator", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`)
+	})
+}
@@ -1,131 +1,467 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/apierror"
+	"library-management/authority"
+	"library-management/enrich"
+	"library-management/middleware"
 	"library-management/models"
+	"library-management/tracing"
+	"library-management/works"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-// AddBook adds a book or increments copies - Only Admin
-func AddBook(db *gorm.DB) gin.HandlerFunc {
+// AddBook adds a book or increments copies. With ?enrich=true only the ISBN is
+// needed: it is normalised to ISBN-13 and a new book's blank fields are filled
+// in from source - Only Admin
+func AddBook(db *gorm.DB, source enrich.Source) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var input models.Book
+		db := db.WithContext(c.Request.Context())
+
+		var body bookInput
 
 		// Extract user ID and role from JWT
 		userID, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		// Bind JSON input
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON input"})
+		if err := c.ShouldBindJSON(&body); err != nil {
+			apierror.Respond(c, apierror.CodeMalformedRequest, "Invalid JSON input")
 			return
 		}
+		input := body.book()
 
 		// Ensure user is an admin of the library
 		var admin models.UserLibrary
 		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You can only add books to libraries you manage"})
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You can only add books to libraries you manage")
 			return
 		}
 
 		// Ensure book has valid copies
 		if input.TotalCopies <= 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Number of copies must be greater than zero"})
+			apierror.Respond(c, apierror.CodeValidationFailed, "Number of copies must be greater than zero")
 			return
 		}
 
+		addByISBN := c.Query("enrich") == "true"
+		if addByISBN {
+			isbn, ok := enrich.NormalizeISBN(input.ISBN)
+			if !ok {
+				apierror.Respond(c, apierror.CodeValidationFailed, "ISBN must be a valid ISBN-10 or ISBN-13")
+				return
+			}
+			input.ISBN = isbn
+		}
+
 		// Check if book already exists in the library
 		var existingBook models.Book
 		if err := db.Where("isbn = ? AND library_id = ?", input.ISBN, input.LibraryID).First(&existingBook).Error; err == nil {
-			// Book already exists, update the total copies
-			existingBook.TotalCopies += input.TotalCopies
-			existingBook.AvailableCopies += input.TotalCopies
-
-			if err := db.Save(&existingBook).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book copies"})
+			// Book already exists, add to its counts in place so loans and
+			// transfers running meanwhile keep their changes
+			before := existingBook
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := moveCopies(tx, input.TotalCopies, "id = ?", existingBook.ID); err != nil {
+					return err
+				}
+				if err := tx.First(&existingBook, existingBook.ID).Error; err != nil {
+					return err
+				}
+				return recordAudit(tx, c, "book.add_copies", "book", existingBook.ISBN, existingBook.LibraryID, before, existingBook)
+			})
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Failed to update book copies")
 				return
 			}
 
+			c.Header("ETag", bookETag(existingBook))
 			c.JSON(http.StatusOK, gin.H{"message": "Book copies updated successfully", "book": existingBook})
 			return
 		}
 
+		// Typed fields win over the source's, which only fill the blanks
+		enrichedFrom := ""
+		if addByISBN {
+			metadata, err := enrich.Find(c.Request.Context(), source, input.ISBN)
+			switch {
+			case errors.Is(err, enrich.ErrNotFound):
+				if input.Title == "" {
+					apierror.Respond(c, apierror.CodeMetadataNotFound, "No source has a record for ISBN "+input.ISBN+", add it with a title")
+					return
+				}
+			case err != nil:
+				middleware.Logger(c).Error("isbn lookup failed", "isbn", input.ISBN, "source", source.Name(), "error", err)
+				apierror.Respond(c, apierror.CodeInternal, "Could not look the ISBN up")
+				return
+			default:
+				enrich.Apply(&input, metadata, false)
+				enrichedFrom = metadata.Source
+			}
+		}
+
+		// A call number and location may be given with a new book
+		if !shelve(db, c, &input, input.CallNumberScheme, input.CallNumber, input.LocationID) {
+			return
+		}
+
 		// New book Insert into DB
 		input.AvailableCopies = input.TotalCopies
-		if err := db.Create(&input).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not add book"})
+		if err := createBook(db, c, &input); err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not add book")
+			return
+		}
+
+		response := gin.H{"message": "Book added successfully", "book": input}
+		if enrichedFrom != "" {
+			response["enriched_from"] = enrichedFrom
+		}
+		c.Header("ETag", bookETag(input))
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// bookInput is the part of a book clients may set; the ID, work, revision,
+// available copies and shelf-order key are the server's. CallNumber,
+// CallNumberScheme and LocationID are only read when a book is added.
+type bookInput struct {
+	ISBN             string
+	Title            string
+	Authors          string
+	Publisher        string
+	Subjects         string
+	Version          string
+	TotalCopies      int
+	LibraryID        uint
+	CallNumber       string
+	CallNumberScheme string
+	LocationID       uint
+}
+
+// book copies the input onto a new book
+func (in bookInput) book() models.Book {
+	return models.Book{
+		ISBN:             in.ISBN,
+		Title:            in.Title,
+		Authors:          in.Authors,
+		Publisher:        in.Publisher,
+		Subjects:         in.Subjects,
+		Version:          in.Version,
+		TotalCopies:      in.TotalCopies,
+		LibraryID:        in.LibraryID,
+		CallNumber:       in.CallNumber,
+		CallNumberScheme: in.CallNumberScheme,
+		LocationID:       in.LocationID,
+	}
+}
+
+// createBook inserts a new book as an edition of a work, linked to its authors,
+// publishers and subjects, and records it in the audit log
+func createBook(db *gorm.DB, c *gin.Context, book *models.Book) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := works.Assign(tx, book); err != nil {
+			return err
+		}
+		if err := tx.Create(book).Error; err != nil {
+			return err
+		}
+		if err := authority.Link(tx, *book); err != nil {
+			return err
+		}
+		return recordAudit(tx, c, "book.create", "book", book.ISBN, book.LibraryID, nil, *book)
+	})
+}
+
+// GetLibraryBook returns one library's copy of a book with its ETag. A matching
+// If-None-Match gets 304 Not Modified - Only Admin
+func GetLibraryBook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		userID, exists := c.Get("userID")
+		if !exists {
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
+			return
+		}
+
+		libraryID, err := strconv.ParseUint(c.Query("library_id"), 10, 64)
+		if err != nil || libraryID == 0 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "library_id must be a positive number")
+			return
+		}
+
+		var admin models.UserLibrary
+		if err := db.Where("user_id = ? AND library_id = ?", userID, libraryID).First(&admin).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You are not assigned as an admin for this library")
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{"message": "Book added successfully", "book": input})
+		var book models.Book
+		if err := db.Where("isbn = ? AND library_id = ?", c.Param("isbn"), libraryID).First(&book).Error; err != nil {
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in the specified library")
+			return
+		}
+
+		if notModified(c, bookETag(book)) {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"book": book})
 	}
 }
 
-// UpdateBook updates book details - Only Admin
+// GetBook returns a book's metadata with copy counts, hold queue length and next
+// due date for every library the caller can access: all of them for owners, the
+// libraries they run for admins and approved memberships for readers. Owners and
+// admins also get each library's copy details and active loans.
+func GetBook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		isbn := c.Param("isbn")
+		role := c.GetString("userRole")
+		staff := role == "owner" || role == "admin"
+
+		query := db.Where("isbn = ?", isbn)
+		if role != "owner" {
+			accessible, err := accessibleLibraries(db, c)
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Could not fetch user libraries")
+				return
+			}
+			if len(accessible) == 0 {
+				apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in any library you can access")
+				return
+			}
+			query = query.Where("library_id IN (?)", accessible)
+		}
+
+		var copies []models.Book
+		if err := query.Order("library_id ASC").Find(&copies).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch book")
+			return
+		}
+		if len(copies) == 0 {
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in any library you can access")
+			return
+		}
+
+		libraryIDs := make([]uint, len(copies))
+		for i, book := range copies {
+			libraryIDs[i] = book.LibraryID
+		}
+		tracing.SetLibraryIDs(c.Request.Context(), libraryIDs)
+
+		// Issue requests not yet turned into a loan are the hold queue, holds on
+		// the work included since any of its editions fulfils them
+		var holds []struct {
+			LibraryID uint
+			Count     int
+		}
+		queued := db.Model(&models.RequestEvent{}).Select("library_id, count(*) AS count").
+			Where("library_id IN (?) AND request_type = ? AND status IN (?)", libraryIDs, "issue", []string{"Pending", "Approved"})
+		if workID := copies[0].WorkID; workID != 0 {
+			queued = queued.Where("(book_id = ? OR work_id = ?)", isbn, workID)
+		} else {
+			queued = queued.Where("book_id = ?", isbn)
+		}
+		if err := queued.Group("library_id").Scan(&holds).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch hold queue")
+			return
+		}
+		holdQueue := make(map[uint]int, len(holds))
+		for _, hold := range holds {
+			holdQueue[hold.LibraryID] = hold.Count
+		}
+
+		var loans []models.IssueRegistry
+		if err := db.Where("isbn = ? AND library_id IN (?) AND return_date = 0", isbn, libraryIDs).
+			Order("expected_return_date ASC").Find(&loans).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch loans")
+			return
+		}
+		activeLoans := make(map[uint][]models.IssueRegistry, len(copies))
+		for _, loan := range loans {
+			activeLoans[loan.LibraryID] = append(activeLoans[loan.LibraryID], loan)
+		}
+
+		locations, err := locationPaths(db, copies)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch locations")
+			return
+		}
+
+		now := time.Now().Unix()
+		totalCopies, availableCopies := 0, 0
+		libraries := make([]gin.H, len(copies))
+		for i, book := range copies {
+			totalCopies += book.TotalCopies
+			availableCopies += book.AvailableCopies
+
+			// Loans are sorted by due date, so the first one is due next
+			var nextDue *int64
+			if open := activeLoans[book.LibraryID]; len(open) > 0 {
+				nextDue = &open[0].ExpectedReturnDate
+			}
+			libraries[i] = gin.H{
+				"library_id":       book.LibraryID,
+				"total_copies":     book.TotalCopies,
+				"available_copies": book.AvailableCopies,
+				"hold_queue":       holdQueue[book.LibraryID],
+				"next_due_date":    formatUnixTime(nextDue),
+				"call_number":      book.CallNumber,
+				"location":         locations[book.LocationID],
+			}
+			if !staff {
+				continue
+			}
+
+			libraries[i]["copy"] = gin.H{
+				"id":         book.ID,
+				"version":    book.Version,
+				"revision":   book.Revision,
+				"etag":       bookETag(book),
+				"created_at": book.CreatedAt,
+				"updated_at": book.UpdatedAt,
+			}
+			formattedLoans := make([]gin.H, len(activeLoans[book.LibraryID]))
+			for j, loan := range activeLoans[book.LibraryID] {
+				formattedLoans[j] = gin.H{
+					"id":                   loan.ID,
+					"reader_id":            loan.ReaderID,
+					"issue_status":         loan.IssueStatus,
+					"issue_date":           formatUnixTime(&loan.IssueDate),
+					"expected_return_date": formatUnixTime(&loan.ExpectedReturnDate),
+					"overdue":              loan.ExpectedReturnDate < now,
+				}
+			}
+			libraries[i]["loans"] = formattedLoans
+		}
+
+		first := copies[0]
+		c.JSON(http.StatusOK, gin.H{
+			"book": gin.H{
+				"isbn":      first.ISBN,
+				"title":     first.Title,
+				"authors":   first.Authors,
+				"publisher": first.Publisher,
+			},
+			"total_copies":     totalCopies,
+			"available_copies": availableCopies,
+			"libraries":        libraries,
+		})
+	}
+}
+
+// accessibleLibraries lists the libraries an admin runs or a reader is an
+// approved member of. Owners can access every library and are not looked up.
+func accessibleLibraries(db *gorm.DB, c *gin.Context) ([]uint, error) {
+	memberships := db.Table("user_libraries").Where("user_id = ?", c.GetUint("userID"))
+	if c.GetString("userRole") != "admin" {
+		memberships = memberships.Where("status = ?", "Approved")
+	}
+	var libraryIDs []uint
+	err := memberships.Pluck("library_id", &libraryIDs).Error
+	return libraryIDs, err
+}
+
+// UpdateBook updates book details, honoring If-Match - Only Admin
 func UpdateBook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		isbn := c.Param("isbn")
-		var input models.Book
+		var input bookInput
 
 		userID, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
 			return
 		}
 
 		if input.LibraryID == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Library ID is required"})
+			apierror.Respond(c, apierror.CodeValidationFailed, "Library ID is required")
 			return
 		}
 
 		var admin models.UserLibrary
 		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You are not assigned as an admin for this library")
 			return
 		}
 
 		var book models.Book
 		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in the specified library")
+			return
+		}
+		if !checkIfMatch(c, book) {
 			return
 		}
 
 		issuedCopies := book.TotalCopies - book.AvailableCopies
 		if input.TotalCopies < issuedCopies {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Total copies cannot be less than issued copies"})
+			apierror.Respond(c, apierror.CodeCopiesIssued, "Total copies cannot be less than issued copies")
 			return
 		}
 
+		before := book
 		book.Title = input.Title
 		book.Authors = input.Authors
 		book.Publisher = input.Publisher
+		book.Subjects = input.Subjects
 		book.Version = input.Version
 		book.TotalCopies = input.TotalCopies
 		book.AvailableCopies = input.TotalCopies - issuedCopies
 
-		if err := db.Save(&book).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update book"})
+		var saved bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if saved, err = saveBookRevision(tx, &book, before.Revision); err != nil || !saved {
+				return err
+			}
+			// Links only change with the text they are derived from
+			if book.Authors != before.Authors || book.Publisher != before.Publisher || book.Subjects != before.Subjects {
+				if err := authority.Link(tx, book); err != nil {
+					return err
+				}
+			}
+			return recordAudit(tx, c, "book.update", "book", book.ISBN, book.LibraryID, before, book)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to update book")
+			return
+		}
+		if !saved {
+			respondStaleBook(c)
 			return
 		}
 
+		c.Header("ETag", bookETag(book))
 		c.JSON(http.StatusOK, gin.H{"message": "Book updated successfully", "book": book})
 	}
 }
 
+// RemoveBook removes one available copy, or the book with its last copy,
+// honoring If-Match - Only Admin
 func RemoveBook(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		isbn := c.Param("isbn")
 		var input struct {
 			LibraryID uint `json:"libraryid"`
@@ -135,51 +471,81 @@ func RemoveBook(db *gorm.DB) gin.HandlerFunc {
 		userID, exists := c.Get("userID")
 		userRole, roleExists := c.Get("userRole")
 		if !exists || !roleExists || userRole != "admin" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		// ✅ Validate JSON input
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
 			return
 		}
 
 		// ✅ Ensure the admin is assigned to this library
 		var admin models.UserLibrary
 		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&admin).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not assigned as an admin for this library"})
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You are not assigned as an admin for this library")
 			return
 		}
 
 		// ✅ Find the book in the specified library
 		var book models.Book
 		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in the specified library")
+			return
+		}
+		if !checkIfMatch(c, book) {
 			return
 		}
 
 		// ✅ Prevent removal if no available copies
 		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot remove book. All copies are currently issued."})
+			apierror.Respond(c, apierror.CodeCopiesIssued, "Cannot remove book. All copies are currently issued.")
 			return
 		}
 
 		// ✅ If there are multiple copies, decrement instead of deleting
 		if book.TotalCopies > 1 {
+			before := book
 			book.TotalCopies--
 			book.AvailableCopies--
-			if err := db.Save(&book).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrement book copies"})
+			var saved bool
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var err error
+				if saved, err = saveBookRevision(tx, &book, before.Revision); err != nil || !saved {
+					return err
+				}
+				return recordAudit(tx, c, "book.remove_copy", "book", book.ISBN, book.LibraryID, before, book)
+			})
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Failed to decrement book copies")
+				return
+			}
+			if !saved {
+				respondStaleBook(c)
 				return
 			}
+			c.Header("ETag", bookETag(book))
 			c.JSON(http.StatusOK, gin.H{"message": "Book copies decremented", "book": book})
 			return
 		}
 
 		// ✅ Delete only if it's the last copy and available (not issued)
-		if err := db.Delete(&book).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove book"})
+		var removed bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			deleted := tx.Where("revision = ?", book.Revision).Delete(&book)
+			if deleted.Error != nil || deleted.RowsAffected == 0 {
+				return deleted.Error
+			}
+			removed = true
+			return recordAudit(tx, c, "book.delete", "book", book.ISBN, book.LibraryID, book, nil)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to remove book")
+			return
+		}
+		if !removed {
+			respondStaleBook(c)
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"message": "Book removed from inventory"})
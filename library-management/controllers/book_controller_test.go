@@ -10,6 +10,8 @@ import (
 	"regexp"
 	"testing"
 
+	"library-management/enrich"
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -29,7 +31,7 @@ func TestAddBook(t *testing.T) {
 	r.POST("/books", func(c *gin.Context) {
 		c.Set("userID", uint(1))
 		c.Set("userRole", "admin")
-		AddBook(gormDB)(c)
+		AddBook(gormDB, enrich.Chain{})(c)
 	})
 
 	t.Run("Successful book addition", func(t *testing.T) {
@@ -72,7 +74,7 @@ func TestAddBook(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "error")
+		assert.Contains(t, w.Body.String(), `"code":"malformed_request"`)
 	})
 
 	t.Run("Library not found", func(t *testing.T) {
@@ -144,6 +146,53 @@ func TestAddBook(t *testing.T) {
 
 }
 
+func TestAddBookIgnoresServerFields(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/books", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+		AddBook(gormDB, enrich.Chain{})(c)
+	})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+		WithArgs(1, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2)`)).
+		WithArgs("123", 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT "work_id" FROM "books" WHERE (isbn = $1 AND work_id <> 0)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"work_id"}).AddRow(7))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(`SAVEPOINT`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "book_authors"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "book_publishers"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM "book_subjects"`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodPost, "/books", bytes.NewBufferString(`{"ISBN":"123","Title":"Dune","LibraryID":1,"TotalCopies":2,"ID":9,"WorkID":3,"Revision":40,"AvailableCopies":50,"CallNumber":"823.914 HER","CallNumberScheme":"ddc"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// No id, the server's work, revision 1 and every copy available, whatever
+	// the client sent
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, `"book-5-1"`, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), `"AvailableCopies":2`)
+	assert.Contains(t, w.Body.String(), `"WorkID":7`)
+	assert.Contains(t, w.Body.String(), `"CallNumber":"823.914 HER"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateBook(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -392,3 +441,204 @@ func TestRemoveBook(t *testing.T) {
 		assert.Contains(t, w.Body.String(), "You are not assigned as an admin for this library")
 	})
 }
+
+func TestBookETags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	admin := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("userID", uint(1))
+			c.Set("userRole", "admin")
+			handler(c)
+		}
+	}
+	r.GET("/book/:isbn", admin(GetLibraryBook(gormDB)))
+	r.PUT("/book/:isbn", admin(UpdateBook(gormDB)))
+	r.DELETE("/book/:isbn", admin(RemoveBook(gormDB)))
+
+	expectBook := func(revision uint, total, available int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
+			WithArgs(1, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("123", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "total_copies", "available_copies", "library_id", "revision"}).
+				AddRow(5, "123", "Dune", total, available, 1, revision))
+	}
+	send := func(method, target, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Read Returns An ETag", func(t *testing.T) {
+		expectBook(3, 2, 2)
+
+		w := send(http.MethodGet, "/book/123?library_id=1", "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"book-5-3"`, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), `"Revision":3`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unchanged Book Is Not Sent Again", func(t *testing.T) {
+		expectBook(3, 2, 2)
+
+		w := send(http.MethodGet, "/book/123?library_id=1", "", map[string]string{"If-None-Match": `W/"book-5-3"`})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stale If-Match Is Rejected", func(t *testing.T) {
+		expectBook(4, 2, 2)
+
+		w := send(http.MethodPut, "/book/123", `{"LibraryID":1,"Title":"Dune","TotalCopies":3}`, map[string]string{"If-Match": `"book-5-3"`})
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"precondition_failed"`)
+		assert.Equal(t, `"book-5-4"`, w.Header().Get("ETag"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Matching If-Match Updates The Revision", func(t *testing.T) {
+		expectBook(3, 2, 2)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "books" SET .*"revision"=\$\d+ WHERE revision = \$\d+ AND "books"."deleted_at" IS NULL AND "id" = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPut, "/book/123", `{"LibraryID":1,"Title":"Dune","TotalCopies":3}`, map[string]string{"If-Match": `"book-5-3"`})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"book-5-4"`, w.Header().Get("ETag"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Server Fields Are Ignored", func(t *testing.T) {
+		expectBook(3, 2, 2)
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "books" SET .*"revision"=\$\d+ WHERE revision = \$\d+ AND "books"."deleted_at" IS NULL AND "id" = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPut, "/book/123", `{"LibraryID":1,"Title":"Dune","TotalCopies":3,"ID":9,"WorkID":7,"Revision":40,"AvailableCopies":50}`, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"ID":5`)
+		assert.Contains(t, w.Body.String(), `"WorkID":0`)
+		assert.Contains(t, w.Body.String(), `"AvailableCopies":3`)
+		assert.Equal(t, `"book-5-4"`, w.Header().Get("ETag"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Concurrent Delete Loses", func(t *testing.T) {
+		expectBook(3, 1, 1)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "deleted_at"=$1 WHERE revision = $2`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+
+		w := send(http.MethodDelete, "/book/123", `{"libraryid":1}`, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestGetBook(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	role := "user"
+	r := gin.New()
+	r.GET("/books/:isbn", func(c *gin.Context) {
+		c.Set("userID", uint(2))
+		c.Set("userRole", role)
+		GetBook(gormDB)(c)
+	})
+	send := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/books/123", nil))
+		return w
+	}
+
+	expectAvailability := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1 AND library_id IN ($2,$3) AND "books"."deleted_at" IS NULL ORDER BY library_id ASC`)).
+			WithArgs("123", 1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "total_copies", "available_copies", "library_id", "work_id", "revision"}).
+				AddRow(5, "123", "Dune", "Frank Herbert", 2, 0, 1, 6, 4).
+				AddRow(9, "123", "Dune", "Frank Herbert", 1, 1, 3, 6, 1))
+		// Holds on the work queue for this edition too
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT library_id, count(*) AS count FROM "request_events" WHERE (library_id IN ($1,$2) AND request_type = $3 AND status IN ($4,$5)) AND ((book_id = $6 OR work_id = $7))`)).
+			WithArgs(1, 3, "issue", "Pending", "Approved", "123", 6).
+			WillReturnRows(sqlmock.NewRows([]string{"library_id", "count"}).AddRow(1, 2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (isbn = $1 AND library_id IN ($2,$3) AND return_date = 0) AND "issue_registries"."deleted_at" IS NULL ORDER BY expected_return_date ASC`)).
+			WithArgs("123", 1, 3).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "reader_id", "issue_status", "issue_date", "expected_return_date"}).
+				AddRow(11, "123", 1, 7, "Issued", 1700000000, 1700864000).
+				AddRow(12, "123", 1, 8, "Issued", 1700000000, 1701000000))
+	}
+
+	t.Run("Reader Sees Availability", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(2, "Approved").
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1).AddRow(3))
+		expectAvailability()
+
+		w := send()
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `"total_copies":3`)
+		assert.Contains(t, body, `"available_copies":1`)
+		assert.Contains(t, body, `"hold_queue":2`)
+		assert.Contains(t, body, `"next_due_date":"N/A"`)
+		assert.NotContains(t, body, `"loans"`)
+		assert.NotContains(t, body, `"copy"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin Sees Loans And Copies", func(t *testing.T) {
+		role = "admin"
+		defer func() { role = "user" }()
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1).AddRow(3))
+		expectAvailability()
+
+		w := send()
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `"etag":"\"book-5-4\""`)
+		assert.Contains(t, body, `"reader_id":7`)
+		assert.Contains(t, body, `"loans":[]`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("No Accessible Copy", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(4))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1 AND library_id IN ($2)`)).
+			WithArgs("123", 4).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w := send()
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"book_not_found"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"errors"
+	"library-management/apierror"
+	"library-management/listquery"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// publicCacheControl lets browsers and shared caches keep anonymous catalog
+// responses for a minute; availability changes too often for longer
+const publicCacheControl = "public, max-age=60"
+
+// publicBookListing is what SearchPublicCatalog accepts
+var publicBookListing = listquery.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     50,
+	Sorts: map[string]string{
+		"title":            "title",
+		"isbn":             "isbn",
+		"available_copies": "available_copies",
+	},
+	DefaultSort: "title",
+	TieBreaker:  "id",
+	Filters: map[string]listquery.Filter{
+		"title":     {Column: "title", Op: listquery.Contains},
+		"author":    {Column: "authors", Op: listquery.Contains},
+		"publisher": {Column: "publisher", Op: listquery.Contains},
+		"isbn":      {Column: "isbn"},
+	},
+}
+
+// ListPublicLibraries lists the libraries whose catalog is open to anonymous visitors
+func ListPublicLibraries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		params, ok := listquery.Parse(c, libraryListing)
+		if !ok {
+			return
+		}
+
+		var libraries []models.Library
+		query := db.Model(&models.Library{}).Where("public_catalog = ? AND archived = ?", true, false)
+		result, err := params.Find(query, &libraries)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch libraries")
+			return
+		}
+
+		formattedLibraries := make([]gin.H, len(libraries))
+		for i, library := range libraries {
+			formattedLibraries[i] = gin.H{
+				"id":       library.ID,
+				"name":     library.Name,
+				"address":  library.Address,
+				"city":     library.City,
+				"phone":    library.Phone,
+				"email":    library.Email,
+				"timezone": library.Timezone,
+			}
+		}
+
+		c.Header("Cache-Control", publicCacheControl)
+		c.JSON(http.StatusOK, params.Envelope("libraries", formattedLibraries, result))
+	}
+}
+
+// SearchPublicCatalog searches one library's catalog by title, author, publisher
+// or ISBN without logging in
+func SearchPublicCatalog(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		library, ok := publicLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		params, ok := listquery.Parse(c, publicBookListing)
+		if !ok {
+			return
+		}
+
+		var books []models.Book
+		query := db.Model(&models.Book{}).Where("library_id = ?", library.ID).
+			Select("id, isbn, title, authors, publisher, total_copies, available_copies, library_id")
+		result, err := params.Find(query, &books)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Error searching books")
+			return
+		}
+
+		formattedBooks := make([]gin.H, len(books))
+		for i, book := range books {
+			formattedBooks[i] = publicBook(book)
+		}
+
+		c.Header("Cache-Control", publicCacheControl)
+		c.JSON(http.StatusOK, params.Envelope("books", formattedBooks, result))
+	}
+}
+
+// GetPublicBook shows one book in a library's catalog with its availability. No
+// borrower data is included; a matching If-None-Match gets 304.
+func GetPublicBook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		library, ok := publicLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		var book models.Book
+		if err := db.Where("isbn = ? AND library_id = ?", c.Param("isbn"), library.ID).First(&book).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in this library's catalog")
+			} else {
+				apierror.Respond(c, apierror.CodeInternal, "Could not fetch book")
+			}
+			return
+		}
+
+		c.Header("Cache-Control", publicCacheControl)
+		if notModified(c, bookETag(book)) {
+			return
+		}
+
+		bookData := publicBook(book)
+		bookData["version"] = book.Version
+		bookData["next_available_date"] = "Available"
+		if book.AvailableCopies == 0 {
+			// Only the earliest due date is shown, never who holds the book
+			var issue models.IssueRegistry
+			if err := db.Select("expected_return_date").
+				Where("isbn = ? AND library_id = ? AND return_date = 0", book.ISBN, library.ID).
+				Order("expected_return_date ASC").First(&issue).Error; err == nil {
+				bookData["next_available_date"] = time.Unix(issue.ExpectedReturnDate, 0).Format("2006-01-02")
+			} else {
+				bookData["next_available_date"] = "Unknown"
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"library": gin.H{"id": library.ID, "name": library.Name}, "book": bookData})
+	}
+}
+
+// publicLibrary loads the library in the :id path parameter when its catalog is
+// public. Private and archived libraries answer 404 like missing ones.
+func publicLibrary(db *gorm.DB, c *gin.Context) (models.Library, bool) {
+	var library models.Library
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Library id must be a positive number")
+		return library, false
+	}
+
+	if err := db.Where("public_catalog = ? AND archived = ?", true, false).First(&library, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found or its catalog is not public")
+		} else {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch library")
+		}
+		return library, false
+	}
+	return library, true
+}
+
+func publicBook(book models.Book) gin.H {
+	return gin.H{
+		"isbn":             book.ISBN,
+		"title":            book.Title,
+		"authors":          book.Authors,
+		"publisher":        book.Publisher,
+		"total_copies":     book.TotalCopies,
+		"available_copies": book.AvailableCopies,
+		"available":        book.AvailableCopies > 0,
+	}
+}
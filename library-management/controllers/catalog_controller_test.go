@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestPublicCatalog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/public/libraries/:id/books", SearchPublicCatalog(gormDB))
+	r.GET("/public/libraries/:id/books/:isbn", GetPublicBook(gormDB))
+
+	send := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for key, value := range headers {
+			req.Header.Set(key, value)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectLibrary := func(found bool) {
+		rows := sqlmock.NewRows([]string{"id", "name", "public_catalog"})
+		if found {
+			rows.AddRow(1, "Central", true)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries" WHERE (public_catalog = $1 AND archived = $2) AND "libraries"."id" = $3`)).
+			WithArgs(true, false, 1, 1).
+			WillReturnRows(rows)
+	}
+	bookRows := func(available int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "total_copies", "available_copies", "library_id", "revision"}).
+			AddRow(5, "123", "Dune", "Frank Herbert", 2, available, 1, 3)
+	}
+
+	t.Run("Search", func(t *testing.T) {
+		expectLibrary(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE library_id = $1 AND title ILIKE $2`)).
+			WithArgs(1, "%dune%").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isbn, title, authors, publisher, total_copies, available_copies, library_id FROM "books" WHERE library_id = $1 AND title ILIKE $2`)).
+			WithArgs(1, "%dune%", 21).
+			WillReturnRows(bookRows(1))
+
+		w := send("/public/libraries/1/books?title=dune", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, publicCacheControl, w.Header().Get("Cache-Control"))
+		assert.Contains(t, w.Body.String(), `"available":true`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Private Catalog Is Hidden", func(t *testing.T) {
+		expectLibrary(false)
+
+		w := send("/public/libraries/1/books", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"library_not_found"`)
+		assert.Empty(t, w.Header().Get("Cache-Control"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Book Shows Next Available Date Only", func(t *testing.T) {
+		expectLibrary(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL`)).
+			WithArgs("123", 1, 1).
+			WillReturnRows(bookRows(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "expected_return_date" FROM "issue_registries" WHERE (isbn = $1 AND library_id = $2 AND return_date = 0)`)).
+			WithArgs("123", 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"expected_return_date"}).AddRow(1700864000))
+
+		w := send("/public/libraries/1/books/123", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"book-5-3"`, w.Header().Get("ETag"))
+		assert.Contains(t, w.Body.String(), `"next_available_date":"2023-11-24"`)
+		assert.NotContains(t, w.Body.String(), "reader")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unchanged Book Is Not Sent Again", func(t *testing.T) {
+		expectLibrary(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2)`)).
+			WillReturnRows(bookRows(1))
+
+		w := send("/public/libraries/1/books/123", map[string]string{"If-None-Match": `"book-5-3"`})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Equal(t, publicCacheControl, w.Header().Get("Cache-Control"))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Library ID", func(t *testing.T) {
+		w := send("/public/libraries/abc/books", nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`)
+	})
+}
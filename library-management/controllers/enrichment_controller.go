@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"errors"
+	"library-management/apierror"
+	"library-management/enrich"
+	"library-management/listquery"
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// enrichmentJobListing is what ListEnrichmentJobs accepts
+var enrichmentJobListing = listquery.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "created_at", "id": "id"},
+	DefaultSort:  "-created_at",
+	TieBreaker:   "id",
+	Filters: map[string]listquery.Filter{
+		"status": {Column: "status", Enum: []string{enrich.JobQueued, enrich.JobRunning, enrich.JobDone, enrich.JobFailed}},
+	},
+}
+
+// LookupMetadata previews what enrichment would fill in for an ISBN, without
+// touching any book - Owner and Admin
+func LookupMetadata(source enrich.Source) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		metadata, err := enrich.Find(c.Request.Context(), source, c.Param("isbn"))
+		switch {
+		case errors.Is(err, enrich.ErrInvalidISBN):
+			apierror.Respond(c, apierror.CodeInvalidParameter, "ISBN must be a valid ISBN-10 or ISBN-13")
+			return
+		case errors.Is(err, enrich.ErrNotFound):
+			apierror.Respond(c, apierror.CodeMetadataNotFound, "No source has a record for ISBN "+c.Param("isbn"))
+			return
+		case err != nil:
+			middleware.Logger(c).Error("isbn lookup failed", "isbn", c.Param("isbn"), "source", source.Name(), "error", err)
+			apierror.Respond(c, apierror.CodeInternal, "Could not look the ISBN up")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"metadata": metadata})
+	}
+}
+
+// StartEnrichment queues a job re-enriching every book of a library. Blank
+// fields are filled in; with "overwrite" fields that have a value are replaced
+// too. A library runs one job at a time - Only Admin
+func StartEnrichment(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Overwrite bool `json:"overwrite"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&input); err != nil {
+				apierror.Respond(c, apierror.CodeMalformedRequest, "Invalid JSON input")
+				return
+			}
+		}
+
+		var active int64
+		err := db.Model(&models.EnrichmentJob{}).
+			Where("library_id = ? AND status IN ?", libraryID, []string{enrich.JobQueued, enrich.JobRunning}).
+			Count(&active).Error
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not start enrichment")
+			return
+		}
+		if active > 0 {
+			apierror.Respond(c, apierror.CodeDuplicateRequest, "An enrichment job is already queued or running for this library")
+			return
+		}
+
+		job := models.EnrichmentJob{
+			LibraryID:   libraryID,
+			RequestedBy: c.GetUint("userID"),
+			Overwrite:   input.Overwrite,
+			Status:      enrich.JobQueued,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&job).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "enrichment_job.create", "enrichment_job", job.ID, libraryID, nil, job)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not start enrichment")
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{"message": "Enrichment queued", "job": job})
+	}
+}
+
+// ListEnrichmentJobs lists a library's enrichment jobs, newest first - Only Admin
+func ListEnrichmentJobs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+		params, ok := listquery.Parse(c, enrichmentJobListing)
+		if !ok {
+			return
+		}
+
+		var jobs []models.EnrichmentJob
+		result, err := params.Find(db.Model(&models.EnrichmentJob{}).Where("library_id = ?", libraryID), &jobs)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch enrichment jobs")
+			return
+		}
+
+		c.JSON(http.StatusOK, params.Envelope("jobs", jobs, result))
+	}
+}
+
+// GetEnrichmentJob reports a job's progress - Only Admin
+func GetEnrichmentJob(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+		jobID, err := strconv.ParseUint(c.Param("job"), 10, 64)
+		if err != nil || jobID == 0 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Job id must be a positive number")
+			return
+		}
+
+		var job models.EnrichmentJob
+		err = db.Where("id = ? AND library_id = ?", jobID, libraryID).First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeEnrichmentJobNotFound, "Enrichment job not found")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch enrichment job")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"job": job})
+	}
+}
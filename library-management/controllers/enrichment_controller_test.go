@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"library-management/enrich"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// stubSource knows a fixed set of ISBN-13s
+type stubSource map[string]enrich.Metadata
+
+func (s stubSource) Name() string { return "stub" }
+
+func (s stubSource) Lookup(ctx context.Context, isbn string) (enrich.Metadata, error) {
+	if metadata, ok := s[isbn]; ok {
+		return metadata, nil
+	}
+	return enrich.Metadata{}, enrich.ErrNotFound
+}
+
+func TestEnrichment(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	source := stubSource{"9780441172719": {Source: "openlibrary", ISBN: "9780441172719", Title: "Dune", Authors: "Frank Herbert"}}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+	})
+	r.POST("/book", AddBook(gormDB, source))
+	r.GET("/enrichment/:isbn", LookupMetadata(source))
+	r.POST("/library/:id/enrichment", StartEnrichment(gormDB))
+	r.GET("/library/:id/enrichment/:job", GetEnrichmentJob(gormDB))
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectManager := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 ORDER BY "user_libraries"."user_id" LIMIT $3`)).
+			WithArgs(1, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 2))
+	}
+	expectNotHeld := func(isbn string) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs(isbn, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	}
+
+	t.Run("Lookup Normalises The ISBN", func(t *testing.T) {
+		w := send(http.MethodGet, "/enrichment/0-441-17271-7", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"Dune"`)
+		assert.Contains(t, w.Body.String(), `"source":"openlibrary"`)
+	})
+
+	t.Run("Lookup Unknown ISBN", func(t *testing.T) {
+		w := send(http.MethodGet, "/enrichment/9780262033848", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"metadata_not_found"`)
+
+		w = send(http.MethodGet, "/enrichment/12345", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`)
+	})
+
+	t.Run("Add By ISBN Rejects Invalid ISBN", func(t *testing.T) {
+		expectManager()
+
+		w := send(http.MethodPost, "/book?enrich=true", `{"ISBN":"9780441172718","LibraryID":2,"TotalCopies":1}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Add By ISBN Needs A Title When Nothing Is Found", func(t *testing.T) {
+		expectManager()
+		expectNotHeld("9780262033848")
+
+		w := send(http.MethodPost, "/book?enrich=true", `{"ISBN":"0-262-03384-4","LibraryID":2,"TotalCopies":1}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"metadata_not_found"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Start Queues A Job", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "enrichment_jobs" WHERE library_id = $1 AND status IN ($2,$3)`)).
+			WithArgs(2, "queued", "running").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "enrichment_jobs"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/2/enrichment", `{"overwrite":true}`)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), `"id":7`)
+		assert.Contains(t, w.Body.String(), `"overwrite":true`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Start While A Job Is Active", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "enrichment_jobs"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		w := send(http.MethodPost, "/library/2/enrichment", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"duplicate_request"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Job Of Another Library", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "enrichment_jobs" WHERE id = $1 AND library_id = $2 ORDER BY "enrichment_jobs"."id" LIMIT $3`)).
+			WithArgs(9, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		w := send(http.MethodGet, "/library/2/enrichment/9", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"enrichment_job_not_found"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"library-management/apierror"
+	"library-management/models"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// bookETag identifies one revision of a library's copy of a book
+func bookETag(book models.Book) string {
+	return fmt.Sprintf(`"book-%d-%d"`, book.ID, book.Revision)
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header lists etag.
+// "*" matches anything. If-Match needs a strong match, If-None-Match passes weak
+// so W/ validators compare by their opaque part.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch writes 412 and returns false when If-Match is sent and does not
+// match the book's current revision. Without the header any revision is accepted.
+func checkIfMatch(c *gin.Context, book models.Book) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" || etagMatches(header, bookETag(book), false) {
+		return true
+	}
+	c.Header("ETag", bookETag(book))
+	apierror.Respond(c, apierror.CodePreconditionFailed, "The book has changed since it was read, fetch it again and retry")
+	return false
+}
+
+// saveBookRevision writes every field of book only if its row is still at
+// revision, so concurrent edits cannot overwrite each other. It returns false
+// when another request got there first.
+func saveBookRevision(db *gorm.DB, book *models.Book, revision uint) (bool, error) {
+	result := db.Model(book).Where("revision = ?", revision).Select("*").Updates(book)
+	return result.RowsAffected == 1, result.Error
+}
+
+// respondStaleBook reports a lost race on a book update: 412 when the client
+// sent If-Match, otherwise a plain conflict it can retry
+func respondStaleBook(c *gin.Context) {
+	if c.GetHeader("If-Match") != "" {
+		apierror.Respond(c, apierror.CodePreconditionFailed, "The book has changed since it was read, fetch it again and retry")
+		return
+	}
+	apierror.Respond(c, apierror.CodeConflict, "The book was changed by another request, retry")
+}
+
+// notModified answers a GET whose If-None-Match already names the current ETag
+func notModified(c *gin.Context, etag string) bool {
+	c.Header("ETag", etag)
+	if header := c.GetHeader("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		c.Status(http.StatusNotModified)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"library-management/config"
+	"library-management/health"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Readiness limits for the database round trip
+const (
+	readinessTimeout  = 2 * time.Second
+	slowDatabaseAfter = 500 * time.Millisecond
+)
+
+// Livez answers as long as the process can serve HTTP, it never touches dependencies
+func Livez() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz reports whether this instance should receive traffic: the database answers
+// in time, the schema is at the version this build expects and every background
+// worker is beating. Each check is detailed in the response. Workers are only
+// checked once they register with the health package, so a process running none
+// reports an empty workers check that never fails.
+func Readyz(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		ready := true
+		checks := gin.H{}
+
+		database := gin.H{"status": "ok"}
+		start := time.Now()
+		err := pingDatabase(ctx, db)
+		latency := time.Since(start)
+		database["latency_ms"] = float64(latency.Microseconds()) / 1000
+		switch {
+		case err != nil:
+			ready = false
+			database["status"] = "down"
+			database["error"] = err.Error()
+		case latency > slowDatabaseAfter:
+			database["status"] = "slow"
+		}
+		checks["database"] = database
+
+		migrations := gin.H{"status": "ok", "expected": config.SchemaVersion}
+		if err != nil {
+			migrations["status"] = "unknown"
+		} else {
+			var applied uint
+			if err := db.WithContext(ctx).Table("schema_migrations").Select("COALESCE(MAX(version), 0)").Scan(&applied).Error; err != nil {
+				ready = false
+				migrations["status"] = "unknown"
+				migrations["error"] = err.Error()
+			} else {
+				migrations["applied"] = applied
+				if applied < config.SchemaVersion {
+					ready = false
+					migrations["status"] = "pending"
+				}
+			}
+		}
+		checks["migrations"] = migrations
+
+		workers := health.Workers(time.Now())
+		for _, worker := range workers {
+			if !worker.Healthy {
+				ready = false
+			}
+		}
+		checks["workers"] = workers
+
+		status, code := "ok", http.StatusOK
+		if health.ShuttingDown() {
+			status, code = "shutting_down", http.StatusServiceUnavailable
+		} else if !ready {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"status": status, "checks": checks})
+	}
+}
+
+func pingDatabase(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"library-management/health"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestReadyz(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	assert.NoError(t, err)
+
+	// gorm.Open pings once on its own
+	mock.ExpectPing()
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/readyz", Readyz(gormDB))
+
+	schemaQuery := regexp.QuoteMeta(`SELECT COALESCE(MAX(version), 0) FROM "schema_migrations"`)
+
+	t.Run("Ready", func(t *testing.T) {
+		mock.ExpectPing()
+		mock.ExpectQuery(schemaQuery).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(3))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"ok"`)
+		assert.Contains(t, w.Body.String(), `"applied":3`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Database Down", func(t *testing.T) {
+		mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"down"`)
+		assert.Contains(t, w.Body.String(), "connection refused")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Pending Migration", func(t *testing.T) {
+		mock.ExpectPing()
+		mock.ExpectQuery(schemaQuery).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(0))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"pending"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Stalled Worker", func(t *testing.T) {
+		health.RegisterWorker("test-worker", time.Minute)
+		defer health.UnregisterWorker("test-worker")
+		health.Beat("test-worker", errors.New("job failed"))
+
+		mock.ExpectPing()
+		mock.ExpectQuery(schemaQuery).WillReturnRows(sqlmock.NewRows([]string{"coalesce"}).AddRow(3))
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "job failed")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -1,7 +1,13 @@
 package controllers
 
 import (
+	"errors"
+	"library-management/apierror"
+	"library-management/listquery"
+	"library-management/metrics"
 	"library-management/models"
+	"library-management/tracing"
+	"library-management/utils"
 	"net/http"
 	"time"
 
@@ -9,32 +15,66 @@ import (
 	"gorm.io/gorm"
 )
 
+// loanPeriodDays is how long a reader may keep an issued book
+const loanPeriodDays = 14
+
+// issueRequestListing is what ListIssueRequests accepts. Columns are qualified
+// because the query joins books.
+var issueRequestListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts: map[string]string{
+		"request_date": "request_events.request_date",
+		"status":       "request_events.status",
+		"id":           "request_events.id",
+	},
+	DefaultSort: "-request_date",
+	TieBreaker:  "request_events.id",
+	Filters: map[string]listquery.Filter{
+		"status":       {Column: "request_events.status", Enum: []string{"Pending", "Approved", "Disapproved", "Issued"}},
+		"request_type": {Column: "request_events.request_type", Enum: []string{"issue", "return"}},
+		"library_id":   {Column: "request_events.library_id", Kind: listquery.Int},
+		"isbn":         {Column: "request_events.book_id"},
+		"user_id":      {Column: "request_events.reader_id", Kind: listquery.Int},
+		"from":         {Column: "request_events.request_date", Op: listquery.From, Kind: listquery.UnixDate},
+		"to":           {Column: "request_events.request_date", Op: listquery.To, Kind: listquery.UnixDate},
+	},
+}
+
 // ListIssueRequests retrieves all issue requests for admin's libraries
 func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		adminID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		var adminLibraryIDs []uint
 		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraryIDs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch admin libraries"})
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch admin libraries")
 			return
 		}
 
 		if len(adminLibraryIDs) == 0 {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin is not associated with any library"})
+			apierror.Respond(c, apierror.CodeLibraryAccess, "Admin is not associated with any library")
+			return
+		}
+		tracing.SetLibraryIDs(c.Request.Context(), adminLibraryIDs)
+
+		params, ok := listquery.Parse(c, issueRequestListing)
+		if !ok {
 			return
 		}
 
 		var requests []models.RequestEvent
-		if err := db.
+		result, err := params.Find(db.
 			Joins("JOIN books ON request_events.book_id = books.isbn").
-			Where("books.library_id IN (?)", adminLibraryIDs).
-			Find(&requests).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch issue requests"})
+			Where("books.library_id IN (?)", adminLibraryIDs), &requests)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch issue requests")
 			return
 		}
 
@@ -43,6 +83,7 @@ func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 			formattedRequests[i] = gin.H{
 				"id":            request.ID,
 				"book_id":       request.BookID,
+				"work_id":       request.WorkID,
 				"user_id":       request.ReaderID,
 				"request_type":  request.RequestType,
 				"status":        request.Status,
@@ -51,42 +92,55 @@ func ListIssueRequests(db *gorm.DB) gin.HandlerFunc {
 				"approver_id":   request.ApproverID,
 			}
 		}
-		c.JSON(http.StatusOK, gin.H{"requests": formattedRequests})
+		c.JSON(http.StatusOK, params.Envelope("requests", formattedRequests, result))
 	}
 }
 
 // ApproveIssue allows an admin to approve a book issue request
 func ApproveIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		requestID := c.Param("id")
 		var request models.RequestEvent
 
 		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+			apierror.Respond(c, apierror.CodeIssueRequestNotFound, "Issue request not found")
 			return
 		}
 
 		adminID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		if request.Status != "Pending" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Request is already processed"})
+			apierror.Respond(c, apierror.CodeAlreadyProcessed, "Request is already processed")
 			return
 		}
 
+		before := request
 		now := time.Now().Unix()
 		request.ApprovalDate = &now
 		request.ApproverID = new(uint)
 		*request.ApproverID = adminID.(uint)
 		request.Status = "Approved"
 
-		if err := db.Save(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not approve request"})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&request).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "issue_request.approve", "issue_request", request.ID, request.LibraryID, before, request)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not approve request")
 			return
 		}
+		metrics.IssueRequestsReviewed.WithLabelValues("approved").Inc()
+		if request.RequestType == "return" {
+			metrics.ReturnsProcessed.Inc()
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "Issue request approved", "status": request.Status})
 	}
@@ -95,25 +149,35 @@ func ApproveIssue(db *gorm.DB) gin.HandlerFunc {
 // DisapproveIssue allows an admin to reject an issue request
 func DisapproveIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		requestID := c.Param("id")
 		var request models.RequestEvent
 
 		if err := db.First(&request, requestID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Issue request not found"})
+			apierror.Respond(c, apierror.CodeIssueRequestNotFound, "Issue request not found")
 			return
 		}
 
 		if request.Status != "Pending" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Request is already processed"})
+			apierror.Respond(c, apierror.CodeAlreadyProcessed, "Request is already processed")
 			return
 		}
 
+		before := request
 		request.Status = "Disapproved" // ✅ Update Status instead of deleting
 
-		if err := db.Save(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not disapprove request"})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Save(&request).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "issue_request.disapprove", "issue_request", request.ID, request.LibraryID, before, request)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not disapprove request")
 			return
 		}
+		metrics.IssueRequestsReviewed.WithLabelValues("disapproved").Inc()
 
 		c.JSON(http.StatusOK, gin.H{"message": "Issue request disapproved", "status": request.Status})
 	}
@@ -122,11 +186,13 @@ func DisapproveIssue(db *gorm.DB) gin.HandlerFunc {
 // 📚 Issue a book to a user (Prevents re-issuing)
 func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		isbn := c.Param("isbn")
 
 		adminID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
@@ -135,37 +201,41 @@ func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 			LibraryID uint `json:"library_id"`
 		}
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON format"})
+			apierror.Respond(c, apierror.CodeMalformedRequest, "Invalid JSON format")
 			return
 		}
 
 		var book models.Book
 		if err := db.Where("isbn = ? AND library_id = ?", isbn, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in this library"})
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in this library")
 			return
 		}
 
 		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "No available copies to issue"})
+			apierror.Respond(c, apierror.CodeNoCopiesAvailable, "No available copies to issue")
 			return
 		}
 
 		// ✅ Check if the book has already been issued
 		var existingIssue models.IssueRegistry
 		if err := db.Where("isbn = ? AND reader_id = ?", isbn, input.UserID).First(&existingIssue).Error; err == nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "This book has already been issued to the user"})
+			apierror.Respond(c, apierror.CodeBookAlreadyIssued, "This book has already been issued to the user")
 			return
 		}
 
-		// ✅ Reduce available copies
-		book.AvailableCopies--
-		db.Save(&book)
+		// Opening hours and closures decide the due date
+		var library models.Library
+		if err := db.Preload("OpeningHours").Preload("Closures").First(&library, input.LibraryID).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found")
+			return
+		}
 
 		issueDate := time.Now()
-		expectedReturnDate := issueDate.AddDate(0, 0, 14)
+		expectedReturnDate := utils.DueDate(library, issueDate, loanPeriodDays)
 
 		issueRecord := models.IssueRegistry{
 			ISBN:               isbn,
+			LibraryID:          input.LibraryID,
 			ReaderID:           input.UserID,
 			IssueApproverID:    adminID.(uint),
 			IssueStatus:        "Issued",
@@ -175,16 +245,65 @@ func IssueBookToUser(db *gorm.DB) gin.HandlerFunc {
 			ReturnApproverID:   0,
 		}
 
-		if err := db.Create(&issueRecord).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not issue book"})
+		// The copy is taken with a guarded decrement in the same transaction as
+		// the loan, so two admins can't both hand out the last one. The reader's
+		// open requests for the book here, holds on its work included, are
+		// fulfilled with it.
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lendCopy(tx, book.ID); err != nil {
+				return err
+			}
+			if err := tx.Create(&issueRecord).Error; err != nil {
+				return err
+			}
+			fulfilled := tx.Model(&models.RequestEvent{}).
+				Where("reader_id = ? AND library_id = ? AND request_type = ? AND status IN ?", input.UserID, input.LibraryID, "issue", []string{"Pending", "Approved"})
+			if book.WorkID != 0 {
+				fulfilled = fulfilled.Where("(book_id = ? OR work_id = ?)", isbn, book.WorkID)
+			} else {
+				fulfilled = fulfilled.Where("book_id = ?", isbn)
+			}
+			if err := fulfilled.Update("status", "Issued").Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "loan.issue", "issue_registry", issueRecord.ID, input.LibraryID, nil, issueRecord)
+		})
+		if errors.Is(err, errCopiesGone) {
+			apierror.Respond(c, apierror.CodeNoCopiesAvailable, "No available copies to issue")
 			return
 		}
-
-		// ✅ Update RequestEvent status to "Issued"
-		db.Model(&models.RequestEvent{}).
-			Where("book_id = ? AND reader_id = ?", isbn, input.UserID).
-			Update("status", "Issued")
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not issue book")
+			return
+		}
+		metrics.LoansIssued.Inc()
 
 		c.JSON(http.StatusOK, gin.H{"message": "Book issued successfully"})
 	}
 }
+
+// lendCopy takes one available copy of the book for a loan, failing with
+// errCopiesGone when none is left. Like moveCopies it updates the count in the
+// database rather than saving a stale row.
+func lendCopy(tx *gorm.DB, bookID uint) error {
+	lent := tx.Model(&models.Book{}).Where("id = ? AND available_copies > 0", bookID).UpdateColumns(map[string]interface{}{
+		"available_copies": gorm.Expr("available_copies - 1"),
+		"revision":         gorm.Expr("revision + 1"),
+		"updated_at":       time.Now(),
+	})
+	if lent.Error != nil {
+		return lent.Error
+	}
+	if lent.RowsAffected == 0 {
+		return errCopiesGone
+	}
+	return nil
+}
+
+// formatUnixTime renders a stored unix timestamp for responses, "N/A" when unset
+func formatUnixTime(timestamp *int64) string {
+	if timestamp == nil || *timestamp == 0 {
+		return "N/A"
+	}
+	return time.Unix(*timestamp, 0).Format("2006-01-02 15:04:05")
+}
@@ -320,6 +320,83 @@ func TestIssueBookToUser(t *testing.T) {
 		//assert.Contains(t, w.Body.String(), "Could not issue book")
 	})
 }
+func TestIssueBookToUserFulfilsRequests(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/issue/:isbn", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+	}, IssueBookToUser(gormDB))
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2)`)).
+		WithArgs("1234567890", 2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "library_id", "work_id", "available_copies"}).
+			AddRow(3, "1234567890", 2, 9, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE (isbn = $1 AND reader_id = $2)`)).
+		WithArgs("1234567890", 5, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries" WHERE "libraries"."id" = $1`)).
+		WithArgs(2, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "timezone"}).AddRow(2, "UTC"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "library_closures"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "opening_hours"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=available_copies - 1`)).
+		WithArgs(sqlmock.AnyArg(), 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "issue_registries"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	// Only the reader's open issue requests in this library are fulfilled
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET "status"=$1,"updated_at"=$2 WHERE (reader_id = $3 AND library_id = $4 AND request_type = $5 AND status IN ($6,$7)) AND ((book_id = $8 OR work_id = $9)) AND "request_events"."deleted_at" IS NULL`)).
+		WithArgs("Issued", sqlmock.AnyArg(), 5, 2, "issue", "Pending", "Approved", "1234567890", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	req := httptest.NewRequest(http.MethodPost, "/issue/1234567890", bytes.NewBufferString(`{"user_id":5,"library_id":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Book issued successfully")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLendCopy(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	expectDecrement := func(rows int64) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=available_copies - 1,"revision"=revision + 1,"updated_at"=$1 WHERE (id = $2 AND available_copies > 0) AND "books"."deleted_at" IS NULL`)).
+			WithArgs(sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, rows))
+		mock.ExpectCommit()
+	}
+
+	t.Run("Copy Available", func(t *testing.T) {
+		expectDecrement(1)
+		assert.NoError(t, lendCopy(gormDB, 3))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Last Copy Taken Meanwhile", func(t *testing.T) {
+		expectDecrement(0)
+		assert.ErrorIs(t, lendCopy(gormDB, 3), errCopiesGone)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestFormatUnixTime(t *testing.T) {
 
 	t.Run("Nil Timestamp", func(t *testing.T) {
@@ -1,25 +1,60 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"library-management/apierror"
+	"library-management/listquery"
 	"library-management/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // CreateLibrary handles creating a new library
 func CreateLibrary(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var input models.Library
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		if err := validateLibrary(input); err != nil {
+			apierror.Respond(c, apierror.CodeValidationFailed, err.Error())
 			return
 		}
 
-		if err := db.Create(&input).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create library"})
+		taken, err := libraryNameTaken(db, input.Name, 0)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create library")
+			return
+		}
+		if taken {
+			apierror.Respond(c, apierror.CodeConflict, fmt.Sprintf("There already is a library named %q", input.Name))
+			return
+		}
+
+		input.ID = 0
+		input.Archived = false
+		if input.Timezone == "" {
+			input.Timezone = "UTC"
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&input).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "library.create", "library", input.ID, input.ID, nil, input)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create library")
 			return
 		}
 
@@ -27,16 +62,348 @@ func CreateLibrary(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// ListLibraries fetches all libraries
+// libraryListing is what ListLibraries accepts besides archived
+var libraryListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"name": "name", "city": "city", "id": "id"},
+	DefaultSort:  "name",
+	TieBreaker:   "id",
+	Filters: map[string]listquery.Filter{
+		"name": {Column: "name", Op: listquery.Contains},
+		"city": {Column: "city", Op: listquery.EqualsFold},
+	},
+}
+
+// ListLibraries fetches libraries, optionally filtered by name, city and archive state
 func ListLibraries(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		params, ok := listquery.Parse(c, libraryListing)
+		if !ok {
+			return
+		}
+
+		query := db.Model(&models.Library{})
+
+		// Archived libraries are hidden unless asked for
+		switch c.DefaultQuery("archived", "false") {
+		case "false":
+			query = query.Where("archived = ?", false)
+		case "true":
+			query = query.Where("archived = ?", true)
+		case "all":
+		default:
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Archived must be one of true, false or all")
+			return
+		}
+
 		var libraries []models.Library
+		result, err := params.Find(query, &libraries)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch libraries")
+			return
+		}
+
+		c.JSON(http.StatusOK, params.Envelope("libraries", libraries, result))
+	}
+}
+
+// GetLibrary returns a library with its opening hours and closures
+func GetLibrary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var library models.Library
+		if err := db.Preload("OpeningHours", func(db *gorm.DB) *gorm.DB {
+			return db.Order("weekday ASC, opens ASC")
+		}).Preload("Closures", func(db *gorm.DB) *gorm.DB {
+			return db.Order("date ASC")
+		}).First(&library, c.Param("id")).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found")
+			} else {
+				apierror.Respond(c, apierror.CodeInternal, "Could not fetch library")
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"library": library})
+	}
+}
+
+// UpdateLibrary updates library details. OpeningHours and Closures, when sent,
+// replace the existing schedule entirely - Owner only
+func UpdateLibrary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Name          *string
+			Address       *string
+			City          *string
+			Phone         *string
+			Email         *string `binding:"omitempty,email"`
+			Timezone      *string
+			PublicCatalog *bool
+			OpeningHours  *[]models.OpeningHours
+			Closures      *[]models.LibraryClosure
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		var library models.Library
+		if err := db.First(&library, c.Param("id")).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found")
+			return
+		}
+
+		before := library
+		updates := map[string]interface{}{}
+		if input.Name != nil {
+			library.Name = *input.Name
+			updates["name"] = library.Name
+		}
+		if input.Address != nil {
+			library.Address = *input.Address
+			updates["address"] = library.Address
+		}
+		if input.City != nil {
+			library.City = *input.City
+			updates["city"] = library.City
+		}
+		if input.Phone != nil {
+			library.Phone = *input.Phone
+			updates["phone"] = library.Phone
+		}
+		if input.Email != nil {
+			library.Email = *input.Email
+			updates["email"] = library.Email
+		}
+		if input.Timezone != nil {
+			library.Timezone = *input.Timezone
+			updates["timezone"] = library.Timezone
+		}
+		if input.PublicCatalog != nil {
+			library.PublicCatalog = *input.PublicCatalog
+			updates["public_catalog"] = library.PublicCatalog
+		}
+		if input.OpeningHours != nil {
+			library.OpeningHours = *input.OpeningHours
+		}
+		if input.Closures != nil {
+			library.Closures = *input.Closures
+		}
+
+		if err := validateLibrary(library); err != nil {
+			apierror.Respond(c, apierror.CodeValidationFailed, err.Error())
+			return
+		}
 
-		if err := db.Find(&libraries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch libraries"})
+		if library.Name != before.Name {
+			taken, err := libraryNameTaken(db, library.Name, library.ID)
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Could not update library")
+				return
+			}
+			if taken {
+				apierror.Respond(c, apierror.CodeConflict, fmt.Sprintf("There already is a library named %q", library.Name))
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if len(updates) > 0 {
+				if err := tx.Model(&library).Omit(clause.Associations).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if input.OpeningHours != nil {
+				if err := tx.Where("library_id = ?", library.ID).Delete(&models.OpeningHours{}).Error; err != nil {
+					return err
+				}
+				for i := range library.OpeningHours {
+					library.OpeningHours[i].ID = 0
+					library.OpeningHours[i].LibraryID = library.ID
+				}
+				if len(library.OpeningHours) > 0 {
+					if err := tx.Create(&library.OpeningHours).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if input.Closures != nil {
+				if err := tx.Where("library_id = ?", library.ID).Delete(&models.LibraryClosure{}).Error; err != nil {
+					return err
+				}
+				for i := range library.Closures {
+					library.Closures[i].ID = 0
+					library.Closures[i].LibraryID = library.ID
+				}
+				if len(library.Closures) > 0 {
+					if err := tx.Create(&library.Closures).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return recordAudit(tx, c, "library.update", "library", library.ID, library.ID, before, library)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not update library")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Library updated successfully", "library": library})
+	}
+}
+
+// ArchiveLibrary hides a library from listings while keeping its data - Owner only
+func ArchiveLibrary(db *gorm.DB) gin.HandlerFunc {
+	return setLibraryArchived(db, true)
+}
+
+// UnarchiveLibrary makes an archived library visible again - Owner only
+func UnarchiveLibrary(db *gorm.DB) gin.HandlerFunc {
+	return setLibraryArchived(db, false)
+}
+
+func setLibraryArchived(db *gorm.DB, archived bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var library models.Library
+		if err := db.First(&library, c.Param("id")).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found")
+			return
+		}
+
+		if library.Archived == archived {
+			apierror.Respond(c, apierror.CodeAlreadyInState, "Library is already in that state")
+			return
+		}
+
+		before := library
+		action, message := "library.archive", "Library archived"
+		if !archived {
+			action, message = "library.unarchive", "Library restored"
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&library).Update("archived", archived).Error; err != nil {
+				return err
+			}
+			library.Archived = archived
+			return recordAudit(tx, c, action, "library", library.ID, library.ID, before, library)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not update library")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "library": library})
+	}
+}
+
+// DeleteLibrary permanently removes an empty library and its memberships - Owner only
+func DeleteLibrary(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var library models.Library
+		if err := db.First(&library, c.Param("id")).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Library not found")
+			return
+		}
+
+		var books int64
+		if err := db.Model(&models.Book{}).Where("library_id = ?", library.ID).Count(&books).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not check library books")
+			return
+		}
+		if books > 0 {
+			apierror.Respond(c, apierror.CodeLibraryNotEmpty, "Library still has books, remove them or archive the library instead")
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"libraries": libraries})
+		// Copies on their way in or out would have nowhere to go
+		var inTransit int64
+		err := db.Model(&models.Transfer{}).
+			Where("(from_library_id = ? OR to_library_id = ?) AND status = ?", library.ID, library.ID, models.TransferInTransit).
+			Count(&inTransit).Error
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not check library transfers")
+			return
+		}
+		if inTransit > 0 {
+			apierror.Respond(c, apierror.CodeLibraryNotEmpty, "Library has transfers in transit, receive or cancel them first")
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("library_id = ?", library.ID).Delete(&models.UserLibrary{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("library_id = ?", library.ID).Delete(&models.OpeningHours{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("library_id = ?", library.ID).Delete(&models.LibraryClosure{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&library).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "library.delete", "library", library.ID, library.ID, library, nil)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not delete library")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Library deleted successfully"})
 	}
 }
+
+// libraryNameTaken reports whether a library other than except has the name,
+// which the unique index would refuse
+func libraryNameTaken(db *gorm.DB, name string, except uint) (bool, error) {
+	var taken int64
+	err := db.Model(&models.Library{}).Where("name = ? AND id <> ?", name, except).Count(&taken).Error
+	return taken > 0, err
+}
+
+// validateLibrary checks the timezone, weekly schedule and closure dates
+func validateLibrary(library models.Library) error {
+	if library.Name == "" {
+		return errors.New("Library name is required")
+	}
+
+	if library.Timezone != "" {
+		if _, err := time.LoadLocation(library.Timezone); err != nil {
+			return fmt.Errorf("Unknown timezone %q", library.Timezone)
+		}
+	}
+
+	for _, hours := range library.OpeningHours {
+		if hours.Weekday < time.Sunday || hours.Weekday > time.Saturday {
+			return fmt.Errorf("Weekday must be between 0 (Sunday) and 6 (Saturday)")
+		}
+		opens, openErr := time.Parse("15:04", hours.Opens)
+		closes, closeErr := time.Parse("15:04", hours.Closes)
+		if openErr != nil || closeErr != nil {
+			return fmt.Errorf("Opening hours must use HH:MM, got %q-%q", hours.Opens, hours.Closes)
+		}
+		if !closes.After(opens) {
+			return fmt.Errorf("Closing time must be after opening time on %s", hours.Weekday)
+		}
+	}
+
+	for _, closure := range library.Closures {
+		if _, err := time.Parse("2006-01-02", closure.Date); err != nil {
+			return fmt.Errorf("Closure date must use YYYY-MM-DD, got %q", closure.Date)
+		}
+	}
+
+	return nil
+}
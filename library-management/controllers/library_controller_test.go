@@ -180,6 +180,10 @@ func TestListLibraries(t *testing.T) {
 	r := gin.Default()
 	r.GET("/libraries", ListLibraries(gormDB))
 	t.Run("Successful Fetch", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries" WHERE archived = $1`)).
+			WithArgs(false).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central Library"))
 
@@ -193,7 +197,7 @@ func TestListLibraries(t *testing.T) {
 	})
 
 	t.Run("Database Error", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries"`)).
 			WillReturnError(errors.New("database error"))
 
 		req := httptest.NewRequest(http.MethodGet, "/libraries", nil)
@@ -205,6 +209,10 @@ func TestListLibraries(t *testing.T) {
 	})
 
 	t.Run("Empty Database", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries" WHERE archived = $1`)).
+			WithArgs(false).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}))
 
@@ -218,6 +226,10 @@ func TestListLibraries(t *testing.T) {
 	})
 
 	t.Run("Multiple Libraries", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries" WHERE archived = $1`)).
+			WithArgs(false).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
 			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
 				AddRow(1, "Central Library").
@@ -236,7 +248,7 @@ func TestListLibraries(t *testing.T) {
 	})
 
 	t.Run("Invalid SQL Query", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries"`)).
 			WillReturnError(errors.New("syntax error in SQL query"))
 
 		req := httptest.NewRequest(http.MethodGet, "/libraries", nil)
@@ -248,7 +260,7 @@ func TestListLibraries(t *testing.T) {
 	})
 
 	t.Run("Database Timeout", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries"`)).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries"`)).
 			WillReturnError(fmt.Errorf("database timeout"))
 
 		req := httptest.NewRequest(http.MethodGet, "/libraries", nil)
@@ -278,3 +290,105 @@ func TestListLibraries(t *testing.T) {
 		//assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
+
+func TestUpdateLibrary(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/library/:id", UpdateLibrary(gormDB))
+	r.PUT("/library/:id/archive", ArchiveLibrary(gormDB))
+
+	libraryQuery := regexp.QuoteMeta(`SELECT * FROM "libraries" WHERE "libraries"."id" = $1 ORDER BY "libraries"."id" LIMIT $2`)
+
+	t.Run("Replace Opening Hours", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).
+			WithArgs("1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "timezone"}).AddRow(1, "Central Library", "UTC"))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "libraries" SET "timezone"=$1 WHERE "id" = $2`)).
+			WithArgs("Asia/Kolkata", 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "opening_hours" WHERE library_id = $1`)).
+			WithArgs(1).
+			WillReturnResult(sqlmock.NewResult(0, 7))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "opening_hours" ("library_id","weekday","opens","closes") VALUES ($1,$2,$3,$4) RETURNING "id"`)).
+			WithArgs(1, 1, "09:00", "17:00").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(10))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1", bytes.NewBufferString(`{"Timezone":"Asia/Kolkata","OpeningHours":[{"Weekday":1,"Opens":"09:00","Closes":"17:00"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Library updated successfully")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Rename To A Taken Name", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).
+			WithArgs("1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "timezone"}).AddRow(1, "Central Library", "UTC"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "libraries" WHERE name = $1 AND id <> $2`)).
+			WithArgs("East Branch", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1", bytes.NewBufferString(`{"Name":"East Branch"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"conflict"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Opening Hours", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).
+			WithArgs("1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central Library"))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1", bytes.NewBufferString(`{"OpeningHours":[{"Weekday":1,"Opens":"17:00","Closes":"09:00"}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Closing time must be after opening time")
+	})
+
+	t.Run("Unknown Timezone", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).
+			WithArgs("1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, "Central Library"))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1", bytes.NewBufferString(`{"Timezone":"Mars/Olympus"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Unknown timezone")
+	})
+
+	t.Run("Archive Already Archived", func(t *testing.T) {
+		mock.ExpectQuery(libraryQuery).
+			WithArgs("1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "archived"}).AddRow(1, "Central Library", true))
+
+		req := httptest.NewRequest(http.MethodPut, "/library/1/archive", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,473 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"library-management/apierror"
+	"library-management/callnumber"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxLocationPosition bounds a location's place among its siblings, which
+	// sort keys hold in four digits
+	maxLocationPosition = 9999
+	// maxLocationName bounds a floor, section or shelf name
+	maxLocationName = 100
+)
+
+// parentLevel is the level each location level sits under, "" for the top
+var parentLevel = map[string]string{
+	models.LocationFloor:   "",
+	models.LocationSection: models.LocationFloor,
+	models.LocationShelf:   models.LocationSection,
+}
+
+// pickListItem is one hold to pull from the shelves
+type pickListItem struct {
+	RequestID       uint   `json:"request_id"`
+	ReaderID        uint   `json:"reader_id"`
+	Status          string `json:"status"`
+	RequestDate     int64  `json:"request_date"`
+	WorkID          uint   `json:"work_id"`
+	ISBN            string `json:"isbn"`
+	Title           string `json:"title"`
+	Authors         string `json:"authors"`
+	CallNumber      string `json:"call_number"`
+	AvailableCopies int    `json:"available_copies"`
+	LocationID      uint   `json:"location_id"`
+	Location        string `json:"location"` // Path of the location, empty when unassigned
+}
+
+// ListLocations lists a library's floors, sections and shelves in walking
+// order, each with its full path - Only Admin
+func ListLocations(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		var locations []models.ShelfLocation
+		if err := db.Where("library_id = ?", libraryID).Order("sort_key ASC").Find(&locations).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch locations")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"locations": locations})
+	}
+}
+
+// CreateLocation adds a floor, a section on a floor or a shelf in a section -
+// Only Admin
+func CreateLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Level    string `json:"level" binding:"required"`
+			Name     string `json:"name" binding:"required"`
+			ParentID uint   `json:"parent_id"`
+			Position int    `json:"position"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+		want, known := parentLevel[input.Level]
+		if !known {
+			apierror.Respond(c, apierror.CodeValidationFailed, "level must be floor, section or shelf")
+			return
+		}
+		location := models.ShelfLocation{LibraryID: libraryID, Level: input.Level, ParentID: input.ParentID}
+		if !setLocationFields(c, &location, input.Name, input.Position) {
+			return
+		}
+
+		var parent models.ShelfLocation
+		switch {
+		case want == "" && input.ParentID != 0:
+			apierror.Respond(c, apierror.CodeValidationFailed, "Floors have no parent_id")
+			return
+		case want != "":
+			err := db.Where("id = ? AND library_id = ?", input.ParentID, libraryID).First(&parent).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && parent.Level != want) {
+				apierror.Respond(c, apierror.CodeValidationFailed, fmt.Sprintf("A %s needs the parent_id of a %s in this library", input.Level, want))
+				return
+			}
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Could not create location")
+				return
+			}
+		}
+		if !uniqueLocationName(db, c, location, 0) {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&location).Error; err != nil {
+				return err
+			}
+			// The key includes the new row's id, so it is set once that is known
+			placeLocation(&location, parent)
+			if err := tx.Model(&location).Updates(map[string]interface{}{"path": location.Path, "sort_key": location.SortKey}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "location.create", "shelf_location", location.ID, libraryID, nil, location)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create location")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Location created", "location": location})
+	}
+}
+
+// UpdateLocation renames a location or moves it among its siblings. The paths
+// and order of everything under it follow - Only Admin
+func UpdateLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		location, ok := libraryLocation(db, c)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Name     string `json:"name" binding:"required"`
+			Position int    `json:"position"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+		before := location
+		if !setLocationFields(c, &location, input.Name, input.Position) {
+			return
+		}
+		if !uniqueLocationName(db, c, location, location.ID) {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&location).Updates(map[string]interface{}{"name": location.Name, "position": location.Position}).Error; err != nil {
+				return err
+			}
+			if err := relocate(tx, location.LibraryID); err != nil {
+				return err
+			}
+			if err := tx.First(&location, location.ID).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "location.update", "shelf_location", location.ID, location.LibraryID, before, location)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not update location")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Location updated", "location": location})
+	}
+}
+
+// DeleteLocation removes a location with nothing under it and no books
+// assigned to it - Only Admin
+func DeleteLocation(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		location, ok := libraryLocation(db, c)
+		if !ok {
+			return
+		}
+
+		var children, books int64
+		if err := db.Model(&models.ShelfLocation{}).Where("parent_id = ?", location.ID).Count(&children).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not delete location")
+			return
+		}
+		if err := db.Model(&models.Book{}).Where("location_id = ?", location.ID).Count(&books).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not delete location")
+			return
+		}
+		if children > 0 || books > 0 {
+			apierror.Respond(c, apierror.CodeLocationNotEmpty,
+				fmt.Sprintf("%s has %d locations under it and %d books assigned, move them first", location.Path, children, books))
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&location).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "location.delete", "shelf_location", location.ID, location.LibraryID, location, nil)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not delete location")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Location deleted"})
+	}
+}
+
+// ShelveBook sets where a library keeps its copies of a book: a Dewey or LC
+// call number, its scheme detected when not given, and a floor, section or
+// shelf. Empty values clear them. Honors If-Match - Only Admin
+func ShelveBook(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		book, ok := libraryBookForAdmin(db, c)
+		if !ok {
+			return
+		}
+		if !checkIfMatch(c, book) {
+			return
+		}
+
+		var input struct {
+			CallNumber string `json:"call_number"`
+			Scheme     string `json:"scheme"`
+			LocationID uint   `json:"location_id"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		before := book
+		if !shelve(db, c, &book, input.Scheme, input.CallNumber, input.LocationID) {
+			return
+		}
+
+		var saved bool
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if saved, err = saveBookRevision(tx, &book, before.Revision); err != nil || !saved {
+				return err
+			}
+			return recordAudit(tx, c, "book.shelve", "book", book.ISBN, book.LibraryID, before, book)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not shelve book")
+			return
+		}
+		if !saved {
+			respondStaleBook(c)
+			return
+		}
+
+		c.Header("ETag", bookETag(book))
+		c.JSON(http.StatusOK, gin.H{"message": "Book shelved", "book": book})
+	}
+}
+
+// PickList lists the holds waiting in a library in the order they are pulled
+// from the shelves: by location, then call number, then ISBN. Books without a
+// location or call number come last. ?status= narrows it to Pending or
+// Approved requests - Only Admin
+func PickList(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		statuses := []string{"Pending", "Approved"}
+		switch status := c.Query("status"); status {
+		case "":
+		case "Pending", "Approved":
+			statuses = []string{status}
+		default:
+			apierror.Respond(c, apierror.CodeInvalidParameter, "status must be Pending or Approved")
+			return
+		}
+
+		items := []pickListItem{}
+		err := db.Table("request_events").
+			Select("request_events.id AS request_id, request_events.reader_id, request_events.status, request_events.request_date, request_events.work_id, "+
+				"books.isbn, books.title, books.authors, books.call_number, books.available_copies, books.location_id, "+
+				"COALESCE(shelf_locations.path, '') AS location").
+			Joins("JOIN books ON books.isbn = request_events.book_id AND books.library_id = request_events.library_id AND books.deleted_at IS NULL").
+			Joins("LEFT JOIN shelf_locations ON shelf_locations.id = books.location_id").
+			Where("request_events.library_id = ? AND request_events.request_type = ? AND request_events.status IN ? AND request_events.deleted_at IS NULL",
+				libraryID, "issue", statuses).
+			Order("shelf_locations.sort_key IS NULL, shelf_locations.sort_key, books.call_number_sort = '', books.call_number_sort, books.isbn, request_events.request_date").
+			Scan(&items).Error
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not build pick list")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"library_id": libraryID, "items": items})
+	}
+}
+
+// shelve validates a call number and location of book's library and sets
+// them. An empty call number or location 0 clears it. It writes the error
+// response and returns false when either is invalid.
+func shelve(db *gorm.DB, c *gin.Context, book *models.Book, scheme, text string, locationID uint) bool {
+	book.CallNumber, book.CallNumberScheme, book.CallNumberSort = "", "", ""
+	if strings.TrimSpace(text) != "" {
+		number, err := callnumber.Parse(strings.ToLower(scheme), text)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeValidationFailed, strings.TrimPrefix(err.Error(), callnumber.ErrInvalid.Error()+": "))
+			return false
+		}
+		book.CallNumber, book.CallNumberScheme, book.CallNumberSort = number.Text, number.Scheme, number.Key
+	}
+
+	book.LocationID = locationID
+	if locationID != 0 {
+		var location models.ShelfLocation
+		err := db.Where("id = ? AND library_id = ?", locationID, book.LibraryID).First(&location).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeValidationFailed, "location_id must be a location of the book's library")
+			return false
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not check location")
+			return false
+		}
+	}
+	return true
+}
+
+// libraryLocation reads the :location of the :id library the calling admin runs
+func libraryLocation(db *gorm.DB, c *gin.Context) (models.ShelfLocation, bool) {
+	libraryID, ok := managedLibrary(db, c)
+	if !ok {
+		return models.ShelfLocation{}, false
+	}
+	id, err := strconv.ParseUint(c.Param("location"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Location id must be a positive number")
+		return models.ShelfLocation{}, false
+	}
+
+	var location models.ShelfLocation
+	err = db.Where("id = ? AND library_id = ?", id, libraryID).First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Respond(c, apierror.CodeLocationNotFound, "Location not found in this library")
+		return models.ShelfLocation{}, false
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not fetch location")
+		return models.ShelfLocation{}, false
+	}
+	return location, true
+}
+
+// setLocationFields checks and sets a location's name and position
+func setLocationFields(c *gin.Context, location *models.ShelfLocation, name string, position int) bool {
+	name = strings.TrimSpace(name)
+	switch {
+	case name == "" || len(name) > maxLocationName:
+		apierror.Respond(c, apierror.CodeValidationFailed, fmt.Sprintf("name must be 1 to %d characters", maxLocationName))
+		return false
+	case strings.Contains(name, "/"):
+		apierror.Respond(c, apierror.CodeValidationFailed, `name cannot contain "/", it separates the levels of a path`)
+		return false
+	case position < 0 || position > maxLocationPosition:
+		apierror.Respond(c, apierror.CodeValidationFailed, fmt.Sprintf("position must be between 0 and %d", maxLocationPosition))
+		return false
+	}
+	location.Name, location.Position = name, position
+	return true
+}
+
+// uniqueLocationName refuses a name a sibling already has, other than except
+func uniqueLocationName(db *gorm.DB, c *gin.Context, location models.ShelfLocation, except uint) bool {
+	var taken int64
+	err := db.Model(&models.ShelfLocation{}).
+		Where("library_id = ? AND parent_id = ? AND name = ? AND id <> ?", location.LibraryID, location.ParentID, location.Name, except).
+		Count(&taken).Error
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not save location")
+		return false
+	}
+	if taken > 0 {
+		apierror.Respond(c, apierror.CodeConflict, fmt.Sprintf("There already is a %s named %q here", location.Level, location.Name))
+		return false
+	}
+	return true
+}
+
+// placeLocation derives a location's path and sort key from its parent's; a
+// floor's parent is the zero value. Each key segment is the position, then the
+// id so siblings at the same position keep a stable order.
+func placeLocation(location *models.ShelfLocation, parent models.ShelfLocation) {
+	segment := fmt.Sprintf("%04d-%08d", location.Position, location.ID)
+	location.Path, location.SortKey = location.Name, segment
+	if parent.ID != 0 {
+		location.Path = parent.Path + " / " + location.Name
+		location.SortKey = parent.SortKey + "/" + segment
+	}
+}
+
+// relocate recomputes the path and sort key of every location of a library,
+// top level first, saving those that changed
+func relocate(tx *gorm.DB, libraryID uint) error {
+	var locations []models.ShelfLocation
+	if err := tx.Where("library_id = ?", libraryID).Find(&locations).Error; err != nil {
+		return err
+	}
+	placed := make(map[uint]models.ShelfLocation, len(locations))
+	for _, level := range []string{models.LocationFloor, models.LocationSection, models.LocationShelf} {
+		for _, location := range locations {
+			if location.Level != level {
+				continue
+			}
+			path, key := location.Path, location.SortKey
+			placeLocation(&location, placed[location.ParentID])
+			placed[location.ID] = location
+			if location.Path == path && location.SortKey == key {
+				continue
+			}
+			if err := tx.Model(&location).Updates(map[string]interface{}{"path": location.Path, "sort_key": location.SortKey}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// locationPaths maps the location ids of books to their paths, skipping 0
+func locationPaths(db *gorm.DB, books []models.Book) (map[uint]string, error) {
+	var ids []uint
+	for _, book := range books {
+		if book.LocationID != 0 {
+			ids = append(ids, book.LocationID)
+		}
+	}
+	paths := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return paths, nil
+	}
+	var locations []models.ShelfLocation
+	if err := db.Select("id, path").Where("id IN ?", ids).Find(&locations).Error; err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		paths[location.ID] = location.Path
+	}
+	return paths, nil
+}
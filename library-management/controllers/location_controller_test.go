@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestLocations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+	})
+	r.POST("/library/:id/locations", CreateLocation(gormDB))
+	r.DELETE("/library/:id/locations/:location", DeleteLocation(gormDB))
+	r.PUT("/book/:isbn/shelf", ShelveBook(gormDB))
+	r.GET("/library/:id/picklist", PickList(gormDB))
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectManager := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 ORDER BY "user_libraries"."user_id" LIMIT $3`)).
+			WithArgs(1, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 2))
+	}
+	expectBook := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs("9780134190440", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "library_id", "revision"}).AddRow(4, "9780134190440", "The Go Programming Language", 2, 3))
+	}
+
+	t.Run("Create Floor", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "shelf_locations" WHERE library_id = $1 AND parent_id = $2 AND name = $3 AND id <> $4`)).
+			WithArgs(2, 0, "Floor 2", 0).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "shelf_locations"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "parent_id", "position"}).AddRow(5, 0, 2))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "shelf_locations" SET "path"=$1,"sort_key"=$2,"updated_at"=$3 WHERE "id" = $4`)).
+			WithArgs("Floor 2", "0002-00000005", sqlmock.AnyArg(), 5).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/2/locations", `{"level":"floor","name":" Floor 2 ","position":2}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"path":"Floor 2"`)
+		assert.NotContains(t, w.Body.String(), "sort_key")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Shelf Needs A Section", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shelf_locations" WHERE id = $1 AND library_id = $2 ORDER BY "shelf_locations"."id" LIMIT $3`)).
+			WithArgs(5, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id", "level"}).AddRow(5, 2, models.LocationFloor))
+
+		w := send(http.MethodPost, "/library/2/locations", `{"level":"shelf","name":"Shelf 4","parent_id":5}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "needs the parent_id of a section")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Names And Levels", func(t *testing.T) {
+		for _, body := range []string{
+			`{"level":"room","name":"Annex"}`,
+			`{"level":"floor","name":"Floor 1/2"}`,
+			`{"level":"floor","name":"Floor 1","position":10000}`,
+		} {
+			expectManager()
+			w := send(http.MethodPost, "/library/2/locations", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), `"code":"validation_failed"`, body)
+		}
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Delete Location In Use", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shelf_locations" WHERE id = $1 AND library_id = $2 ORDER BY "shelf_locations"."id" LIMIT $3`)).
+			WithArgs(8, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id", "level", "path"}).AddRow(8, 2, models.LocationShelf, "Floor 2 / Science / Shelf 4"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "shelf_locations" WHERE parent_id = $1`)).
+			WithArgs(8).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE location_id = $1 AND "books"."deleted_at" IS NULL`)).
+			WithArgs(8).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+		w := send(http.MethodDelete, "/library/2/locations/8", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"location_not_empty"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Shelve Book", func(t *testing.T) {
+		expectManager()
+		expectBook()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "shelf_locations" WHERE id = $1 AND library_id = $2 ORDER BY "shelf_locations"."id" LIMIT $3`)).
+			WithArgs(8, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id", "level"}).AddRow(8, 2, models.LocationShelf))
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPut, "/book/9780134190440/shelf?library_id=2", `{"call_number":"QA76.73.G63  D66 2016","location_id":8}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"CallNumber":"QA76.73.G63 D66 2016"`)
+		assert.Contains(t, w.Body.String(), `"CallNumberScheme":"lcc"`)
+		assert.Contains(t, w.Body.String(), `"LocationID":8`)
+		assert.NotContains(t, w.Body.String(), "CallNumberSort")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Shelve Book Rejects Invalid Call Number", func(t *testing.T) {
+		expectManager()
+		expectBook()
+
+		w := send(http.MethodPut, "/book/9780134190440/shelf?library_id=2", `{"call_number":"823.9.1","scheme":"ddc"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Shelve Book With Stale ETag", func(t *testing.T) {
+		expectManager()
+		expectBook()
+
+		req := httptest.NewRequest(http.MethodPut, "/book/9780134190440/shelf?library_id=2", bytes.NewBufferString(`{"call_number":"823.914 TOL"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"book-4-2"`)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Pick List In Shelf Order", func(t *testing.T) {
+		expectManager()
+		mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY shelf_locations.sort_key IS NULL, shelf_locations.sort_key, books.call_number_sort = '', books.call_number_sort, books.isbn, request_events.request_date`)).
+			WithArgs(2, "issue", "Approved").
+			WillReturnRows(sqlmock.NewRows([]string{"request_id", "status", "isbn", "call_number", "location_id", "location"}).
+				AddRow(11, "Approved", "9780134190440", "QA76.73.G63 D66 2016", 8, "Floor 2 / Science / Shelf 4").
+				AddRow(12, "Approved", "9780261103252", "", 0, ""))
+
+		w := send(http.MethodGet, "/library/2/picklist?status=Approved", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Regexp(t, `"request_id":11.*"location":"Floor 2 / Science / Shelf 4".*"request_id":12`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Pick List Rejects Other Statuses", func(t *testing.T) {
+		expectManager()
+
+		w := send(http.MethodGet, "/library/2/picklist?status=Rejected", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestPlaceLocation(t *testing.T) {
+	floor := models.ShelfLocation{ID: 3, Name: "Floor 2", Position: 2}
+	placeLocation(&floor, models.ShelfLocation{})
+	section := models.ShelfLocation{ID: 12, Name: "Science", Position: 10}
+	placeLocation(&section, floor)
+
+	assert.Equal(t, "Floor 2 / Science", section.Path)
+	assert.Equal(t, "0002-00000003/0010-00000012", section.SortKey)
+
+	// A later sibling placed first in the walk sorts first
+	before := models.ShelfLocation{ID: 40, Name: "Art", Position: 1}
+	placeLocation(&before, floor)
+	assert.Less(t, before.SortKey, section.SortKey)
+}
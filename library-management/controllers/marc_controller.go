@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"library-management/apierror"
+	"library-management/authority"
+	"library-management/marc"
+	"library-management/middleware"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxMARCUpload bounds one import, a few thousand vendor records
+	maxMARCUpload = 10 << 20
+	// maxImportCopies bounds ?copies, the stock given to each new book
+	maxImportCopies = 1000
+)
+
+// marcImportResult is what happened to one record of an import
+type marcImportResult struct {
+	Index    int             `json:"index"`
+	ISBN     string          `json:"isbn"`
+	Title    string          `json:"title"`
+	Status   string          `json:"status"` // created, updated, unchanged or rejected
+	Unmapped []marc.Unmapped `json:"unmapped"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// marcImportReport sums up an import
+type marcImportReport struct {
+	Created   int                `json:"created"`
+	Updated   int                `json:"updated"`
+	Unchanged int                `json:"unchanged"`
+	Rejected  int                `json:"rejected"`
+	Records   []marcImportResult `json:"records"`
+}
+
+// ImportMARC adds a library's books from MARC21 or MARCXML records, chosen by
+// Content-Type. New ISBNs are created with ?copies copies each; ISBNs the library
+// already holds have their details replaced by the record's and keep their
+// stock. Every record is reported with the fields it could not be mapped from.
+// A bad record is rejected without stopping the rest - Only Admin
+func ImportMARC(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		format, ok := marc.FormatOf(c.GetHeader("Content-Type"))
+		if !ok {
+			apierror.Respond(c, apierror.CodeMalformedRequest, "Send records as application/marc or application/marcxml+xml")
+			return
+		}
+
+		copies, err := strconv.Atoi(c.DefaultQuery("copies", "1"))
+		if err != nil || copies < 1 || copies > maxImportCopies {
+			apierror.Respond(c, apierror.CodeInvalidParameter, fmt.Sprintf("copies must be between 1 and %d", maxImportCopies))
+			return
+		}
+
+		// Everything is read before anything is written, so a truncated or
+		// malformed upload changes nothing
+		var records []marc.Record
+		var readErrors []error
+		reader := marc.NewReader(format, http.MaxBytesReader(c.Writer, c.Request.Body, maxMARCUpload))
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil && !errors.Is(err, marc.ErrInvalidRecord) {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					apierror.Respond(c, apierror.CodeMalformedRequest, fmt.Sprintf("Upload is larger than %d MB, split it", maxMARCUpload>>20))
+				} else {
+					apierror.Respond(c, apierror.CodeMalformedRequest, "Could not read MARC records: "+err.Error())
+				}
+				return
+			}
+			records = append(records, record)
+			readErrors = append(readErrors, err)
+		}
+		if len(records) == 0 {
+			apierror.Respond(c, apierror.CodeValidationFailed, "The upload holds no MARC records")
+			return
+		}
+
+		report := marcImportReport{Records: make([]marcImportResult, len(records))}
+		for i, record := range records {
+			result := marcImportResult{Index: i + 1, Unmapped: []marc.Unmapped{}}
+			if readErrors[i] != nil {
+				result.Error = strings.TrimPrefix(readErrors[i].Error(), "marc: ")
+			} else {
+				var book models.Book
+				book, result.Unmapped = marc.ToBook(record)
+				result.ISBN, result.Title = book.ISBN, book.Title
+				switch {
+				case book.ISBN == "":
+					result.Error = "Record has no ISBN in 020 $a"
+				case book.Title == "":
+					result.Error = "Record has no title in 245 $a"
+				default:
+					book.LibraryID = libraryID
+					if result.Status, err = importBook(db, c, book, copies); err != nil {
+						middleware.Logger(c).Error("marc import failed", "isbn", book.ISBN, "library_id", libraryID, "error", err)
+						result.Error = "Could not save the book"
+					}
+				}
+			}
+
+			if result.Error != "" {
+				result.Status = "rejected"
+			}
+			switch result.Status {
+			case "created":
+				report.Created++
+			case "updated":
+				report.Updated++
+			case "unchanged":
+				report.Unchanged++
+			default:
+				report.Rejected++
+			}
+			report.Records[i] = result
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// importBook creates book in its library with copies copies, or replaces the
+// details of the library's existing copy. It returns the record's status.
+func importBook(db *gorm.DB, c *gin.Context, book models.Book, copies int) (string, error) {
+	var existing models.Book
+	err := db.Where("isbn = ? AND library_id = ?", book.ISBN, book.LibraryID).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		book.TotalCopies, book.AvailableCopies = copies, copies
+		if err := createBook(db, c, &book); err != nil {
+			return "", err
+		}
+		return "created", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Fields the record leaves empty keep what the library has
+	before := existing
+	existing.Title = book.Title
+	replace := func(field *string, value string) {
+		if value != "" {
+			*field = value
+		}
+	}
+	replace(&existing.Authors, book.Authors)
+	replace(&existing.Publisher, book.Publisher)
+	replace(&existing.Version, book.Version)
+	replace(&existing.Subjects, book.Subjects)
+	if existing.Title == before.Title && existing.Authors == before.Authors && existing.Publisher == before.Publisher &&
+		existing.Version == before.Version && existing.Subjects == before.Subjects {
+		return "unchanged", nil
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		saved, err := saveBookRevision(tx, &existing, before.Revision)
+		if err != nil {
+			return err
+		}
+		if !saved {
+			return errors.New("book changed during import")
+		}
+		if existing.Authors != before.Authors || existing.Publisher != before.Publisher || existing.Subjects != before.Subjects {
+			if err := authority.Link(tx, existing); err != nil {
+				return err
+			}
+		}
+		return recordAudit(tx, c, "book.update", "book", existing.ISBN, existing.LibraryID, before, existing)
+	})
+	if err != nil {
+		return "", err
+	}
+	return "updated", nil
+}
+
+// ExportMARC streams a library's books as MARCXML, or as MARC21 with
+// ?format=marc - Only Admin
+func ExportMARC(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		format := marc.Format(c.DefaultQuery("format", string(marc.XML)))
+		if format != marc.XML && format != marc.Binary {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "format must be marcxml or marc")
+			return
+		}
+
+		c.Header("Content-Type", format.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="library-%d%s"`, libraryID, format.Extension()))
+		c.Status(http.StatusOK)
+
+		writer := marc.NewWriter(format, c.Writer)
+		var batch []models.Book
+		err := db.Where("library_id = ?", libraryID).FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, book := range batch {
+				if err := writer.Write(marc.FromBook(book)); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		}).Error
+		if err == nil {
+			err = writer.Close()
+		}
+		if err != nil {
+			// Headers are already sent, all we can do is stop and log
+			middleware.Logger(c).Error("marc export aborted", "library_id", libraryID, "error", err)
+		}
+	}
+}
+
+// managedLibrary reads the :id library and checks the calling admin runs it
+func managedLibrary(db *gorm.DB, c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Library id must be a positive number")
+		return 0, false
+	}
+
+	var admin models.UserLibrary
+	if err := db.Where("user_id = ? AND library_id = ?", c.GetUint("userID"), id).First(&admin).Error; err != nil {
+		apierror.Respond(c, apierror.CodeLibraryAccess, "You can only work on the catalogs of libraries you manage")
+		return 0, false
+	}
+	return uint(id), true
+}
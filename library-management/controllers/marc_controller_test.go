@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"library-management/marc"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// marcUpload has a record changing a held book's title, one matching a held
+// book exactly, one without an ISBN and one that is not a valid record
+const marcUpload = `<?xml version="1.0" encoding="UTF-8"?>
+<collection xmlns="http://www.loc.gov/MARC21/slim">
+  <record>
+    <leader>00000cam a2200000 i 4500</leader>
+    <controlfield tag="001">ocm1</controlfield>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780441172719</subfield></datafield>
+    <datafield tag="100" ind1="1" ind2=" "><subfield code="a">Herbert, Frank.</subfield></datafield>
+    <datafield tag="245" ind1="1" ind2="0"><subfield code="a">Dune :</subfield><subfield code="b">a novel</subfield></datafield>
+  </record>
+  <record>
+    <leader>00000cam a2200000 i 4500</leader>
+    <datafield tag="020" ind1=" " ind2=" "><subfield code="a">9780140449136</subfield></datafield>
+    <datafield tag="245" ind1="0" ind2="0"><subfield code="a">Crime and Punishment</subfield></datafield>
+  </record>
+  <record>
+    <leader>00000cam a2200000 i 4500</leader>
+    <datafield tag="245" ind1="0" ind2="0"><subfield code="a">Beowulf</subfield></datafield>
+  </record>
+  <record>
+    <datafield tag="24" ind1="0" ind2="0"><subfield code="a">Broken</subfield></datafield>
+  </record>
+</collection>`
+
+func TestMARC(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+	})
+	r.POST("/library/:id/marc", ImportMARC(gormDB))
+	r.GET("/library/:id/marc", ExportMARC(gormDB))
+
+	send := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectManager := func(manages bool) {
+		rows := sqlmock.NewRows([]string{"user_id", "library_id"})
+		if manages {
+			rows.AddRow(1, 2)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 ORDER BY "user_libraries"."user_id" LIMIT $3`)).
+			WithArgs(1, 2, 1).
+			WillReturnRows(rows)
+	}
+	expectHeldBook := func(isbn, title, authors string) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs(isbn, 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "total_copies", "available_copies", "library_id", "revision"}).
+				AddRow(5, isbn, title, authors, 2, 2, 2, 1))
+	}
+
+	t.Run("Import Reports Each Record", func(t *testing.T) {
+		expectManager(true)
+		expectHeldBook("9780441172719", "Dune", "Frank Herbert")
+		mock.ExpectBegin()
+		mock.ExpectExec(`UPDATE "books" SET .*"revision"=\$\d+ WHERE revision = \$\d+ AND "books"."deleted_at" IS NULL AND "id" = \$\d+`).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+		expectHeldBook("9780140449136", "Crime and Punishment", "")
+
+		w := send(http.MethodPost, "/library/2/marc", "application/marcxml+xml", marcUpload)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var report marcImportReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, 0, report.Created)
+		assert.Equal(t, 1, report.Updated)
+		assert.Equal(t, 1, report.Unchanged)
+		assert.Equal(t, 2, report.Rejected)
+		require.Len(t, report.Records, 4)
+		assert.Equal(t, "Dune: a novel", report.Records[0].Title)
+		assert.Equal(t, []marc.Unmapped{{Tag: "001", Reason: "field is not represented"}}, report.Records[0].Unmapped)
+		assert.Equal(t, "unchanged", report.Records[1].Status)
+		assert.Equal(t, "Record has no ISBN in 020 $a", report.Records[2].Error)
+		assert.Equal(t, "rejected", report.Records[3].Status)
+		assert.Contains(t, report.Records[3].Error, `data field tag "24"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unsupported Content Type", func(t *testing.T) {
+		expectManager(true)
+
+		w := send(http.MethodPost, "/library/2/marc", "application/json", `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"malformed_request"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Malformed XML Changes Nothing", func(t *testing.T) {
+		expectManager(true)
+
+		w := send(http.MethodPost, "/library/2/marc", "application/marcxml+xml", `<collection><record><leader>`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Could not read MARC records")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Library Not Managed", func(t *testing.T) {
+		expectManager(false)
+
+		w := send(http.MethodGet, "/library/2/marc", "", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"library_access_denied"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Export As MARC21", func(t *testing.T) {
+		expectManager(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE library_id = $1 AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $2`)).
+			WithArgs(2, 500).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "publisher", "library_id"}).
+				AddRow(5, "9780441172719", "Dune", "Frank Herbert", "Ace", 2))
+
+		w := send(http.MethodGet, "/library/2/marc?format=marc", "", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/marc", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="library-2.mrc"`, w.Header().Get("Content-Disposition"))
+
+		record, err := marc.NewReader(marc.Binary, w.Body).Read()
+		require.NoError(t, err)
+		book, _ := marc.ToBook(record)
+		assert.Equal(t, "9780441172719", book.ISBN)
+		assert.Equal(t, "Frank Herbert", book.Authors)
+		assert.Equal(t, "Ace", book.Publisher)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Export Format", func(t *testing.T) {
+		expectManager(true)
+
+		w := send(http.MethodGet, "/library/2/marc?format=csv", "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,289 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"library-management/apierror"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListMemberships lists reader membership applications for the admin's libraries
+func ListMemberships(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
+			return
+		}
+
+		status := c.DefaultQuery("status", "Pending")
+		if status != "Pending" && status != "Approved" && status != "Rejected" {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Status must be one of Pending, Approved or Rejected")
+			return
+		}
+
+		var adminLibraryIDs []uint
+		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraryIDs).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch admin libraries")
+			return
+		}
+
+		if len(adminLibraryIDs) == 0 {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "Admin is not associated with any library")
+			return
+		}
+
+		var memberships []struct {
+			models.UserLibrary
+			Name  string
+			Email string
+		}
+		if err := db.Table("user_libraries").
+			Select("user_libraries.*, users.name, users.email").
+			Joins("JOIN users ON users.id = user_libraries.user_id").
+			Where("user_libraries.library_id IN (?) AND user_libraries.status = ? AND users.role = ? AND users.deleted_at IS NULL", adminLibraryIDs, status, "user").
+			Order("user_libraries.requested_at ASC").
+			Scan(&memberships).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch memberships")
+			return
+		}
+
+		formattedMemberships := make([]gin.H, len(memberships))
+		for i, membership := range memberships {
+			formattedMemberships[i] = gin.H{
+				"user_id":      membership.UserID,
+				"name":         membership.Name,
+				"email":        membership.Email,
+				"library_id":   membership.LibraryID,
+				"status":       membership.Status,
+				"requested_at": formatUnixTime(&membership.RequestedAt),
+				"reviewed_at":  formatUnixTime(membership.ReviewedAt),
+				"reviewer_id":  membership.ReviewerID,
+				"reason":       membership.Reason,
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"memberships": formattedMemberships})
+	}
+}
+
+// ApproveMembership allows an admin to approve a pending membership
+func ApproveMembership(db *gorm.DB) gin.HandlerFunc {
+	return reviewMembership(db, "Approved")
+}
+
+// RejectMembership allows an admin to reject a pending membership, a reason is required
+func RejectMembership(db *gorm.DB) gin.HandlerFunc {
+	return reviewMembership(db, "Rejected")
+}
+
+func reviewMembership(db *gorm.DB, decision string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		adminID, exists := c.Get("userID")
+		if !exists {
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
+			return
+		}
+
+		libraryID, libErr := strconv.ParseUint(c.Param("libraryid"), 10, 64)
+		userID, userErr := strconv.ParseUint(c.Param("userid"), 10, 64)
+		if libErr != nil || userErr != nil {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Invalid library or user ID")
+			return
+		}
+
+		// The body is optional when approving
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+			apierror.Respond(c, apierror.CodeMalformedRequest, "Invalid JSON input")
+			return
+		}
+		input.Reason = strings.TrimSpace(input.Reason)
+
+		if decision == "Rejected" && input.Reason == "" {
+			apierror.Respond(c, apierror.CodeValidationFailed, "A reason is required to reject a membership")
+			return
+		}
+
+		var admin models.UserLibrary
+		if err := db.Where("user_id = ? AND library_id = ?", adminID, libraryID).First(&admin).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You can only review memberships for libraries you manage")
+			return
+		}
+
+		var membership models.UserLibrary
+		if err := db.Where("user_id = ? AND library_id = ?", userID, libraryID).First(&membership).Error; err != nil {
+			apierror.Respond(c, apierror.CodeMembershipNotFound, "Membership request not found")
+			return
+		}
+
+		if membership.Status != "Pending" {
+			apierror.Respond(c, apierror.CodeAlreadyProcessed, "Membership request is already processed")
+			return
+		}
+
+		before := membership
+		now := time.Now().Unix()
+		reviewerID := adminID.(uint)
+		membership.Status = decision
+		membership.ReviewerID = &reviewerID
+		membership.ReviewedAt = &now
+		membership.Reason = input.Reason
+
+		action := "membership.approve"
+		if decision == "Rejected" {
+			action = "membership.reject"
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&models.UserLibrary{}).
+				Where("user_id = ? AND library_id = ?", userID, libraryID).
+				Updates(map[string]interface{}{
+					"status":      membership.Status,
+					"reviewer_id": reviewerID,
+					"reviewed_at": now,
+					"reason":      membership.Reason,
+				}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, action, "membership", fmt.Sprintf("%d:%d", libraryID, userID), uint(libraryID), before, membership)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not update membership")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Membership " + strings.ToLower(decision), "membership": membership})
+	}
+}
+
+// EnrollUser lets an admin enroll a reader directly into libraries they manage.
+// New readers are created on the fly; memberships created this way skip the queue.
+func EnrollUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Name       string `json:"name"`
+			Email      string `json:"email" binding:"required,email"`
+			Password   string `json:"password"`
+			Contact    string `json:"contact"`
+			LibraryIDs []uint `json:"library_ids" binding:"required,min=1"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		adminID := c.GetUint("userID")
+
+		var adminLibraries []uint
+		if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraries).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not verify admin libraries")
+			return
+		}
+
+		// Check if the libraries provided in the input are accessible by the admin
+		for _, libID := range input.LibraryIDs {
+			found := false
+			for _, adminLibID := range adminLibraries {
+				if libID == adminLibID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				apierror.Respond(c, apierror.CodeLibraryAccess, fmt.Sprintf("You can only add users to libraries you manage (Library ID: %d)", libID))
+				return
+			}
+		}
+
+		var user models.User
+		err := db.Where("email = ?", input.Email).First(&user).Error
+		isNewUser := errors.Is(err, gorm.ErrRecordNotFound)
+		if err != nil && !isNewUser {
+			apierror.Respond(c, apierror.CodeInternal, "Database error")
+			return
+		}
+
+		if !isNewUser && user.Role != "user" {
+			apierror.Respond(c, apierror.CodeEmailTaken, "Email is registered to a staff account")
+			return
+		}
+
+		if isNewUser {
+			if input.Name == "" || len(input.Password) < 8 {
+				apierror.Respond(c, apierror.CodeValidationFailed, "Name and a password of at least 8 characters are required for new users")
+				return
+			}
+			user = models.User{
+				Name:     input.Name,
+				Email:    input.Email,
+				Password: input.Password,
+				Contact:  input.Contact,
+				Role:     "user",
+			}
+		}
+
+		now := time.Now().Unix()
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if isNewUser {
+				if err := tx.Create(&user).Error; err != nil {
+					return err
+				}
+			}
+
+			// Approve any pending or rejected application for the same library
+			for _, libID := range input.LibraryIDs {
+				membership := models.UserLibrary{
+					UserID:      user.ID,
+					LibraryID:   libID,
+					Status:      "Approved",
+					RequestedAt: now,
+					ReviewerID:  &adminID,
+					ReviewedAt:  &now,
+				}
+				if err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "user_id"}, {Name: "library_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"status", "reviewer_id", "reviewed_at", "reason"}),
+				}).Create(&membership).Error; err != nil {
+					return err
+				}
+				if err := recordAudit(tx, c, "membership.enroll", "membership", fmt.Sprintf("%d:%d", libID, user.ID), libID, nil, gin.H{"UserID": user.ID, "NewUser": isNewUser, "Status": "Approved"}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not enroll user")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "User enrolled successfully",
+			"user": gin.H{
+				"ID":          user.ID,
+				"Name":        user.Name,
+				"Email":       user.Email,
+				"Role":        user.Role,
+				"Contact":     user.Contact,
+				"library_ids": input.LibraryIDs,
+			},
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListMemberships(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/memberships", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		ListMemberships(gormDB)(c)
+	})
+
+	t.Run("Pending Queue", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT user_libraries.*, users.name, users.email FROM "user_libraries" JOIN users ON users.id = user_libraries.user_id WHERE user_libraries.library_id IN ($1) AND user_libraries.status = $2 AND users.role = $3 AND users.deleted_at IS NULL ORDER BY user_libraries.requested_at ASC`)).
+			WithArgs(1, "Pending", "user").
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id", "status", "requested_at", "name", "email"}).
+				AddRow(2, 1, "Pending", 1741480342, "Reader", "reader@example.com"))
+
+		req := httptest.NewRequest(http.MethodGet, "/memberships", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "reader@example.com")
+		assert.Contains(t, w.Body.String(), `"reviewed_at":"N/A"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Status Filter", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/memberships?status=Unknown", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestReviewMembership(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/membership/approve/:libraryid/:userid", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		ApproveMembership(gormDB)(c)
+	})
+	r.PUT("/membership/reject/:libraryid/:userid", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		RejectMembership(gormDB)(c)
+	})
+
+	adminQuery := regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 ORDER BY "user_libraries"."user_id" LIMIT $3`)
+
+	t.Run("Approve Pending Membership", func(t *testing.T) {
+		mock.ExpectQuery(adminQuery).
+			WithArgs(1, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id", "status"}).AddRow(1, 1, "Approved"))
+		mock.ExpectQuery(adminQuery).
+			WithArgs(2, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id", "status"}).AddRow(2, 1, "Pending"))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "user_libraries" SET "reason"=$1,"reviewed_at"=$2,"reviewer_id"=$3,"status"=$4 WHERE user_id = $5 AND library_id = $6`)).
+			WithArgs("", sqlmock.AnyArg(), 1, "Approved", 2, 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/membership/approve/1/2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Membership approved")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already Processed", func(t *testing.T) {
+		mock.ExpectQuery(adminQuery).
+			WithArgs(1, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id", "status"}).AddRow(1, 1, "Approved"))
+		mock.ExpectQuery(adminQuery).
+			WithArgs(2, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id", "status"}).AddRow(2, 1, "Rejected"))
+
+		req := httptest.NewRequest(http.MethodPut, "/membership/approve/1/2", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Membership request is already processed")
+	})
+
+	t.Run("Reject Without Reason", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/membership/reject/1/2", bytes.NewBufferString(`{"reason":"  "}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "A reason is required to reject a membership")
+	})
+
+	t.Run("Library Not Managed", func(t *testing.T) {
+		mock.ExpectQuery(adminQuery).
+			WithArgs(1, 3, 1).
+			WillReturnError(gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest(http.MethodPut, "/membership/reject/3/2", bytes.NewBufferString(`{"reason":"Outside catchment area"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
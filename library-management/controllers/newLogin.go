@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"fmt"
+	"library-management/apierror"
 	"library-management/models"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -11,20 +13,28 @@ import (
 
 func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var input models.User
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
 			return
 		}
 
 		if input.Role != "owner" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role, must be 'owner'"})
+			apierror.Respond(c, apierror.CodeValidationFailed, "Invalid role, must be 'owner'")
 			return
 		}
 
-		if err := db.Create(&input).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create owner"})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&input).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "user.create_owner", "user", input.ID, 0, nil, input)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create owner")
 			return
 		}
 
@@ -34,6 +44,8 @@ func RegisterOwnerNew(db *gorm.DB) gin.HandlerFunc {
 
 func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var input struct {
 			Name       string `json:"name" binding:"required"`
 			Email      string `json:"email" binding:"required,email"`
@@ -43,14 +55,14 @@ func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
 			return
 		}
 
 		creatorID := c.GetUint("userID")
 		var creator models.User
 		if err := db.First(&creator, creatorID).Error; err != nil || creator.Role != "owner" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Only an owner can create an admin"})
+			apierror.Respond(c, apierror.CodeForbidden, "Only an owner can create an admin")
 			return
 		}
 
@@ -62,31 +74,44 @@ func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 			Role:     "admin",
 		}
 
-		if err := db.Create(&admin).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create admin"})
-			return
-		}
-
-		for _, libID := range input.LibraryIDs {
-			var library models.Library
-			if err := db.First(&library, libID).Error; err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Library ID %d not found", libID)})
-				return
+		// The admin, their libraries and the audit entry are kept together or not at all
+		var missing uint
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&admin).Error; err != nil {
+				return err
 			}
 
-			adminLibrary := models.UserLibrary{
-				UserID:    admin.ID,
-				LibraryID: libID,
-			}
-			if err := db.Create(&adminLibrary).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate admin with library"})
-				return
+			for _, libID := range input.LibraryIDs {
+				var library models.Library
+				if err := tx.First(&library, libID).Error; err != nil {
+					missing = libID
+					return err
+				}
+
+				adminLibrary := models.UserLibrary{
+					UserID:    admin.ID,
+					LibraryID: libID,
+					Status:    "Approved",
+				}
+				if err := tx.Create(&adminLibrary).Error; err != nil {
+					return err
+				}
 			}
+
+			return recordAudit(tx, c, "user.create_admin", "user", admin.ID, 0, nil, gin.H{"User": admin, "LibraryIDs": input.LibraryIDs})
+		})
+		if missing != 0 {
+			apierror.Respond(c, apierror.CodeValidationFailed, fmt.Sprintf("Library ID %d not found", missing))
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create admin")
+			return
 		}
 
 		var adminWithLibraries models.User
 		if err := db.Preload("Library").First(&adminWithLibraries, admin.ID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load libraries"})
+			apierror.Respond(c, apierror.CodeInternal, "Failed to load libraries")
 			return
 		}
 
@@ -106,6 +131,8 @@ func RegisterAdmin(db *gorm.DB) gin.HandlerFunc {
 
 func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		var input struct {
 			Name       string `json:"name" binding:"required"`
 			Email      string `json:"email" binding:"required,email"`
@@ -115,41 +142,13 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing required fields"})
+			apierror.Respond(c, apierror.CodeValidationFailed, "Missing required fields")
 			return
 		}
-		//adminID := c.GetUint("userID")
-		///var admin models.User
-		//if err := db.First(&admin, adminID).Error; err != nil || admin.Role != "admin" {
-		//	c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can create users"})
-		//	return
-		//}
-
-		//var adminLibraries []uint
-		//if err := db.Table("user_libraries").Where("user_id = ?", adminID).Pluck("library_id", &adminLibraries).Error; err != nil {
-		//	c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not verify admin libraries"})
-		//	return
-		//}
-
-		// Check if the libraries provided in the input are accessible by the admin
-		//	for _, libID := range input.LibraryIDs {
-		//		found := false
-		//		for _, adminLibID := range adminLibraries {
-		//			if libID == adminLibID {
-		//			found = true
-		//			break
-		//		}
-		//	}
-		//	if !found {
-		//		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("You can only add users to libraries you manage (Library ID: %d)", libID)})
-		//		return
-		//	}
-		//}
-
 		// Check for duplicate email
 		var existingUser models.User
 		if err := db.Where("email = ?", input.Email).First(&existingUser).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			apierror.Respond(c, apierror.CodeEmailTaken, "Email already registered")
 			return
 		}
 
@@ -162,31 +161,39 @@ func RegisterUser(db *gorm.DB) gin.HandlerFunc {
 			Role:     "user",
 		}
 
-		if err := db.Create(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not register user"})
-			return
-		}
-
-		for _, libID := range input.LibraryIDs {
-			userLibrary := models.UserLibrary{
-				UserID:    user.ID,
-				LibraryID: libID,
+		// Memberships stay pending until an admin of the library approves them
+		requestedAt := time.Now().Unix()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&user).Error; err != nil {
+				return err
 			}
-			if err := db.Create(&userLibrary).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate user with library"})
-				return
+			for _, libID := range input.LibraryIDs {
+				userLibrary := models.UserLibrary{
+					UserID:      user.ID,
+					LibraryID:   libID,
+					Status:      "Pending",
+					RequestedAt: requestedAt,
+				}
+				if err := tx.Create(&userLibrary).Error; err != nil {
+					return err
+				}
 			}
+			return recordAudit(tx, c, "user.register", "user", user.ID, 0, nil, gin.H{"User": user, "LibraryIDs": input.LibraryIDs})
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not register user")
+			return
 		}
 
 		// Preload libraries for the response
 		var userWithLibraries models.User
 		if err := db.Preload("Library").First(&userWithLibraries, user.ID).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load libraries"})
+			apierror.Respond(c, apierror.CodeInternal, "Failed to load libraries")
 			return
 		}
 
 		c.JSON(http.StatusCreated, gin.H{
-			"message": "User registered successfully",
+			"message": "User registered successfully, library memberships are pending approval",
 			"user": gin.H{
 				"ID":      userWithLibraries.ID,
 				"Name":    userWithLibraries.Name,
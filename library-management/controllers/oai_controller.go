@@ -0,0 +1,274 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"library-management/apierror"
+	"library-management/models"
+	"library-management/oai"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// oaiDatestamp is when a book last changed, or when it was deleted
+const oaiDatestamp = "COALESCE(books.deleted_at, books.updated_at)"
+
+// OAIPMH is an OAI-PMH 2.0 data provider over the books of libraries with a
+// public catalog, each library a set, in oai_dc. Deleted books keep answering
+// with a deleted header; a library leaving the public catalog takes its books
+// out without one, so deleted records are only transient. Protocol errors are
+// OAI error elements with 200 OK, as the protocol requires.
+func OAIPMH(db *gorm.DB, config oai.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		repository := oaiRepository(c, config)
+		response := oai.NewResponse(repository.BaseURL, time.Now())
+
+		values := c.Request.URL.Query()
+		var err error
+		if c.Request.Method == http.MethodPost {
+			if err := c.Request.ParseForm(); err != nil {
+				apierror.Respond(c, apierror.CodeMalformedRequest, "Send arguments as application/x-www-form-urlencoded")
+				return
+			}
+			values = c.Request.PostForm
+		}
+		request, oaiErr := oai.ParseRequest(values)
+		if oaiErr == nil {
+			response.Request.Request = request
+			err = answerOAI(db, repository, request, response)
+			if err != nil && !errors.As(err, &oaiErr) {
+				apierror.Respond(c, apierror.CodeInternal, "Could not read the catalog")
+				return
+			}
+		}
+		if oaiErr != nil {
+			response.Fail(oaiErr)
+		}
+
+		body, err := response.Marshal()
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not render the OAI-PMH response")
+			return
+		}
+		c.Data(http.StatusOK, "text/xml; charset=utf-8", body)
+	}
+}
+
+// oaiRepository fills in what the configuration leaves to the request
+func oaiRepository(c *gin.Context, config oai.Config) oai.Config {
+	if config.RepositoryIdentifier == "" {
+		config.RepositoryIdentifier = c.Request.Host
+		if host, _, err := net.SplitHostPort(c.Request.Host); err == nil {
+			config.RepositoryIdentifier = host
+		}
+	}
+	if config.AdminEmail == "" {
+		config.AdminEmail = "admin@" + config.RepositoryIdentifier
+	}
+	if config.BaseURL == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		config.BaseURL = scheme + "://" + c.Request.Host + c.Request.URL.Path
+	}
+	return config
+}
+
+// answerOAI fills in the response to a valid request. Protocol errors are
+// returned as *oai.Error.
+func answerOAI(db *gorm.DB, repository oai.Config, request oai.Request, response *oai.Response) error {
+	switch request.Verb {
+	case oai.Identify:
+		var earliest sql.NullTime
+		if err := oaiBooks(db).Select("MIN(" + oaiDatestamp + ")").Row().Scan(&earliest); err != nil {
+			return err
+		}
+		if !earliest.Valid {
+			earliest.Time = time.Now()
+		}
+		response.Identify = &oai.IdentifyResult{
+			RepositoryName:    repository.RepositoryName,
+			BaseURL:           repository.BaseURL,
+			ProtocolVersion:   "2.0",
+			AdminEmail:        repository.AdminEmail,
+			EarliestDatestamp: oai.Datestamp(earliest.Time),
+			DeletedRecord:     "transient",
+			Granularity:       "YYYY-MM-DDThh:mm:ssZ",
+		}
+
+	case oai.ListMetadataFormats:
+		if request.Identifier != "" {
+			if _, err := oaiBook(db, repository, request.Identifier); err != nil {
+				return err
+			}
+		}
+		response.ListMetadataFormats = &oai.MetadataFormatList{Formats: []oai.MetadataFormat{oai.DublinCoreFormat}}
+
+	case oai.ListSets:
+		if request.ResumptionToken != "" {
+			return oai.Errorf(oai.BadResumptionToken, "Sets are listed in one response")
+		}
+		var libraries []models.Library
+		if err := oaiLibraries(db).Select("id, name").Order("id").Find(&libraries).Error; err != nil {
+			return err
+		}
+		if len(libraries) == 0 {
+			return oai.Errorf(oai.NoSetHierarchy, "No library publishes its catalog")
+		}
+		sets := make([]oai.Set, len(libraries))
+		for i, library := range libraries {
+			sets[i] = oai.Set{Spec: oai.SetSpec(library.ID), Name: library.Name}
+		}
+		response.ListSets = &oai.SetList{Sets: sets}
+
+	case oai.GetRecord:
+		if request.MetadataPrefix != oai.DublinCoreFormat.Prefix {
+			return oai.Errorf(oai.CannotDisseminateFormat, "Only oai_dc is supported")
+		}
+		book, err := oaiBook(db, repository, request.Identifier)
+		if err != nil {
+			return err
+		}
+		response.GetRecord = &oai.RecordList{Records: []oai.Record{oaiRecord(repository, book)}}
+
+	case oai.ListIdentifiers, oai.ListRecords:
+		return listOAIRecords(db, repository, request, response)
+	}
+	return nil
+}
+
+// listOAIRecords answers ListIdentifiers and ListRecords a page at a time, in
+// id order, continuing after the last id a resumption token carries
+func listOAIRecords(db *gorm.DB, repository oai.Config, request oai.Request, response *oai.Response) error {
+	var token oai.Token
+	if request.ResumptionToken != "" {
+		var oaiErr *oai.Error
+		if token, oaiErr = oai.DecodeToken(request.ResumptionToken); oaiErr != nil {
+			return oaiErr
+		}
+		if token.MetadataPrefix != oai.DublinCoreFormat.Prefix {
+			return oai.Errorf(oai.BadResumptionToken, "The resumption token is not valid")
+		}
+	} else {
+		if request.MetadataPrefix != oai.DublinCoreFormat.Prefix {
+			return oai.Errorf(oai.CannotDisseminateFormat, "Only oai_dc is supported")
+		}
+		window, oaiErr := oai.ParseRange(request.From, request.Until)
+		if oaiErr != nil {
+			return oaiErr
+		}
+		token = oai.NewToken(request, window)
+	}
+
+	query := oaiBooks(db)
+	if token.Set != "" {
+		libraryID, ok := oai.ParseSetSpec(token.Set)
+		if !ok {
+			return oai.Errorf(oai.NoRecordsMatch, "No set is named %s", token.Set)
+		}
+		query = query.Where("books.library_id = ?", libraryID)
+	}
+	window := token.Range()
+	if !window.From.IsZero() {
+		query = query.Where(oaiDatestamp+" >= ?", window.From)
+	}
+	if !window.Before.IsZero() {
+		query = query.Where(oaiDatestamp+" < ?", window.Before)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return err
+	}
+	page := query.Session(&gorm.Session{}).Where("books.id > ?", token.After).Order("books.id").Limit(oai.PageSize)
+	if request.Verb == oai.ListIdentifiers {
+		page = page.Select("id, library_id, updated_at, deleted_at")
+	}
+	var books []models.Book
+	if err := page.Find(&books).Error; err != nil {
+		return err
+	}
+	if len(books) == 0 {
+		return oai.Errorf(oai.NoRecordsMatch, "No records match the request")
+	}
+
+	// A split list ends with an empty token
+	var resumption *oai.ResumptionToken
+	if sent := token.Cursor + len(books); int64(sent) < total {
+		next := token
+		next.After, next.Cursor = books[len(books)-1].ID, sent
+		resumption = &oai.ResumptionToken{CompleteListSize: total, Cursor: token.Cursor, Value: next.Encode()}
+	} else if request.ResumptionToken != "" {
+		resumption = &oai.ResumptionToken{CompleteListSize: total, Cursor: token.Cursor}
+	}
+
+	if request.Verb == oai.ListIdentifiers {
+		headers := make([]oai.Header, len(books))
+		for i, book := range books {
+			headers[i] = oaiHeader(repository, book)
+		}
+		response.ListIdentifiers = &oai.HeaderList{Headers: headers, ResumptionToken: resumption}
+		return nil
+	}
+	records := make([]oai.Record, len(books))
+	for i, book := range books {
+		records[i] = oaiRecord(repository, book)
+	}
+	response.ListRecords = &oai.RecordList{Records: records, ResumptionToken: resumption}
+	return nil
+}
+
+// oaiLibraries selects the libraries whose catalog is harvestable
+func oaiLibraries(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Library{}).Where("public_catalog = ? AND archived = ?", true, false)
+}
+
+// oaiBooks selects the harvestable books, deleted ones included
+func oaiBooks(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Model(&models.Book{}).Where("books.library_id IN (?)", oaiLibraries(db).Select("id"))
+}
+
+// oaiBook finds the harvestable book an identifier names
+func oaiBook(db *gorm.DB, repository oai.Config, identifier string) (models.Book, error) {
+	var book models.Book
+	id, ok := repository.ParseIdentifier(identifier)
+	if !ok {
+		return book, oai.Errorf(oai.IDDoesNotExist, "%s is not an identifier of this repository", identifier)
+	}
+	if err := oaiBooks(db).Where("books.id = ?", id).First(&book).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return book, oai.Errorf(oai.IDDoesNotExist, "No record has the identifier %s", identifier)
+		}
+		return book, err
+	}
+	return book, nil
+}
+
+func oaiHeader(repository oai.Config, book models.Book) oai.Header {
+	header := oai.Header{
+		Identifier: repository.Identifier(book.ID),
+		Datestamp:  oai.Datestamp(book.UpdatedAt),
+		SetSpecs:   []string{oai.SetSpec(book.LibraryID)},
+	}
+	if book.DeletedAt.Valid {
+		header.Status = "deleted"
+		header.Datestamp = oai.Datestamp(book.DeletedAt.Time)
+	}
+	return header
+}
+
+// oaiRecord is a book's header with its Dublin Core, header only once deleted
+func oaiRecord(repository oai.Config, book models.Book) oai.Record {
+	record := oai.Record{Header: oaiHeader(repository, book)}
+	if !book.DeletedAt.Valid {
+		record.Metadata = &oai.Metadata{DublinCore: oai.BookDublinCore(book)}
+	}
+	return record
+}
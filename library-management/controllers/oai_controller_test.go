@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"library-management/oai"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestOAIPMH(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := OAIPMH(gormDB, oai.Config{RepositoryName: "Test Libraries", RepositoryIdentifier: "library.example.org"})
+	r.GET("/oai", handler)
+	r.POST("/oai", handler)
+
+	get := func(query string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/oai?"+query, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/xml; charset=utf-8", w.Header().Get("Content-Type"))
+		return w.Body.String()
+	}
+	const published = `books.library_id IN (SELECT "id" FROM "libraries" WHERE public_catalog = $1 AND archived = $2)`
+	updated := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	bookRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "library_id", "updated_at", "deleted_at"})
+	}
+
+	t.Run("Identify", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT MIN(COALESCE(books.deleted_at, books.updated_at)) FROM "books" WHERE `+published)).
+			WithArgs(true, false).
+			WillReturnRows(sqlmock.NewRows([]string{"min"}).AddRow(updated))
+
+		doc := get("verb=Identify")
+		assert.Contains(t, doc, `<request verb="Identify">http://example.com/oai</request>`)
+		assert.Contains(t, doc, `<repositoryName>Test Libraries</repositoryName>`)
+		assert.Contains(t, doc, `<adminEmail>admin@library.example.org</adminEmail>`)
+		assert.Contains(t, doc, `<earliestDatestamp>2024-03-01T09:30:00Z</earliestDatestamp>`)
+		assert.Contains(t, doc, `<deletedRecord>transient</deletedRecord>`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Bad Verb", func(t *testing.T) {
+		doc := get("verb=ListBooks")
+		assert.Contains(t, doc, `<request>http://example.com/oai</request>`)
+		assert.Contains(t, doc, `<error code="badVerb">`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("List Sets", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, name FROM "libraries" WHERE public_catalog = $1 AND archived = $2 ORDER BY id`)).
+			WithArgs(true, false).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(2, "Central"))
+
+		doc := get("verb=ListSets")
+		assert.Contains(t, doc, `<setSpec>library-2</setSpec>`)
+		assert.Contains(t, doc, `<setName>Central</setName>`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Incremental Harvest Reports Deletions", func(t *testing.T) {
+		window := ` AND books.library_id = $3 AND COALESCE(books.deleted_at, books.updated_at) >= $4`
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE `+published+window)).
+			WithArgs(true, false, 2, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE `+published+window+` AND books.id > $5 ORDER BY books.id LIMIT $6`)).
+			WithArgs(true, false, 2, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 0, oai.PageSize).
+			WillReturnRows(bookRows().
+				AddRow(4, "9780441172719", "Dune", "Frank Herbert", 2, updated, nil).
+				AddRow(5, "9780140449136", "Crime and Punishment", "", 2, updated, updated.Add(time.Hour)))
+
+		doc := get("verb=ListRecords&metadataPrefix=oai_dc&set=library-2&from=2024-03-01")
+		assert.Contains(t, doc, `<identifier>oai:library.example.org:book/4</identifier>`)
+		assert.Contains(t, doc, `<dc:title>Dune</dc:title>`)
+		assert.Contains(t, doc, `<header status="deleted">`)
+		assert.Contains(t, doc, `<datestamp>2024-03-01T10:30:00Z</datestamp>`)
+		assert.Equal(t, 1, strings.Count(doc, "<metadata>"), "deleted records have no metadata")
+		assert.NotContains(t, doc, "resumptionToken")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Resumption Token Continues The List", func(t *testing.T) {
+		token := oai.Token{MetadataPrefix: "oai_dc", After: 4, Cursor: 1}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE `+published)).
+			WithArgs(true, false).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, library_id, updated_at, deleted_at FROM "books" WHERE `+published+` AND books.id > $3 ORDER BY books.id LIMIT $4`)).
+			WithArgs(true, false, 4, oai.PageSize).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "library_id", "updated_at", "deleted_at"}).AddRow(5, 2, updated, nil))
+
+		doc := get("verb=ListIdentifiers&resumptionToken=" + url.QueryEscape(token.Encode()))
+		assert.Contains(t, doc, `<identifier>oai:library.example.org:book/5</identifier>`)
+		assert.Contains(t, doc, `<resumptionToken completeListSize="2" cursor="1"></resumptionToken>`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Identifier", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE `+published+` AND books.id = $3 ORDER BY "books"."id" LIMIT $4`)).
+			WithArgs(true, false, 9, 1).
+			WillReturnRows(bookRows())
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/oai", strings.NewReader("verb=GetRecord&metadataPrefix=oai_dc&identifier=oai:library.example.org:book/9"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `<error code="idDoesNotExist">`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unsupported Format", func(t *testing.T) {
+		doc := get("verb=ListRecords&metadataPrefix=marc21")
+		assert.Contains(t, doc, `<request verb="ListRecords" metadataPrefix="marc21">`)
+		assert.Contains(t, doc, `<error code="cannotDisseminateFormat">`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
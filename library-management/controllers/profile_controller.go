@@ -0,0 +1,258 @@
+package controllers
+
+import (
+	"library-management/apierror"
+	"library-management/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetProfile returns the logged-in user's own profile
+func GetProfile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		user, ok := loadCurrentUser(db, c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"user": userResponse(*user)})
+	}
+}
+
+// UpdateProfile lets the logged-in user change their name and contact
+func UpdateProfile(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Name    *string `json:"name"`
+			Contact *string `json:"contact"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadCurrentUser(db, c)
+		if !ok {
+			return
+		}
+
+		before := *user
+		if input.Name != nil {
+			if *input.Name == "" {
+				apierror.Respond(c, apierror.CodeValidationFailed, "Name cannot be empty")
+				return
+			}
+			user.Name = *input.Name
+		}
+		if input.Contact != nil {
+			user.Contact = *input.Contact
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Updates(map[string]interface{}{
+				"name":    user.Name,
+				"contact": user.Contact,
+			}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "profile.update", "user", user.ID, 0, before, *user)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to update profile")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Profile updated successfully", "user": userResponse(*user)})
+	}
+}
+
+// ChangePassword replaces the logged-in user's password after checking the current one
+func ChangePassword(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			CurrentPassword string `json:"current_password" binding:"required"`
+			NewPassword     string `json:"new_password" binding:"required,min=8"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadCurrentUser(db, c)
+		if !ok {
+			return
+		}
+
+		if input.CurrentPassword != user.Password {
+			apierror.Respond(c, apierror.CodeIncorrectPassword, "Current password is incorrect")
+			return
+		}
+
+		if input.NewPassword == input.CurrentPassword {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "New password must be different from the current one")
+			return
+		}
+
+		before := gin.H{"Password": user.Password}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("password", input.NewPassword).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "profile.change_password", "user", user.ID, 0, before, gin.H{"Password": input.NewPassword})
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to change password")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+	}
+}
+
+// ListMyLibraries lists the logged-in user's library memberships with their review status
+func ListMyLibraries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		userID := c.GetUint("userID")
+
+		var memberships []struct {
+			models.UserLibrary
+			LibraryName string
+		}
+		if err := db.Table("user_libraries").
+			Select("user_libraries.*, libraries.name AS library_name").
+			Joins("JOIN libraries ON libraries.id = user_libraries.library_id").
+			Where("user_libraries.user_id = ?", userID).
+			Order("libraries.name ASC").
+			Scan(&memberships).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch memberships")
+			return
+		}
+
+		formattedMemberships := make([]gin.H, len(memberships))
+		for i, membership := range memberships {
+			formattedMemberships[i] = gin.H{
+				"library_id":   membership.LibraryID,
+				"library_name": membership.LibraryName,
+				"status":       membership.Status,
+				"requested_at": formatUnixTime(&membership.RequestedAt),
+				"reviewed_at":  formatUnixTime(membership.ReviewedAt),
+				"reason":       membership.Reason,
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"libraries": formattedMemberships})
+	}
+}
+
+// ListMyLoans lists the books the logged-in user currently holds
+func ListMyLoans(db *gorm.DB) gin.HandlerFunc {
+	return listMyIssues(db, "return_date = 0", "expected_return_date ASC")
+}
+
+// ListMyLoanHistory lists the books the logged-in user has returned
+func ListMyLoanHistory(db *gorm.DB) gin.HandlerFunc {
+	return listMyIssues(db, "return_date <> 0", "return_date DESC")
+}
+
+func listMyIssues(db *gorm.DB, condition string, order string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		userID := c.GetUint("userID")
+
+		var issues []models.IssueRegistry
+		if err := db.Where("reader_id = ?", userID).Where(condition).Order(order).Find(&issues).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch loans")
+			return
+		}
+
+		now := time.Now().Unix()
+		formattedIssues := make([]gin.H, len(issues))
+		for i, issue := range issues {
+			formattedIssues[i] = gin.H{
+				"id":                   issue.ID,
+				"isbn":                 issue.ISBN,
+				"issue_status":         issue.IssueStatus,
+				"issue_date":           formatUnixTime(&issue.IssueDate),
+				"expected_return_date": formatUnixTime(&issue.ExpectedReturnDate),
+				"return_date":          formatUnixTime(&issue.ReturnDate),
+				"overdue":              issue.ReturnDate == 0 && issue.ExpectedReturnDate < now,
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"loans": formattedIssues})
+	}
+}
+
+// DeleteAccount lets the logged-in user delete their own account, confirmed by password
+func DeleteAccount(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Password string `json:"password" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadCurrentUser(db, c)
+		if !ok {
+			return
+		}
+
+		if input.Password != user.Password {
+			apierror.Respond(c, apierror.CodeIncorrectPassword, "Password is incorrect")
+			return
+		}
+
+		// Somebody has to be left to run the system
+		if user.Role == "owner" {
+			var owners int64
+			if err := db.Model(&models.User{}).Where("role = ?", "owner").Count(&owners).Error; err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Database error")
+				return
+			}
+			if owners <= 1 {
+				apierror.Respond(c, apierror.CodeLastOwner, "The last owner account cannot be deleted")
+				return
+			}
+		}
+
+		if code, message := softDeleteUser(db, c, user, "profile.delete"); code != "" {
+			apierror.Respond(c, code, message)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Account deleted successfully"})
+	}
+}
+
+// loadCurrentUser fetches the user behind the JWT, writing the error response if it fails
+func loadCurrentUser(db *gorm.DB, c *gin.Context) (*models.User, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.Preload("Library").First(&user, userID).Error; err != nil {
+		apierror.Respond(c, apierror.CodeUserNotFound, "User not found")
+		return nil, false
+	}
+	return &user, true
+}
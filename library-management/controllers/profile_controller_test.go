@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestChangePassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.PUT("/me/password", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		ChangePassword(gormDB)(c)
+	})
+
+	expectCurrentUser := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."id" = $1 AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "email", "password", "role"}).AddRow(1, "reader@example.com", "password123", "user"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE "user_libraries"."user_id" = $1`)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}))
+	}
+
+	t.Run("Successful Change", func(t *testing.T) {
+		expectCurrentUser()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "password"=$1,"updated_at"=$2 WHERE "users"."deleted_at" IS NULL AND "id" = $3`)).
+			WithArgs("newpassword456", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/me/password", bytes.NewBufferString(`{"current_password":"password123","new_password":"newpassword456"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Password changed successfully")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Audit Failure Keeps The Old Password", func(t *testing.T) {
+		expectCurrentUser()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "password"=$1`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnError(fmt.Errorf("disk full"))
+		mock.ExpectRollback()
+
+		req := httptest.NewRequest(http.MethodPut, "/me/password", bytes.NewBufferString(`{"current_password":"password123","new_password":"newpassword456"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Failed to change password")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Wrong Current Password", func(t *testing.T) {
+		expectCurrentUser()
+
+		req := httptest.NewRequest(http.MethodPut, "/me/password", bytes.NewBufferString(`{"current_password":"guess","new_password":"newpassword456"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Current password is incorrect")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Short New Password", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/me/password", bytes.NewBufferString(`{"current_password":"password123","new_password":"short"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestListMyLoans(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/me/loans", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		ListMyLoans(gormDB)(c)
+	})
+
+	t.Run("Overdue Loan", func(t *testing.T) {
+		issued := time.Now().AddDate(0, 0, -20).Unix()
+		due := time.Now().AddDate(0, 0, -6).Unix()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "issue_registries" WHERE reader_id = $1 AND return_date = 0 AND "issue_registries"."deleted_at" IS NULL ORDER BY expected_return_date ASC`)).
+			WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "reader_id", "issue_status", "issue_date", "expected_return_date", "return_date"}).
+				AddRow(1, "123456789", 1, "Issued", issued, due, 0))
+
+		req := httptest.NewRequest(http.MethodGet, "/me/loans", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"overdue":true`)
+		assert.Contains(t, w.Body.String(), `"return_date":"N/A"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
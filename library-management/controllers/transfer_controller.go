@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"library-management/apierror"
+	"library-management/authority"
+	"library-management/listquery"
+	"library-management/models"
+	"library-management/works"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// transferListing is what ListTransfers accepts
+var transferListing = listquery.Spec{
+	DefaultLimit: 20,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"created_at": "created_at", "id": "id"},
+	DefaultSort:  "-created_at",
+	TieBreaker:   "id",
+	Filters: map[string]listquery.Filter{
+		"status":          {Column: "status", Enum: []string{models.TransferInTransit, models.TransferReceived, models.TransferCancelled}},
+		"isbn":            {Column: "isbn"},
+		"from_library_id": {Column: "from_library_id", Kind: listquery.Int},
+		"to_library_id":   {Column: "to_library_id", Kind: listquery.Int},
+	},
+}
+
+// errTransferClosed and errCopiesGone end a transfer transaction without
+// changing anything
+var (
+	errTransferClosed = errors.New("transfer already closed")
+	errCopiesGone     = errors.New("copies no longer available")
+)
+
+// SendTransfer takes available copies of a book out of the :id library and
+// puts them in transit to another library. A library sending its last copy
+// keeps the book's record at zero copies - Only Admin
+func SendTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			ISBN        string `json:"isbn" binding:"required"`
+			ToLibraryID uint   `json:"to_library_id" binding:"required"`
+			Copies      int    `json:"copies" binding:"required"`
+			Note        string `json:"note"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+		if input.Copies <= 0 {
+			apierror.Respond(c, apierror.CodeValidationFailed, "copies must be greater than zero")
+			return
+		}
+		if input.ToLibraryID == libraryID {
+			apierror.Respond(c, apierror.CodeValidationFailed, "to_library_id must be another library")
+			return
+		}
+
+		var destination models.Library
+		err := db.First(&destination, input.ToLibraryID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeLibraryNotFound, "Destination library not found")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not send transfer")
+			return
+		}
+		if destination.Archived {
+			apierror.Respond(c, apierror.CodeValidationFailed, "Copies cannot be sent to an archived library")
+			return
+		}
+
+		var book models.Book
+		if err := db.Where("isbn = ? AND library_id = ?", input.ISBN, libraryID).First(&book).Error; err != nil {
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in this library")
+			return
+		}
+		if book.AvailableCopies < input.Copies {
+			apierror.Respond(c, apierror.CodeNoCopiesAvailable,
+				fmt.Sprintf("Only %d copies are available to send, the others are issued", book.AvailableCopies))
+			return
+		}
+
+		now := time.Now()
+		transfer := models.Transfer{
+			ISBN:          book.ISBN,
+			FromLibraryID: libraryID,
+			ToLibraryID:   input.ToLibraryID,
+			Copies:        input.Copies,
+			Status:        models.TransferInTransit,
+			Note:          strings.TrimSpace(input.Note),
+			SentBy:        c.GetUint("userID"),
+			SentAt:        now,
+		}
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Copies issued since the book was read cannot be sent
+			if err := moveCopies(tx, -input.Copies, "id = ? AND available_copies >= ?", book.ID, input.Copies); err != nil {
+				return err
+			}
+			if err := tx.Create(&transfer).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "transfer.send", "transfer", transfer.ID, libraryID, nil, transfer)
+		})
+		if errors.Is(err, errCopiesGone) {
+			apierror.Respond(c, apierror.CodeNoCopiesAvailable, "The copies were issued meanwhile, fetch the book again and retry")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not send transfer")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Copies in transit", "transfer": transfer})
+	}
+}
+
+// ReceiveTransfer adds the copies of a transfer to the :id library, the
+// destination, cataloguing the book there if it is new to the library - Only
+// Admin
+func ReceiveTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		transfer, libraryID, ok := libraryTransfer(db, c)
+		if !ok {
+			return
+		}
+		if transfer.ToLibraryID != libraryID {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "Only the destination library can receive a transfer")
+			return
+		}
+		if transfer.Status != models.TransferInTransit {
+			apierror.Respond(c, apierror.CodeTransferClosed, "The transfer was already "+transfer.Status)
+			return
+		}
+
+		before := transfer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := closeTransfer(tx, &transfer, models.TransferReceived, c.GetUint("userID")); err != nil {
+				return err
+			}
+			err := moveCopies(tx, transfer.Copies, "isbn = ? AND library_id = ?", transfer.ISBN, transfer.ToLibraryID)
+			if errors.Is(err, errCopiesGone) {
+				err = catalogTransfer(tx, transfer, transfer.ToLibraryID)
+			}
+			if err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "transfer.receive", "transfer", transfer.ID, transfer.ToLibraryID, before, transfer)
+		})
+		if errors.Is(err, errTransferClosed) {
+			apierror.Respond(c, apierror.CodeTransferClosed, "The transfer was received or cancelled meanwhile")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not receive transfer")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Copies received", "transfer": transfer})
+	}
+}
+
+// CancelTransfer returns the copies of a transfer still in transit to the :id
+// library, the source, cataloguing the book again if it was removed there
+// meanwhile - Only Admin
+func CancelTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		transfer, libraryID, ok := libraryTransfer(db, c)
+		if !ok {
+			return
+		}
+		if transfer.FromLibraryID != libraryID {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "Only the source library can cancel a transfer")
+			return
+		}
+		if transfer.Status != models.TransferInTransit {
+			apierror.Respond(c, apierror.CodeTransferClosed, "The transfer was already "+transfer.Status)
+			return
+		}
+
+		before := transfer
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := closeTransfer(tx, &transfer, models.TransferCancelled, c.GetUint("userID")); err != nil {
+				return err
+			}
+			err := moveCopies(tx, transfer.Copies, "isbn = ? AND library_id = ?", transfer.ISBN, transfer.FromLibraryID)
+			if errors.Is(err, errCopiesGone) {
+				err = catalogTransfer(tx, transfer, transfer.FromLibraryID)
+			}
+			if err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "transfer.cancel", "transfer", transfer.ID, transfer.FromLibraryID, before, transfer)
+		})
+		if errors.Is(err, errTransferClosed) {
+			apierror.Respond(c, apierror.CodeTransferClosed, "The transfer was received or cancelled meanwhile")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not cancel transfer")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Transfer cancelled, copies returned", "transfer": transfer})
+	}
+}
+
+// ListTransfers lists the transfers the :id library sent or received, newest
+// first - Only Admin
+func ListTransfers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		libraryID, ok := managedLibrary(db, c)
+		if !ok {
+			return
+		}
+		params, ok := listquery.Parse(c, transferListing)
+		if !ok {
+			return
+		}
+
+		var transfers []models.Transfer
+		query := db.Model(&models.Transfer{}).Where("from_library_id = ? OR to_library_id = ?", libraryID, libraryID)
+		result, err := params.Find(query, &transfers)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch transfers")
+			return
+		}
+
+		c.JSON(http.StatusOK, params.Envelope("transfers", transfers, result))
+	}
+}
+
+// GetTransfer returns one transfer of the :id library, sent or received - Only
+// Admin
+func GetTransfer(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		transfer, _, ok := libraryTransfer(db.WithContext(c.Request.Context()), c)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"transfer": transfer})
+	}
+}
+
+// libraryTransfer reads the :transfer sent or received by the :id library the
+// calling admin runs, along with that library's id
+func libraryTransfer(db *gorm.DB, c *gin.Context) (models.Transfer, uint, bool) {
+	libraryID, ok := managedLibrary(db, c)
+	if !ok {
+		return models.Transfer{}, 0, false
+	}
+	id, err := strconv.ParseUint(c.Param("transfer"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Transfer id must be a positive number")
+		return models.Transfer{}, 0, false
+	}
+
+	var transfer models.Transfer
+	err = db.Where("id = ? AND (from_library_id = ? OR to_library_id = ?)", id, libraryID, libraryID).First(&transfer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apierror.Respond(c, apierror.CodeTransferNotFound, "Transfer not found in this library")
+		return models.Transfer{}, 0, false
+	}
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not fetch transfer")
+		return models.Transfer{}, 0, false
+	}
+	return transfer, libraryID, true
+}
+
+// closeTransfer moves a transfer out of transit, failing with
+// errTransferClosed when another request closed it first
+func closeTransfer(tx *gorm.DB, transfer *models.Transfer, status string, adminID uint) error {
+	now := time.Now()
+	closed := tx.Model(&models.Transfer{}).
+		Where("id = ? AND status = ?", transfer.ID, models.TransferInTransit).
+		Updates(map[string]interface{}{"status": status, "closed_by": adminID, "closed_at": now, "updated_at": now})
+	if closed.Error != nil {
+		return closed.Error
+	}
+	if closed.RowsAffected != 1 {
+		return errTransferClosed
+	}
+	transfer.Status, transfer.ClosedBy, transfer.ClosedAt, transfer.UpdatedAt = status, adminID, &now, now
+	return nil
+}
+
+// moveCopies adds copies, or removes them when negative, to the total and
+// available counts of the book matching the condition in one statement, so
+// concurrent loans and transfers cannot lose an update. It fails with
+// errCopiesGone when no book matched. The revision is bumped by hand since
+// UpdateColumns skips the BeforeUpdate hook.
+func moveCopies(tx *gorm.DB, copies int, condition string, args ...interface{}) error {
+	moved := tx.Model(&models.Book{}).Where(condition, args...).UpdateColumns(map[string]interface{}{
+		"total_copies":     gorm.Expr("total_copies + ?", copies),
+		"available_copies": gorm.Expr("available_copies + ?", copies),
+		"revision":         gorm.Expr("revision + 1"),
+		"updated_at":       time.Now(),
+	})
+	if moved.Error != nil {
+		return moved.Error
+	}
+	if moved.RowsAffected == 0 {
+		return errCopiesGone
+	}
+	return nil
+}
+
+// catalogTransfer adds the copies of a transfer to libraryID as a new book,
+// described as the source describes it, removed or not. That is the
+// destination on receipt, or the source when the book was removed there while
+// the copies were away. The call number travels with it; the location, which
+// may no longer exist, does not.
+func catalogTransfer(tx *gorm.DB, transfer models.Transfer, libraryID uint) error {
+	var source models.Book
+	if err := tx.Unscoped().Where("isbn = ? AND library_id = ?", transfer.ISBN, transfer.FromLibraryID).First(&source).Error; err != nil {
+		return err
+	}
+	book := models.Book{
+		ISBN:             source.ISBN,
+		Title:            source.Title,
+		Authors:          source.Authors,
+		Publisher:        source.Publisher,
+		Subjects:         source.Subjects,
+		Version:          source.Version,
+		TotalCopies:      transfer.Copies,
+		AvailableCopies:  transfer.Copies,
+		LibraryID:        libraryID,
+		CallNumber:       source.CallNumber,
+		CallNumberScheme: source.CallNumberScheme,
+		CallNumberSort:   source.CallNumberSort,
+	}
+	if err := works.Assign(tx, &book); err != nil {
+		return err
+	}
+	if err := tx.Create(&book).Error; err != nil {
+		return err
+	}
+	return authority.Link(tx, book)
+}
@@ -0,0 +1,260 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestTransfers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "admin")
+	})
+	r.POST("/library/:id/transfers", SendTransfer(gormDB))
+	r.POST("/library/:id/transfers/:transfer/receive", ReceiveTransfer(gormDB))
+	r.POST("/library/:id/transfers/:transfer/cancel", CancelTransfer(gormDB))
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectManager := func(libraryID int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 ORDER BY "user_libraries"."user_id" LIMIT $3`)).
+			WithArgs(1, libraryID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, libraryID))
+	}
+	expectDestination := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "libraries" WHERE "libraries"."id" = $1 ORDER BY "libraries"."id" LIMIT $2`)).
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "archived"}).AddRow(3, "East Branch", false))
+	}
+	expectSourceBook := func(available int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (isbn = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs("9780134190440", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "total_copies", "available_copies", "library_id"}).
+				AddRow(4, "9780134190440", "The Go Programming Language", 5, available, 2))
+	}
+	expectTransfer := func(libraryID int, status string) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "transfers" WHERE id = $1 AND (from_library_id = $2 OR to_library_id = $3) ORDER BY "transfers"."id" LIMIT $4`)).
+			WithArgs(9, libraryID, libraryID, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "from_library_id", "to_library_id", "copies", "status"}).
+				AddRow(9, "9780134190440", 2, 3, 2, status))
+	}
+	expectClose := func(status string, closed int64) {
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "transfers" SET "closed_at"=$1,"closed_by"=$2,"status"=$3,"updated_at"=$4 WHERE id = $5 AND status = $6`)).
+			WithArgs(sqlmock.AnyArg(), 1, status, sqlmock.AnyArg(), 9, models.TransferInTransit).
+			WillReturnResult(sqlmock.NewResult(0, closed))
+	}
+	expectAudit := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	}
+
+	t.Run("Send Puts Copies In Transit", func(t *testing.T) {
+		expectManager(2)
+		expectDestination()
+		expectSourceBook(3)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=available_copies + $1,"revision"=revision + 1,"total_copies"=total_copies + $2,"updated_at"=$3 WHERE (id = $4 AND available_copies >= $5) AND "books"."deleted_at" IS NULL`)).
+			WithArgs(-2, -2, sqlmock.AnyArg(), 4, 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "transfers"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		expectAudit()
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/2/transfers", `{"isbn":"9780134190440","to_library_id":3,"copies":2,"note":" For the course reserve "}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"in_transit"`)
+		assert.Contains(t, w.Body.String(), `"note":"For the course reserve"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Send More Than Available", func(t *testing.T) {
+		expectManager(2)
+		expectDestination()
+		expectSourceBook(1)
+
+		w := send(http.MethodPost, "/library/2/transfers", `{"isbn":"9780134190440","to_library_id":3,"copies":2}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"no_copies_available"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Send Loses A Race With A Loan", func(t *testing.T) {
+		expectManager(2)
+		expectDestination()
+		expectSourceBook(2)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectRollback()
+
+		w := send(http.MethodPost, "/library/2/transfers", `{"isbn":"9780134190440","to_library_id":3,"copies":2}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"no_copies_available"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Send To Itself", func(t *testing.T) {
+		expectManager(2)
+
+		w := send(http.MethodPost, "/library/2/transfers", `{"isbn":"9780134190440","to_library_id":2,"copies":1}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Receive Adds To Existing Copies", func(t *testing.T) {
+		expectManager(3)
+		expectTransfer(3, models.TransferInTransit)
+		mock.ExpectBegin()
+		expectClose(models.TransferReceived, 1)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "available_copies"=available_copies + $1,"revision"=revision + 1,"total_copies"=total_copies + $2,"updated_at"=$3 WHERE (isbn = $4 AND library_id = $5) AND "books"."deleted_at" IS NULL`)).
+			WithArgs(2, 2, sqlmock.AnyArg(), "9780134190440", 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit()
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/3/transfers/9/receive", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"received"`)
+		assert.Contains(t, w.Body.String(), `"closed_by":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Receive Catalogs A Book New To The Library", func(t *testing.T) {
+		expectManager(3)
+		expectTransfer(3, models.TransferInTransit)
+		mock.ExpectBegin()
+		expectClose(models.TransferReceived, 1)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1 AND library_id = $2 ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs("9780134190440", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "library_id", "work_id", "call_number", "call_number_scheme", "location_id"}).
+				AddRow(4, "9780134190440", "The Go Programming Language", 2, 7, "QA76.73.G63 D66 2016", "lcc", 8))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "work_id" FROM "books" WHERE (isbn = $1 AND work_id <> 0) AND "books"."deleted_at" IS NULL LIMIT $2`)).
+			WithArgs("9780134190440", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}).AddRow(7))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(15))
+		mock.ExpectExec(regexp.QuoteMeta(`SAVEPOINT`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, table := range []string{"book_authors", "book_publishers", "book_subjects"} {
+			mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE book_id = $1`)).
+				WithArgs(15).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		expectAudit()
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/3/transfers/9/receive", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"received"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Only The Destination Receives", func(t *testing.T) {
+		expectManager(2)
+		expectTransfer(2, models.TransferInTransit)
+
+		w := send(http.MethodPost, "/library/2/transfers/9/receive", "")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"library_access_denied"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel Returns Copies To The Source", func(t *testing.T) {
+		expectManager(2)
+		expectTransfer(2, models.TransferInTransit)
+		mock.ExpectBegin()
+		expectClose(models.TransferCancelled, 1)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WithArgs(2, 2, sqlmock.AnyArg(), "9780134190440", 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		expectAudit()
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/2/transfers/9/cancel", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel Catalogs A Book Removed From The Source", func(t *testing.T) {
+		expectManager(2)
+		expectTransfer(2, models.TransferInTransit)
+		mock.ExpectBegin()
+		expectClose(models.TransferCancelled, 1)
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET`)).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1 AND library_id = $2 ORDER BY "books"."id" LIMIT $3`)).
+			WithArgs("9780134190440", 2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "library_id", "work_id", "deleted_at"}).
+				AddRow(4, "9780134190440", "The Go Programming Language", 2, 7, time.Now()))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "work_id" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}).AddRow(7))
+		// The copies come back as a new book in the source library
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "books"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "9780134190440", "The Go Programming Language", "", "", "", "", 2, 2, 2, 7, "", "", "", 0, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(16))
+		mock.ExpectExec(regexp.QuoteMeta(`SAVEPOINT`)).WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, table := range []string{"book_authors", "book_publishers", "book_subjects"} {
+			mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "` + table + `" WHERE book_id = $1`)).
+				WithArgs(16).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		expectAudit()
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/library/2/transfers/9/cancel", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"cancelled"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel After Receipt", func(t *testing.T) {
+		expectManager(2)
+		expectTransfer(2, models.TransferReceived)
+
+		w := send(http.MethodPost, "/library/2/transfers/9/cancel", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"transfer_closed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Cancel Loses A Race With Receipt", func(t *testing.T) {
+		expectManager(2)
+		expectTransfer(2, models.TransferInTransit)
+		mock.ExpectBegin()
+		expectClose(models.TransferCancelled, 0)
+		mock.ExpectRollback()
+
+		w := send(http.MethodPost, "/library/2/transfers/9/cancel", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"transfer_closed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
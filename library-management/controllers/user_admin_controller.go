@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"library-management/apierror"
+	"library-management/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ListUsers lists users with optional role, library and status filters - Owner and Admin.
+// Admins only see readers registered in the libraries they manage.
+func ListUsers(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		actorID := c.GetUint("userID")
+		actorRole := c.GetString("userRole")
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Page must be a positive number")
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Limit must be between 1 and 100")
+			return
+		}
+
+		query := db.Model(&models.User{})
+
+		switch status := c.Query("status"); status {
+		case "":
+		case "active", "deactivated":
+			query = query.Where("status = ?", status)
+		case "deleted":
+			query = query.Unscoped().Where("deleted_at IS NOT NULL")
+		default:
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Status must be one of active, deactivated or deleted")
+			return
+		}
+
+		if role := c.Query("role"); role != "" {
+			query = query.Where("role = ?", role)
+		}
+
+		if libraryID := c.Query("library_id"); libraryID != "" {
+			query = query.Where("id IN (?)", db.Table("user_libraries").Select("user_id").Where("library_id = ?", libraryID))
+		}
+
+		if actorRole == "admin" {
+			adminLibraries := db.Table("user_libraries").Select("library_id").Where("user_id = ?", actorID)
+			query = query.Where("role = ?", "user").
+				Where("id IN (?)", db.Table("user_libraries").Select("user_id").Where("library_id IN (?)", adminLibraries))
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not count users")
+			return
+		}
+
+		var users []models.User
+		if err := query.Preload("Library").Order("id ASC").Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch users")
+			return
+		}
+
+		formattedUsers := make([]gin.H, len(users))
+		for i, user := range users {
+			formattedUsers[i] = userResponse(user)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"users": formattedUsers, "page": page, "limit": limit, "total": total})
+	}
+}
+
+// GetUser returns a single user with their library memberships - Owner and Admin
+func GetUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		var memberships []models.UserLibrary
+		if err := db.Where("user_id = ?", user.ID).Find(&memberships).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch memberships")
+			return
+		}
+
+		response := userResponse(*user)
+		response["Memberships"] = memberships
+		c.JSON(http.StatusOK, gin.H{"user": response})
+	}
+}
+
+// UpdateUser updates a user's name, email or contact - Owner and Admin
+func UpdateUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Name    *string `json:"name"`
+			Email   *string `json:"email" binding:"omitempty,email"`
+			Contact *string `json:"contact"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		before := *user
+		if input.Name != nil {
+			if *input.Name == "" {
+				apierror.Respond(c, apierror.CodeValidationFailed, "Name cannot be empty")
+				return
+			}
+			user.Name = *input.Name
+		}
+		if input.Email != nil && *input.Email != user.Email {
+			var existingUser models.User
+			if err := db.Unscoped().Where("email = ?", *input.Email).First(&existingUser).Error; err == nil {
+				apierror.Respond(c, apierror.CodeEmailTaken, "Email already registered")
+				return
+			}
+			user.Email = *input.Email
+		}
+		if input.Contact != nil {
+			user.Contact = *input.Contact
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Updates(map[string]interface{}{
+				"name":    user.Name,
+				"email":   user.Email,
+				"contact": user.Contact,
+			}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "user.update", "user", user.ID, 0, before, *user)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to update user")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User updated successfully", "user": userResponse(*user)})
+	}
+}
+
+// DeactivateUser blocks a user from logging in without removing their data - Owner and Admin
+func DeactivateUser(db *gorm.DB) gin.HandlerFunc {
+	return setUserStatus(db, "deactivated")
+}
+
+// ReactivateUser restores login access for a deactivated user - Owner and Admin
+func ReactivateUser(db *gorm.DB) gin.HandlerFunc {
+	return setUserStatus(db, "active")
+}
+
+func setUserStatus(db *gorm.DB, status string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		if user.ID == c.GetUint("userID") {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "You cannot change the status of your own account")
+			return
+		}
+
+		if user.Status == status {
+			apierror.Respond(c, apierror.CodeAlreadyInState, fmt.Sprintf("User is already %s", status))
+			return
+		}
+
+		before := *user
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("status", status).Error; err != nil {
+				return err
+			}
+			user.Status = status
+			return recordAudit(tx, c, "user.set_status", "user", user.ID, 0, before, *user)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to update user status")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("User %s", status), "user": userResponse(*user)})
+	}
+}
+
+// DeleteUser soft deletes a user, the row is kept with DeletedAt set - Owner and Admin
+func DeleteUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		if user.ID == c.GetUint("userID") {
+			apierror.Respond(c, apierror.CodeSelfModification, "You cannot delete your own account here")
+			return
+		}
+
+		if code, message := softDeleteUser(db, c, user, "user.delete"); code != "" {
+			apierror.Respond(c, code, message)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+	}
+}
+
+// ChangeUserRole promotes or demotes a user - Owner only
+func ChangeUserRole(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			Role string `json:"role" binding:"required,oneof=owner admin user"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		if user.ID == c.GetUint("userID") {
+			apierror.Respond(c, apierror.CodeSelfModification, "You cannot change your own role")
+			return
+		}
+
+		if user.Role == input.Role {
+			apierror.Respond(c, apierror.CodeAlreadyInState, fmt.Sprintf("User already has the %s role", input.Role))
+			return
+		}
+
+		before := *user
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("role", input.Role).Error; err != nil {
+				return err
+			}
+			user.Role = input.Role
+
+			// Owners are not tied to libraries, and unapproved reader applications
+			// must not turn into admin rights for that library
+			memberships := tx.Where("user_id = ?", user.ID)
+			if input.Role != "owner" {
+				memberships = memberships.Where("status <> ?", "Approved")
+			}
+			if err := memberships.Delete(&models.UserLibrary{}).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "user.change_role", "user", user.ID, 0, before, *user)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to change user role")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "User role updated successfully", "user": userResponse(*user)})
+	}
+}
+
+// AssignUserLibraries replaces the set of libraries an admin or reader belongs to - Owner only
+func AssignUserLibraries(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			LibraryIDs []uint `json:"library_ids" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		user, ok := loadManagedUser(db, c)
+		if !ok {
+			return
+		}
+
+		if user.Role == "owner" {
+			apierror.Respond(c, apierror.CodeValidationFailed, "Owners are not assigned to libraries")
+			return
+		}
+
+		for _, libID := range input.LibraryIDs {
+			var library models.Library
+			if err := db.First(&library, libID).Error; err != nil {
+				apierror.Respond(c, apierror.CodeValidationFailed, fmt.Sprintf("Library ID %d not found", libID))
+				return
+			}
+		}
+
+		ownerID := c.GetUint("userID")
+		now := time.Now().Unix()
+		var userWithLibraries models.User
+		err := db.Transaction(func(tx *gorm.DB) error {
+			removed := tx.Where("user_id = ?", user.ID)
+			if len(input.LibraryIDs) > 0 {
+				removed = removed.Where("library_id NOT IN (?)", input.LibraryIDs)
+			}
+			if err := removed.Delete(&models.UserLibrary{}).Error; err != nil {
+				return err
+			}
+
+			for _, libID := range input.LibraryIDs {
+				membership := models.UserLibrary{
+					UserID:      user.ID,
+					LibraryID:   libID,
+					Status:      "Approved",
+					RequestedAt: now,
+					ReviewerID:  &ownerID,
+					ReviewedAt:  &now,
+				}
+				if err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "user_id"}, {Name: "library_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{"status", "reviewer_id", "reviewed_at", "reason"}),
+				}).Create(&membership).Error; err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Preload("Library").First(&userWithLibraries, user.ID).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "user.assign_libraries", "user", user.ID, 0,
+				gin.H{"Libraries": libraryIDs(user.Library)}, gin.H{"Libraries": libraryIDs(userWithLibraries.Library)})
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Failed to assign libraries")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "User libraries updated successfully", "user": userResponse(userWithLibraries)})
+	}
+}
+
+// loadManagedUser fetches the user named by the :id parameter and checks the caller
+// may manage them. Owners manage everyone, admins only readers of their libraries.
+// It writes the error response itself and reports whether the handler may continue.
+func loadManagedUser(db *gorm.DB, c *gin.Context) (*models.User, bool) {
+	targetID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Invalid user ID")
+		return nil, false
+	}
+
+	var user models.User
+	if err := db.Preload("Library").First(&user, targetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeUserNotFound, "User not found")
+		} else {
+			apierror.Respond(c, apierror.CodeInternal, "Database error")
+		}
+		return nil, false
+	}
+
+	if c.GetString("userRole") == "owner" {
+		return &user, true
+	}
+
+	if user.Role != "user" {
+		apierror.Respond(c, apierror.CodeForbidden, "Admins can only manage readers")
+		return nil, false
+	}
+
+	var shared int64
+	if err := db.Table("user_libraries").
+		Where("user_id = ? AND status = ? AND library_id IN (?)", user.ID, "Approved",
+			db.Table("user_libraries").Select("library_id").Where("user_id = ?", c.GetUint("userID"))).
+		Count(&shared).Error; err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not verify admin libraries")
+		return nil, false
+	}
+	if shared == 0 {
+		apierror.Respond(c, apierror.CodeLibraryAccess, "You can only manage readers of libraries you manage")
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// softDeleteUser marks a user deleted unless they still hold books, recording it
+// in the audit log as action. Memberships are kept so the history stays intact.
+// It returns the error code and message to report, an empty code on success.
+func softDeleteUser(db *gorm.DB, c *gin.Context, user *models.User, action string) (apierror.Code, string) {
+	var activeLoans int64
+	if err := db.Model(&models.IssueRegistry{}).Where("reader_id = ? AND return_date = 0", user.ID).Count(&activeLoans).Error; err != nil {
+		return apierror.CodeInternal, "Could not check active loans"
+	}
+	if activeLoans > 0 {
+		return apierror.CodeActiveLoans, "User has books that are not yet returned"
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(user).Error; err != nil {
+			return err
+		}
+		return recordAudit(tx, c, action, "user", user.ID, 0, *user, nil)
+	})
+	if err != nil {
+		return apierror.CodeInternal, "Failed to delete user"
+	}
+	return "", ""
+}
+
+func libraryIDs(libraries []models.Library) []uint {
+	ids := make([]uint, len(libraries))
+	for i, library := range libraries {
+		ids[i] = library.ID
+	}
+	return ids
+}
+
+// userResponse shapes a user for API responses without exposing the password
+func userResponse(user models.User) gin.H {
+	return gin.H{
+		"ID":        user.ID,
+		"Name":      user.Name,
+		"Email":     user.Email,
+		"Contact":   user.Contact,
+		"Role":      user.Role,
+		"Status":    user.Status,
+		"Libraries": user.Library,
+	}
+}
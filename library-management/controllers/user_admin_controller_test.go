@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestListUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	r.GET("/users", func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", "owner")
+		ListUsers(gormDB)(c)
+	})
+
+	t.Run("Filter By Role", func(t *testing.T) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "users" WHERE role = $1 AND "users"."deleted_at" IS NULL`)).
+			WithArgs("admin").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "users" WHERE role = $1 AND "users"."deleted_at" IS NULL ORDER BY id ASC LIMIT $2`)).
+			WithArgs("admin", 20).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "role", "status"}).
+				AddRow(2, "Admin Name", "admin@example.com", "admin", "active"))
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE "user_libraries"."user_id" = $1`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}))
+
+		req := httptest.NewRequest(http.MethodGet, "/users?role=admin", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "admin@example.com")
+		assert.Contains(t, w.Body.String(), `"total":1`)
+		assert.NotContains(t, w.Body.String(), "Password")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Invalid Limit", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users?limit=500", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Limit must be between 1 and 100")
+	})
+
+	t.Run("Invalid Status", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/users?status=banned", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestManageUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.Default()
+	asRole := func(role string, handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("userID", uint(1))
+			c.Set("userRole", role)
+			handler(c)
+		}
+	}
+	r.PUT("/owner/users/:id/deactivate", asRole("owner", DeactivateUser(gormDB)))
+	r.PUT("/admin/users/:id/deactivate", asRole("admin", DeactivateUser(gormDB)))
+	r.PUT("/owner/users/:id/role", asRole("owner", ChangeUserRole(gormDB)))
+
+	userQuery := regexp.QuoteMeta(`SELECT * FROM "users" WHERE "users"."id" = $1 AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)
+
+	t.Run("Owner Deactivates User", func(t *testing.T) {
+		mock.ExpectQuery(userQuery).
+			WithArgs(2, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "status"}).AddRow(2, "Reader", "user", "active"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE "user_libraries"."user_id" = $1`)).
+			WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}))
+
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "users" SET "status"=$1,"updated_at"=$2 WHERE "users"."deleted_at" IS NULL AND "id" = $3`)).
+			WithArgs("deactivated", sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPut, "/owner/users/2/deactivate", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "User deactivated")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin Cannot Manage Admins", func(t *testing.T) {
+		mock.ExpectQuery(userQuery).
+			WithArgs(3, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "status"}).AddRow(3, "Other Admin", "admin", "active"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE "user_libraries"."user_id" = $1`)).
+			WithArgs(3).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}))
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/users/3/deactivate", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Admins can only manage readers")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin Cannot Manage Pending Readers", func(t *testing.T) {
+		mock.ExpectQuery(userQuery).
+			WithArgs(4, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "role", "status"}).AddRow(4, "Applicant", "user", "active"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE "user_libraries"."user_id" = $1`)).
+			WithArgs(4).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}))
+		// Only approved memberships count, a pending request gives the admin no say
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "user_libraries" WHERE user_id = $1 AND status = $2 AND library_id IN (SELECT library_id FROM "user_libraries" WHERE user_id = $3)`)).
+			WithArgs(4, "Approved", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+		req := httptest.NewRequest(http.MethodPut, "/admin/users/4/deactivate", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("User Not Found", func(t *testing.T) {
+		mock.ExpectQuery(userQuery).
+			WithArgs(9, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		req := httptest.NewRequest(http.MethodPut, "/owner/users/9/deactivate", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid Role", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/owner/users/2/role", bytes.NewBufferString(`{"role":"superuser"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
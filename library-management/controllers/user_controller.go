@@ -2,7 +2,13 @@
 package controllers
 
 import (
+	"library-management/apierror"
+	"library-management/authority"
+	"library-management/listquery"
+	"library-management/metrics"
 	"library-management/models"
+	"library-management/storage"
+	"library-management/tracing"
 	"net/http"
 	"time"
 
@@ -10,48 +16,115 @@ import (
 	"gorm.io/gorm"
 )
 
-func SearchBooks(db *gorm.DB) gin.HandlerFunc {
+// bookSearchListing is what SearchBooks accepts. Pages hold works; the filters
+// pick which editions make a work match.
+var bookSearchListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"title": "works.title", "id": "works.id"},
+	DefaultSort:  "title",
+	TieBreaker:   "works.id",
+	Filters: map[string]listquery.Filter{
+		"title":      {Column: "books.title", Op: listquery.Contains},
+		"library_id": {Column: "books.library_id", Kind: listquery.Int},
+	},
+}
+
+// bookSearchAuthorities are the SearchBooks parameters matched against linked
+// records rather than the book's own text
+var bookSearchAuthorities = []struct {
+	param string
+	kind  authority.Kind
+}{
+	{"author", authority.Authors},
+	{"publisher", authority.Publishers},
+	{"subject", authority.Subjects},
+}
+
+// SearchBooks finds works with an edition matching the filters in the reader's
+// approved libraries, each listed with all of its editions there. Editions
+// carry their cover and thumbnail URLs, call number and location, works the
+// covers of their first edition with one.
+func SearchBooks(db *gorm.DB, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
+			return
+		}
+
+		params, ok := listquery.Parse(c, bookSearchListing)
+		if !ok {
 			return
 		}
 
 		var userLibraries []uint
-		if err := db.Table("user_libraries").Where("user_id = ?", userID).Pluck("library_id", &userLibraries).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch user libraries"})
+		if err := db.Table("user_libraries").Where("user_id = ? AND status = ?", userID, "Approved").Pluck("library_id", &userLibraries).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch user libraries")
 			return
 		}
 
 		if len(userLibraries) == 0 {
-			c.JSON(http.StatusOK, gin.H{"books": []gin.H{}})
+			c.JSON(http.StatusOK, params.Envelope("works", []gin.H{}, listquery.Result{}))
 			return
 		}
+		tracing.SetLibraryIDs(c.Request.Context(), userLibraries)
+
+		matching := db.Model(&models.Book{}).Select("books.work_id").Where("books.library_id IN (?)", userLibraries)
+		for _, filter := range bookSearchAuthorities {
+			if text := c.Query(filter.param); text != "" {
+				matching = matching.Where("books.id IN (?)", authority.Matching(db, filter.kind, text))
+			}
+		}
 
-		title := c.Query("title")
-		author := c.Query("author")
-		publisher := c.Query("publisher")
+		var works []models.Work
+		result, err := params.FindPage(db.Model(&models.Work{}).Where("works.id IN (?)", params.Filter(matching)), &works)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Error searching books")
+			return
+		}
+		if len(works) == 0 {
+			c.JSON(http.StatusOK, params.Envelope("works", []gin.H{}, result))
+			return
+		}
 
+		workIDs := make([]uint, len(works))
+		for i, work := range works {
+			workIDs[i] = work.ID
+		}
 		var books []models.Book
-		query := db.Where("library_id IN (?)", userLibraries)
+		if err := db.Select("id, isbn, title, authors, publisher, version, available_copies, library_id, work_id, call_number, location_id").
+			Where("work_id IN (?) AND library_id IN (?)", workIDs, userLibraries).
+			Order("title ASC, library_id ASC, id ASC").
+			Find(&books).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Error searching books")
+			return
+		}
 
-		if title != "" {
-			query = query.Where("title ILIKE ?", "%"+title+"%")
+		bookIDs := make([]uint, len(books))
+		for i, book := range books {
+			bookIDs[i] = book.ID
 		}
-		if author != "" {
-			query = query.Where("authors ILIKE ?", "%"+author+"%")
+		var covers []models.Attachment
+		if err := db.Where("book_id IN (?) AND kind = ?", bookIDs, models.AttachmentCover).Find(&covers).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Error searching books")
+			return
 		}
-		if publisher != "" {
-			query = query.Where("publisher ILIKE ?", "%"+publisher+"%")
+		coverOf := make(map[uint]models.Attachment, len(covers))
+		for _, cover := range covers {
+			coverOf[cover.BookID] = cover
 		}
-
-		if err := query.Select("isbn, title, authors, publisher, available_copies, library_id").Find(&books).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching books"})
+		locations, err := locationPaths(db, books)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Error searching books")
 			return
 		}
 
-		response := make([]gin.H, 0, len(books))
+		editions := make(map[uint][]gin.H, len(works))
+		workCovers := make(map[uint]gin.H, len(works))
+		available := make(map[uint]int, len(works))
 		for _, book := range books {
 			authors := book.Authors
 			if authors == "" {
@@ -63,8 +136,20 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 				"title":            book.Title,
 				"author":           authors,
 				"publisher":        book.Publisher,
+				"version":          book.Version,
 				"available_copies": book.AvailableCopies,
 				"library_id":       book.LibraryID,
+				"call_number":      book.CallNumber,
+				"location":         locations[book.LocationID],
+				"cover_url":        nil,
+				"thumbnail_url":    nil,
+			}
+			if cover, ok := coverOf[book.ID]; ok {
+				bookData["cover_url"] = store.URL(cover.Key)
+				bookData["thumbnail_url"] = store.URL(cover.ThumbnailKey)
+				if _, ok := workCovers[book.WorkID]; !ok {
+					workCovers[book.WorkID] = gin.H{"cover_url": bookData["cover_url"], "thumbnail_url": bookData["thumbnail_url"]}
+				}
 			}
 
 			// ✅ Fix: Check if the book is unavailable (no copies left)
@@ -87,59 +172,91 @@ func SearchBooks(db *gorm.DB) gin.HandlerFunc {
 				bookData["next_available_date"] = "Available"
 			}
 
-			// Append the book data to the response
-			response = append(response, bookData)
+			editions[book.WorkID] = append(editions[book.WorkID], bookData)
+			available[book.WorkID] += book.AvailableCopies
 		}
 
-		c.JSON(http.StatusOK, gin.H{"books": response})
+		response := make([]gin.H, 0, len(works))
+		for _, work := range works {
+			response = append(response, gin.H{
+				"work_id":          work.ID,
+				"title":            work.Title,
+				"authors":          work.Authors,
+				"available_copies": available[work.ID],
+				"cover_url":        workCovers[work.ID]["cover_url"],
+				"thumbnail_url":    workCovers[work.ID]["thumbnail_url"],
+				"editions":         editions[work.ID],
+			})
+		}
+
+		c.JSON(http.StatusOK, params.Envelope("works", response, result))
 	}
 }
 
 func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		// A request names an edition by isbn, or holds a work by work_id so that
+		// any of its editions may be issued
 		var input struct {
-			BookID    string `json:"isbn" binding:"required"`
+			BookID    string `json:"isbn"`
+			WorkID    uint   `json:"work_id"`
 			LibraryID uint   `json:"libraryid" binding:"required"`
 		}
 
 		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.RespondBinding(c, err)
+			return
+		}
+		if (input.BookID == "") == (input.WorkID == 0) {
+			apierror.Respond(c, apierror.CodeValidationFailed, "Give either isbn or work_id")
 			return
 		}
 
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
 		var book models.Book
-		if err := db.Where("isbn = ? AND library_id = ?", input.BookID, input.LibraryID).First(&book).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Book not found in the specified library"})
+		lookup := db.Where("isbn = ? AND library_id = ?", input.BookID, input.LibraryID)
+		if input.WorkID != 0 {
+			// The edition with the most copies on the shelf stands in for the work
+			lookup = db.Where("work_id = ? AND library_id = ?", input.WorkID, input.LibraryID).Order("available_copies DESC")
+		}
+		if err := lookup.First(&book).Error; err != nil {
+			apierror.Respond(c, apierror.CodeBookNotFound, "Book not found in the specified library")
 			return
 		}
 
 		if book.AvailableCopies == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Book not available for issue"})
+			apierror.Respond(c, apierror.CodeNoCopiesAvailable, "Book not available for issue")
 			return
 		}
 
 		var userLibrary models.UserLibrary
-		if err := db.Where("user_id = ? AND library_id = ?", userID, input.LibraryID).First(&userLibrary).Error; err != nil {
-			c.JSON(http.StatusForbidden, gin.H{"error": "You can only request books from libraries you are registered in"})
+		if err := db.Where("user_id = ? AND library_id = ? AND status = ?", userID, input.LibraryID, "Approved").First(&userLibrary).Error; err != nil {
+			apierror.Respond(c, apierror.CodeLibraryAccess, "You can only request books from libraries where your membership is approved")
 			return
 		}
 
 		var existingRequest models.RequestEvent
-		if err := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND approval_date IS NULL", userID, input.BookID, input.LibraryID).First(&existingRequest).Error; err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "You already have a pending request for this book in this library"})
+		pending := db.Where("reader_id = ? AND book_id = ? AND library_id = ? AND approval_date IS NULL", userID, input.BookID, input.LibraryID)
+		if input.WorkID != 0 {
+			pending = db.Where("reader_id = ? AND work_id = ? AND library_id = ? AND approval_date IS NULL", userID, input.WorkID, input.LibraryID)
+		}
+		if err := pending.First(&existingRequest).Error; err == nil {
+			apierror.Respond(c, apierror.CodeDuplicateRequest, "You already have a pending request for this book in this library")
 			return
 		}
 
 		requestDate := time.Now()
 		request := models.RequestEvent{
-			BookID:       input.BookID,
+			BookID:       book.ISBN,
 			LibraryID:    input.LibraryID,
+			WorkID:       input.WorkID,
 			ReaderID:     userID.(uint),
 			RequestDate:  requestDate.Unix(),
 			ApprovalDate: nil,
@@ -147,45 +264,69 @@ func RequestIssue(db *gorm.DB) gin.HandlerFunc {
 			RequestType:  "issue",
 		}
 
-		if err := db.Create(&request).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create issue request"})
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&request).Error; err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "issue_request.create", "issue_request", request.ID, request.LibraryID, nil, request)
+		})
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not create issue request")
 			return
 		}
+		metrics.IssueRequestsCreated.Inc()
 
 		c.JSON(http.StatusCreated, gin.H{"message": "Issue request submitted", "request": request})
 	}
 }
 
+// issueStatusListing is what StatusIssue accepts
+var issueStatusListing = listquery.Spec{
+	DefaultLimit: 50,
+	MaxLimit:     100,
+	Sorts:        map[string]string{"request_date": "request_date", "status": "status", "id": "id"},
+	DefaultSort:  "-request_date",
+	TieBreaker:   "id",
+	Filters: map[string]listquery.Filter{
+		"status":       {Column: "status", Enum: []string{"Pending", "Approved", "Disapproved", "Issued"}},
+		"request_type": {Column: "request_type", Enum: []string{"issue", "return"}},
+		"library_id":   {Column: "library_id", Kind: listquery.Int},
+		"from":         {Column: "request_date", Op: listquery.From, Kind: listquery.UnixDate},
+		"to":           {Column: "request_date", Op: listquery.To, Kind: listquery.UnixDate},
+	},
+}
+
 func StatusIssue(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
 		// Get user ID from the token
 		userID, exists := c.Get("userID")
 		if !exists {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized request"})
+			apierror.Respond(c, apierror.CodeUnauthorized, "Unauthorized request")
 			return
 		}
 
-		// Retrieve all requests for this user (sorted by request date, newest first)
-		var requests []models.RequestEvent
-		if err := db.Where("reader_id = ?", userID).
-			Order("request_date DESC"). // ✅ Sorts by latest request first
-			Find(&requests).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not retrieve request statuses"})
+		params, ok := listquery.Parse(c, issueStatusListing)
+		if !ok {
 			return
 		}
 
-		// If no requests exist, return an empty list
-		if len(requests) == 0 {
-			c.JSON(http.StatusOK, gin.H{"requests": []gin.H{}}) // ✅ Return empty array instead of error
+		// Retrieve this user's requests, newest first unless sorted otherwise
+		var requests []models.RequestEvent
+		result, err := params.Find(db.Where("reader_id = ?", userID), &requests)
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not retrieve request statuses")
 			return
 		}
 
 		// Convert requests into a response format
-		var formattedRequests []gin.H
+		formattedRequests := make([]gin.H, 0, len(requests))
 		for _, request := range requests {
 			formattedRequests = append(formattedRequests, gin.H{
 				"request_id":    request.ID,
 				"book_id":       request.BookID,
+				"work_id":       request.WorkID,
 				"library_id":    request.LibraryID,
 				"reader_id":     request.ReaderID,
 				"request_date":  request.RequestDate,
@@ -194,7 +335,6 @@ func StatusIssue(db *gorm.DB) gin.HandlerFunc {
 			})
 		}
 
-		// Return all requests for this user
-		c.JSON(http.StatusOK, gin.H{"requests": formattedRequests})
+		c.JSON(http.StatusOK, params.Envelope("requests", formattedRequests, result))
 	}
 }
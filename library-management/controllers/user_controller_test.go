@@ -2,12 +2,15 @@ package controllers
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
 	"testing"
 
+	"library-management/storage"
+
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -26,31 +29,61 @@ func TestSearchBooks(t *testing.T) {
 	r := gin.Default()
 	r.GET("/search", func(c *gin.Context) {
 		c.Set("userID", uint(1))
-		SearchBooks(gormDB)(c)
+		SearchBooks(gormDB, storage.NewLocal(t.TempDir(), "/files/"))(c)
 	})
 
-	t.Run("Successful Book Search", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
+	expectLibraries := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(1, "Approved").
 			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
+	}
+	// expectWorks expects one page of works whose editions match the condition
+	expectWorks := func(condition string, args ...driver.Value) *sqlmock.ExpectedQuery {
+		matching := `works.id IN (SELECT books.work_id FROM "books" WHERE books.library_id IN ($1)` + condition
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "works" WHERE ` + matching)).
+			WithArgs(append([]driver.Value{1}, args...)...).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		return mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "works" WHERE ` + matching)).
+			WithArgs(append(append([]driver.Value{1}, args...), 51)...)
+	}
+	workRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "title", "authors"}).AddRow(7, "Test Book", "Test Author")
+	}
+	expectEditions := func() {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT id, isbn, title, authors, publisher, version, available_copies, library_id, work_id, call_number, location_id FROM "books" WHERE (work_id IN ($1) AND library_id IN ($2)) AND "books"."deleted_at" IS NULL ORDER BY title ASC, library_id ASC, id ASC`)).
+			WithArgs(7, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "publisher", "version", "available_copies", "library_id", "work_id"}).
+				AddRow(1, "123456789", "Test Book", "Test Author", "Test Publisher", "Hardcover", 2, 1, 7).
+				AddRow(2, "987654321", "Test Book", "Test Author", "Test Publisher", "Paperback", 1, 1, 7))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "attachments" WHERE book_id IN ($1,$2) AND kind = $3`)).
+			WithArgs(1, 2, "cover").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "book_id", "kind", "key", "thumbnail_key"}).
+				AddRow(3, 2, "cover", "abc.png", "def.jpg"))
+	}
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"}).
-				AddRow("123456789", "Test Book", "Test Author", "Test Publisher", 2, 1))
+	t.Run("Successful Book Search", func(t *testing.T) {
+		expectLibraries()
+		expectWorks("").WillReturnRows(workRows())
+		expectEditions()
 
 		req := httptest.NewRequest(http.MethodGet, "/search", nil)
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), "Test Book")
+		assert.Contains(t, w.Body.String(), `"work_id":7`)
+		assert.Contains(t, w.Body.String(), `"available_copies":3`)
+		assert.Contains(t, w.Body.String(), `"version":"Hardcover"`)
+		assert.Contains(t, w.Body.String(), `"version":"Paperback"`)
+		assert.Contains(t, w.Body.String(), `"cover_url":"/files/abc.png"`)
+		assert.Contains(t, w.Body.String(), `"thumbnail_url":"/files/def.jpg"`)
+		assert.Contains(t, w.Body.String(), `"cover_url":null`, "the hardcover has no cover")
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("No Libraries Found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(1, "Approved").
 			WillReturnRows(sqlmock.NewRows([]string{}))
 
 		req := httptest.NewRequest(http.MethodGet, "/search", nil)
@@ -58,29 +91,25 @@ func TestSearchBooks(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), `"books":[]`)
+		assert.Contains(t, w.Body.String(), `"works":[]`)
 	})
 
 	t.Run("No Books Found", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
-
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"}))
+		expectLibraries()
+		expectWorks("").WillReturnRows(sqlmock.NewRows([]string{"id", "title", "authors"}))
 
 		req := httptest.NewRequest(http.MethodGet, "/search", nil)
 		w := httptest.NewRecorder()
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
-		assert.Contains(t, w.Body.String(), `"books":[]`)
+		assert.Contains(t, w.Body.String(), `"works":[]`)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Error Fetching User Libraries", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(1, "Approved").
 			WillReturnError(errors.New("db error"))
 
 		req := httptest.NewRequest(http.MethodGet, "/search", nil)
@@ -92,14 +121,9 @@ func TestSearchBooks(t *testing.T) {
 	})
 
 	t.Run("Error Searching Books", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
-
-		// Mock error in book query
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1)`)).
-			WithArgs(1).
-			WillReturnError(errors.New("db error"))
+		expectLibraries()
+		// Mock error in work query
+		expectWorks("").WillReturnError(errors.New("db error"))
 
 		req := httptest.NewRequest(http.MethodGet, "/search", nil)
 		w := httptest.NewRecorder()
@@ -107,17 +131,13 @@ func TestSearchBooks(t *testing.T) {
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		assert.Contains(t, w.Body.String(), "Error searching books")
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Search with Filters", func(t *testing.T) {
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1`)).
-			WithArgs(1).
-			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(1))
-
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT isbn, title, authors, publisher, available_copies, library_id FROM "books" WHERE library_id IN ($1) AND title ILIKE $2`)).
-			WithArgs(1, "%Test Title%").
-			WillReturnRows(sqlmock.NewRows([]string{"isbn", "title", "authors", "publisher", "available_copies", "library_id"}).
-				AddRow("123456789", "Test Book", "Test Author", "Test Publisher", 2, 1))
+		expectLibraries()
+		expectWorks(` AND books.title ILIKE $2`, "%Test Title%").WillReturnRows(workRows())
+		expectEditions()
 
 		req := httptest.NewRequest(http.MethodGet, "/search?title=Test+Title", nil)
 		w := httptest.NewRecorder()
@@ -125,6 +145,32 @@ func TestSearchBooks(t *testing.T) {
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Contains(t, w.Body.String(), "Test Book")
+		assert.Contains(t, w.Body.String(), `"total":1`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Search By Author Matches Any Spelling", func(t *testing.T) {
+		expectLibraries()
+		expectWorks(` AND books.id IN (SELECT book_authors.book_id FROM "book_authors" JOIN authors ON authors.id = book_authors.author_id WHERE authors.key LIKE $2)`, "%jk rowling%").
+			WillReturnRows(workRows())
+		expectEditions()
+
+		req := httptest.NewRequest(http.MethodGet, "/search?author=Rowling,+J.+K.", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Test Author")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Sort", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/search?sort=authors", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_parameter")
 	})
 
 }
@@ -150,8 +196,8 @@ func TestRequestIssue(t *testing.T) {
 			WillReturnRows(sqlmock.NewRows([]string{"isbn", "available_copies"}).
 				AddRow("123456789", 1))
 
-		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2`)).
-			WithArgs(1, 1).
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 AND status = $3`)).
+			WithArgs(1, 1, "Approved").
 			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 1))
 
 		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (reader_id = $1 AND book_id = $2 AND library_id = $3 AND approval_date IS NULL) AND "request_events"."deleted_at" IS NULL`)).
@@ -178,7 +224,7 @@ func TestRequestIssue(t *testing.T) {
 		r.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "Key: 'LibraryID' Error:Field validation for 'LibraryID' failed on the 'required' tag")
+		assert.Contains(t, w.Body.String(), `{"field":"libraryid","rule":"required"`)
 	})
 
 	t.Run("Book Not Found", func(t *testing.T) {
@@ -194,4 +240,52 @@ func TestRequestIssue(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		assert.Contains(t, w.Body.String(), "Book not found in the specified library")
 	})
+
+	t.Run("Hold On Work Takes The Edition On The Shelf", func(t *testing.T) {
+		// Own mock, the cases above leave expectations unmet
+		db, mock, err := sqlmock.New()
+		assert.NoError(t, err)
+		gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+		assert.NoError(t, err)
+		r := gin.New()
+		r.POST("/request/issue", func(c *gin.Context) {
+			c.Set("userID", uint(1))
+			RequestIssue(gormDB)(c)
+		})
+
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE (work_id = $1 AND library_id = $2) AND "books"."deleted_at" IS NULL ORDER BY available_copies DESC,"books"."id" LIMIT $3`)).
+			WithArgs(7, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "available_copies", "library_id", "work_id"}).AddRow(2, "987654321", 1, 1, 7))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "user_libraries" WHERE user_id = $1 AND library_id = $2 AND status = $3`)).
+			WithArgs(1, 1, "Approved", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "library_id"}).AddRow(1, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "request_events" WHERE (reader_id = $1 AND work_id = $2 AND library_id = $3 AND approval_date IS NULL) AND "request_events"."deleted_at" IS NULL`)).
+			WithArgs(1, 7, 1, 1).
+			WillReturnRows(sqlmock.NewRows([]string{}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "request_events"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "987654321", 1, 7, 1, sqlmock.AnyArg(), "issue", "Pending").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(`{"work_id":7,"libraryid":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Contains(t, w.Body.String(), `"work_id":7`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Edition Or Work Required", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/request/issue", bytes.NewBufferString(`{"libraryid":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+	})
 }
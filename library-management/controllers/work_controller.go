@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"errors"
+	"library-management/apierror"
+	"library-management/models"
+	"library-management/works"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetWork returns a work with its editions in the libraries the caller can
+// access: all of them for owners, the libraries they run for admins and approved
+// memberships for readers
+func GetWork(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		work, ok := findWork(db, c)
+		if !ok {
+			return
+		}
+
+		query := db.Where("work_id = ?", work.ID)
+		if c.GetString("userRole") != "owner" {
+			libraryIDs, err := accessibleLibraries(db, c)
+			if err != nil {
+				apierror.Respond(c, apierror.CodeInternal, "Could not fetch user libraries")
+				return
+			}
+			if len(libraryIDs) == 0 {
+				c.JSON(http.StatusOK, gin.H{"work": work, "editions": []gin.H{}})
+				return
+			}
+			query = query.Where("library_id IN (?)", libraryIDs)
+		}
+
+		var books []models.Book
+		if err := query.Order("isbn ASC, library_id ASC").Find(&books).Error; err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch editions")
+			return
+		}
+
+		editions := make([]gin.H, len(books))
+		for i, book := range books {
+			editions[i] = gin.H{
+				"isbn":             book.ISBN,
+				"title":            book.Title,
+				"authors":          book.Authors,
+				"publisher":        book.Publisher,
+				"version":          book.Version,
+				"total_copies":     book.TotalCopies,
+				"available_copies": book.AvailableCopies,
+				"library_id":       book.LibraryID,
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"work": work, "editions": editions})
+	}
+}
+
+// MergeEditions makes the given ISBNs editions of a work, so a hold on the work
+// can be filled by any of them. Works left without editions are deleted - Owner
+// and Admin, admins only for ISBNs their libraries hold
+func MergeEditions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		var input struct {
+			ISBNs []string `json:"isbns" binding:"required,min=1,dive,required"`
+		}
+		if err := c.ShouldBindJSON(&input); err != nil {
+			apierror.RespondBinding(c, err)
+			return
+		}
+
+		work, ok := findWork(db, c)
+		if !ok {
+			return
+		}
+		if !holdsEditions(db, c, input.ISBNs...) {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := works.Merge(tx, work.ID, input.ISBNs); err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "work.merge", "work", work.ID, 0, nil, input)
+		})
+		if err != nil {
+			if errors.Is(err, works.ErrUnknownEdition) {
+				apierror.Respond(c, apierror.CodeBookNotFound, "No library holds one of the ISBNs")
+				return
+			}
+			apierror.Respond(c, apierror.CodeInternal, "Could not merge editions")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Editions merged", "work": work})
+	}
+}
+
+// SplitEdition moves one edition out of a work into a new work of its own - Owner
+// and Admin, admins only for ISBNs their libraries hold
+func SplitEdition(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db := db.WithContext(c.Request.Context())
+
+		isbn := c.Param("isbn")
+		work, ok := findWork(db, c)
+		if !ok {
+			return
+		}
+		if !holdsEditions(db, c, isbn) {
+			return
+		}
+
+		var split models.Work
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if split, err = works.Split(tx, work.ID, isbn); err != nil {
+				return err
+			}
+			return recordAudit(tx, c, "work.split", "work", work.ID, 0, nil, gin.H{"isbn": isbn, "work_id": split.ID})
+		})
+		switch {
+		case errors.Is(err, works.ErrUnknownEdition):
+			apierror.Respond(c, apierror.CodeBookNotFound, "No library holds this ISBN")
+			return
+		case errors.Is(err, works.ErrNotInWork):
+			apierror.Respond(c, apierror.CodeConflict, "The ISBN is not an edition of this work")
+			return
+		case errors.Is(err, works.ErrLastEdition):
+			apierror.Respond(c, apierror.CodeConflict, "The work has no other edition to keep")
+			return
+		case err != nil:
+			apierror.Respond(c, apierror.CodeInternal, "Could not split edition")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Edition split into a new work", "work": split})
+	}
+}
+
+// findWork loads the work named by the :id parameter, writing the problem and
+// returning false when it cannot
+func findWork(db *gorm.DB, c *gin.Context) (models.Work, bool) {
+	var work models.Work
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		apierror.Respond(c, apierror.CodeInvalidParameter, "Work id must be a positive number")
+		return work, false
+	}
+	if err := db.First(&work, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeNotFound, "Work not found")
+		} else {
+			apierror.Respond(c, apierror.CodeInternal, "Could not fetch work")
+		}
+		return work, false
+	}
+	return work, true
+}
+
+// holdsEditions checks that an admin's libraries hold every ISBN, since regrouping
+// editions changes how all libraries' copies are searched. Owners may regroup any.
+func holdsEditions(db *gorm.DB, c *gin.Context, isbns ...string) bool {
+	if c.GetString("userRole") == "owner" {
+		return true
+	}
+
+	var held []string
+	err := db.Model(&models.Book{}).
+		Where("isbn IN (?) AND library_id IN (?)", isbns, db.Table("user_libraries").Select("library_id").Where("user_id = ?", c.GetUint("userID"))).
+		Distinct().Pluck("isbn", &held).Error
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not fetch admin libraries")
+		return false
+	}
+	if len(held) < len(uniqueStrings(isbns)) {
+		apierror.Respond(c, apierror.CodeLibraryAccess, "You can only regroup editions your libraries hold")
+		return false
+	}
+	return true
+}
+
+func uniqueStrings(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
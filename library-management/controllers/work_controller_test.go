@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestWorks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	role := "admin"
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("userID", uint(1))
+		c.Set("userRole", role)
+	})
+	r.GET("/works/:id", GetWork(gormDB))
+	r.POST("/works/:id/editions", MergeEditions(gormDB))
+	r.DELETE("/works/:id/editions/:isbn", SplitEdition(gormDB))
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectWork := func(found bool) {
+		rows := sqlmock.NewRows([]string{"id", "title", "authors"})
+		if found {
+			rows.AddRow(1, "Dune", "Frank Herbert")
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "works" WHERE "works"."id" = $1 ORDER BY "works"."id" LIMIT $2`)).
+			WithArgs(1, 1).
+			WillReturnRows(rows)
+	}
+	expectHeld := func(isbns ...string) {
+		rows := sqlmock.NewRows([]string{"isbn"})
+		for _, isbn := range isbns {
+			rows.AddRow(isbn)
+		}
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "isbn" FROM "books" WHERE (isbn IN ($1,$2) AND library_id IN (SELECT library_id FROM "user_libraries" WHERE user_id = $3))`)).
+			WithArgs("111", "222", 1).
+			WillReturnRows(rows)
+	}
+
+	t.Run("Editions In Accessible Libraries", func(t *testing.T) {
+		role = "user"
+		expectWork(true)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "library_id" FROM "user_libraries" WHERE user_id = $1 AND status = $2`)).
+			WithArgs(1, "Approved").
+			WillReturnRows(sqlmock.NewRows([]string{"library_id"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE work_id = $1 AND library_id IN ($2) AND "books"."deleted_at" IS NULL ORDER BY isbn ASC, library_id ASC`)).
+			WithArgs(1, 2).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "version", "total_copies", "available_copies", "library_id", "work_id"}).
+				AddRow(4, "111", "Dune", "Hardcover", 2, 1, 2, 1).
+				AddRow(5, "222", "Dune", "Paperback", 3, 3, 2, 1))
+
+		w := send(http.MethodGet, "/works/1", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"Dune"`)
+		assert.Contains(t, w.Body.String(), `"version":"Paperback"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown Work", func(t *testing.T) {
+		expectWork(false)
+
+		w := send(http.MethodGet, "/works/1", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"not_found"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		role = "admin"
+		expectWork(true)
+		expectHeld("111", "222")
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "isbn" FROM "books" WHERE isbn IN ($1,$2)`)).
+			WillReturnRows(sqlmock.NewRows([]string{"isbn"}).AddRow("111").AddRow("222"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "work_id" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "revision"=revision + 1,"work_id"=$1 WHERE isbn IN ($2,$3)`)).
+			WithArgs(1, "111", "222").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "audit_logs"`)).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+		mock.ExpectCommit()
+
+		w := send(http.MethodPost, "/works/1/editions", `{"isbns":["111","222"]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Editions merged")
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Admin Cannot Merge Editions Other Libraries Hold", func(t *testing.T) {
+		role = "admin"
+		expectWork(true)
+		expectHeld("111")
+
+		w := send(http.MethodPost, "/works/1/editions", `{"isbns":["111","222"]}`)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"library_access_denied"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Merge Needs ISBNs", func(t *testing.T) {
+		w := send(http.MethodPost, "/works/1/editions", `{"isbns":[]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"validation_failed"`)
+	})
+
+	t.Run("Split Keeps The Last Edition", func(t *testing.T) {
+		role = "owner"
+		expectWork(true)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1`)).
+			WithArgs("111", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "work_id"}).AddRow(4, "111", 1))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (work_id = $1 AND isbn <> $2)`)).
+			WithArgs(1, "111").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		w := send(http.MethodDelete, "/works/1/editions/111", "")
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"conflict"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,340 @@
+package enrich
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"library-management/marc"
+	"library-management/models"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Dumps that can be loaded, also the names of their sources
+const (
+	DumpOpenLibrary = "openlibrary"
+	DumpLOC         = "loc"
+)
+
+// loadBatch is how many records are written per statement while loading
+const loadBatch = 1000
+
+// DumpSource looks editions up in one dump loaded into the database
+type DumpSource struct {
+	db   *gorm.DB
+	dump string
+}
+
+// NewDumpSource reads the records loaded from dump, DumpOpenLibrary or DumpLOC
+func NewDumpSource(db *gorm.DB, dump string) *DumpSource {
+	return &DumpSource{db: db, dump: dump}
+}
+
+func (s *DumpSource) Name() string {
+	return s.dump
+}
+
+func (s *DumpSource) Lookup(ctx context.Context, isbn string) (Metadata, error) {
+	db := s.db.WithContext(ctx)
+
+	var record models.BibRecord
+	err := db.Where("source = ? AND isbn = ?", s.dump, isbn).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Metadata{}, ErrNotFound
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	authors := record.Authors
+	if authors == "" && record.AuthorKeys != "" {
+		keys := strings.Split(record.AuthorKeys, ";")
+		var named []models.BibAuthor
+		if err := db.Where("key IN (?)", keys).Find(&named).Error; err != nil {
+			return Metadata{}, err
+		}
+		names := make(map[string]string, len(named))
+		for _, author := range named {
+			names[author.Key] = author.Name
+		}
+		// Credits keep the edition's order, authors missing from the dump are left out
+		var credits []string
+		for _, key := range keys {
+			if name := names[key]; name != "" {
+				credits = append(credits, name)
+			}
+		}
+		authors = strings.Join(credits, "; ")
+	}
+
+	return Metadata{
+		Source:    s.dump,
+		ISBN:      record.ISBN,
+		Title:     record.Title,
+		Authors:   authors,
+		Publisher: record.Publisher,
+		Edition:   record.Edition,
+		Subjects:  record.Subjects,
+	}, nil
+}
+
+// LoadStats counts what a dump load did
+type LoadStats struct {
+	Records int // Editions or authors written
+	Skipped int // Lines or records without a usable ISBN, title or name
+}
+
+// LoadOpenLibrary indexes an Open Library dump, the tab-separated type, key,
+// revision, last modified and JSON columns. Editions are stored once per ISBN
+// they list and authors by key; other types are skipped. Re-loading a newer
+// dump replaces what an older one wrote.
+func LoadOpenLibrary(ctx context.Context, db *gorm.DB, r io.Reader) (LoadStats, error) {
+	db = db.WithContext(ctx)
+	var stats LoadStats
+	var records []models.BibRecord
+	var authors []models.BibAuthor
+
+	flush := func() error {
+		if len(records) > 0 {
+			if err := upsert(db, latest(records)); err != nil {
+				return err
+			}
+			stats.Records += len(records)
+			records = records[:0]
+		}
+		if len(authors) > 0 {
+			if err := upsert(db, authors); err != nil {
+				return err
+			}
+			stats.Records += len(authors)
+			authors = authors[:0]
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20) // Some edition records run to megabytes
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		columns := strings.SplitN(scanner.Text(), "\t", 5)
+		if len(columns) != 5 {
+			stats.Skipped++
+			continue
+		}
+		switch columns[0] {
+		case "/type/edition":
+			parsed := parseOpenLibraryEdition([]byte(columns[4]))
+			if len(parsed) == 0 {
+				stats.Skipped++
+			}
+			records = append(records, parsed...)
+		case "/type/author":
+			author, ok := parseOpenLibraryAuthor([]byte(columns[4]))
+			if !ok {
+				stats.Skipped++
+				continue
+			}
+			authors = append(authors, author)
+		default:
+			continue
+		}
+		if len(records)+len(authors) >= loadBatch {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return stats, err
+	}
+	return stats, flush()
+}
+
+// openLibraryEdition is the part of an edition record that is kept
+type openLibraryEdition struct {
+	Key         string   `json:"key"`
+	Title       string   `json:"title"`
+	Subtitle    string   `json:"subtitle"`
+	ISBN10      []string `json:"isbn_10"`
+	ISBN13      []string `json:"isbn_13"`
+	Publishers  []string `json:"publishers"`
+	EditionName string   `json:"edition_name"`
+	Subjects    []string `json:"subjects"`
+	Authors     []struct {
+		Key string `json:"key"`
+	} `json:"authors"`
+}
+
+// parseOpenLibraryEdition returns a record per valid ISBN of an edition
+func parseOpenLibraryEdition(data []byte) []models.BibRecord {
+	var edition openLibraryEdition
+	if err := json.Unmarshal(data, &edition); err != nil || strings.TrimSpace(edition.Title) == "" {
+		return nil
+	}
+
+	title := strings.TrimSpace(edition.Title)
+	if subtitle := strings.TrimSpace(edition.Subtitle); subtitle != "" {
+		title += ": " + subtitle
+	}
+	keys := make([]string, 0, len(edition.Authors))
+	for _, author := range edition.Authors {
+		if author.Key != "" {
+			keys = append(keys, author.Key)
+		}
+	}
+	template := models.BibRecord{
+		Source:     DumpOpenLibrary,
+		SourceID:   edition.Key,
+		Title:      title,
+		AuthorKeys: strings.Join(keys, ";"),
+		Publisher:  joinClean(edition.Publishers),
+		Edition:    strings.TrimSpace(edition.EditionName),
+		Subjects:   joinClean(edition.Subjects),
+	}
+
+	var records []models.BibRecord
+	seen := map[string]bool{}
+	for _, isbn := range append(edition.ISBN13, edition.ISBN10...) {
+		normalized, ok := NormalizeISBN(isbn)
+		if !ok || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		record := template
+		record.ISBN = normalized
+		records = append(records, record)
+	}
+	return records
+}
+
+func parseOpenLibraryAuthor(data []byte) (models.BibAuthor, bool) {
+	var author models.BibAuthor
+	if err := json.Unmarshal(data, &author); err != nil {
+		return models.BibAuthor{}, false
+	}
+	author.Name = strings.TrimSpace(author.Name)
+	return author, author.Key != "" && author.Name != ""
+}
+
+// LoadMARC indexes Library of Congress MARC records, or any other MARC21 or
+// MARCXML catalog, mapped as a MARC import would map them. A record is stored
+// under every ISBN in its 020 fields. Unreadable records are skipped.
+func LoadMARC(ctx context.Context, db *gorm.DB, format marc.Format, r io.Reader) (LoadStats, error) {
+	db = db.WithContext(ctx)
+	var stats LoadStats
+	var records []models.BibRecord
+
+	reader := marc.NewReader(format, r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, marc.ErrInvalidRecord) {
+			stats.Skipped++
+			continue
+		}
+		if err != nil {
+			return stats, err
+		}
+
+		parsed := marcRecords(record)
+		if len(parsed) == 0 {
+			stats.Skipped++
+		}
+		records = append(records, parsed...)
+		if len(records) >= loadBatch {
+			if err := upsert(db, latest(records)); err != nil {
+				return stats, err
+			}
+			stats.Records += len(records)
+			records = records[:0]
+		}
+	}
+	if len(records) > 0 {
+		if err := upsert(db, latest(records)); err != nil {
+			return stats, err
+		}
+		stats.Records += len(records)
+	}
+	return stats, nil
+}
+
+// marcRecords returns a record per valid ISBN of a MARC record
+func marcRecords(record marc.Record) []models.BibRecord {
+	book, _ := marc.ToBook(record)
+	if book.Title == "" {
+		return nil
+	}
+	template := models.BibRecord{
+		Source:    DumpLOC,
+		Title:     book.Title,
+		Authors:   book.Authors,
+		Publisher: book.Publisher,
+		Edition:   book.Version,
+		Subjects:  book.Subjects,
+	}
+	for _, field := range record.FieldsWithTag("001") {
+		template.SourceID = strings.TrimSpace(field.Value)
+	}
+
+	var records []models.BibRecord
+	seen := map[string]bool{}
+	for _, field := range record.FieldsWithTag("020") {
+		// $a often carries a qualifier, as in "0262033844 (hardcover)"
+		value, _, _ := strings.Cut(strings.TrimSpace(field.Subfield('a')), " ")
+		normalized, ok := NormalizeISBN(value)
+		if !ok || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		record := template
+		record.ISBN = normalized
+		records = append(records, record)
+	}
+	return records
+}
+
+// upsert writes rows, replacing existing ones with the same primary key
+func upsert[T any](db *gorm.DB, rows []T) error {
+	return db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error
+}
+
+// latest keeps the last record for each ISBN, as one statement cannot upsert
+// the same row twice. Dumps do list a few ISBNs under more than one edition.
+func latest(records []models.BibRecord) []models.BibRecord {
+	index := make(map[string]int, len(records))
+	kept := records[:0:0]
+	for _, record := range records {
+		if i, ok := index[record.ISBN]; ok {
+			kept[i] = record
+			continue
+		}
+		index[record.ISBN] = len(kept)
+		kept = append(kept, record)
+	}
+	return kept
+}
+
+// joinClean trims values and joins the non-empty, distinct ones with "; "
+func joinClean(values []string) string {
+	var kept []string
+	seen := map[string]bool{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !seen[value] {
+			seen[value] = true
+			kept = append(kept, value)
+		}
+	}
+	return strings.Join(kept, "; ")
+}
@@ -0,0 +1,109 @@
+// Package enrich fills in a book's title, authors, publisher, edition and
+// subjects from its ISBN. Metadata comes from a Source; the ones shipped read
+// bibliographic dumps loaded into the database, so no request leaves the
+// network. Live providers can be added behind the same interface.
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"library-management/models"
+	"os"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned when a source has no record for an ISBN
+var ErrNotFound = errors.New("enrich: no record for this ISBN")
+
+// Metadata is what a source knows about an edition
+type Metadata struct {
+	Source    string `json:"source"`
+	ISBN      string `json:"isbn"` // ISBN-13
+	Title     string `json:"title"`
+	Authors   string `json:"authors"`
+	Publisher string `json:"publisher"`
+	Edition   string `json:"edition"`
+	Subjects  string `json:"subjects"`
+}
+
+// Source looks up editions by ISBN
+type Source interface {
+	// Name identifies the source in responses and logs
+	Name() string
+	// Lookup returns the edition with an ISBN-13, ErrNotFound when there is none
+	Lookup(ctx context.Context, isbn string) (Metadata, error)
+}
+
+// Chain asks each source in turn and returns the first record found
+type Chain []Source
+
+func (c Chain) Name() string {
+	names := make([]string, len(c))
+	for i, source := range c {
+		names[i] = source.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (c Chain) Lookup(ctx context.Context, isbn string) (Metadata, error) {
+	for _, source := range c {
+		metadata, err := source.Lookup(ctx, isbn)
+		if !errors.Is(err, ErrNotFound) {
+			return metadata, err
+		}
+	}
+	return Metadata{}, ErrNotFound
+}
+
+// Find normalises an ISBN as typed, hyphens and ISBN-10 included, and looks it up
+func Find(ctx context.Context, source Source, isbn string) (Metadata, error) {
+	normalized, ok := NormalizeISBN(isbn)
+	if !ok {
+		return Metadata{}, ErrInvalidISBN
+	}
+	return source.Lookup(ctx, normalized)
+}
+
+// Apply copies metadata onto a book. Only empty fields are filled unless
+// overwrite is set, and empty metadata never clears a field. It reports
+// whether anything changed.
+func Apply(book *models.Book, metadata Metadata, overwrite bool) bool {
+	changed := false
+	set := func(field *string, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" || *field == value || (*field != "" && !overwrite) {
+			return
+		}
+		*field = value
+		changed = true
+	}
+	set(&book.Title, metadata.Title)
+	set(&book.Authors, metadata.Authors)
+	set(&book.Publisher, metadata.Publisher)
+	set(&book.Version, metadata.Edition)
+	set(&book.Subjects, metadata.Subjects)
+	return changed
+}
+
+// SourceFromEnv builds the chain named by ENRICH_SOURCES, dump sources in the
+// order to ask them, "openlibrary,loc" by default. "none" turns lookups off.
+func SourceFromEnv(db *gorm.DB) (Source, error) {
+	names := os.Getenv("ENRICH_SOURCES")
+	if names == "" {
+		names = DumpOpenLibrary + "," + DumpLOC
+	}
+	var chain Chain
+	for _, name := range strings.Split(names, ",") {
+		switch name = strings.ToLower(strings.TrimSpace(name)); name {
+		case DumpOpenLibrary, DumpLOC:
+			chain = append(chain, NewDumpSource(db, name))
+		case "none", "":
+		default:
+			return nil, fmt.Errorf("enrich: unknown source %q in ENRICH_SOURCES, use openlibrary, loc or none", name)
+		}
+	}
+	return chain, nil
+}
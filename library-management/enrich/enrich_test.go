@@ -0,0 +1,265 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"library-management/marc"
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func open(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		input, expected string
+		ok              bool
+	}{
+		{"978-0-441-17271-9", "9780441172719", true},
+		{"0441172717", "9780441172719", true},
+		{"0-8044-2957-X", "9780804429573", true},
+		{"080442957x", "9780804429573", true},
+		{"9780441172718", "", false}, // Bad check digit
+		{"0441172718", "", false},
+		{"97804411727", "", false},
+		{"978044117271X", "", false},
+		{"ISBN 0441172717", "", false},
+	}
+	for _, tt := range tests {
+		isbn, ok := NormalizeISBN(tt.input)
+		assert.Equal(t, tt.ok, ok, tt.input)
+		assert.Equal(t, tt.expected, isbn, tt.input)
+	}
+}
+
+func TestApply(t *testing.T) {
+	metadata := Metadata{Title: "Dune", Authors: "Herbert, Frank", Publisher: "Ace Books", Edition: "40th anniversary edition", Subjects: "Science fiction"}
+
+	book := models.Book{ISBN: "9780441172719", Title: "DUNE (pbk)"}
+	assert.True(t, Apply(&book, metadata, false))
+	assert.Equal(t, "DUNE (pbk)", book.Title, "filled fields are kept")
+	assert.Equal(t, "Herbert, Frank", book.Authors)
+	assert.Equal(t, "40th anniversary edition", book.Version)
+	assert.False(t, Apply(&book, metadata, false), "nothing left to fill")
+
+	assert.True(t, Apply(&book, metadata, true))
+	assert.Equal(t, "Dune", book.Title)
+	assert.False(t, Apply(&book, Metadata{}, true), "empty metadata never clears a field")
+	assert.Equal(t, "Dune", book.Title)
+}
+
+type fixedSource map[string]Metadata
+
+func (s fixedSource) Name() string { return "fixed" }
+
+func (s fixedSource) Lookup(ctx context.Context, isbn string) (Metadata, error) {
+	if metadata, ok := s[isbn]; ok {
+		return metadata, nil
+	}
+	return Metadata{}, ErrNotFound
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		fixedSource{"9780441172719": {Source: "first", Title: "Dune"}},
+		fixedSource{"9780441172719": {Source: "second"}, "9780140449136": {Source: "second", Title: "Crime and Punishment"}},
+	}
+
+	metadata, err := Find(context.Background(), chain, "0441172717")
+	require.NoError(t, err)
+	assert.Equal(t, "first", metadata.Source)
+
+	metadata, err = Find(context.Background(), chain, "978-0-14-044913-6")
+	require.NoError(t, err)
+	assert.Equal(t, "Crime and Punishment", metadata.Title)
+
+	_, err = Find(context.Background(), chain, "9780262033848")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = Find(context.Background(), chain, "12345")
+	assert.ErrorIs(t, err, ErrInvalidISBN)
+}
+
+func TestSourceFromEnv(t *testing.T) {
+	t.Setenv("ENRICH_SOURCES", "")
+	source, err := SourceFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "openlibrary,loc", source.Name())
+
+	t.Setenv("ENRICH_SOURCES", "LOC")
+	source, err = SourceFromEnv(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "loc", source.Name())
+
+	t.Setenv("ENRICH_SOURCES", "none")
+	source, err = SourceFromEnv(nil)
+	require.NoError(t, err)
+	_, err = source.Lookup(context.Background(), "9780441172719")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	t.Setenv("ENRICH_SOURCES", "amazon")
+	_, err = SourceFromEnv(nil)
+	assert.Error(t, err)
+}
+
+func TestParseOpenLibraryEdition(t *testing.T) {
+	records := parseOpenLibraryEdition([]byte(`{
+		"key": "/books/OL7353617M", "title": "Dune", "subtitle": " a novel ",
+		"isbn_10": ["0441172717", "not-an-isbn"], "isbn_13": ["9780441172719"],
+		"publishers": ["Ace Books", " ", "Ace Books"], "edition_name": "40th anniversary edition",
+		"subjects": ["Science fiction", "Deserts"], "authors": [{"key": "/authors/OL79034A"}]
+	}`))
+	require.Len(t, records, 1, "both ISBNs are the same edition")
+	assert.Equal(t, models.BibRecord{
+		Source:     DumpOpenLibrary,
+		ISBN:       "9780441172719",
+		SourceID:   "/books/OL7353617M",
+		Title:      "Dune: a novel",
+		AuthorKeys: "/authors/OL79034A",
+		Publisher:  "Ace Books",
+		Edition:    "40th anniversary edition",
+		Subjects:   "Science fiction; Deserts",
+	}, records[0])
+
+	assert.Empty(t, parseOpenLibraryEdition([]byte(`{"isbn_13": ["9780441172719"]}`)), "no title")
+	assert.Empty(t, parseOpenLibraryEdition([]byte(`{"title": "No ISBN"}`)))
+	assert.Empty(t, parseOpenLibraryEdition([]byte(`{not json`)))
+}
+
+func TestMARCRecords(t *testing.T) {
+	record := marc.Record{
+		Leader: "00000cam a2200000 i 4500",
+		Fields: []marc.Field{
+			{Tag: "001", Value: "2004063447"},
+			{Tag: "020", Subfields: []marc.Subfield{{Code: 'a', Value: "0262033844 (hardcover : alk. paper)"}}},
+			{Tag: "020", Subfields: []marc.Subfield{{Code: 'a', Value: "9780262033848"}}},
+			{Tag: "100", Indicator1: '1', Subfields: []marc.Subfield{{Code: 'a', Value: "Cormen, Thomas H."}}},
+			{Tag: "245", Indicator1: '1', Indicator2: '0', Subfields: []marc.Subfield{{Code: 'a', Value: "Introduction to algorithms /"}}},
+		},
+	}
+	records := marcRecords(record)
+	require.Len(t, records, 1)
+	assert.Equal(t, "9780262033848", records[0].ISBN)
+	assert.Equal(t, "2004063447", records[0].SourceID)
+	assert.Equal(t, "Introduction to algorithms", records[0].Title)
+	assert.Equal(t, "Thomas H. Cormen", records[0].Authors)
+}
+
+func TestLoadOpenLibrary(t *testing.T) {
+	db, mock := open(t)
+	dump := strings.Join([]string{
+		"/type/author\t/authors/OL79034A\t3\t2010-04-28T06:54:19\t" + `{"key": "/authors/OL79034A", "name": "Frank Herbert"}`,
+		"/type/edition\t/books/OL1M\t5\t2010-04-28T06:54:19\t" + `{"key": "/books/OL1M", "title": "Dune", "isbn_13": ["9780441172719"]}`,
+		"/type/edition\t/books/OL2M\t2\t2010-04-28T06:54:19\t" + `{"key": "/books/OL2M", "title": "Dune (reissue)", "isbn_10": ["0441172717"]}`,
+		"/type/work\t/works/OL1W\t1\t2010-04-28T06:54:19\t{}",
+		"truncated line",
+	}, "\n")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "bib_records" ("source","isbn","source_id","title","authors","author_keys","publisher","edition","subjects","updated_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT ("source","isbn") DO UPDATE`)).
+		WithArgs("openlibrary", "9780441172719", "/books/OL2M", "Dune (reissue)", "", "", "", "", "", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "bib_authors" ("key","name") VALUES ($1,$2) ON CONFLICT ("key") DO UPDATE`)).
+		WithArgs("/authors/OL79034A", "Frank Herbert").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	stats, err := LoadOpenLibrary(context.Background(), db, strings.NewReader(dump))
+	require.NoError(t, err)
+	assert.Equal(t, LoadStats{Records: 3, Skipped: 1}, stats, "both editions are read, the later one is kept")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDumpSourceLookup(t *testing.T) {
+	db, mock := open(t)
+	source := NewDumpSource(db, DumpOpenLibrary)
+	expectRecord := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bib_records" WHERE source = $1 AND isbn = $2 ORDER BY "bib_records"."source" LIMIT $3`)).
+			WithArgs("openlibrary", "9780441172719", 1).
+			WillReturnRows(rows)
+	}
+
+	t.Run("Authors Named From Their Keys", func(t *testing.T) {
+		expectRecord(sqlmock.NewRows([]string{"source", "isbn", "title", "author_keys", "publisher"}).
+			AddRow("openlibrary", "9780441172719", "Dune", "/authors/OL2A;/authors/OL1A;/authors/OL3A", "Ace Books"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "bib_authors" WHERE key IN ($1,$2,$3)`)).
+			WithArgs("/authors/OL2A", "/authors/OL1A", "/authors/OL3A").
+			WillReturnRows(sqlmock.NewRows([]string{"key", "name"}).
+				AddRow("/authors/OL1A", "Frank Herbert").
+				AddRow("/authors/OL2A", "Brian Herbert"))
+
+		metadata, err := source.Lookup(context.Background(), "9780441172719")
+		require.NoError(t, err)
+		assert.Equal(t, "Brian Herbert; Frank Herbert", metadata.Authors, "edition order, unknown keys left out")
+		assert.Equal(t, "Ace Books", metadata.Publisher)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Not Loaded", func(t *testing.T) {
+		expectRecord(sqlmock.NewRows([]string{"isbn"}))
+		_, err := source.Lookup(context.Background(), "9780441172719")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Database Error", func(t *testing.T) {
+		mock.ExpectQuery(`SELECT`).WillReturnError(errors.New("connection reset"))
+		_, err := source.Lookup(context.Background(), "9780441172719")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestClaim(t *testing.T) {
+	db, mock := open(t)
+	expectCandidates := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "enrichment_jobs" WHERE status = $1 OR (status = $2 AND updated_at < $3) ORDER BY id ASC LIMIT $4`)).
+			WithArgs(JobQueued, JobRunning, sqlmock.AnyArg(), 5).
+			WillReturnRows(rows)
+	}
+	expectClaim := func(id int, claimed int64) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "enrichment_jobs" SET "status"=$1,"updated_at"=$2 WHERE id = $3 AND status = $4 AND updated_at = $5`)).
+			WithArgs(JobRunning, sqlmock.AnyArg(), id, JobQueued, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, claimed))
+		mock.ExpectCommit()
+	}
+
+	t.Run("Skips Jobs Another Worker Took", func(t *testing.T) {
+		expectCandidates(sqlmock.NewRows([]string{"id", "library_id", "status"}).
+			AddRow(3, 1, JobQueued).
+			AddRow(4, 2, JobQueued))
+		expectClaim(3, 0)
+		expectClaim(4, 1)
+
+		job, ok, err := Claim(context.Background(), db)
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, uint(4), job.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Nothing To Do", func(t *testing.T) {
+		expectCandidates(sqlmock.NewRows([]string{"id"}))
+
+		_, ok, err := Claim(context.Background(), db)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
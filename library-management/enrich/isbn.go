@@ -0,0 +1,70 @@
+package enrich
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalidISBN is returned for text that is not a valid ISBN-10 or ISBN-13
+var ErrInvalidISBN = errors.New("enrich: not a valid ISBN-10 or ISBN-13")
+
+// NormalizeISBN returns the ISBN-13 for an ISBN-10 or ISBN-13 with or without
+// hyphens and spaces, checking its check digit
+func NormalizeISBN(isbn string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return r
+		case r == 'x' || r == 'X':
+			return 'X'
+		case r == '-' || r == ' ':
+			return -1
+		}
+		return '?'
+	}, isbn)
+
+	switch len(digits) {
+	case 10:
+		if !validISBN10(digits) {
+			return "", false
+		}
+		body := "978" + digits[:9]
+		return body + string(checkDigit13(body)), true
+	case 13:
+		if strings.ContainsAny(digits, "X?") || checkDigit13(digits[:12]) != digits[12] {
+			return "", false
+		}
+		return digits, true
+	}
+	return "", false
+}
+
+func validISBN10(digits string) bool {
+	sum := 0
+	for i := 0; i < 10; i++ {
+		var value int
+		switch c := digits[i]; {
+		case c >= '0' && c <= '9':
+			value = int(c - '0')
+		case c == 'X' && i == 9:
+			value = 10
+		default:
+			return false
+		}
+		sum += value * (10 - i)
+	}
+	return sum%11 == 0
+}
+
+// checkDigit13 computes the last digit of an ISBN-13 from its first twelve
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(body[i]-'0') * weight
+	}
+	return byte('0' + (10-sum%10)%10)
+}
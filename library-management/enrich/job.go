@@ -0,0 +1,198 @@
+package enrich
+
+import (
+	"context"
+	"errors"
+	"library-management/authority"
+	"library-management/health"
+	"library-management/models"
+	"log/slog"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Job states
+const (
+	JobQueued  = "queued"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobFailed  = "failed"
+)
+
+// WorkerName is how the job worker shows up in readiness
+const WorkerName = "enrichment"
+
+const (
+	// jobBatch is how many books a job reads and reports progress for at a time
+	jobBatch = 100
+	// staleAfter is how long a running job may go without progress before
+	// another worker takes it over, as its own worker must have stopped
+	staleAfter = 10 * time.Minute
+)
+
+// Enrich looks a book up and applies what was found. It reports whether the
+// book changed; ErrNotFound and ErrInvalidISBN mean there was nothing to apply.
+// The book is saved only if nobody changed it since it was read, and its
+// authority links follow its new text.
+func Enrich(ctx context.Context, db *gorm.DB, source Source, book *models.Book, overwrite bool) (bool, error) {
+	metadata, err := Find(ctx, source, book.ISBN)
+	if err != nil {
+		return false, err
+	}
+	before := *book
+	if !Apply(book, metadata, overwrite) {
+		return false, nil
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(book).Where("revision = ?", before.Revision).Select("*").Updates(book)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errors.New("enrich: book changed while it was enriched")
+		}
+		if book.Authors != before.Authors || book.Publisher != before.Publisher || book.Subjects != before.Subjects {
+			return authority.Link(tx, *book)
+		}
+		return nil
+	})
+	if err != nil {
+		*book = before
+		return false, err
+	}
+	return true, nil
+}
+
+// Run works through every book of a job's library, saving progress after each
+// batch. Books that fail are counted and skipped; the job fails only when its
+// library cannot be read. A job stopped by ctx is left running, to be taken
+// over once it goes stale.
+func Run(ctx context.Context, db *gorm.DB, source Source, job *models.EnrichmentJob) error {
+	db = db.WithContext(ctx)
+	now := time.Now()
+	job.Status, job.StartedAt = JobRunning, &now
+	job.Processed, job.Updated, job.NotFound, job.Failed, job.Error = 0, 0, 0, 0, ""
+
+	var total int64
+	if err := db.Model(&models.Book{}).Where("library_id = ?", job.LibraryID).Count(&total).Error; err != nil {
+		return finish(db, job, err)
+	}
+	job.Total = int(total)
+	if err := db.Save(job).Error; err != nil {
+		return err
+	}
+
+	var batch []models.Book
+	err := db.Where("library_id = ?", job.LibraryID).FindInBatches(&batch, jobBatch, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			changed, err := Enrich(ctx, db, source, &batch[i], job.Overwrite)
+			switch {
+			case errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalidISBN):
+				job.NotFound++
+			case err != nil:
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				slog.Warn("book not enriched", "job_id", job.ID, "book_id", batch[i].ID, "isbn", batch[i].ISBN, "error", err)
+				job.Failed++
+			case changed:
+				job.Updated++
+			}
+			job.Processed++
+		}
+		health.Beat(WorkerName, nil) // A long job keeps the worker ready
+		return db.Save(job).Error
+	}).Error
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return finish(db, job, err)
+}
+
+// finish records how a job ended
+func finish(db *gorm.DB, job *models.EnrichmentJob, err error) error {
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = JobDone
+	if err != nil {
+		job.Status, job.Error = JobFailed, err.Error()
+	}
+	if saveErr := db.Save(job).Error; saveErr != nil {
+		return saveErr
+	}
+	return err
+}
+
+// Claim takes the oldest queued job, or a running one whose worker stopped
+// making progress. It returns false when there is nothing to do. Workers on
+// several replicas never claim the same job.
+func Claim(ctx context.Context, db *gorm.DB) (models.EnrichmentJob, bool, error) {
+	db = db.WithContext(ctx)
+	var candidates []models.EnrichmentJob
+	err := db.Where("status = ? OR (status = ? AND updated_at < ?)", JobQueued, JobRunning, time.Now().Add(-staleAfter)).
+		Order("id ASC").Limit(5).Find(&candidates).Error
+	if err != nil {
+		return models.EnrichmentJob{}, false, err
+	}
+	for _, job := range candidates {
+		// Only one worker sees its update change the row
+		claimed := db.Model(&models.EnrichmentJob{}).
+			Where("id = ? AND status = ? AND updated_at = ?", job.ID, job.Status, job.UpdatedAt).
+			Updates(map[string]interface{}{"status": JobRunning, "updated_at": time.Now()})
+		if claimed.Error != nil {
+			return models.EnrichmentJob{}, false, claimed.Error
+		}
+		if claimed.RowsAffected == 1 {
+			return job, true, nil
+		}
+	}
+	return models.EnrichmentJob{}, false, nil
+}
+
+// Worker runs queued jobs until ctx is done, checking for new ones every
+// interval. It beats the health registry after each check.
+func Worker(ctx context.Context, db *gorm.DB, source Source, interval time.Duration) {
+	health.RegisterWorker(WorkerName, interval)
+	defer health.UnregisterWorker(WorkerName)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var err error
+		for {
+			var job models.EnrichmentJob
+			var ok bool
+			if job, ok, err = Claim(ctx, db); err != nil || !ok {
+				break
+			}
+			slog.Info("enrichment job started", "job_id", job.ID, "library_id", job.LibraryID)
+			if runErr := Run(ctx, db, source, &job); runErr != nil {
+				slog.Error("enrichment job failed", "job_id", job.ID, "error", runErr)
+			} else {
+				slog.Info("enrichment job done", "job_id", job.ID, "updated", job.Updated, "not_found", job.NotFound, "failed", job.Failed)
+			}
+			health.Beat(WorkerName, nil)
+		}
+		if err != nil && ctx.Err() == nil {
+			slog.Error("enrichment worker could not claim a job", "error", err)
+		}
+		health.Beat(WorkerName, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// WorkerIntervalFromEnv reads ENRICH_WORKER_INTERVAL, 10 seconds by default
+func WorkerIntervalFromEnv() time.Duration {
+	if interval, err := time.ParseDuration(os.Getenv("ENRICH_WORKER_INTERVAL")); err == nil && interval > 0 {
+		return interval
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,83 @@
+package health
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerStatus is what readiness reports for one background worker
+type WorkerStatus struct {
+	Healthy  bool      `json:"healthy"`
+	LastBeat time.Time `json:"last_beat"`
+	Interval string    `json:"interval"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type worker struct {
+	interval time.Duration
+	lastBeat time.Time
+	lastErr  error
+}
+
+var (
+	mu           sync.RWMutex
+	workers      = map[string]*worker{}
+	shuttingDown atomic.Bool
+)
+
+// RegisterWorker announces a background worker expected to call Beat at least
+// once per interval. A worker that misses two intervals makes the service unready.
+func RegisterWorker(name string, interval time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	workers[name] = &worker{interval: interval, lastBeat: time.Now()}
+}
+
+// UnregisterWorker forgets a worker that stopped on purpose
+func UnregisterWorker(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(workers, name)
+}
+
+// Beat records a finished worker run, with the error it ended on if any
+func Beat(name string, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if w, ok := workers[name]; ok {
+		w.lastBeat = time.Now()
+		w.lastErr = err
+	}
+}
+
+// Workers reports every registered worker as of now
+func Workers(now time.Time) map[string]WorkerStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	statuses := make(map[string]WorkerStatus, len(workers))
+	for name, w := range workers {
+		status := WorkerStatus{
+			Healthy:  w.lastErr == nil && now.Sub(w.lastBeat) <= 2*w.interval,
+			LastBeat: w.lastBeat,
+			Interval: w.interval.String(),
+		}
+		if w.lastErr != nil {
+			status.Error = w.lastErr.Error()
+		}
+		statuses[name] = status
+	}
+	return statuses
+}
+
+// StartShutdown flips readiness off so load balancers stop routing new traffic
+// while in-flight requests drain
+func StartShutdown() {
+	shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether StartShutdown was called
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
@@ -0,0 +1,89 @@
+package listquery
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// cursorPayload is what an opaque cursor carries: the sort it was issued for
+// and the sort values of the last row on the page
+type cursorPayload struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+func encodeCursor(sort string, values []interface{}) (string, error) {
+	raw, err := json.Marshal(cursorPayload{Sort: sort, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func decodeCursor(cursor, sort string, keys int) ([]interface{}, error) {
+	invalid := fmt.Errorf("Cursor is invalid or was issued for a different sort")
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var payload cursorPayload
+	if err := decoder.Decode(&payload); err != nil || payload.Sort != sort || len(payload.Values) != keys {
+		return nil, invalid
+	}
+
+	for i, value := range payload.Values {
+		switch v := value.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				payload.Values[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				payload.Values[i] = f
+			} else {
+				return nil, invalid
+			}
+		case string, bool:
+		default:
+			return nil, invalid
+		}
+	}
+	return payload.Values, nil
+}
+
+// trim drops the look-ahead row from dest and returns the cursor for the next
+// page, empty when this is the last one
+func (p *Params) trim(tx *gorm.DB, dest interface{}) (string, error) {
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= p.Limit {
+		return "", nil
+	}
+	rows.Set(rows.Slice(0, p.Limit))
+
+	schema := tx.Statement.Schema
+	if schema == nil {
+		return "", fmt.Errorf("listquery: cannot build a cursor without a model")
+	}
+	last := rows.Index(p.Limit - 1)
+	for last.Kind() == reflect.Ptr {
+		last = last.Elem()
+	}
+
+	values := make([]interface{}, len(p.keys))
+	for i, key := range p.keys {
+		column := key.column[strings.LastIndex(key.column, ".")+1:]
+		field := schema.LookUpField(column)
+		if field == nil {
+			return "", fmt.Errorf("listquery: %s has no field for sort column %s", schema.Name, column)
+		}
+		values[i], _ = field.ValueOf(tx.Statement.Context, last)
+	}
+	return encodeCursor(p.sort, values)
+}
@@ -0,0 +1,366 @@
+// Package listquery parses the paging, sorting and filtering parameters shared by
+// list endpoints and applies them to GORM queries. Only the sort keys and filters
+// an endpoint declares in its Spec reach SQL, always as bound values.
+//
+// Clients page with either ?page=N (offset) or ?cursor=... (keyset, stable while
+// rows are added). Every response carries limit, total and next_cursor; page is
+// included when offset paging was used.
+package listquery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"library-management/apierror"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Op is how a filter value is compared with its column
+type Op int
+
+const (
+	// Equals matches the value exactly
+	Equals Op = iota
+	// Contains matches a case-insensitive substring
+	Contains
+	// EqualsFold matches the whole value case-insensitively
+	EqualsFold
+	// From keeps rows at or after the value; dates include the whole day
+	From
+	// To keeps rows at or before the value; dates include the whole day
+	To
+)
+
+// Kind is how a filter value is parsed
+type Kind int
+
+const (
+	// String values are used as given
+	String Kind = iota
+	// Int values must be whole numbers
+	Int
+	// Bool values must be true or false
+	Bool
+	// Date values are YYYY-MM-DD, compared with a timestamp column
+	Date
+	// UnixDate values are YYYY-MM-DD, compared with a column of Unix seconds
+	UnixDate
+)
+
+// Filter binds a query parameter to a column
+type Filter struct {
+	Column string
+	Op     Op
+	Kind   Kind
+	// Enum, when set, lists the only accepted values
+	Enum []string
+}
+
+// Spec declares what an endpoint accepts
+type Spec struct {
+	DefaultLimit int
+	MaxLimit     int
+
+	// Sorts maps the public sort key to its column
+	Sorts map[string]string
+	// DefaultSort is used without ?sort, e.g. "-request_date"
+	DefaultSort string
+	// TieBreaker is a unique column appended to every sort so pages never overlap
+	TieBreaker string
+
+	// Filters maps the query parameter name to its filter
+	Filters map[string]Filter
+}
+
+// sortKey is one column of the ORDER BY
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// condition is a parsed filter ready to be applied
+type condition struct {
+	sql   string
+	value interface{}
+}
+
+// Params is a parsed list request
+type Params struct {
+	Limit int
+	// Page is zero when the client sent a cursor
+	Page int
+
+	sort       string
+	keys       []sortKey
+	cursor     []interface{}
+	conditions []condition
+}
+
+// Parse reads limit, page or cursor, sort and the declared filters. On invalid
+// input it writes an invalid_parameter problem and returns false.
+func Parse(c *gin.Context, spec Spec) (*Params, bool) {
+	params, err := parse(c, spec)
+	if err != nil {
+		apierror.Respond(c, apierror.CodeInvalidParameter, err.Error())
+		return nil, false
+	}
+	return params, true
+}
+
+func parse(c *gin.Context, spec Spec) (*Params, error) {
+	params := &Params{Limit: spec.DefaultLimit}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > spec.MaxLimit {
+			return nil, fmt.Errorf("Limit must be between 1 and %d", spec.MaxLimit)
+		}
+		params.Limit = limit
+	}
+
+	params.sort = c.DefaultQuery("sort", spec.DefaultSort)
+	keys, err := parseSort(params.sort, spec)
+	if err != nil {
+		return nil, err
+	}
+	params.keys = keys
+
+	cursor, hasCursor := c.GetQuery("cursor")
+	if _, hasPage := c.GetQuery("page"); hasPage && hasCursor {
+		return nil, fmt.Errorf("Use either page or cursor, not both")
+	}
+	if hasCursor {
+		if params.cursor, err = decodeCursor(cursor, params.sort, len(params.keys)); err != nil {
+			return nil, err
+		}
+	} else {
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			return nil, fmt.Errorf("Page must be a positive number")
+		}
+		params.Page = page
+	}
+
+	// Sorted so the generated SQL is stable
+	names := make([]string, 0, len(spec.Filters))
+	for name := range spec.Filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		filter := spec.Filters[name]
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		cond, err := filter.condition(name, raw)
+		if err != nil {
+			return nil, err
+		}
+		params.conditions = append(params.conditions, cond)
+	}
+
+	return params, nil
+}
+
+// parseSort reads a comma separated list such as "-request_date,status"
+func parseSort(raw string, spec Spec) ([]sortKey, error) {
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		desc := strings.HasPrefix(field, "-")
+		name := strings.TrimPrefix(field, "-")
+		column, ok := spec.Sorts[name]
+		if !ok {
+			return nil, fmt.Errorf("Cannot sort by %q, allowed: %s", name, strings.Join(sortNames(spec), ", "))
+		}
+		if !seen[column] {
+			seen[column] = true
+			keys = append(keys, sortKey{column: column, desc: desc})
+		}
+	}
+	if spec.TieBreaker != "" && !seen[spec.TieBreaker] {
+		keys = append(keys, sortKey{column: spec.TieBreaker})
+	}
+	return keys, nil
+}
+
+func (f Filter) condition(name, raw string) (condition, error) {
+	if len(f.Enum) > 0 && !contains(f.Enum, raw) {
+		return condition{}, fmt.Errorf("%s must be one of %s", name, strings.Join(f.Enum, ", "))
+	}
+
+	var value interface{} = raw
+	switch f.Kind {
+	case Int:
+		number, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return condition{}, fmt.Errorf("%s must be a whole number", name)
+		}
+		value = number
+	case Bool:
+		flag, err := strconv.ParseBool(raw)
+		if err != nil {
+			return condition{}, fmt.Errorf("%s must be true or false", name)
+		}
+		value = flag
+	case Date, UnixDate:
+		day, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return condition{}, fmt.Errorf("%s must be a date in YYYY-MM-DD format", name)
+		}
+		// "to" covers the whole day
+		if f.Op == To {
+			day = day.AddDate(0, 0, 1)
+		}
+		if f.Kind == UnixDate {
+			value = day.Unix()
+		} else {
+			value = day
+		}
+	}
+
+	switch f.Op {
+	case Contains:
+		return condition{f.Column + " ILIKE ?", "%" + escapeLike(raw) + "%"}, nil
+	case EqualsFold:
+		return condition{f.Column + " ILIKE ?", escapeLike(raw)}, nil
+	case From:
+		return condition{f.Column + " >= ?", value}, nil
+	case To:
+		if f.Kind == Date || f.Kind == UnixDate {
+			return condition{f.Column + " < ?", value}, nil
+		}
+		return condition{f.Column + " <= ?", value}, nil
+	}
+	return condition{f.Column + " = ?", value}, nil
+}
+
+// Filter applies the parsed filters, use it before counting
+func (p *Params) Filter(query *gorm.DB) *gorm.DB {
+	for _, cond := range p.conditions {
+		query = query.Where(cond.sql, cond.value)
+	}
+	return query
+}
+
+// Result describes the page that was fetched
+type Result struct {
+	Total      int64
+	NextCursor string
+}
+
+// Find filters query, counts every match, then fetches one page into dest, a
+// pointer to a slice of models. dest's model must have a field for each sort column.
+func (p *Params) Find(query *gorm.DB, dest interface{}) (Result, error) {
+	return p.FindPage(p.Filter(query), dest)
+}
+
+// FindPage is Find for a query the filters were already applied to elsewhere,
+// such as to a subquery selecting the rows to list
+func (p *Params) FindPage(query *gorm.DB, dest interface{}) (Result, error) {
+	var result Result
+	if err := query.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+		return result, err
+	}
+
+	page := query.Session(&gorm.Session{})
+	for _, key := range p.keys {
+		page = page.Order(key.orderBy())
+	}
+	if p.cursor != nil {
+		sql, vars := p.after()
+		page = page.Where(sql, vars...)
+	} else if p.Page > 1 {
+		page = page.Offset((p.Page - 1) * p.Limit)
+	}
+
+	// One extra row tells whether another page follows
+	tx := page.Limit(p.Limit + 1).Find(dest)
+	if tx.Error != nil {
+		return result, tx.Error
+	}
+
+	next, err := p.trim(tx, dest)
+	if err != nil {
+		return result, err
+	}
+	result.NextCursor = next
+	return result, nil
+}
+
+// Envelope wraps items in the standard list response
+func (p *Params) Envelope(key string, items interface{}, result Result) gin.H {
+	envelope := gin.H{key: items, "limit": p.Limit, "total": result.Total, "next_cursor": nil}
+	if result.NextCursor != "" {
+		envelope["next_cursor"] = result.NextCursor
+	}
+	if p.Page > 0 {
+		envelope["page"] = p.Page
+	}
+	return envelope
+}
+
+func (k sortKey) orderBy() string {
+	if k.desc {
+		return k.column + " DESC"
+	}
+	return k.column + " ASC"
+}
+
+// after builds the keyset condition for rows that sort after the cursor:
+// (a > x) OR (a = x AND b > y) ..., flipping the comparison for descending keys.
+// GORM parenthesises it when other conditions are present.
+func (p *Params) after() (string, []interface{}) {
+	var (
+		clauses []string
+		vars    []interface{}
+	)
+	for i, key := range p.keys {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, p.keys[j].column+" = ?")
+			vars = append(vars, p.cursor[j])
+		}
+		op := " > ?"
+		if key.desc {
+			op = " < ?"
+		}
+		parts = append(parts, key.column+op)
+		vars = append(vars, p.cursor[i])
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+	return strings.Join(clauses, " OR "), vars
+}
+
+func sortNames(spec Spec) []string {
+	names := make([]string, 0, len(spec.Sorts))
+	for name := range spec.Sorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeLike stops user input acting as LIKE wildcards
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
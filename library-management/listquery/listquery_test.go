@@ -0,0 +1,148 @@
+package listquery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+type widget struct {
+	ID    uint
+	Name  string
+	Stock int
+}
+
+var widgetListing = Spec{
+	DefaultLimit: 2,
+	MaxLimit:     10,
+	Sorts:        map[string]string{"name": "name", "stock": "stock"},
+	DefaultSort:  "name",
+	TieBreaker:   "id",
+	Filters: map[string]Filter{
+		"name":  {Column: "name", Op: Contains},
+		"kind":  {Column: "kind", Enum: []string{"small", "large"}},
+		"stock": {Column: "stock", Kind: Int},
+		"from":  {Column: "created", Op: From, Kind: UnixDate},
+		"to":    {Column: "created", Op: To, Kind: UnixDate},
+	},
+}
+
+func parseQuery(t *testing.T, query string) (*Params, error) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/widgets?"+query, nil)
+	return parse(c, widgetListing)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		err   string
+	}{
+		{"Defaults", "", ""},
+		{"Limit Too Large", "limit=11", "Limit must be between 1 and 10"},
+		{"Limit Not A Number", "limit=ten", "Limit must be between 1 and 10"},
+		{"Page And Cursor", "page=2&cursor=abc", "Use either page or cursor, not both"},
+		{"Negative Page", "page=-1", "Page must be a positive number"},
+		{"Unknown Sort", "sort=price", `Cannot sort by "price", allowed: name, stock`},
+		{"Enum", "kind=medium", "kind must be one of small, large"},
+		{"Int", "stock=many", "stock must be a whole number"},
+		{"Date", "from=yesterday", "from must be a date in YYYY-MM-DD format"},
+		{"Bad Cursor", "cursor=not-base64!", "Cursor is invalid or was issued for a different sort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseQuery(t, tt.query)
+			if tt.err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, 2, params.Limit)
+				assert.Equal(t, 1, params.Page)
+				assert.Equal(t, []sortKey{{column: "name"}, {column: "id"}}, params.keys)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	t.Run("Cursor From Another Sort", func(t *testing.T) {
+		cursor, err := encodeCursor("stock", []interface{}{3, 1})
+		require.NoError(t, err)
+		_, err = parseQuery(t, "cursor="+cursor)
+		assert.EqualError(t, err, "Cursor is invalid or was issued for a different sort")
+	})
+
+	t.Run("Filters", func(t *testing.T) {
+		params, err := parseQuery(t, "name=50%25_off&to=2025-03-11&from=2025-03-10&sort=-stock")
+		require.NoError(t, err)
+		assert.Equal(t, []sortKey{{column: "stock", desc: true}, {column: "id"}}, params.keys)
+		assert.Equal(t, []condition{
+			{"created >= ?", int64(1741564800)},
+			{`name ILIKE ?`, `%50\%\_off%`},
+			{"created < ?", int64(1741737600)},
+		}, params.conditions)
+	})
+}
+
+func TestFind(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	// The first page reads a look-ahead row and hands out a cursor for the last one it keeps
+	params, err := parseQuery(t, "stock=4&sort=-stock")
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "widgets" WHERE stock = $1`)).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "widgets" WHERE stock = $1 ORDER BY stock DESC,id ASC LIMIT $2`)).
+		WithArgs(4, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock"}).
+			AddRow(1, "Bolt", 4).AddRow(2, "Nut", 4).AddRow(3, "Washer", 4))
+
+	var widgets []widget
+	result, err := params.Find(db.Model(&widget{}), &widgets)
+	require.NoError(t, err)
+	assert.Len(t, widgets, 2)
+	assert.Equal(t, int64(3), result.Total)
+	require.NotEmpty(t, result.NextCursor)
+
+	// The second page continues after that row
+	params, err = parseQuery(t, "stock=4&sort=-stock&cursor="+result.NextCursor)
+	require.NoError(t, err)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "widgets" WHERE stock = $1`)).
+		WithArgs(4).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "widgets" WHERE stock = $1 AND ((stock < $2) OR (stock = $3 AND id > $4)) ORDER BY stock DESC,id ASC LIMIT $5`)).
+		WithArgs(4, 4, 4, 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "stock"}).AddRow(3, "Washer", 4))
+
+	widgets = nil
+	result, err = params.Find(db.Model(&widget{}), &widgets)
+	require.NoError(t, err)
+	assert.Len(t, widgets, 1)
+	assert.Empty(t, result.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	assert.Equal(t, gin.H{"widgets": widgets, "limit": 2, "total": int64(3), "next_cursor": nil},
+		params.Envelope("widgets", widgets, result))
+}
+
+func TestEnvelopeWithPage(t *testing.T) {
+	params, err := parseQuery(t, "page=3")
+	require.NoError(t, err)
+
+	envelope := params.Envelope("widgets", []widget{}, Result{Total: 9, NextCursor: "next"})
+	assert.Equal(t, gin.H{"widgets": []widget{}, "limit": 2, "total": int64(9), "next_cursor": "next", "page": 3}, envelope)
+}
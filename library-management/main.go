@@ -1,24 +1,118 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"library-management/config"
+	"library-management/enrich"
+	"library-management/health"
+	"library-management/metrics"
 	"library-management/routes"
-	"log"
+	"library-management/storage"
+	"library-management/tracing"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout bounds how long in-flight requests get to finish on SIGTERM
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	if err := run(); err != nil {
+		slog.Error("server stopped with error", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	config.SetupLogger()
+
+	// Trace exporter from OTEL_TRACES_EXPORTER, spans are flushed on exit
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("tracing shutdown failed", "error", err)
+		}
+	}()
+
 	// Initialize the database and handle errors
 	db, err := config.ConnectDatabase(false)
 	if err != nil {
-		log.Fatalf("Database initialization failed: %v", err)
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	// Query timings and the pending requests gauge
+	if err := metrics.Register(db); err != nil {
+		return err
+	}
+	if err := db.Use(&tracing.GormPlugin{}); err != nil {
+		return err
+	}
+
+	// Covers and attachments go where STORAGE_BACKEND says, book metadata by
+	// ISBN comes from the dumps ENRICH_SOURCES names
+	store, err := storage.FromEnv()
+	if err != nil {
+		return err
+	}
+	source, err := enrich.SourceFromEnv(db)
+	if err != nil {
+		return err
 	}
 
 	// Set up the Gin router with the database instance
-	r := routes.SetupRouter(db)
+	router, err := routes.SetupRouter(db, store, source)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:    ":8080",
+		Handler: router,
+	}
 
-	// Start the server on port 8080
-	log.Println("Server is running on port 8080...")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Batch re-enrichment jobs run beside the server until shutdown begins
+	go enrich.Worker(ctx, db, source, enrich.WorkerIntervalFromEnv())
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("server is running", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	// Fail readiness first, then drain what is already in flight. The DB pool
+	// and tracer are closed by the deferred calls once Shutdown returns.
+	slog.Info("shutting down", "timeout", shutdownTimeout.String())
+	health.StartShutdown()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serverErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	slog.Info("server stopped")
+	return nil
 }
@@ -0,0 +1,200 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ISO 2709 structure
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+
+	leaderLength         = 24
+	directoryEntryLength = 12
+	maxRecordLength      = 99999
+	maxFieldLength       = 9999
+)
+
+// defaultLeader describes a new, Unicode, full level monograph record; the
+// writer fills in the lengths
+const defaultLeader = "00000nam a2200000 i 4500"
+
+type binaryReader struct {
+	r *bufio.Reader
+}
+
+func newBinaryReader(r io.Reader) *binaryReader {
+	return &binaryReader{r: bufio.NewReader(r)}
+}
+
+// Read splits the stream on record terminators rather than trusting the record
+// length, so one record with a wrong length does not lose the rest
+func (br *binaryReader) Read() (Record, error) {
+	raw, err := br.r.ReadBytes(recordTerminator)
+	// Some exports put line breaks between records
+	raw = bytes.TrimLeft(raw, "\r\n")
+	if errors.Is(err, io.EOF) {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			return Record{}, io.EOF
+		}
+		return Record{}, fmt.Errorf("%w: missing record terminator", ErrInvalidRecord)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	return parseBinary(raw)
+}
+
+// parseBinary decodes one record, raw ending with its record terminator
+func parseBinary(raw []byte) (Record, error) {
+	if len(raw) < leaderLength+1 {
+		return Record{}, fmt.Errorf("%w: %d bytes is shorter than a leader", ErrInvalidRecord, len(raw))
+	}
+	leader := string(raw[:leaderLength])
+	base, ok := number(raw[12:17])
+	if !ok || base <= leaderLength || base > len(raw) {
+		return Record{}, fmt.Errorf("%w: bad base address %q", ErrInvalidRecord, leader[12:17])
+	}
+	if raw[base-1] != fieldTerminator {
+		return Record{}, fmt.Errorf("%w: directory is not terminated", ErrInvalidRecord)
+	}
+	directory := raw[leaderLength : base-1]
+	if len(directory)%directoryEntryLength != 0 {
+		return Record{}, fmt.Errorf("%w: directory length %d is not a multiple of %d", ErrInvalidRecord, len(directory), directoryEntryLength)
+	}
+
+	record := Record{Leader: leader}
+	data := raw[base:]
+	for entry := 0; entry < len(directory); entry += directoryEntryLength {
+		tag := string(directory[entry : entry+3])
+		length, lengthOK := number(directory[entry+3 : entry+7])
+		start, startOK := number(directory[entry+7 : entry+12])
+		if !lengthOK || !startOK || length < 1 || start+length > len(data) {
+			return Record{}, fmt.Errorf("%w: bad directory entry for field %s", ErrInvalidRecord, tag)
+		}
+		content := data[start : start+length]
+		if content[len(content)-1] != fieldTerminator {
+			return Record{}, fmt.Errorf("%w: field %s is not terminated", ErrInvalidRecord, tag)
+		}
+		content = content[:len(content)-1]
+
+		field := Field{Tag: tag}
+		if field.IsControl() {
+			field.Value = string(content)
+			record.Fields = append(record.Fields, field)
+			continue
+		}
+		if len(content) < 2 {
+			return Record{}, fmt.Errorf("%w: field %s has no indicators", ErrInvalidRecord, tag)
+		}
+		field.Indicator1, field.Indicator2 = content[0], content[1]
+		// Anything before the first delimiter is not part of a subfield
+		for _, part := range bytes.Split(content[2:], []byte{subfieldDelimiter})[1:] {
+			if len(part) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: part[0], Value: string(part[1:])})
+		}
+		record.Fields = append(record.Fields, field)
+	}
+	return record, nil
+}
+
+// number reads a fixed-width leader or directory number, which is only ever
+// ASCII digits: strconv would also take a sign
+func number(digits []byte) (int, bool) {
+	n := 0
+	for _, digit := range digits {
+		if digit < '0' || digit > '9' {
+			return 0, false
+		}
+		n = n*10 + int(digit-'0')
+	}
+	return n, true
+}
+
+type binaryWriter struct {
+	w io.Writer
+}
+
+func newBinaryWriter(w io.Writer) *binaryWriter {
+	return &binaryWriter{w: w}
+}
+
+func (bw *binaryWriter) Write(record Record) error {
+	raw, err := encodeBinary(record)
+	if err != nil {
+		return err
+	}
+	_, err = bw.w.Write(raw)
+	return err
+}
+
+func (bw *binaryWriter) Close() error {
+	return nil
+}
+
+// encodeBinary lays out the directory and data area and fills in the leader's
+// lengths. Records are always written as UTF-8.
+func encodeBinary(record Record) ([]byte, error) {
+	var directory, data bytes.Buffer
+	for _, field := range record.Fields {
+		if len(field.Tag) != 3 {
+			return nil, fmt.Errorf("marc: tag %q is not three characters", field.Tag)
+		}
+		start := data.Len()
+		if field.IsControl() {
+			data.WriteString(field.Value)
+		} else {
+			data.WriteByte(indicator(field.Indicator1))
+			data.WriteByte(indicator(field.Indicator2))
+			for _, subfield := range field.Subfields {
+				data.WriteByte(subfieldDelimiter)
+				data.WriteByte(subfield.Code)
+				data.WriteString(subfield.Value)
+			}
+		}
+		data.WriteByte(fieldTerminator)
+		length := data.Len() - start
+		if length > maxFieldLength {
+			return nil, fmt.Errorf("marc: field %s is %d bytes, longer than %d", field.Tag, length, maxFieldLength)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", field.Tag, length, start)
+	}
+	directory.WriteByte(fieldTerminator)
+
+	base := leaderLength + directory.Len()
+	total := base + data.Len() + 1
+	if total > maxRecordLength {
+		return nil, fmt.Errorf("marc: record is %d bytes, longer than %d", total, maxRecordLength)
+	}
+
+	leader := []byte(defaultLeader)
+	if len(record.Leader) == leaderLength {
+		copy(leader, record.Leader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", total))
+	leader[9] = 'a'
+	copy(leader[10:12], "22")
+	copy(leader[12:17], fmt.Sprintf("%05d", base))
+	copy(leader[20:24], "4500")
+
+	raw := make([]byte, 0, total)
+	raw = append(raw, leader...)
+	raw = append(raw, directory.Bytes()...)
+	raw = append(raw, data.Bytes()...)
+	return append(raw, recordTerminator), nil
+}
+
+// indicator writes an unset indicator as a blank
+func indicator(value byte) byte {
+	if value == 0 {
+		return ' '
+	}
+	return value
+}
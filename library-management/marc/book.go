@@ -0,0 +1,288 @@
+package marc
+
+import (
+	"library-management/authority"
+	"library-management/models"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Unmapped is a field or subfield of a record that ToBook had no place for.
+// Code is empty when the whole field was left out.
+type Unmapped struct {
+	Tag    string `json:"tag"`
+	Code   string `json:"code,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// subjectTags are the 6XX fields read as subjects
+var subjectTags = map[string]bool{
+	"600": true, "610": true, "611": true, "630": true, "650": true, "651": true, "655": true,
+}
+
+// subdivisions are the subject subfields joined to $a with " -- "
+var subdivisions = map[byte]bool{'v': true, 'x': true, 'y': true, 'z': true}
+
+// relators maps $e relator terms and $4 relator codes to credit roles
+var relators = map[string]string{
+	"author":      authority.RoleAuthor,
+	"aut":         authority.RoleAuthor,
+	"editor":      authority.RoleEditor,
+	"ed":          authority.RoleEditor,
+	"edt":         authority.RoleEditor,
+	"translator":  authority.RoleTranslator,
+	"tr":          authority.RoleTranslator,
+	"trl":         authority.RoleTranslator,
+	"illustrator": authority.RoleIllustrator,
+	"ill":         authority.RoleIllustrator,
+}
+
+// ToBook maps a bibliographic record onto a book: 020 $a is the ISBN, 100 and
+// 700 the credits, 245 the title, 250 the edition, 260 or 264 the publishers
+// and the 6XX subject fields the subjects. The copies and library are left for
+// the caller. Everything else in the record is listed as unmapped, once per
+// field and subfield code.
+func ToBook(record Record) (models.Book, []Unmapped) {
+	var book models.Book
+	report := newReport()
+	if len(record.Leader) == leaderLength && record.Leader[9] != 'a' {
+		report.add("LDR", "", "record is MARC-8 encoded; non-ASCII characters may be wrong")
+	}
+
+	var credits, publishers, subjects []string
+	for _, field := range record.Fields {
+		switch {
+		case field.Tag == "020":
+			isbn := isbnOf(field.Subfield('a'))
+			switch {
+			case isbn == "":
+				report.add(field.Tag, "", "no ISBN in $a")
+			case book.ISBN != "":
+				report.add(field.Tag, "a", "only the first ISBN is kept")
+			default:
+				book.ISBN = isbn
+			}
+			report.others(field, "a")
+
+		case field.Tag == "100" || field.Tag == "700":
+			if credit := creditOf(field); credit != "" {
+				credits = append(credits, credit)
+			}
+			report.others(field, "a", "e", "4")
+
+		case field.Tag == "245":
+			book.Title = titleOf(field)
+			report.others(field, "a", "b", "n", "p")
+
+		case field.Tag == "250":
+			book.Version = trimPunctuation(field.Subfield('a'))
+			report.others(field, "a")
+
+		case field.Tag == "260" || (field.Tag == "264" && field.Indicator2 == '1'):
+			for _, subfield := range field.Subfields {
+				if subfield.Code == 'b' {
+					publishers = append(publishers, trimPunctuation(subfield.Value))
+				}
+			}
+			report.others(field, "b")
+
+		case subjectTags[field.Tag]:
+			if subject := subjectOf(field); subject != "" {
+				subjects = append(subjects, subject)
+			}
+			report.others(field, "a", "v", "x", "y", "z")
+
+		default:
+			report.add(field.Tag, "", "field is not represented")
+		}
+	}
+
+	book.Authors = strings.Join(credits, "; ")
+	book.Publisher = strings.Join(unique(publishers), "; ")
+	book.Subjects = strings.Join(unique(subjects), "; ")
+	return book, report.entries
+}
+
+// FromBook builds a record for a book. The first author goes in 100 and every
+// other credit in 700, names inverted as MARC expects.
+func FromBook(book models.Book) Record {
+	record := Record{Leader: defaultLeader}
+	record.Fields = append(record.Fields,
+		Field{Tag: "001", Value: book.ISBN},
+		dataField("020", ' ', ' ', Subfield{'a', book.ISBN}),
+	)
+
+	main := false
+	for _, credit := range authority.ParseCredits(book.Authors) {
+		tag := "700"
+		if !main && credit.Role == authority.RoleAuthor {
+			tag, main = "100", true
+		}
+		subfields := []Subfield{{'a', invertName(credit.Name)}}
+		if credit.Role != authority.RoleAuthor {
+			subfields[0].Value += ","
+			subfields = append(subfields, Subfield{'e', credit.Role})
+		}
+		record.Fields = append(record.Fields, dataField(tag, '1', ' ', subfields...))
+	}
+	// Keep fields in tag order, 100 ahead of 700 whatever the credit order
+	sort.SliceStable(record.Fields, func(i, j int) bool { return record.Fields[i].Tag < record.Fields[j].Tag })
+
+	// Indicator 1 says whether a 1XX main entry exists
+	added := byte('0')
+	if main {
+		added = '1'
+	}
+	record.Fields = append(record.Fields, dataField("245", added, '0', Subfield{'a', book.Title}))
+	if book.Version != "" {
+		record.Fields = append(record.Fields, dataField("250", ' ', ' ', Subfield{'a', book.Version}))
+	}
+	if publishers := authority.SplitList(book.Publisher); len(publishers) > 0 {
+		var subfields []Subfield
+		for _, publisher := range publishers {
+			subfields = append(subfields, Subfield{'b', publisher})
+		}
+		record.Fields = append(record.Fields, dataField("264", ' ', '1', subfields...))
+	}
+	for _, subject := range authority.SplitList(book.Subjects) {
+		parts := strings.Split(subject, " -- ")
+		subfields := []Subfield{{'a', strings.TrimSpace(parts[0])}}
+		for _, part := range parts[1:] {
+			subfields = append(subfields, Subfield{'x', strings.TrimSpace(part)})
+		}
+		// Indicator 2 "4": source of the heading not specified
+		record.Fields = append(record.Fields, dataField("650", ' ', '4', subfields...))
+	}
+	return record
+}
+
+func dataField(tag string, ind1, ind2 byte, subfields ...Subfield) Field {
+	return Field{Tag: tag, Indicator1: ind1, Indicator2: ind2, Subfields: subfields}
+}
+
+// isbnOf takes the ISBN from a 020 $a such as "978-0-441-17271-9 (pbk.)"
+func isbnOf(value string) string {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ReplaceAll(fields[0], "-", "")
+}
+
+// creditOf renders a 100 or 700 as an Authors entry, "Frank Herbert" or
+// "Richard Pevear (translator)"
+func creditOf(field Field) string {
+	name := authority.DisplayName(trimPunctuation(field.Subfield('a')))
+	if name == "" {
+		return ""
+	}
+	for _, subfield := range field.Subfields {
+		if subfield.Code != 'e' && subfield.Code != '4' {
+			continue
+		}
+		role, ok := relators[strings.Trim(strings.ToLower(subfield.Value), " .,")]
+		if ok && role != authority.RoleAuthor {
+			return name + " (" + role + ")"
+		}
+	}
+	return name
+}
+
+// titleOf joins the title proper, remainder and part as "Dune: Book one"
+func titleOf(field Field) string {
+	var title string
+	for _, subfield := range field.Subfields {
+		value := trimPunctuation(subfield.Value)
+		if value == "" {
+			continue
+		}
+		switch {
+		case subfield.Code == 'a':
+			title = value
+		case subfield.Code == 'b' && title != "":
+			title += ": " + value
+		case subfield.Code == 'n' || subfield.Code == 'p':
+			if title != "" {
+				title += ". "
+			}
+			title += value
+		}
+	}
+	return title
+}
+
+// subjectOf renders a subject heading as "Science fiction -- History"
+func subjectOf(field Field) string {
+	var parts []string
+	for _, subfield := range field.Subfields {
+		if subfield.Code == 'a' || subdivisions[subfield.Code] {
+			if value := trimPunctuation(subfield.Value); value != "" {
+				parts = append(parts, value)
+			}
+		}
+	}
+	return strings.Join(parts, " -- ")
+}
+
+// trimPunctuation drops the ISBD punctuation cataloguers end subfields with. A
+// final full stop is kept after an initial, as in "Rowling, J. K."
+func trimPunctuation(value string) string {
+	value = strings.TrimRight(strings.TrimSpace(value), " ,;:/=")
+	if strings.HasSuffix(value, ".") {
+		words := strings.Fields(value)
+		if last := strings.TrimSuffix(words[len(words)-1], "."); len([]rune(last)) > 1 {
+			value = strings.TrimSuffix(value, ".")
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// invertName turns "Frank Herbert" into "Herbert, Frank"; single names are kept
+func invertName(name string) string {
+	words := strings.Fields(name)
+	if len(words) < 2 {
+		return name
+	}
+	return words[len(words)-1] + ", " + strings.Join(words[:len(words)-1], " ")
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	var kept []string
+	for _, value := range values {
+		if value != "" && !seen[value] {
+			seen[value] = true
+			kept = append(kept, value)
+		}
+	}
+	return kept
+}
+
+// report collects unmapped entries without repeating one
+type report struct {
+	entries []Unmapped
+	seen    map[Unmapped]bool
+}
+
+func newReport() *report {
+	return &report{entries: []Unmapped{}, seen: map[Unmapped]bool{}}
+}
+
+func (r *report) add(tag, code, reason string) {
+	entry := Unmapped{Tag: tag, Code: code, Reason: reason}
+	if !r.seen[entry] {
+		r.seen[entry] = true
+		r.entries = append(r.entries, entry)
+	}
+}
+
+// others reports the subfields of field whose codes are not in mapped
+func (r *report) others(field Field, mapped ...string) {
+	for _, subfield := range field.Subfields {
+		code := string(subfield.Code)
+		if !slices.Contains(mapped, code) {
+			r.add(field.Tag, code, "subfield is not represented")
+		}
+	}
+}
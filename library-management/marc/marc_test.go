@@ -0,0 +1,171 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"library-management/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dune is a record as a union catalog delivers it, ISBD punctuation included
+var dune = Record{
+	Leader: "00000cam a2200000 i 4500",
+	Fields: []Field{
+		{Tag: "001", Value: "ocm00123456"},
+		{Tag: "008", Value: "650101s1965    nyu           000 1 eng d"},
+		dataField("020", ' ', ' ', Subfield{'a', "978-0-441-17271-9 (pbk.)"}, Subfield{'c', "$9.99"}),
+		dataField("020", ' ', ' ', Subfield{'a', "0441172717"}),
+		dataField("100", '1', ' ', Subfield{'a', "Herbert, Frank."}, Subfield{'d', "1920-1986"}),
+		dataField("245", '1', '0', Subfield{'a', "Dune :"}, Subfield{'b', "a novel /"}, Subfield{'c', "Frank Herbert."}),
+		dataField("250", ' ', ' ', Subfield{'a', "40th anniversary edition."}),
+		dataField("264", ' ', '1', Subfield{'a', "New York :"}, Subfield{'b', "Ace Books,"}, Subfield{'c', "2005."}),
+		dataField("264", ' ', '4', Subfield{'c', "©1965"}),
+		dataField("650", ' ', '0', Subfield{'a', "Science fiction"}, Subfield{'x', "History."}),
+		dataField("700", '1', ' ', Subfield{'a', "Rowling, J. K.,"}, Subfield{'e', "editor."}),
+	},
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(Binary, &buf)
+	require.NoError(t, writer.Write(dune))
+	require.NoError(t, writer.Write(FromBook(models.Book{ISBN: "9780140449136", Title: "Crime and Punishment"})))
+	require.NoError(t, writer.Close())
+
+	raw := buf.Bytes()
+	assert.Equal(t, "a", string(raw[9]), "written as UTF-8")
+
+	reader := NewReader(Binary, &buf)
+	first, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, dune.Fields, first.Fields)
+	assert.Equal(t, "cam", first.Leader[5:8])
+
+	second, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, "Crime and Punishment", second.FieldsWithTag("245")[0].Subfield('a'))
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestBinaryInvalidRecordIsSkipped(t *testing.T) {
+	var good bytes.Buffer
+	require.NoError(t, NewWriter(Binary, &good).Write(dune))
+
+	stream := append([]byte("00042nam  2200099   4500garbage\x1d"), good.Bytes()...)
+	reader := NewReader(Binary, bytes.NewReader(stream))
+
+	_, err := reader.Read()
+	assert.True(t, errors.Is(err, ErrInvalidRecord), "got %v", err)
+
+	record, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, dune.Fields, record.Fields)
+
+	_, err = NewReader(Binary, strings.NewReader("00000nam")).Read()
+	assert.True(t, errors.Is(err, ErrInvalidRecord), "unterminated record, got %v", err)
+
+	// Directory numbers are plain digits, a sign would slice outside the data
+	_, err = NewReader(Binary, strings.NewReader("00000nam  2200037   45002450002-0001\x1ea\x1e\x1d")).Read()
+	assert.True(t, errors.Is(err, ErrInvalidRecord), "negative start, got %v", err)
+
+	_, err = NewReader(Binary, strings.NewReader("00000nam  2200037   4500245+00200000\x1ea\x1e\x1d")).Read()
+	assert.True(t, errors.Is(err, ErrInvalidRecord), "signed length, got %v", err)
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	writer := NewWriter(XML, &buf)
+	require.NoError(t, writer.Write(dune))
+	require.NoError(t, writer.Close())
+	assert.Contains(t, buf.String(), `<collection xmlns="`+Namespace+`">`)
+
+	reader := NewReader(XML, &buf)
+	record, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, dune, record)
+
+	_, err = reader.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestXMLPrefixedRecord(t *testing.T) {
+	doc := `<?xml version="1.0"?>
+<marc:record xmlns:marc="http://www.loc.gov/MARC21/slim">
+  <marc:leader>00000nam a2200000 i 4500</marc:leader>
+  <marc:controlfield tag="001">42</marc:controlfield>
+  <marc:datafield tag="245" ind1="0" ind2="0"><marc:subfield code="a">Beowulf.</marc:subfield></marc:datafield>
+</marc:record>`
+	record, err := NewReader(XML, strings.NewReader(doc)).Read()
+	require.NoError(t, err)
+	assert.Equal(t, []Field{
+		{Tag: "001", Value: "42"},
+		dataField("245", '0', '0', Subfield{'a', "Beowulf."}),
+	}, record.Fields)
+
+	_, err = NewReader(XML, strings.NewReader(`<record><datafield tag="24"/></record>`)).Read()
+	assert.True(t, errors.Is(err, ErrInvalidRecord), "got %v", err)
+
+	_, err = NewReader(XML, strings.NewReader(`<record><leader>`)).Read()
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrInvalidRecord), "malformed XML is not a per-record error")
+}
+
+func TestToBook(t *testing.T) {
+	book, unmapped := ToBook(dune)
+
+	assert.Equal(t, models.Book{
+		ISBN:      "9780441172719",
+		Title:     "Dune: a novel",
+		Authors:   "Frank Herbert; J. K. Rowling (editor)",
+		Publisher: "Ace Books",
+		Version:   "40th anniversary edition",
+		Subjects:  "Science fiction -- History",
+	}, book)
+	assert.Equal(t, []Unmapped{
+		{Tag: "001", Reason: "field is not represented"},
+		{Tag: "008", Reason: "field is not represented"},
+		{Tag: "020", Code: "c", Reason: "subfield is not represented"},
+		{Tag: "020", Code: "a", Reason: "only the first ISBN is kept"},
+		{Tag: "100", Code: "d", Reason: "subfield is not represented"},
+		{Tag: "245", Code: "c", Reason: "subfield is not represented"},
+		{Tag: "264", Code: "a", Reason: "subfield is not represented"},
+		{Tag: "264", Code: "c", Reason: "subfield is not represented"},
+		{Tag: "264", Reason: "field is not represented"},
+	}, unmapped)
+}
+
+func TestFromBook(t *testing.T) {
+	book := models.Book{
+		ISBN:      "9780140449136",
+		Title:     "Crime and Punishment",
+		Authors:   "Pevear, Richard (trans.); Fyodor Dostoevsky",
+		Publisher: "Penguin; Vintage Classics",
+		Version:   "2nd",
+		Subjects:  "Murder -- Fiction; Russia",
+	}
+	record := FromBook(book)
+
+	tags := make([]string, len(record.Fields))
+	for i, field := range record.Fields {
+		tags[i] = field.Tag
+	}
+	assert.Equal(t, []string{"001", "020", "100", "700", "245", "250", "264", "650", "650"}, tags)
+	assert.Equal(t, "Dostoevsky, Fyodor", record.FieldsWithTag("100")[0].Subfield('a'))
+	assert.Equal(t, "translator", record.FieldsWithTag("700")[0].Subfield('e'))
+	assert.Equal(t, byte('1'), record.FieldsWithTag("245")[0].Indicator1)
+
+	// Reading it back gives the same book, credits in MARC order
+	mapped, unmapped := ToBook(record)
+	assert.Equal(t, "Fyodor Dostoevsky; Richard Pevear (translator)", mapped.Authors)
+	mapped.Authors = book.Authors
+	assert.Equal(t, book, mapped)
+	assert.Equal(t, []Unmapped{{Tag: "001", Reason: "field is not represented"}}, unmapped)
+}
@@ -0,0 +1,130 @@
+// Package marc reads and writes MARC 21 bibliographic records, both ISO 2709
+// binary ("application/marc") and MARCXML, and maps them to and from books.
+package marc
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strings"
+)
+
+// ErrInvalidRecord is wrapped by read errors that only affect one record; the
+// reader can carry on with the next
+var ErrInvalidRecord = errors.New("marc: invalid record")
+
+// Format is a serialisation of MARC records
+type Format string
+
+const (
+	Binary Format = "marc"
+	XML    Format = "marcxml"
+)
+
+// ContentType is the media type records in f are sent as
+func (f Format) ContentType() string {
+	if f == XML {
+		return "application/marcxml+xml"
+	}
+	return "application/marc"
+}
+
+// Extension is the usual file extension for f, including the dot
+func (f Format) Extension() string {
+	if f == XML {
+		return ".xml"
+	}
+	return ".mrc"
+}
+
+// FormatOf picks the format a Content-Type header names. Plain XML media types
+// are read as MARCXML.
+func FormatOf(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case "application/marc":
+		return Binary, true
+	case "application/marcxml+xml", "application/xml":
+		return XML, true
+	}
+	return "", false
+}
+
+// Record is one bibliographic record
+type Record struct {
+	Leader string
+	Fields []Field
+}
+
+// Field is a control field (tags 001-009), which only has a Value, or a data
+// field with two indicators and subfields. Blank indicators are spaces.
+type Field struct {
+	Tag        string
+	Value      string
+	Indicator1 byte
+	Indicator2 byte
+	Subfields  []Subfield
+}
+
+// Subfield is one coded value of a data field
+type Subfield struct {
+	Code  byte
+	Value string
+}
+
+// IsControl reports whether the field is a control field
+func (f Field) IsControl() bool {
+	return strings.HasPrefix(f.Tag, "00")
+}
+
+// Subfield returns the first value of the subfield with code, "" if none
+func (f Field) Subfield(code byte) string {
+	for _, subfield := range f.Subfields {
+		if subfield.Code == code {
+			return subfield.Value
+		}
+	}
+	return ""
+}
+
+// FieldsWithTag returns the record's fields with tag, in order
+func (r Record) FieldsWithTag(tag string) []Field {
+	var fields []Field
+	for _, field := range r.Fields {
+		if field.Tag == tag {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Reader reads records one at a time. Read returns io.EOF after the last one.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// Writer writes records one at a time. Close finishes the output without
+// closing the underlying writer.
+type Writer interface {
+	Write(Record) error
+	Close() error
+}
+
+// NewReader returns a reader for records in format f
+func NewReader(f Format, r io.Reader) Reader {
+	if f == XML {
+		return newXMLReader(r)
+	}
+	return newBinaryReader(r)
+}
+
+// NewWriter returns a writer for records in format f
+func NewWriter(f Format, w io.Writer) Writer {
+	if f == XML {
+		return newXMLWriter(w)
+	}
+	return newBinaryWriter(w)
+}
@@ -0,0 +1,167 @@
+package marc
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Namespace is the MARCXML namespace
+const Namespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlReader finds record elements wherever they are, so a bare record, a
+// collection and either with a namespace prefix all read the same
+type xmlReader struct {
+	decoder *xml.Decoder
+}
+
+func newXMLReader(r io.Reader) *xmlReader {
+	return &xmlReader{decoder: xml.NewDecoder(r)}
+}
+
+// Read fails for good on malformed XML; a well-formed record element that is
+// not a valid record returns ErrInvalidRecord
+func (xr *xmlReader) Read() (Record, error) {
+	for {
+		token, err := xr.decoder.Token()
+		if err != nil {
+			return Record{}, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+		var element xmlRecord
+		if err := xr.decoder.DecodeElement(&element, &start); err != nil {
+			return Record{}, err
+		}
+		return element.record()
+	}
+}
+
+// record converts the element, keeping control fields ahead of data fields as
+// ISO 2709 would
+func (element xmlRecord) record() (Record, error) {
+	record := Record{Leader: element.Leader}
+	for _, control := range element.ControlFields {
+		if len(control.Tag) != 3 {
+			return Record{}, fmt.Errorf("%w: control field tag %q", ErrInvalidRecord, control.Tag)
+		}
+		record.Fields = append(record.Fields, Field{Tag: control.Tag, Value: control.Value})
+	}
+	for _, data := range element.DataFields {
+		if len(data.Tag) != 3 {
+			return Record{}, fmt.Errorf("%w: data field tag %q", ErrInvalidRecord, data.Tag)
+		}
+		field := Field{Tag: data.Tag, Indicator1: xmlIndicator(data.Ind1), Indicator2: xmlIndicator(data.Ind2)}
+		for _, subfield := range data.Subfields {
+			if len(subfield.Code) != 1 {
+				return Record{}, fmt.Errorf("%w: subfield code %q in field %s", ErrInvalidRecord, subfield.Code, data.Tag)
+			}
+			field.Subfields = append(field.Subfields, Subfield{Code: subfield.Code[0], Value: subfield.Value})
+		}
+		record.Fields = append(record.Fields, field)
+	}
+	return record, nil
+}
+
+func xmlIndicator(value string) byte {
+	if value == "" {
+		return ' '
+	}
+	return value[0]
+}
+
+// xmlWriter wraps the records in a collection element, opened by the first
+// Write or by Close when there are none
+type xmlWriter struct {
+	w       io.Writer
+	encoder *xml.Encoder
+	started bool
+}
+
+func newXMLWriter(w io.Writer) *xmlWriter {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &xmlWriter{w: w, encoder: encoder}
+}
+
+var collection = xml.StartElement{
+	Name: xml.Name{Local: "collection"},
+	Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: Namespace}},
+}
+
+func (xw *xmlWriter) start() error {
+	if xw.started {
+		return nil
+	}
+	xw.started = true
+	if _, err := io.WriteString(xw.w, xml.Header); err != nil {
+		return err
+	}
+	return xw.encoder.EncodeToken(collection)
+}
+
+func (xw *xmlWriter) Write(record Record) error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	element := xmlRecord{Leader: record.Leader}
+	if len(element.Leader) != leaderLength {
+		element.Leader = defaultLeader
+	}
+	for _, field := range record.Fields {
+		if field.IsControl() {
+			element.ControlFields = append(element.ControlFields, xmlControlField{Tag: field.Tag, Value: field.Value})
+			continue
+		}
+		data := xmlDataField{
+			Tag:  field.Tag,
+			Ind1: string(indicator(field.Indicator1)),
+			Ind2: string(indicator(field.Indicator2)),
+		}
+		for _, subfield := range field.Subfields {
+			data.Subfields = append(data.Subfields, xmlSubfield{Code: string(subfield.Code), Value: subfield.Value})
+		}
+		element.DataFields = append(element.DataFields, data)
+	}
+	return xw.encoder.Encode(element)
+}
+
+func (xw *xmlWriter) Close() error {
+	if err := xw.start(); err != nil {
+		return err
+	}
+	if err := xw.encoder.EncodeToken(collection.End()); err != nil {
+		return err
+	}
+	if err := xw.encoder.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(xw.w, "\n")
+	return err
+}
@@ -0,0 +1,117 @@
+// Package media checks uploaded files and makes thumbnails of images. File types
+// are sniffed from the content, never taken from the client's Content-Type or
+// file name.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // Decoders for the image Types
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+)
+
+// MaxPixels bounds the images Thumbnail decodes, so a small file claiming huge
+// dimensions cannot exhaust memory
+const MaxPixels = 25_000_000
+
+// ErrTooManyPixels is returned for images larger than MaxPixels
+var ErrTooManyPixels = errors.New("media: image has too many pixels")
+
+// Type is a kind of file uploads may hold
+type Type struct {
+	ContentType string
+	Extension   string
+	Image       bool // Thumbnail can decode it
+}
+
+// types are the accepted uploads by sniffed content type
+var types = map[string]Type{
+	"image/jpeg":                {"image/jpeg", ".jpg", true},
+	"image/png":                 {"image/png", ".png", true},
+	"image/gif":                 {"image/gif", ".gif", true},
+	"application/pdf":           {"application/pdf", ".pdf", false},
+	"application/zip":           {"application/zip", ".zip", false}, // Also EPUB
+	"text/plain; charset=utf-8": {"text/plain; charset=utf-8", ".txt", false},
+}
+
+// Sniff identifies a file from its first 512 bytes. It returns false for types
+// uploads may not hold, such as HTML that a browser would run.
+func Sniff(head []byte) (Type, bool) {
+	t, ok := types[http.DetectContentType(head)]
+	return t, ok
+}
+
+// Thumbnail scales an image down to fit in size x size pixels, keeping its
+// aspect ratio, and encodes it as JPEG. Smaller images are not enlarged.
+// Transparent areas are flattened onto white. It also returns the dimensions of
+// the original image.
+func Thumbnail(r io.Reader, size int) (thumbnail []byte, width, height int, err error) {
+	var buf bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &buf))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, 0, 0, ErrTooManyPixels
+	}
+	src, _, err := image.Decode(io.MultiReader(&buf, r))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	bounds := src.Bounds()
+	width, height = bounds.Dx(), bounds.Dy()
+	flat := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, bounds.Min, draw.Over)
+
+	dstWidth, dstHeight := width, height
+	if width > size || height > size {
+		if width >= height {
+			dstWidth, dstHeight = size, max(1, height*size/width)
+		} else {
+			dstWidth, dstHeight = max(1, width*size/height), size
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, shrink(flat, dstWidth, dstHeight), &jpeg.Options{Quality: 85}); err != nil {
+		return nil, 0, 0, err
+	}
+	return out.Bytes(), width, height, nil
+}
+
+// shrink scales src down by averaging the source pixels each destination pixel
+// covers, which avoids the aliasing of nearest-neighbour sampling
+func shrink(src *image.RGBA, width, height int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	if width == srcWidth && height == srcHeight {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*srcHeight/height, max((y+1)*srcHeight/height, y*srcHeight/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*srcWidth/width, max((x+1)*srcWidth/width, x*srcWidth/width+1)
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					r += int(row[sx*4])
+					g += int(row[sx*4+1])
+					b += int(row[sx*4+2])
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i], dst.Pix[i+1], dst.Pix[i+2], dst.Pix[i+3] = uint8(r/n), uint8(g/n), uint8(b/n), 0xff
+		}
+	}
+	return dst
+}
@@ -0,0 +1,86 @@
+package media
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name      string
+		head      []byte
+		extension string
+		ok        bool
+	}{
+		{"PNG", encodePNG(t, image.NewRGBA(image.Rect(0, 0, 1, 1))), ".png", true},
+		{"PDF", []byte("%PDF-1.7\n"), ".pdf", true},
+		{"Plain Text", []byte("Chapter one\n"), ".txt", true},
+		{"HTML Is Refused", []byte("<html><script>alert(1)</script>"), "", false},
+		{"Unknown Binary", []byte{0x00, 0x01, 0x02, 0xfe}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typ, ok := Sniff(tt.head)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.extension, typ.Extension)
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	// A wide image, left half red and right half transparent
+	src := image.NewNRGBA(image.Rect(0, 0, 800, 400))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+
+	thumbnail, width, height, err := Thumbnail(bytes.NewReader(encodePNG(t, src)), 200)
+	require.NoError(t, err)
+	assert.Equal(t, 800, width)
+	assert.Equal(t, 400, height)
+
+	img, err := jpeg.Decode(bytes.NewReader(thumbnail))
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 200, 100), img.Bounds())
+	r, g, b, _ := img.At(50, 50).RGBA()
+	assert.Greater(t, r>>8, uint32(200), "red half stays red")
+	assert.Less(t, g>>8, uint32(60))
+	r, g, b, _ = img.At(150, 50).RGBA()
+	assert.Greater(t, r>>8+g>>8+b>>8, uint32(700), "transparent half turns white")
+
+	t.Run("Small Images Are Not Enlarged", func(t *testing.T) {
+		thumbnail, _, _, err := Thumbnail(bytes.NewReader(encodePNG(t, image.NewRGBA(image.Rect(0, 0, 40, 60)))), 200)
+		require.NoError(t, err)
+		config, err := jpeg.DecodeConfig(bytes.NewReader(thumbnail))
+		require.NoError(t, err)
+		assert.Equal(t, 40, config.Width)
+		assert.Equal(t, 60, config.Height)
+	})
+
+	t.Run("Too Many Pixels", func(t *testing.T) {
+		// Only the header is read, the pixel data is never decoded
+		huge := encodePNG(t, image.NewGray(image.Rect(0, 0, 6000, 6000)))
+		_, _, _, err := Thumbnail(bytes.NewReader(huge), 200)
+		assert.ErrorIs(t, err, ErrTooManyPixels)
+	})
+
+	t.Run("Not An Image", func(t *testing.T) {
+		_, _, _, err := Thumbnail(bytes.NewReader([]byte("%PDF-1.7")), 200)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// HTTP and database timings
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database statement latency by operation and table.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"operation", "table"})
+
+	DBQueryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_query_errors_total",
+		Help: "Database statements that returned an error, record-not-found excluded.",
+	}, []string{"operation", "table"})
+)
+
+// Domain counters
+var (
+	IssueRequestsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "library_issue_requests_created_total",
+		Help: "Issue requests submitted by readers.",
+	})
+
+	IssueRequestsReviewed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "library_issue_requests_reviewed_total",
+		Help: "Issue requests approved or disapproved by admins.",
+	}, []string{"decision"})
+
+	LoansIssued = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "library_loans_issued_total",
+		Help: "Books handed out to readers.",
+	})
+
+	ReturnsProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "library_returns_processed_total",
+		Help: "Return requests approved by admins.",
+	})
+
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_login_failures_total",
+		Help: "Rejected login attempts by reason.",
+	}, []string{"reason"})
+
+	RateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "http_rate_limited_total",
+		Help: "Requests rejected with 429 by the per-client rate limit.",
+	})
+)
+
+var registerOnce sync.Once
+
+// Register wires the metrics that need the database: query timings through the
+// GORM plugin and the pending issue request gauge, read on every scrape
+func Register(db *gorm.DB) error {
+	var err error
+	registerOnce.Do(func() {
+		if err = db.Use(&GormPlugin{}); err != nil {
+			return
+		}
+		err = prometheus.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "library_issue_requests_pending",
+			Help: "Issue requests waiting for an admin decision.",
+		}, func() float64 {
+			var pending int64
+			if err := db.Table("request_events").Where("status = ? AND deleted_at IS NULL", "Pending").Count(&pending).Error; err != nil {
+				return -1
+			}
+			return float64(pending)
+		}))
+	})
+	return err
+}
+
+// Handler serves the Prometheus exposition format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
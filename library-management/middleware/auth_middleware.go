@@ -1,30 +1,33 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
+	"library-management/apierror"
+	"library-management/models"
 	"library-management/utils"
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-// AuthMiddleware verifies JWT and checks user role
-func AuthMiddleware(requiredRole string) gin.HandlerFunc {
+// AuthMiddleware verifies JWT and checks user role. The user is read on every
+// request, so deactivating or deleting an account, or changing its role, takes
+// effect at once rather than when the token expires.
+func AuthMiddleware(db *gorm.DB, requiredRole string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 
 		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing token"})
-			c.Abort()
+			apierror.Respond(c, apierror.CodeMissingToken, "Missing token")
 			return
 		}
 
 		// Ensure "Bearer " prefix is present
 		tokenParts := strings.Split(tokenString, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token format"})
-			c.Abort()
+			apierror.Respond(c, apierror.CodeInvalidToken, "Invalid token format")
 			return
 		}
 
@@ -33,12 +36,28 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 		// Validate JWT using utils.ValidateJWT
 		userID, userRole, err := utils.ValidateJWT(tokenString)
 		if err != nil {
-			fmt.Println("JWT Validation Error:", err) 
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+			Logger(c).Warn("jwt validation failed", "error", err)
+			apierror.Respond(c, apierror.CodeInvalidToken, "Invalid or expired token")
 			return
 		}
 
+		// Tokens outlive deactivation, deletion and role changes, the account does not
+		var user models.User
+		err = db.WithContext(c.Request.Context()).Select("id", "role", "status").First(&user, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			apierror.Respond(c, apierror.CodeInvalidToken, "The account of this token no longer exists")
+			return
+		}
+		if err != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not check the account")
+			return
+		}
+		if user.Status != "active" {
+			apierror.Respond(c, apierror.CodeAccountDeactivated, "Account is deactivated")
+			return
+		}
+		userRole = user.Role
+
 		// If a role is required, check access
 		if requiredRole != "" {
 			allowedRoles := strings.Split(requiredRole, "|")
@@ -51,12 +70,7 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 			}
 
 			if !roleAllowed {
-				c.JSON(http.StatusForbidden, gin.H{
-					"error":        "Access denied",
-					"requiredRole": requiredRole,
-					"yourRole":     userRole,
-				})
-				c.Abort()
+				apierror.Respond(c, apierror.CodeRoleNotAllowed, fmt.Sprintf("This endpoint requires role %s, you are %s", requiredRole, userRole))
 				return
 			}
 		}
@@ -66,4 +80,4 @@ func AuthMiddleware(requiredRole string) gin.HandlerFunc {
 		c.Set("userRole", userRole)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"library-management/utils"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestAuthMiddleware(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/admin", AuthMiddleware(db, "admin"), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("userID")})
+	})
+
+	token, err := utils.GenerateJWT(7, "admin")
+	require.NoError(t, err)
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	expectUser := func(rows *sqlmock.Rows) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "id","role","status" FROM "users" WHERE "users"."id" = $1 AND "users"."deleted_at" IS NULL ORDER BY "users"."id" LIMIT $2`)).
+			WithArgs(7, 1).
+			WillReturnRows(rows)
+	}
+
+	t.Run("Active User", func(t *testing.T) {
+		expectUser(sqlmock.NewRows([]string{"id", "role", "status"}).AddRow(7, "admin", "active"))
+
+		w := send()
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deactivated User", func(t *testing.T) {
+		expectUser(sqlmock.NewRows([]string{"id", "role", "status"}).AddRow(7, "admin", "deactivated"))
+
+		w := send()
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"account_deactivated"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Deleted User", func(t *testing.T) {
+		expectUser(sqlmock.NewRows([]string{"id", "role", "status"}))
+
+		w := send()
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_token"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Demoted User", func(t *testing.T) {
+		expectUser(sqlmock.NewRows([]string{"id", "role", "status"}).AddRow(7, "user", "active"))
+
+		w := send()
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"role_not_allowed"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,194 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"library-management/apierror"
+	"library-management/models"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// IdempotencyKeyHeader lets a client retry a POST or PUT without repeating its effect
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed from a stored key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is how long responses are kept without IDEMPOTENCY_TTL
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyMaxBody is the largest body read for hashing without
+	// IDEMPOTENCY_MAX_BODY, enough for the biggest attachment upload
+	DefaultIdempotencyMaxBody = 21 << 20
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyTTLFromEnv reads IDEMPOTENCY_TTL as a Go duration such as "12h",
+// falling back to DefaultIdempotencyTTL when unset or invalid
+func IdempotencyTTLFromEnv() time.Duration {
+	raw := os.Getenv("IDEMPOTENCY_TTL")
+	if raw == "" {
+		return DefaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		return DefaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// IdempotencyMaxBodyFromEnv reads IDEMPOTENCY_MAX_BODY in bytes, falling back
+// to DefaultIdempotencyMaxBody when unset or invalid
+func IdempotencyMaxBodyFromEnv() int64 {
+	limit, err := strconv.ParseInt(os.Getenv("IDEMPOTENCY_MAX_BODY"), 10, 64)
+	if err != nil || limit <= 0 {
+		return DefaultIdempotencyMaxBody
+	}
+	return limit
+}
+
+// Idempotency stores the first response to a POST or PUT carrying an
+// Idempotency-Key and replays it for retries with the same key, per user, for
+// ttl. Reusing a key for a different request is rejected with 422 and bodies
+// over maxBody bytes with 413, as the body is buffered to hash it. It must run
+// after AuthMiddleware; requests without a user or key pass straight through.
+// Server errors are not stored, so the client may retry them with the same key.
+func Idempotency(db *gorm.DB, ttl time.Duration, maxBody int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPut) {
+			c.Next()
+			return
+		}
+		userID, ok := c.Get("userID")
+		id, isUint := userID.(uint)
+		if !ok || !isUint {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			apierror.Respond(c, apierror.CodeInvalidParameter, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		db := db.WithContext(c.Request.Context())
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Respond(c, apierror.CodeRequestTooLarge, fmt.Sprintf("Requests with an Idempotency-Key may be at most %d bytes", maxBody))
+			} else {
+				apierror.Respond(c, apierror.CodeMalformedRequest, "Could not read request body")
+			}
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := requestHash(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		// Every expired key is cleared first, so the table stays bounded by the
+		// traffic within ttl and this user's key can be claimed again
+		now := time.Now()
+		if err := db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			Logger(c).Error("idempotency cleanup failed", "error", err)
+		}
+
+		record := models.IdempotencyKey{UserID: id, Key: key, RequestHash: hash, ExpiresAt: now.Add(ttl)}
+		claim := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if claim.Error != nil {
+			apierror.Respond(c, apierror.CodeInternal, "Could not record idempotency key")
+			return
+		}
+		if claim.RowsAffected == 0 {
+			replay(c, db, record)
+			return
+		}
+
+		stored := db.Model(&models.IdempotencyKey{}).Where("user_id = ? AND key = ?", record.UserID, key)
+
+		// A panicking handler must not leave the key stuck in progress
+		defer func() {
+			if r := recover(); r != nil {
+				stored.Delete(&models.IdempotencyKey{})
+				panic(r)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+			err = stored.Delete(&models.IdempotencyKey{}).Error
+		} else {
+			err = stored.Updates(map[string]interface{}{
+				"status":       status,
+				"content_type": c.Writer.Header().Get("Content-Type"),
+				"etag":         c.Writer.Header().Get("ETag"),
+				"body":         recorder.body.Bytes(),
+			}).Error
+		}
+		if err != nil {
+			Logger(c).Error("storing idempotent response failed", "error", err)
+		}
+	}
+}
+
+// replay answers a retry from the stored response of the first request
+func replay(c *gin.Context, db *gorm.DB, claim models.IdempotencyKey) {
+	var existing models.IdempotencyKey
+	if err := db.Where("user_id = ? AND key = ?", claim.UserID, claim.Key).First(&existing).Error; err != nil {
+		apierror.Respond(c, apierror.CodeInternal, "Could not load idempotency key")
+		return
+	}
+	if existing.RequestHash != claim.RequestHash {
+		apierror.Respond(c, apierror.CodeIdempotencyKeyReused, "This Idempotency-Key was already used for a different request")
+		return
+	}
+	if existing.Status == 0 {
+		apierror.Respond(c, apierror.CodeIdempotencyInProgress, "The first request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	if existing.ETag != "" {
+		c.Header("ETag", existing.ETag)
+	}
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(existing.Status, existing.ContentType, existing.Body)
+	c.Abort()
+}
+
+// requestHash identifies a request by method, path with query string and body
+func requestHash(method, target string, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(method + " " + target + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// bodyRecorder copies the response body while still sending it to the client
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
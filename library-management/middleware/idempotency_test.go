@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestIdempotency(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+
+	calls := 0
+	status := http.StatusCreated
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/book", func(c *gin.Context) {
+		c.Set("userID", uint(7))
+	}, Idempotency(db, time.Hour, 64), func(c *gin.Context) {
+		calls++
+		c.JSON(status, gin.H{"message": "Book added successfully"})
+	})
+
+	send := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/book", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	expectClaim := func(inserted int64) {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_keys" WHERE expires_at < $1`)).
+			WithArgs(sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectCommit()
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "idempotency_keys"`) + `.*ON CONFLICT DO NOTHING`).
+			WillReturnResult(sqlmock.NewResult(0, inserted))
+		mock.ExpectCommit()
+	}
+	expectStored := func(hash string, status int) {
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "idempotency_keys" WHERE user_id = $1 AND key = $2`)).
+			WithArgs(7, "key-1", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "key", "request_hash", "status", "content_type", "body"}).
+				AddRow(7, "key-1", hash, status, "application/json; charset=utf-8", []byte(`{"message":"Book added successfully"}`)))
+	}
+	body := `{"isbn":"123","libraryid":1}`
+	hash := requestHash(http.MethodPost, "/api/book", []byte(body))
+
+	t.Run("Without A Key", func(t *testing.T) {
+		calls = 0
+		w := send("", body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 1, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("First Request Is Stored", func(t *testing.T) {
+		calls = 0
+		expectClaim(1)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "idempotency_keys" SET "body"=$1,"content_type"=$2,"etag"=$3,"status"=$4 WHERE user_id = $5 AND key = $6`)).
+			WithArgs([]byte(`{"message":"Book added successfully"}`), "application/json; charset=utf-8", "", http.StatusCreated, 7, "key-1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := send("key-1", body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 1, calls)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Retry Is Replayed", func(t *testing.T) {
+		calls = 0
+		expectClaim(0)
+		expectStored(hash, http.StatusCreated)
+
+		w := send("key-1", body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, 0, calls)
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.JSONEq(t, `{"message":"Book added successfully"}`, w.Body.String())
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Different Payload Is Rejected", func(t *testing.T) {
+		calls = 0
+		expectClaim(0)
+		expectStored(hash, http.StatusCreated)
+
+		w := send("key-1", `{"isbn":"456","libraryid":1}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"idempotency_key_reused"`)
+		assert.Equal(t, 0, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Different Query Is Rejected", func(t *testing.T) {
+		calls = 0
+		expectClaim(0)
+		expectStored(hash, http.StatusCreated)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/book?library_id=2", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, 0, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("First Request Still Running", func(t *testing.T) {
+		expectClaim(0)
+		expectStored(hash, 0)
+
+		w := send("key-1", body)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"idempotency_in_progress"`)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Oversized Body Is Refused", func(t *testing.T) {
+		calls = 0
+
+		w := send("key-3", `{"isbn":"123","libraryid":1,"title":"`+strings.Repeat("x", 64)+`"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"request_too_large"`)
+		assert.Equal(t, 0, calls)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Server Errors Release The Key", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		expectClaim(1)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "idempotency_keys" WHERE user_id = $1 AND key = $2`)).
+			WithArgs(7, "key-2").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		w := send("key-2", body)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestIdempotencyMaxBodyFromEnv(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_MAX_BODY", "1048576")
+	assert.Equal(t, int64(1<<20), IdempotencyMaxBodyFromEnv())
+
+	t.Setenv("IDEMPOTENCY_MAX_BODY", "-1")
+	assert.Equal(t, int64(DefaultIdempotencyMaxBody), IdempotencyMaxBodyFromEnv())
+}
+
+func TestIdempotencyTTLFromEnv(t *testing.T) {
+	t.Setenv("IDEMPOTENCY_TTL", "90m")
+	assert.Equal(t, 90*time.Minute, IdempotencyTTLFromEnv())
+
+	t.Setenv("IDEMPOTENCY_TTL", "soon")
+	assert.Equal(t, DefaultIdempotencyTTL, IdempotencyTTLFromEnv())
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestLogger writes one structured access log line per request
+func RequestLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []any{
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"route", c.FullPath(),
+			"status", c.Writer.Status(),
+			"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+			"ip", c.ClientIP(),
+			"bytes", c.Writer.Size(),
+		}
+		if userID := c.GetUint("userID"); userID != 0 {
+			attrs = append(attrs, "user_id", userID)
+		}
+		if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.HasTraceID() {
+			attrs = append(attrs, "trace_id", spanContext.TraceID().String())
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, "errors", c.Errors.String())
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case c.Writer.Status() >= 500:
+			level = slog.LevelError
+		case c.Writer.Status() >= 400:
+			level = slog.LevelWarn
+		}
+		Logger(c).Log(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Logger returns the default logger tagged with the current request ID
+func Logger(c *gin.Context) *slog.Logger {
+	if id := c.GetString("requestID"); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
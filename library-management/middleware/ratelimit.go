@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"fmt"
+	"library-management/apierror"
+	"library-management/metrics"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultPublicRateLimit is how many anonymous requests a client may make per
+// minute without PUBLIC_RATE_LIMIT
+const DefaultPublicRateLimit = 60
+
+// PublicRateLimitFromEnv reads PUBLIC_RATE_LIMIT as requests per minute per
+// client, falling back to DefaultPublicRateLimit when unset or invalid
+func PublicRateLimitFromEnv() int {
+	limit, err := strconv.Atoi(os.Getenv("PUBLIC_RATE_LIMIT"))
+	if err != nil || limit <= 0 {
+		return DefaultPublicRateLimit
+	}
+	return limit
+}
+
+// TrustedProxiesFromEnv reads TRUSTED_PROXIES as comma separated addresses or
+// CIDRs whose X-Forwarded-For is believed. Unset means none, so clients are
+// told apart by the address that connected.
+func TrustedProxiesFromEnv() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// RateLimit lets each client IP make limit requests per window and answers the
+// rest with 429 and Retry-After. The IP comes from c.ClientIP, so forwarded
+// headers only count behind the engine's trusted proxies. Counts are kept in
+// memory, so every instance limits on its own.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	limiter := &windowLimiter{limit: limit, window: window, clients: map[string]*clientWindow{}}
+	return func(c *gin.Context) {
+		remaining, reset, allowed := limiter.take(c.ClientIP(), time.Now())
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		if !allowed {
+			seconds := int((reset + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			metrics.RateLimited.Inc()
+			apierror.Respond(c, apierror.CodeRateLimited, fmt.Sprintf("Rate limit of %d requests exceeded, retry in %d seconds", limit, seconds))
+			return
+		}
+		c.Next()
+	}
+}
+
+// windowLimiter counts requests per client in fixed windows
+type windowLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*clientWindow
+	nextSweep time.Time
+}
+
+type clientWindow struct {
+	start time.Time
+	count int
+}
+
+// take counts one request from client and reports how many are left in its
+// window, how long until the window resets and whether the request may proceed
+func (l *windowLimiter) take(client string, now time.Time) (int, time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Clients idle for a whole window are forgotten so the map stays small
+	if now.After(l.nextSweep) {
+		for key, w := range l.clients {
+			if now.Sub(w.start) >= l.window {
+				delete(l.clients, key)
+			}
+		}
+		l.nextSweep = now.Add(l.window)
+	}
+
+	w, ok := l.clients[client]
+	if !ok || now.Sub(w.start) >= l.window {
+		w = &clientWindow{start: now}
+		l.clients[client] = w
+	}
+	reset := w.start.Add(l.window).Sub(now)
+	if w.count >= l.limit {
+		return 0, reset, false
+	}
+	w.count++
+	return l.limit - w.count, reset, true
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/public", RateLimit(2, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public", nil)
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "1", send("192.0.2.1").Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, send("192.0.2.1").Code)
+
+	w := send("192.0.2.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Other clients have their own budget
+	assert.Equal(t, http.StatusOK, send("192.0.2.2").Code)
+}
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	require.NoError(t, r.SetTrustedProxies(nil))
+	r.GET("/public", RateLimit(1, time.Minute), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	send := func(forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, send("203.0.113.1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.2").Code)
+}
+
+func TestWindowLimiterResets(t *testing.T) {
+	limiter := &windowLimiter{limit: 1, window: time.Minute, clients: map[string]*clientWindow{}}
+	start := time.Now()
+
+	_, _, allowed := limiter.take("a", start)
+	assert.True(t, allowed)
+	_, reset, allowed := limiter.take("a", start.Add(20*time.Second))
+	assert.False(t, allowed)
+	assert.Equal(t, 40*time.Second, reset)
+	_, _, allowed = limiter.take("a", start.Add(time.Minute))
+	assert.True(t, allowed)
+}
+
+func TestTrustedProxiesFromEnv(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	assert.Empty(t, TrustedProxiesFromEnv())
+
+	t.Setenv("TRUSTED_PROXIES", " 10.0.0.0/8, 192.0.2.7 ,")
+	assert.Equal(t, []string{"10.0.0.0/8", "192.0.2.7"}, TrustedProxiesFromEnv())
+}
+
+func TestPublicRateLimitFromEnv(t *testing.T) {
+	t.Setenv("PUBLIC_RATE_LIMIT", "120")
+	assert.Equal(t, 120, PublicRateLimitFromEnv())
+
+	t.Setenv("PUBLIC_RATE_LIMIT", "lots")
+	assert.Equal(t, DefaultPublicRateLimit, PublicRateLimitFromEnv())
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID())
+	r.GET("/ping", func(c *gin.Context) {
+		assert.Equal(t, c.GetString("requestID"), RequestIDFromContext(c.Request.Context()))
+		c.String(http.StatusOK, c.GetString("requestID"))
+	})
+
+	t.Run("Reuses Caller ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+		assert.Equal(t, "abc-123", w.Body.String())
+	})
+
+	t.Run("Generates Missing ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+		assert.Equal(t, w.Header().Get(RequestIDHeader), w.Body.String())
+	})
+
+	t.Run("Replaces Unsafe ID", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		req.Header.Set(RequestIDHeader, "bad id\nwith newline")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.NotEqual(t, "bad id\nwith newline", w.Header().Get(RequestIDHeader))
+		assert.Len(t, w.Header().Get(RequestIDHeader), 32)
+	})
+}
@@ -1,6 +1,14 @@
 package models
 
+// UserLibrary links a user to a library. For readers it doubles as the
+// membership application: registrations start as "Pending" and only
+// "Approved" memberships grant access to the library's catalogue.
 type UserLibrary struct {
-	UserID    uint `gorm:"primaryKey"`
-	LibraryID uint `gorm:"primaryKey"`
-}
\ No newline at end of file
+	UserID      uint   `gorm:"primaryKey" json:"user_id"`
+	LibraryID   uint   `gorm:"primaryKey" json:"library_id"`
+	Status      string `gorm:"type:varchar(20);not null;default:'Approved'" json:"status"` // Pending, Approved or Rejected
+	RequestedAt int64  `gorm:"default:0" json:"requested_at"`
+	ReviewerID  *uint  `gorm:"default:null" json:"reviewer_id"` // NULL means not yet reviewed
+	ReviewedAt  *int64 `gorm:"default:null" json:"reviewed_at"` // NULL means not yet reviewed
+	Reason      string `json:"reason"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Attachment kinds. A book has at most one cover and any number of files.
+const (
+	AttachmentCover = "cover"
+	AttachmentFile  = "file"
+)
+
+// Attachment is a file uploaded for a library's copy of a book, its cover image
+// or a supplementary file such as a table of contents. The bytes live in
+// storage under Key; covers also get a JPEG thumbnail under ThumbnailKey.
+type Attachment struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	BookID       uint      `gorm:"index;not null" json:"book_id"`
+	Kind         string    `gorm:"type:varchar(20);not null" json:"kind"`
+	Key          string    `gorm:"type:varchar(120);uniqueIndex;not null" json:"-"`
+	ThumbnailKey string    `gorm:"type:varchar(120);index" json:"-"`
+	FileName     string    `gorm:"type:varchar(255)" json:"file_name"`
+	ContentType  string    `gorm:"type:varchar(100);not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	Width        int       `json:"width,omitempty"` // Pixels, images only
+	Height       int       `json:"height,omitempty"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditLogAppendOnly is returned when something tries to rewrite audit history
+var ErrAuditLogAppendOnly = errors.New("audit log entries cannot be modified or deleted")
+
+// AuditLog records one privileged action. Rows are only ever inserted.
+type AuditLog struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	CreatedAt  time.Time       `gorm:"index" json:"created_at"`
+	ActorID    uint            `gorm:"index" json:"actor_id"` // 0 for anonymous actions such as self-registration
+	ActorRole  string          `gorm:"type:varchar(50)" json:"actor_role"`
+	Action     string          `gorm:"type:varchar(100);index;not null" json:"action"` // e.g. "book.update"
+	EntityType string          `gorm:"type:varchar(50);index:idx_audit_logs_entity;not null" json:"entity_type"`
+	EntityID   string          `gorm:"index:idx_audit_logs_entity" json:"entity_id"`
+	LibraryID  *uint           `gorm:"index" json:"library_id"`
+	Changes    json.RawMessage `gorm:"type:jsonb" json:"changes"` // {"field": {"before": ..., "after": ...}}
+	IP         string          `gorm:"type:varchar(64)" json:"ip"`
+	RequestID  string          `gorm:"type:varchar(64)" json:"request_id"`
+}
+
+func (AuditLog) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
+
+func (AuditLog) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditLogAppendOnly
+}
@@ -0,0 +1,44 @@
+package models
+
+// Author is a person or organisation credited on books. Key is the folded name
+// used to match spelling variants to the same record.
+type Author struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+	Key  string `gorm:"type:varchar(255);uniqueIndex;not null" json:"-"`
+}
+
+// Publisher is a publishing house, matched on Key like Author
+type Publisher struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+	Key  string `gorm:"type:varchar(255);uniqueIndex;not null" json:"-"`
+}
+
+// Subject is a topic heading books can be browsed by, matched on Key like Author
+type Subject struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"not null" json:"name"`
+	Key  string `gorm:"type:varchar(255);uniqueIndex;not null" json:"-"`
+}
+
+// BookAuthor credits an author on a book. The same author may appear in
+// several roles, such as author and illustrator.
+type BookAuthor struct {
+	BookID   uint   `gorm:"primaryKey" json:"book_id"`
+	AuthorID uint   `gorm:"primaryKey;index" json:"author_id"`
+	Role     string `gorm:"primaryKey;type:varchar(20);default:'author'" json:"role"` // author, editor, translator or illustrator
+	Position int    `gorm:"not null;default:0" json:"position"`                       // Order the credits were given in
+}
+
+// BookPublisher links a book to its publishers
+type BookPublisher struct {
+	BookID      uint `gorm:"primaryKey" json:"book_id"`
+	PublisherID uint `gorm:"primaryKey;index" json:"publisher_id"`
+}
+
+// BookSubject links a book to its subjects
+type BookSubject struct {
+	BookID    uint `gorm:"primaryKey" json:"book_id"`
+	SubjectID uint `gorm:"primaryKey;index" json:"subject_id"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// BibRecord is one edition from a bibliographic dump, such as Open Library's
+// editions dump or Library of Congress MARC records, indexed by ISBN-13 so
+// books can be filled in without calling out to the source.
+type BibRecord struct {
+	Source     string    `gorm:"primaryKey;type:varchar(20)" json:"source"` // openlibrary or loc
+	ISBN       string    `gorm:"primaryKey;type:char(13)" json:"isbn"`
+	SourceID   string    `gorm:"type:varchar(100)" json:"source_id"` // e.g. /books/OL7353617M
+	Title      string    `gorm:"not null" json:"title"`
+	Authors    string    `json:"authors"`
+	AuthorKeys string    `json:"-"` // Open Library author keys separated by ";", named from BibAuthor at lookup
+	Publisher  string    `json:"publisher"`
+	Edition    string    `json:"edition"`
+	Subjects   string    `json:"subjects"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// BibAuthor names an Open Library author key. Authors come in their own dump,
+// loaded before or after the editions that credit them.
+type BibAuthor struct {
+	Key  string `gorm:"primaryKey;type:varchar(50)" json:"key"` // e.g. /authors/OL23919A
+	Name string `gorm:"not null" json:"name"`
+}
+
+// EnrichmentJob re-enriches every book of a library in the background. Counts
+// are updated as the job runs.
+type EnrichmentJob struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"index" json:"updated_at"` // Touched after every batch, stale running jobs are picked up again
+	LibraryID   uint       `gorm:"index;not null" json:"library_id"`
+	RequestedBy uint       `json:"requested_by"`
+	Overwrite   bool       `json:"overwrite"`                                                    // Replace fields that already have a value, not only fill blanks
+	Status      string     `gorm:"type:varchar(20);index;not null;default:queued" json:"status"` // queued, running, done or failed
+	Total       int        `json:"total"`
+	Processed   int        `json:"processed"`
+	Updated     int        `json:"updated"`
+	NotFound    int        `json:"not_found"`
+	Failed      int        `json:"failed"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
@@ -4,13 +4,34 @@ import "gorm.io/gorm"
 
 type Book struct {
 	gorm.Model
-	ID              uint   `gorm:"primaryKey"`
-	ISBN            string `gorm:"not null"`
-	Title           string `gorm:"not null"`
-	Authors         string
-	Publisher       string
-	Version         string
-	TotalCopies     int
-	AvailableCopies int
-	LibraryID       uint `gorm:"index"`
-}
\ No newline at end of file
+	ID               uint   `gorm:"primaryKey"`
+	ISBN             string `gorm:"not null"`
+	Title            string `gorm:"not null"`
+	Authors          string // Free text, also split into linked Author records
+	Publisher        string // Free text, also split on ";" into linked Publisher records
+	Subjects         string // Free text, split on ";" into linked Subject records
+	Version          string
+	TotalCopies      int
+	AvailableCopies  int
+	LibraryID        uint   `gorm:"index"`
+	WorkID           uint   `gorm:"index;not null;default:0"`         // Work this edition belongs to, shared by every library's copies of the ISBN
+	CallNumber       string `gorm:"type:varchar(100)"`                // Shelf mark of the library's copies, e.g. "823.914 TOL" or "QA76.73.G63 D66 2016"
+	CallNumberScheme string `gorm:"type:varchar(3)"`                  // ddc or lcc, empty without a call number
+	CallNumberSort   string `gorm:"type:varchar(150);index" json:"-"` // Shelf-order key of CallNumber
+	LocationID       uint   `gorm:"index;not null;default:0"`         // Floor, section or shelf holding the copies, 0 when unassigned
+	Revision         uint   `gorm:"not null;default:1"`               // Bumped on every update, the ETag is derived from it
+}
+
+// BeforeCreate starts new books at revision 1
+func (b *Book) BeforeCreate(tx *gorm.DB) error {
+	if b.Revision == 0 {
+		b.Revision = 1
+	}
+	return nil
+}
+
+// BeforeUpdate bumps the revision so any change invalidates earlier ETags
+func (b *Book) BeforeUpdate(tx *gorm.DB) error {
+	b.Revision++
+	return nil
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey remembers the response to a mutating request so a retry with
+// the same Idempotency-Key header gets it replayed instead of running twice.
+// Status is zero while the first request is still being handled.
+type IdempotencyKey struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	Key         string    `gorm:"primaryKey;type:varchar(255)" json:"key"`
+	RequestHash string    `gorm:"type:char(64);not null" json:"request_hash"` // SHA-256 of method, path, query and body
+	Status      int       `gorm:"not null;default:0" json:"status"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"`
+	ETag        string    `gorm:"column:etag;type:varchar(100)" json:"etag"`
+	Body        []byte    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `gorm:"index;not null" json:"expires_at"`
+}
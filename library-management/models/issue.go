@@ -5,6 +5,7 @@ import "gorm.io/gorm"
 type IssueRegistry struct {
 	gorm.Model
 	ISBN               string `gorm:"not null" json:"isbn"`
+	LibraryID          uint   `gorm:"index;default:0" json:"library_id"` // 0 for loans issued before libraries were recorded
 	ReaderID           uint   `gorm:"not null" json:"reader_id"`
 	IssueApproverID    uint   `gorm:"not null" json:"issue_approver_id"`
 	IssueStatus        string `gorm:"type:varchar(50);not null" json:"issue_status"`
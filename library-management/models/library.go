@@ -1,6 +1,35 @@
 package models
 
+import "time"
+
 type Library struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"unique;not null"`
-}
\ No newline at end of file
+	ID            uint   `gorm:"primaryKey"`
+	Name          string `gorm:"unique;not null"`
+	Address       string
+	City          string `gorm:"index"`
+	Phone         string
+	Email         string
+	Timezone      string           `gorm:"not null;default:'UTC'"` // IANA name, e.g. "Asia/Kolkata"
+	Archived      bool             `gorm:"not null;default:false"` // Archived libraries are hidden from listings
+	PublicCatalog bool             `gorm:"not null;default:false"` // Opted in to the anonymous catalog under /public
+	OpeningHours  []OpeningHours   `gorm:"constraint:OnDelete:CASCADE"`
+	Closures      []LibraryClosure `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+// OpeningHours is one weekly opening slot. A weekday without a slot is a closed day;
+// a library with no slots at all is treated as open every day.
+type OpeningHours struct {
+	ID        uint         `gorm:"primaryKey"`
+	LibraryID uint         `gorm:"index;not null"`
+	Weekday   time.Weekday `gorm:"not null"`                 // 0 = Sunday
+	Opens     string       `gorm:"type:varchar(5);not null"` // "09:00"
+	Closes    string       `gorm:"type:varchar(5);not null"` // "17:30"
+}
+
+// LibraryClosure is a one-off closed day such as a public holiday
+type LibraryClosure struct {
+	ID        uint   `gorm:"primaryKey"`
+	LibraryID uint   `gorm:"uniqueIndex:idx_library_closure_date;not null"`
+	Date      string `gorm:"type:varchar(10);uniqueIndex:idx_library_closure_date;not null"` // "2006-01-02" in the library's timezone
+	Reason    string
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Location levels, from the top of a library's hierarchy down
+const (
+	LocationFloor   = "floor"
+	LocationSection = "section"
+	LocationShelf   = "shelf"
+)
+
+// ShelfLocation is a floor, a section on a floor or a shelf in a section of
+// one library. Books are assigned to the most precise one known.
+type ShelfLocation struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LibraryID uint      `gorm:"uniqueIndex:idx_location_name;not null" json:"library_id"`
+	ParentID  uint      `gorm:"uniqueIndex:idx_location_name;not null;default:0" json:"parent_id"` // 0 for floors
+	Level     string    `gorm:"type:varchar(10);not null" json:"level"`                            // floor, section or shelf
+	Name      string    `gorm:"uniqueIndex:idx_location_name;not null" json:"name"`                // Unique among its siblings
+	Position  int       `gorm:"not null;default:0" json:"position"`                                // Walking order among its siblings
+	Path      string    `gorm:"not null" json:"path"`                                              // Names from the floor down, e.g. "Floor 2 / Science / Shelf 4"
+	SortKey   string    `gorm:"type:varchar(100);index;not null" json:"-"`                         // Positions from the floor down, orders every location of a library
+}
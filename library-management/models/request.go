@@ -7,7 +7,8 @@ type RequestEvent struct {
 	ID           uint   `gorm:"primaryKey"`
 	BookID       string `gorm:"not null" json:"isbn"`
 	LibraryID    uint   `gorm:"not null" json:"libraryid"`
-	ReaderID     uint   `gorm:"not null"` // Reference to User (Reader)
+	WorkID       uint   `gorm:"index;not null;default:0" json:"work_id"` // Set for holds on a work, which any of its editions fulfils
+	ReaderID     uint   `gorm:"not null"`                                // Reference to User (Reader)
 	RequestDate  int64  `gorm:"not null"`
 	ApprovalDate *int64 `gorm:"default:null"` // NULL means not yet approved
 	ApproverID   *uint  `gorm:"default:null"` // NULL means not yet approved
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// SchemaMigration marks a schema version as applied. AutoMigrate keeps tables in
+// shape; versions track the changes it cannot make, such as moving data around.
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	AppliedAt time.Time `gorm:"not null" json:"applied_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Transfer states
+const (
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// Transfer moves copies of a book from one library to another. The copies
+// leave the source's counts when sent and join the destination's when
+// received; a cancelled transfer returns them to the source. Rows are never
+// deleted, so they are the history of every move.
+type Transfer struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	ISBN          string     `gorm:"index;not null" json:"isbn"`
+	FromLibraryID uint       `gorm:"index;not null" json:"from_library_id"`
+	ToLibraryID   uint       `gorm:"index;not null" json:"to_library_id"`
+	Copies        int        `gorm:"not null" json:"copies"`
+	Status        string     `gorm:"type:varchar(12);index;not null" json:"status"` // in_transit, received or cancelled
+	Note          string     `json:"note"`
+	SentBy        uint       `gorm:"not null" json:"sent_by"`
+	SentAt        time.Time  `gorm:"not null" json:"sent_at"`
+	ClosedBy      uint       `json:"closed_by"` // Admin who received or cancelled it, 0 while in transit
+	ClosedAt      *time.Time `json:"closed_at"`
+}
@@ -9,6 +9,7 @@ type User struct {
 	Email    string `gorm:"unique;not null"`
 	Contact  string
 	Role     string    `gorm:"type:varchar(50);check:role IN ('owner', 'admin', 'user')"`
+	Status   string    `gorm:"type:varchar(20);not null;default:'active';check:status IN ('active', 'deactivated')"` // Deactivated users cannot log in
 	Password string    `gorm:"not null"`
 	Library  []Library `gorm:"many2many:UserLibrary;"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Work groups the editions of one title, such as its hardcover, paperback and
+// revised editions. Each edition is an ISBN, and every library's copies of that
+// ISBN belong to the same work.
+type Work struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Title     string    `gorm:"not null" json:"title"` // Taken from the first edition catalogued
+	Authors   string    `json:"authors"`
+}
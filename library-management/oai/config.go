@@ -0,0 +1,75 @@
+package oai
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PageSize is how many headers or records one list response holds
+const PageSize = 100
+
+// Config describes the repository to harvesters. Empty fields are derived from
+// the request, so a plain deployment needs no settings.
+type Config struct {
+	RepositoryName string
+	// AdminEmail defaults to admin@ the repository identifier
+	AdminEmail string
+	// BaseURL defaults to the scheme, host and path the request came in on
+	BaseURL string
+	// RepositoryIdentifier, the domain in oai:<domain>:<id> identifiers,
+	// defaults to the request's host
+	RepositoryIdentifier string
+}
+
+// ConfigFromEnv reads OAI_REPOSITORY_NAME, OAI_ADMIN_EMAIL, OAI_BASE_URL and
+// OAI_REPOSITORY_IDENTIFIER
+func ConfigFromEnv() Config {
+	config := Config{
+		RepositoryName:       os.Getenv("OAI_REPOSITORY_NAME"),
+		AdminEmail:           os.Getenv("OAI_ADMIN_EMAIL"),
+		BaseURL:              os.Getenv("OAI_BASE_URL"),
+		RepositoryIdentifier: os.Getenv("OAI_REPOSITORY_IDENTIFIER"),
+	}
+	if config.RepositoryName == "" {
+		config.RepositoryName = "Library Management catalog"
+	}
+	return config
+}
+
+// Identifier is the OAI identifier of a book
+func (c Config) Identifier(id uint) string {
+	return "oai:" + c.RepositoryIdentifier + ":book/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ParseIdentifier returns the book id an identifier names, false when it is not
+// one of this repository's
+func (c Config) ParseIdentifier(identifier string) (uint, bool) {
+	rest, ok := strings.CutPrefix(identifier, "oai:"+c.RepositoryIdentifier+":book/")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// SetSpec names the set of one library's books
+func SetSpec(libraryID uint) string {
+	return "library-" + strconv.FormatUint(uint64(libraryID), 10)
+}
+
+// ParseSetSpec returns the library a set names
+func ParseSetSpec(spec string) (uint, bool) {
+	rest, ok := strings.CutPrefix(spec, "library-")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(rest, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
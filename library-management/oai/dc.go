@@ -0,0 +1,51 @@
+package oai
+
+import (
+	"library-management/authority"
+	"library-management/models"
+)
+
+// DublinCore is an oai_dc record. Element names carry their prefix, which the
+// dc element declares.
+type DublinCore struct {
+	OAIDC          string   `xml:"xmlns:oai_dc,attr"`
+	DC             string   `xml:"xmlns:dc,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	Titles         []string `xml:"dc:title"`
+	Creators       []string `xml:"dc:creator"`
+	Contributors   []string `xml:"dc:contributor"`
+	Subjects       []string `xml:"dc:subject"`
+	Publishers     []string `xml:"dc:publisher"`
+	Descriptions   []string `xml:"dc:description"`
+	Types          []string `xml:"dc:type"`
+	Identifiers    []string `xml:"dc:identifier"`
+}
+
+// BookDublinCore describes a book in simple Dublin Core. Authors are creators,
+// editors, translators and illustrators contributors; the ISBN is given as a
+// URN.
+func BookDublinCore(book models.Book) DublinCore {
+	dc := DublinCore{
+		OAIDC:          DublinCoreFormat.Namespace,
+		DC:             "http://purl.org/dc/elements/1.1/",
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: DublinCoreFormat.Namespace + " " + DublinCoreFormat.Schema,
+		Titles:         []string{book.Title},
+		Subjects:       authority.SplitList(book.Subjects),
+		Publishers:     authority.SplitList(book.Publisher),
+		Types:          []string{"Text"},
+		Identifiers:    []string{"urn:isbn:" + book.ISBN},
+	}
+	for _, credit := range authority.ParseCredits(book.Authors) {
+		if credit.Role == authority.RoleAuthor {
+			dc.Creators = append(dc.Creators, credit.Name)
+		} else {
+			dc.Contributors = append(dc.Contributors, credit.Name+" ("+credit.Role+")")
+		}
+	}
+	if book.Version != "" {
+		dc.Descriptions = []string{"Edition: " + book.Version}
+	}
+	return dc
+}
@@ -0,0 +1,141 @@
+package oai
+
+import (
+	"encoding/xml"
+	"net/url"
+	"testing"
+	"time"
+
+	"library-management/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  Request
+		code  string
+	}{
+		{"Identify", "verb=Identify", Request{Verb: Identify}, ""},
+		{"List Records", "verb=ListRecords&metadataPrefix=oai_dc&set=library-2&from=2024-01-01",
+			Request{Verb: ListRecords, MetadataPrefix: "oai_dc", Set: "library-2", From: "2024-01-01"}, ""},
+		{"Resumption Token", "verb=ListIdentifiers&resumptionToken=abc", Request{Verb: ListIdentifiers, ResumptionToken: "abc"}, ""},
+		{"Missing Verb", "metadataPrefix=oai_dc", Request{}, BadVerb},
+		{"Repeated Verb", "verb=Identify&verb=Identify", Request{}, BadVerb},
+		{"Unknown Verb", "verb=ListBooks", Request{}, BadVerb},
+		{"Unknown Argument", "verb=Identify&set=library-2", Request{}, BadArgument},
+		{"Repeated Argument", "verb=GetRecord&identifier=a&identifier=b&metadataPrefix=oai_dc", Request{}, BadArgument},
+		{"Missing Required", "verb=GetRecord&identifier=a", Request{}, BadArgument},
+		{"Token Is Exclusive", "verb=ListRecords&resumptionToken=abc&metadataPrefix=oai_dc", Request{}, BadArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			got, oaiErr := ParseRequest(values)
+			if tt.code != "" {
+				require.NotNil(t, oaiErr)
+				assert.Equal(t, tt.code, oaiErr.Code)
+				return
+			}
+			assert.Nil(t, oaiErr)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	window, err := ParseRange("2024-03-01", "2024-03-02")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), window.From)
+	assert.Equal(t, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), window.Before, "until covers its whole day")
+
+	window, err = ParseRange("", "2024-03-02T10:00:00Z")
+	assert.Nil(t, err)
+	assert.True(t, window.From.IsZero())
+	assert.Equal(t, time.Date(2024, 3, 2, 10, 0, 1, 0, time.UTC), window.Before)
+
+	for _, bad := range [][2]string{
+		{"2024-03-01", "2024-03-02T10:00:00Z"}, // Mixed granularity
+		{"2024-03-05", "2024-03-01"},           // Backwards
+		{"yesterday", ""},
+		{"", "2024-03-02T10:00:00+01:00"},
+	} {
+		_, err := ParseRange(bad[0], bad[1])
+		if assert.NotNil(t, err, bad) {
+			assert.Equal(t, BadArgument, err.Code)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	window, _ := ParseRange("2024-03-01", "")
+	token := NewToken(Request{MetadataPrefix: "oai_dc", Set: "library-2"}, window)
+	token.After, token.Cursor = 250, 100
+
+	decoded, err := DecodeToken(token.Encode())
+	assert.Nil(t, err)
+	assert.Equal(t, token, decoded)
+	assert.Equal(t, window, decoded.Range())
+
+	for _, bad := range []string{"", "not base64!", "e30"} { // e30 is {}
+		_, err := DecodeToken(bad)
+		if assert.NotNil(t, err, bad) {
+			assert.Equal(t, BadResumptionToken, err.Code)
+		}
+	}
+}
+
+func TestIdentifiers(t *testing.T) {
+	config := Config{RepositoryIdentifier: "library.example.org"}
+	assert.Equal(t, "oai:library.example.org:book/42", config.Identifier(42))
+
+	id, ok := config.ParseIdentifier("oai:library.example.org:book/42")
+	assert.True(t, ok)
+	assert.Equal(t, uint(42), id)
+	for _, bad := range []string{"oai:elsewhere.org:book/42", "oai:library.example.org:book/x", "oai:library.example.org:book/0"} {
+		_, ok := config.ParseIdentifier(bad)
+		assert.False(t, ok, bad)
+	}
+
+	library, ok := ParseSetSpec(SetSpec(7))
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), library)
+	_, ok = ParseSetSpec("branch-7")
+	assert.False(t, ok)
+}
+
+func TestResponse(t *testing.T) {
+	response := NewResponse("http://library.example.org/public/oai", time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC))
+	response.Request.Request = Request{Verb: GetRecord, Identifier: "oai:x:book/1", MetadataPrefix: "oai_dc"}
+	response.GetRecord = &RecordList{Records: []Record{{
+		Header:   Header{Identifier: "oai:x:book/1", Datestamp: "2024-02-01T00:00:00Z", SetSpecs: []string{"library-2"}},
+		Metadata: &Metadata{DublinCore: BookDublinCore(models.Book{ISBN: "9780140449136", Title: "Crime and Punishment", Authors: "Fyodor Dostoevsky; Pevear, Richard (trans.)", Publisher: "Penguin", Version: "2nd"})},
+	}}}
+
+	body, err := response.Marshal()
+	require.NoError(t, err)
+	doc := string(body)
+	assert.Contains(t, doc, `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, doc, `<OAI-PMH xmlns="http://www.openarchives.org/OAI/2.0/"`)
+	assert.Contains(t, doc, `<responseDate>2024-03-01T12:00:00Z</responseDate>`)
+	assert.Contains(t, doc, `<request verb="GetRecord" identifier="oai:x:book/1" metadataPrefix="oai_dc">http://library.example.org/public/oai</request>`)
+	assert.Contains(t, doc, `<oai_dc:dc xmlns:oai_dc="http://www.openarchives.org/OAI/2.0/oai_dc/" xmlns:dc="http://purl.org/dc/elements/1.1/"`)
+	assert.Contains(t, doc, `<dc:creator>Fyodor Dostoevsky</dc:creator>`)
+	assert.Contains(t, doc, `<dc:contributor>Richard Pevear (translator)</dc:contributor>`)
+	assert.Contains(t, doc, `<dc:identifier>urn:isbn:9780140449136</dc:identifier>`)
+	assert.Contains(t, doc, `<dc:description>Edition: 2nd</dc:description>`)
+	require.NoError(t, xml.Unmarshal(body, new(struct{})), "well-formed")
+
+	// A bad argument drops the echoed arguments and the answer
+	response.Fail(Errorf(BadArgument, "set is repeated"))
+	body, err = response.Marshal()
+	require.NoError(t, err)
+	doc = string(body)
+	assert.Contains(t, doc, `<request>http://library.example.org/public/oai</request>`)
+	assert.Contains(t, doc, `<error code="badArgument">set is repeated</error>`)
+	assert.NotContains(t, doc, "GetRecord")
+}
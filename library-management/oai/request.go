@@ -0,0 +1,203 @@
+// Package oai implements the protocol side of an OAI-PMH 2.0 data provider:
+// argument rules for each verb, datestamps, resumption tokens and the response
+// document. What records exist is left to the caller.
+package oai
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Verbs
+const (
+	Identify            = "Identify"
+	ListMetadataFormats = "ListMetadataFormats"
+	ListSets            = "ListSets"
+	ListIdentifiers     = "ListIdentifiers"
+	ListRecords         = "ListRecords"
+	GetRecord           = "GetRecord"
+)
+
+// verbArguments lists, for each verb, the arguments it requires and those it
+// allows. resumptionToken is exclusive wherever it is allowed.
+var verbArguments = map[string]struct{ required, optional []string }{
+	Identify:            {},
+	ListMetadataFormats: {optional: []string{"identifier"}},
+	ListSets:            {optional: []string{"resumptionToken"}},
+	ListIdentifiers:     {required: []string{"metadataPrefix"}, optional: []string{"from", "until", "set", "resumptionToken"}},
+	ListRecords:         {required: []string{"metadataPrefix"}, optional: []string{"from", "until", "set", "resumptionToken"}},
+	GetRecord:           {required: []string{"identifier", "metadataPrefix"}},
+}
+
+// Request holds the arguments of a valid request
+type Request struct {
+	Verb            string `xml:"verb,attr,omitempty"`
+	Identifier      string `xml:"identifier,attr,omitempty"`
+	MetadataPrefix  string `xml:"metadataPrefix,attr,omitempty"`
+	From            string `xml:"from,attr,omitempty"`
+	Until           string `xml:"until,attr,omitempty"`
+	Set             string `xml:"set,attr,omitempty"`
+	ResumptionToken string `xml:"resumptionToken,attr,omitempty"`
+}
+
+// ParseRequest checks the arguments of a GET query or POST form against the
+// verb's rules
+func ParseRequest(values url.Values) (Request, *Error) {
+	verbs := values["verb"]
+	if len(verbs) != 1 {
+		return Request{}, Errorf(BadVerb, "Exactly one verb is required")
+	}
+	rules, ok := verbArguments[verbs[0]]
+	if !ok {
+		return Request{}, Errorf(BadVerb, "%q is not an OAI-PMH verb", verbs[0])
+	}
+
+	args := map[string]string{}
+	for name, value := range values {
+		if name == "verb" {
+			continue
+		}
+		if !slices.Contains(rules.required, name) && !slices.Contains(rules.optional, name) {
+			return Request{}, Errorf(BadArgument, "%s does not take %s", verbs[0], name)
+		}
+		if len(value) != 1 {
+			return Request{}, Errorf(BadArgument, "%s is repeated", name)
+		}
+		args[name] = value[0]
+	}
+
+	if token, ok := args["resumptionToken"]; ok {
+		if len(args) > 1 {
+			return Request{}, Errorf(BadArgument, "resumptionToken is exclusive")
+		}
+		return Request{Verb: verbs[0], ResumptionToken: token}, nil
+	}
+	for _, name := range rules.required {
+		if args[name] == "" {
+			return Request{}, Errorf(BadArgument, "%s requires %s", verbs[0], name)
+		}
+	}
+
+	return Request{
+		Verb:           verbs[0],
+		Identifier:     args["identifier"],
+		MetadataPrefix: args["metadataPrefix"],
+		From:           args["from"],
+		Until:          args["until"],
+		Set:            args["set"],
+	}, nil
+}
+
+// Granularity of datestamps, the finest the repository supports
+const (
+	DayGranularity     = "2006-01-02"
+	SecondsGranularity = "2006-01-02T15:04:05Z"
+)
+
+// Datestamp formats t in UTC at seconds granularity
+func Datestamp(t time.Time) string {
+	return t.UTC().Format(SecondsGranularity)
+}
+
+// Range is a selective harvesting window. From is inclusive and Before
+// exclusive; either is zero when open.
+type Range struct {
+	From   time.Time
+	Before time.Time
+}
+
+// ParseRange reads from and until, which may be days or seconds but not one of
+// each. until covers the whole day or second it names.
+func ParseRange(from, until string) (Range, *Error) {
+	var window Range
+	var fromLayout, untilLayout string
+	var err *Error
+	if from != "" {
+		if window.From, fromLayout, err = parseDatestamp("from", from); err != nil {
+			return Range{}, err
+		}
+	}
+	if until != "" {
+		var end time.Time
+		if end, untilLayout, err = parseDatestamp("until", until); err != nil {
+			return Range{}, err
+		}
+		if untilLayout == DayGranularity {
+			window.Before = end.AddDate(0, 0, 1)
+		} else {
+			window.Before = end.Add(time.Second)
+		}
+	}
+	if fromLayout != "" && untilLayout != "" {
+		if fromLayout != untilLayout {
+			return Range{}, Errorf(BadArgument, "from and until must have the same granularity")
+		}
+		if !window.From.Before(window.Before) {
+			return Range{}, Errorf(BadArgument, "from is later than until")
+		}
+	}
+	return window, nil
+}
+
+func parseDatestamp(name, value string) (time.Time, string, *Error) {
+	for _, layout := range []string{DayGranularity, SecondsGranularity} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, layout, nil
+		}
+	}
+	return time.Time{}, "", Errorf(BadArgument, "%s must be YYYY-MM-DD or YYYY-MM-DDThh:mm:ssZ", name)
+}
+
+// Token is the state of a list request split across responses. Tokens carry
+// everything needed to continue, so no state is kept between requests.
+type Token struct {
+	MetadataPrefix string `json:"m"`
+	Set            string `json:"s,omitempty"`
+	From           int64  `json:"f,omitempty"` // Unix seconds, 0 when open
+	Before         int64  `json:"b,omitempty"`
+	After          uint   `json:"a"` // Last id sent
+	Cursor         int    `json:"c"` // Records sent before this page
+}
+
+// NewToken starts a token for the arguments of a list request
+func NewToken(request Request, window Range) Token {
+	token := Token{MetadataPrefix: request.MetadataPrefix, Set: request.Set}
+	if !window.From.IsZero() {
+		token.From = window.From.Unix()
+	}
+	if !window.Before.IsZero() {
+		token.Before = window.Before.Unix()
+	}
+	return token
+}
+
+// Range is the harvesting window the token was issued for
+func (t Token) Range() Range {
+	var window Range
+	if t.From != 0 {
+		window.From = time.Unix(t.From, 0).UTC()
+	}
+	if t.Before != 0 {
+		window.Before = time.Unix(t.Before, 0).UTC()
+	}
+	return window
+}
+
+// Encode renders the token for a resumptionToken element
+func (t Token) Encode() string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeToken reads a token sent back by a harvester
+func DecodeToken(value string) (Token, *Error) {
+	var token Token
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || json.Unmarshal(raw, &token) != nil || token.MetadataPrefix == "" || token.Cursor < 0 {
+		return Token{}, Errorf(BadResumptionToken, "The resumption token is not valid")
+	}
+	return token, nil
+}
@@ -0,0 +1,163 @@
+package oai
+
+import (
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// Error codes
+const (
+	BadArgument             = "badArgument"
+	BadResumptionToken      = "badResumptionToken"
+	BadVerb                 = "badVerb"
+	CannotDisseminateFormat = "cannotDisseminateFormat"
+	IDDoesNotExist          = "idDoesNotExist"
+	NoRecordsMatch          = "noRecordsMatch"
+	NoMetadataFormats       = "noMetadataFormats"
+	NoSetHierarchy          = "noSetHierarchy"
+)
+
+// Error is an OAI-PMH error condition. Errors are answered with 200 OK, the
+// code tells the harvester what went wrong.
+type Error struct {
+	Code    string `xml:"code,attr"`
+	Message string `xml:",chardata"`
+}
+
+// Errorf builds an Error with a formatted message
+func Errorf(code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Response is an OAI-PMH document. Exactly one of Errors or the verb elements is set.
+type Response struct {
+	XMLName        xml.Name `xml:"OAI-PMH"`
+	Namespace      string   `xml:"xmlns,attr"`
+	XSI            string   `xml:"xmlns:xsi,attr"`
+	SchemaLocation string   `xml:"xsi:schemaLocation,attr"`
+	ResponseDate   string   `xml:"responseDate"`
+	Request        struct {
+		Request
+		BaseURL string `xml:",chardata"`
+	} `xml:"request"`
+	Errors []*Error `xml:"error"`
+
+	Identify            *IdentifyResult     `xml:"Identify,omitempty"`
+	ListMetadataFormats *MetadataFormatList `xml:"ListMetadataFormats,omitempty"`
+	ListSets            *SetList            `xml:"ListSets,omitempty"`
+	GetRecord           *RecordList         `xml:"GetRecord,omitempty"`
+	ListIdentifiers     *HeaderList         `xml:"ListIdentifiers,omitempty"`
+	ListRecords         *RecordList         `xml:"ListRecords,omitempty"`
+}
+
+// NewResponse starts a response to a request made to baseURL
+func NewResponse(baseURL string, now time.Time) *Response {
+	response := &Response{
+		Namespace:      "http://www.openarchives.org/OAI/2.0/",
+		XSI:            "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.openarchives.org/OAI/2.0/ http://www.openarchives.org/OAI/2.0/OAI-PMH.xsd",
+		ResponseDate:   Datestamp(now),
+	}
+	response.Request.BaseURL = baseURL
+	return response
+}
+
+// Fail replaces the response's content with err. The request's arguments are
+// only echoed when they were valid.
+func (r *Response) Fail(err *Error) {
+	if err.Code == BadVerb || err.Code == BadArgument {
+		r.Request.Request = Request{}
+	}
+	r.Errors = append(r.Errors, err)
+	r.Identify, r.ListMetadataFormats, r.ListSets = nil, nil, nil
+	r.GetRecord, r.ListIdentifiers, r.ListRecords = nil, nil, nil
+}
+
+// Marshal renders the document with its XML declaration
+func (r *Response) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// IdentifyResult describes the repository
+type IdentifyResult struct {
+	RepositoryName    string `xml:"repositoryName"`
+	BaseURL           string `xml:"baseURL"`
+	ProtocolVersion   string `xml:"protocolVersion"`
+	AdminEmail        string `xml:"adminEmail"`
+	EarliestDatestamp string `xml:"earliestDatestamp"`
+	DeletedRecord     string `xml:"deletedRecord"`
+	Granularity       string `xml:"granularity"`
+}
+
+// MetadataFormat is a format records can be disseminated in
+type MetadataFormat struct {
+	Prefix    string `xml:"metadataPrefix"`
+	Schema    string `xml:"schema"`
+	Namespace string `xml:"metadataNamespace"`
+}
+
+// DublinCoreFormat is oai_dc, which every repository must support
+var DublinCoreFormat = MetadataFormat{
+	Prefix:    "oai_dc",
+	Schema:    "http://www.openarchives.org/OAI/2.0/oai_dc.xsd",
+	Namespace: "http://www.openarchives.org/OAI/2.0/oai_dc/",
+}
+
+type MetadataFormatList struct {
+	Formats []MetadataFormat `xml:"metadataFormat"`
+}
+
+// Set is a group of records harvesters can select
+type Set struct {
+	Spec string `xml:"setSpec"`
+	Name string `xml:"setName"`
+}
+
+type SetList struct {
+	Sets []Set `xml:"set"`
+}
+
+// Header identifies a record; deleted records only have a header
+type Header struct {
+	Status     string   `xml:"status,attr,omitempty"`
+	Identifier string   `xml:"identifier"`
+	Datestamp  string   `xml:"datestamp"`
+	SetSpecs   []string `xml:"setSpec"`
+}
+
+// Record is a header with its metadata, nil for deleted records
+type Record struct {
+	Header   Header    `xml:"header"`
+	Metadata *Metadata `xml:"metadata,omitempty"`
+}
+
+type Metadata struct {
+	DublinCore DublinCore `xml:"oai_dc:dc"`
+}
+
+// ResumptionToken continues a list. The last page of a split list carries an
+// empty one.
+type ResumptionToken struct {
+	CompleteListSize int64  `xml:"completeListSize,attr"`
+	Cursor           int    `xml:"cursor,attr"`
+	Value            string `xml:",chardata"`
+}
+
+type HeaderList struct {
+	Headers         []Header         `xml:"header"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken,omitempty"`
+}
+
+type RecordList struct {
+	Records         []Record         `xml:"record"`
+	ResumptionToken *ResumptionToken `xml:"resumptionToken,omitempty"`
+}
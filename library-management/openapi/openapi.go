@@ -0,0 +1,92 @@
+// Package openapi embeds the API description and serves it, together with a
+// docs page and optional middleware that checks traffic against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed spec.yaml
+var specYAML []byte
+
+var (
+	loadOnce sync.Once
+	spec     *openapi3.T
+	specJSON []byte
+	loadErr  error
+)
+
+// Load parses and validates the embedded document. The result is cached, callers
+// must not modify it.
+func Load() (*openapi3.T, error) {
+	loadOnce.Do(func() {
+		loader := openapi3.NewLoader()
+		spec, loadErr = loader.LoadFromData(specYAML)
+		if loadErr != nil {
+			return
+		}
+		if loadErr = spec.Validate(context.Background()); loadErr != nil {
+			loadErr = fmt.Errorf("openapi: invalid spec: %w", loadErr)
+			return
+		}
+		specJSON, loadErr = spec.MarshalJSON()
+	})
+	return spec, loadErr
+}
+
+// MustLoad is Load for callers that cannot run without the document
+func MustLoad() *openapi3.T {
+	doc, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// Handler serves the document as JSON
+func Handler() gin.HandlerFunc {
+	MustLoad()
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", specJSON)
+	}
+}
+
+// DocsHandler serves an interactive page rendering /openapi.json
+func DocsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+	}
+}
+
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Library Management API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui", persistAuthorization: true});
+  </script>
+</body>
+</html>
+`
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// PathFromGin converts a gin route such as /api/book/:isbn to its OpenAPI
+// form, /api/book/{isbn}
+func PathFromGin(route string) string {
+	return ginParam.ReplaceAllString(route, "{$1}")
+}
@@ -0,0 +1,64 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	assert.NotNil(t, doc.Paths.Find("/api/book/{isbn}"))
+}
+
+func TestPathFromGin(t *testing.T) {
+	assert.Equal(t, "/api/book/{isbn}", PathFromGin("/api/book/:isbn"))
+	assert.Equal(t, "/api/membership/approve/{libraryid}/{userid}", PathFromGin("/api/membership/approve/:libraryid/:userid"))
+	assert.Equal(t, "/api/me", PathFromGin("/api/me"))
+}
+
+func TestValidator(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Validator(ModeAll))
+	r.PUT("/api/users/:id/role", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Role updated", "user": gin.H{"ID": 3, "Role": "admin"}})
+	})
+	r.GET("/undocumented", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name           string
+		method, path   string
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Valid Request", http.MethodPut, "/api/users/3/role", `{"role":"admin"}`, http.StatusOK, `"Role updated"`},
+		{"Value Outside Enum", http.MethodPut, "/api/users/3/role", `{"role":"superuser"}`, http.StatusBadRequest, `"field":"role","rule":"enum"`},
+		{"Missing Required Field", http.MethodPut, "/api/users/3/role", `{}`, http.StatusBadRequest, `"code":"validation_failed"`},
+		{"Bad Path Parameter", http.MethodPut, "/api/users/abc/role", `{"role":"admin"}`, http.StatusBadRequest, `"field":"id"`},
+		{"Undocumented Route Passes", http.MethodGet, "/undocumented", "", http.StatusNoContent, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			assert.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package openapi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"library-management/apierror"
+	"library-management/middleware"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	// MARC uploads reach the handler as they were sent
+	for _, contentType := range []string{"application/marc", "application/marcxml+xml", "application/xml"} {
+		openapi3filter.RegisterBodyDecoder(contentType, openapi3filter.FileBodyDecoder)
+	}
+}
+
+// Mode selects how much traffic Validator checks
+type Mode string
+
+const (
+	// ModeOff disables validation
+	ModeOff Mode = "off"
+	// ModeRequests rejects requests that do not match the spec
+	ModeRequests Mode = "requests"
+	// ModeAll also checks responses, logging mismatches without changing them
+	ModeAll Mode = "all"
+)
+
+// ModeFromEnv reads OPENAPI_VALIDATION, validation is off unless asked for
+func ModeFromEnv() Mode {
+	switch mode := Mode(strings.ToLower(os.Getenv("OPENAPI_VALIDATION"))); mode {
+	case ModeRequests, ModeAll:
+		return mode
+	}
+	return ModeOff
+}
+
+// Validator checks each request against the operation documented for its route.
+// Bad requests are answered with a validation_failed problem before any handler
+// runs. In ModeAll responses are buffered and mismatches logged, which catches
+// drift between handlers and the spec in staging. Routes missing from the spec
+// pass through untouched; the contract test keeps that set empty.
+func Validator(mode Mode) gin.HandlerFunc {
+	if mode == ModeOff {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	doc := MustLoad()
+	options := &openapi3filter.Options{
+		// Authorization is enforced by AuthMiddleware, not here
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		// Handlers see the body exactly as the client sent it
+		SkipSettingDefaults: true,
+	}
+
+	return func(c *gin.Context) {
+		route, pathParams, ok := findRoute(doc, c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), input); err != nil {
+			apierror.Write(c, requestProblem(c, err))
+			return
+		}
+
+		if mode != ModeAll {
+			c.Next()
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if !isJSON(recorder.Header().Get("Content-Type")) {
+			return
+		}
+		err := openapi3filter.ValidateResponse(c.Request.Context(), &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 recorder.Status(),
+			Header:                 recorder.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(recorder.body.Bytes())),
+			Options:                &openapi3filter.Options{IncludeResponseStatus: true},
+		})
+		if err != nil {
+			middleware.Logger(c).Warn("response does not match openapi spec",
+				"method", c.Request.Method, "route", c.FullPath(), "status", recorder.Status(), "error", err)
+		}
+	}
+}
+
+// findRoute looks up the operation for the gin route that matched the request
+func findRoute(doc *openapi3.T, c *gin.Context) (*routers.Route, map[string]string, bool) {
+	if c.FullPath() == "" {
+		return nil, nil, false
+	}
+	path := PathFromGin(c.FullPath())
+	pathItem := doc.Paths.Find(path)
+	if pathItem == nil {
+		return nil, nil, false
+	}
+	operation := pathItem.GetOperation(c.Request.Method)
+	if operation == nil {
+		return nil, nil, false
+	}
+
+	params := make(map[string]string, len(c.Params))
+	for _, param := range c.Params {
+		params[param.Key] = param.Value
+	}
+	return &routers.Route{
+		Spec:      doc,
+		Path:      path,
+		PathItem:  pathItem,
+		Method:    c.Request.Method,
+		Operation: operation,
+	}, params, true
+}
+
+// requestProblem reports which parameter or body field broke the spec
+func requestProblem(c *gin.Context, err error) apierror.Problem {
+	problem := apierror.New(c, apierror.CodeValidationFailed, "Request does not match the API specification")
+
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return problem
+	}
+
+	field := "body"
+	if requestErr.Parameter != nil {
+		field = requestErr.Parameter.Name
+	}
+	fieldErr := apierror.FieldError{Field: field, Rule: "schema", Message: requestErr.Reason}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(requestErr.Err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 && requestErr.Parameter == nil {
+			fieldErr.Field = strings.Join(pointer, ".")
+		}
+		fieldErr.Rule = schemaErr.SchemaField
+		fieldErr.Message = schemaErr.Reason
+	} else if fieldErr.Message == "" {
+		fieldErr.Message = requestErr.Err.Error()
+	}
+
+	problem.Errors = []apierror.FieldError{fieldErr}
+	return problem
+}
+
+func isJSON(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, apierror.ContentType)
+}
+
+// bodyRecorder copies the response body while still sending it to the client
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
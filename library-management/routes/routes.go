@@ -1,8 +1,16 @@
 package routes
 
 import (
+	"fmt"
+	"library-management/apierror"
 	controllers "library-management/controllers"
+	"library-management/enrich"
+	"library-management/metrics"
 	"library-management/middleware"
+	"library-management/oai"
+	"library-management/openapi"
+	"library-management/storage"
+	"library-management/tracing"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -10,47 +18,162 @@ import (
 	"gorm.io/gorm"
 )
 
-func SetupRouter(db *gorm.DB) *gin.Engine {
-	r := gin.Default()
+// SetupRouter wires every route. Covers and attachments are kept in store, book
+// metadata by ISBN comes from source. It fails when TRUSTED_PROXIES is invalid.
+func SetupRouter(db *gorm.DB, store storage.Store, source enrich.Source) (*gin.Engine, error) {
+	r := gin.New()
+	r.HandleMethodNotAllowed = true
+	// X-Forwarded-For is only believed from TRUSTED_PROXIES, so clients can't
+	// pick their own rate limit bucket
+	if err := r.SetTrustedProxies(middleware.TrustedProxiesFromEnv()); err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(tracing.Middleware(), middleware.RequestID(), middleware.RequestLogger(), gin.CustomRecovery(func(c *gin.Context, _ any) {
+		apierror.Respond(c, apierror.CodeInternal, "Unexpected server error")
+	}), middleware.Metrics(), tracing.Annotate())
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", middleware.RequestIDHeader, middleware.IdempotencyKeyHeader, "If-Match", "If-None-Match", "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"Content-Length", "ETag", middleware.RequestIDHeader, middleware.IdempotentReplayedHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
+	r.NoRoute(func(c *gin.Context) {
+		apierror.Respond(c, apierror.CodeRouteNotFound, "No route matches "+c.Request.Method+" "+c.Request.URL.Path)
+	})
+	r.NoMethod(func(c *gin.Context) {
+		apierror.Respond(c, apierror.CodeMethodNotAllowed, c.Request.Method+" is not supported on "+c.Request.URL.Path)
+	})
+
+	// Prometheus scrape endpoint and probes
+	r.GET("/metrics", metrics.Handler())
+	r.GET("/livez", controllers.Livez())     // Process is up
+	r.GET("/readyz", controllers.Readyz(db)) // Database, schema version and workers
+
+	// Covers and attachments, by the random keys their URLs carry
+	r.GET("/files/:key", controllers.ServeFile(db, store))
+
+	// API description and docs UI
+	r.GET("/openapi.json", openapi.Handler())
+	r.GET("/docs", openapi.DocsHandler())
+
+	// Requests are checked against the spec (OPENAPI_VALIDATION=off|requests|all)
+	// after authentication, so anonymous callers can't probe the request shapes
+	// of routes they may not use
+	validate := openapi.Validator(openapi.ModeFromEnv())
+
 	// Public routes (No authentication needed)
-	auth := r.Group("/auth")
+	auth := r.Group("/auth", validate)
 	{
 		auth.POST("/login", controllers.Login(db))
 	}
 
+	// Anonymous read-only catalog of libraries that opted in, PUBLIC_RATE_LIMIT requests per minute per client
+	public := r.Group("/public", middleware.RateLimit(middleware.PublicRateLimitFromEnv(), time.Minute), validate)
+	{
+		public.GET("/libraries", controllers.ListPublicLibraries(db))           // Libraries with a public catalog
+		public.GET("/libraries/:id/books", controllers.SearchPublicCatalog(db)) // Search one library's catalog
+		public.GET("/libraries/:id/books/:isbn", controllers.GetPublicBook(db)) // Availability without borrower data
+
+		// OAI-PMH 2.0 harvesting of the same libraries, one set per library
+		oaiConfig := oai.ConfigFromEnv()
+		public.GET("/oai", controllers.OAIPMH(db, oaiConfig))
+		public.POST("/oai", controllers.OAIPMH(db, oaiConfig))
+	}
+
+	// Retries of authenticated POST and PUT requests carrying an Idempotency-Key
+	// replay the first response, kept for IDEMPOTENCY_TTL. Their bodies are
+	// capped at IDEMPOTENCY_MAX_BODY bytes.
+	idempotent := middleware.Idempotency(db, middleware.IdempotencyTTLFromEnv(), middleware.IdempotencyMaxBodyFromEnv())
+
 	// Protected API routes (needs authentication)
 	api := r.Group("/api")
 	{
-		r.GET("/libraries", controllers.ListLibraries(db))
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{"message": "API is running"})
-		})
+		r.GET("/libraries", validate, controllers.ListLibraries(db))  // Filter by name, city and archived, paginated
+		r.GET("/libraries/:id", validate, controllers.GetLibrary(db)) // Library details with opening hours and closures
+		api.GET("/health", controllers.Readyz(db))                    // Older name for /readyz
 
 		// Owner-Only Routes
-		ownerRoutes := api.Group("", middleware.AuthMiddleware("owner"))
+		ownerRoutes := api.Group("", middleware.AuthMiddleware(db, "owner"), validate, idempotent)
+		{
+			ownerRoutes.POST("/library", controllers.CreateLibrary(db))                 // Owner can create a library
+			ownerRoutes.PUT("/library/:id", controllers.UpdateLibrary(db))              // Owner can update details, opening hours and closures
+			ownerRoutes.PUT("/library/:id/archive", controllers.ArchiveLibrary(db))     // Owner can archive a library
+			ownerRoutes.PUT("/library/:id/unarchive", controllers.UnarchiveLibrary(db)) // Owner can restore an archived library
+			ownerRoutes.DELETE("/library/:id", controllers.DeleteLibrary(db))           // Owner can delete an empty library
+			ownerRoutes.POST("/admin", controllers.RegisterAdmin(db))                   // Owner can create Admins
+			ownerRoutes.POST("/owner", controllers.RegisterOwnerNew(db))                // Owner can create a new Owner
+
+			// User Administration
+			ownerRoutes.PUT("/users/:id/role", controllers.ChangeUserRole(db))           // Owner can promote or demote users
+			ownerRoutes.PUT("/users/:id/libraries", controllers.AssignUserLibraries(db)) // Owner can reassign library memberships
+		}
+
+		// Owner and Admin Routes
+		staffRoutes := api.Group("", middleware.AuthMiddleware(db, "owner|admin"), validate, idempotent)
 		{
-			ownerRoutes.POST("/library", controllers.CreateLibrary(db))  // Owner can create a library
-			ownerRoutes.POST("/admin", controllers.RegisterAdmin(db))    // Owner can create Admins
-			ownerRoutes.POST("/owner", controllers.RegisterOwnerNew(db)) // Owner can create a new Owner
+			staffRoutes.GET("/users", controllers.ListUsers(db))                     // Filter by role, library_id and status, paginated
+			staffRoutes.GET("/users/:id", controllers.GetUser(db))                   // View a user with memberships
+			staffRoutes.PUT("/users/:id", controllers.UpdateUser(db))                // Update name, email or contact
+			staffRoutes.PUT("/users/:id/deactivate", controllers.DeactivateUser(db)) // Block login and existing tokens
+			staffRoutes.PUT("/users/:id/reactivate", controllers.ReactivateUser(db)) // Restore login
+			staffRoutes.DELETE("/users/:id", controllers.DeleteUser(db))             // Soft delete
+
+			// Audit Trail
+			staffRoutes.GET("/audit", controllers.ListAuditLogs(db))          // Filter by actor, action, entity, library and date range, paginated
+			staffRoutes.GET("/audit/export", controllers.ExportAuditLogs(db)) // Same filters, streamed as JSON Lines
+
+			// Metadata Enrichment
+			staffRoutes.GET("/enrichment/:isbn", controllers.LookupMetadata(source)) // Preview what a lookup fills in
+
+			// Works and Editions, admins only for ISBNs their libraries hold
+			staffRoutes.POST("/works/:id/editions", controllers.MergeEditions(db))        // Make ISBNs editions of this work
+			staffRoutes.DELETE("/works/:id/editions/:isbn", controllers.SplitEdition(db)) // Move an edition into a new work
 		}
 
 		// Admin-Only Routes
-		adminRoutes := api.Group("", middleware.AuthMiddleware("admin"))
+		adminRoutes := api.Group("", middleware.AuthMiddleware(db, "admin"), validate, idempotent)
 		{
 
 			// Book Management
-			adminRoutes.POST("/book", controllers.AddBook(db))            // Admin can add books
-			adminRoutes.PUT("/book/:isbn", controllers.UpdateBook(db))    // Admin can update book details (copies, title, etc.)
-			adminRoutes.DELETE("/book/:isbn", controllers.RemoveBook(db)) // Admin can remove books
+			adminRoutes.POST("/book", controllers.AddBook(db, source))     // Admin can add books, ?enrich=true fills them in from the ISBN
+			adminRoutes.GET("/book/:isbn", controllers.GetLibraryBook(db)) // Admin can read one library's copy, with ETag
+			adminRoutes.PUT("/book/:isbn", controllers.UpdateBook(db))     // Admin can update book details (copies, title, etc.), honors If-Match
+			adminRoutes.DELETE("/book/:isbn", controllers.RemoveBook(db))  // Admin can remove books, honors If-Match
+
+			// Shelving, by call number and location
+			adminRoutes.PUT("/book/:isbn/shelf", controllers.ShelveBook(db))                       // Admin can set a Dewey or LC call number and location, honors If-Match
+			adminRoutes.GET("/library/:id/locations", controllers.ListLocations(db))               // Admin can list floors, sections and shelves in walking order
+			adminRoutes.POST("/library/:id/locations", controllers.CreateLocation(db))             // Admin can add a floor, section or shelf
+			adminRoutes.PUT("/library/:id/locations/:location", controllers.UpdateLocation(db))    // Admin can rename or reorder a location
+			adminRoutes.DELETE("/library/:id/locations/:location", controllers.DeleteLocation(db)) // Admin can remove an empty location
+			adminRoutes.GET("/library/:id/picklist", controllers.PickList(db))                     // Admin can list holds to pull, in shelf order
+
+			// Covers and Attachments, uploaded as multipart/form-data
+			adminRoutes.PUT("/book/:isbn/cover", controllers.PutCover(db, store))                      // Admin can set or replace a cover, thumbnailed
+			adminRoutes.DELETE("/book/:isbn/cover", controllers.DeleteCover(db, store))                // Admin can remove a cover
+			adminRoutes.GET("/book/:isbn/attachments", controllers.ListAttachments(db, store))         // Admin can list a book's cover and files
+			adminRoutes.POST("/book/:isbn/attachments", controllers.AddAttachment(db, store))          // Admin can add a supplementary file
+			adminRoutes.PUT("/book/:isbn/attachments/:id", controllers.ReplaceAttachment(db, store))   // Admin can replace a file, under a new URL
+			adminRoutes.DELETE("/book/:isbn/attachments/:id", controllers.DeleteAttachment(db, store)) // Admin can delete a file
+
+			// MARC Catalog Exchange
+			adminRoutes.POST("/library/:id/marc", controllers.ImportMARC(db)) // Admin can import MARC21 or MARCXML records, with a mapping report
+			adminRoutes.GET("/library/:id/marc", controllers.ExportMARC(db))  // Admin can export the catalog as MARCXML or MARC21
+
+			// Batch Re-Enrichment, run by the enrichment worker
+			adminRoutes.POST("/library/:id/enrichment", controllers.StartEnrichment(db))      // Admin can queue a job for every book of a library
+			adminRoutes.GET("/library/:id/enrichment", controllers.ListEnrichmentJobs(db))    // Admin can list jobs, newest first
+			adminRoutes.GET("/library/:id/enrichment/:job", controllers.GetEnrichmentJob(db)) // Admin can follow a job's progress
+
+			// Inter-Library Transfers, sent by the source and received by the destination
+			adminRoutes.POST("/library/:id/transfers", controllers.SendTransfer(db))                      // Admin can put available copies in transit to another library
+			adminRoutes.GET("/library/:id/transfers", controllers.ListTransfers(db))                      // Admin can list transfers sent or received, newest first
+			adminRoutes.GET("/library/:id/transfers/:transfer", controllers.GetTransfer(db))              // Admin can view one transfer
+			adminRoutes.POST("/library/:id/transfers/:transfer/receive", controllers.ReceiveTransfer(db)) // Destination admin can add the copies to the library
+			adminRoutes.POST("/library/:id/transfers/:transfer/cancel", controllers.CancelTransfer(db))   // Source admin can take back copies still in transit
 
 			// Issue Request Management
 			adminRoutes.GET("/issues", controllers.ListIssueRequests(db))             // Admin can list issue requests
@@ -59,21 +182,52 @@ func SetupRouter(db *gorm.DB) *gin.Engine {
 
 			// Issue Books to Users
 			adminRoutes.POST("/issue/book/:isbn", controllers.IssueBookToUser(db)) // Admin can issue books to a reader
+
+			// Reader Membership Management
+			adminRoutes.GET("/memberships", controllers.ListMemberships(db))                             // Admin can list membership applications
+			adminRoutes.PUT("/membership/approve/:libraryid/:userid", controllers.ApproveMembership(db)) // Admin can approve a membership
+			adminRoutes.PUT("/membership/reject/:libraryid/:userid", controllers.RejectMembership(db))   // Admin can reject a membership with a reason
+			adminRoutes.POST("/membership/enroll", controllers.EnrollUser(db))                           // Admin can enroll readers directly
+		}
+
+		// Self-Service Routes (any logged-in role)
+		meRoutes := api.Group("/me", middleware.AuthMiddleware(db, ""), validate, idempotent)
+		{
+			meRoutes.GET("", controllers.GetProfile(db))                      // View own profile
+			meRoutes.PUT("", controllers.UpdateProfile(db))                   // Update own name and contact
+			meRoutes.DELETE("", controllers.DeleteAccount(db))                // Delete own account
+			meRoutes.PUT("/password", controllers.ChangePassword(db))         // Change password, current one required
+			meRoutes.GET("/libraries", controllers.ListMyLibraries(db))       // Library memberships and their status
+			meRoutes.GET("/loans", controllers.ListMyLoans(db))               // Books currently held
+			meRoutes.GET("/loans/history", controllers.ListMyLoanHistory(db)) // Returned books
+		}
+
+		// Authority Browsing (any logged-in role), books limited to the caller's libraries
+		browseRoutes := api.Group("", middleware.AuthMiddleware(db, ""), validate)
+		{
+			browseRoutes.GET("/authors", controllers.ListAuthors(db))                     // Filter by name, paginated
+			browseRoutes.GET("/authors/:id/books", controllers.ListAuthorBooks(db))       // Optionally by role: author, editor, translator, illustrator
+			browseRoutes.GET("/publishers", controllers.ListPublishers(db))               // Filter by name, paginated
+			browseRoutes.GET("/publishers/:id/books", controllers.ListPublisherBooks(db)) // Books from one publisher
+			browseRoutes.GET("/subjects", controllers.ListSubjects(db))                   // Filter by name, paginated
+			browseRoutes.GET("/subjects/:id/books", controllers.ListSubjectBooks(db))     // Books on one subject
+			browseRoutes.GET("/works/:id", controllers.GetWork(db))                       // A work with its editions
 		}
 
-		api.POST("/user", controllers.RegisterUser(db))
+		api.POST("/user", validate, controllers.RegisterUser(db))                                     // Self-registration, memberships start pending
+		api.GET("/books/:isbn", middleware.AuthMiddleware(db, ""), validate, controllers.GetBook(db)) // Availability per accessible library, loans and copies for staff
 		// User-Only Routes
-		userRoutes := api.Group("", middleware.AuthMiddleware("user"))
+		userRoutes := api.Group("", middleware.AuthMiddleware(db, "user"), validate, idempotent)
 		{
 			// Book Search
-			userRoutes.GET("/books/search", controllers.SearchBooks(db)) // Users can search books by title, author, publisher
+			userRoutes.GET("/books/search", controllers.SearchBooks(db, store)) // Users can search works by title, author, publisher, subject
 
 			// Request a Book
-			userRoutes.POST("/issue", controllers.RequestIssue(db)) // Users can request book issues
+			userRoutes.POST("/issue", controllers.RequestIssue(db)) // Users can request an edition, or hold a work for any edition
 
 			userRoutes.GET("/issue/status", controllers.StatusIssue(db))
 		}
 	}
 
-	return r
+	return r, nil
 }
@@ -0,0 +1,166 @@
+package routes
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"library-management/apierror"
+	"library-management/enrich"
+	"library-management/openapi"
+	"library-management/storage"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRouter sets the router up without a database, keeping files in a
+// temporary directory and looking no metadata up
+func newRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	r, err := SetupRouter(nil, storage.NewLocal(t.TempDir(), "/files/"), enrich.Chain{})
+	require.NoError(t, err)
+	return r
+}
+
+func TestSetupRouterRejectsBadTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.0/33")
+	_, err := SetupRouter(nil, storage.NewLocal(t.TempDir(), "/files/"), enrich.Chain{})
+	assert.ErrorContains(t, err, "TRUSTED_PROXIES")
+}
+
+// The probes answer 503 with their checks rather than a problem
+var probeOperations = map[string]bool{"readyz": true, "health": true}
+
+// TestRoutesMatchOpenAPISpec fails when a route is added without documenting it,
+// or the spec describes an operation the router does not serve. Every error must
+// be documented as a problem+json Problem, and every authenticated operation must
+// document what AuthMiddleware and the validator behind it can answer.
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+
+	routed := map[string]bool{}
+	for _, route := range newRouter(t).Routes() {
+		routed[route.Method+" "+openapi.PathFromGin(route.Path)] = true
+	}
+
+	documented := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	assert.Empty(t, missing(routed, documented), "routes missing from openapi/spec.yaml")
+	assert.Empty(t, missing(documented, routed), "operations in openapi/spec.yaml with no route")
+
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			name := strings.ToUpper(method) + " " + path
+			for code, response := range operation.Responses.Map() {
+				if code < "400" || probeOperations[operation.OperationID] && code == "503" {
+					continue
+				}
+				media := response.Value.Content.Get(apierror.ContentType)
+				assert.True(t, media != nil && media.Schema != nil && media.Schema.Ref == "#/components/schemas/Problem",
+					"%s documents %s without a problem+json Problem", name, code)
+			}
+
+			if !secured(doc, operation) {
+				continue
+			}
+			codes := []string{"401", "403", "500"}
+			if operation.RequestBody != nil || len(operation.Parameters)+len(item.Parameters) > 0 {
+				codes = append(codes, "400")
+			}
+			for _, code := range codes {
+				assert.NotNil(t, operation.Responses.Value(code), "%s does not document %s", name, code)
+			}
+		}
+	}
+}
+
+// TestAnonymousRequestsAreRefusedFirst sends each authenticated operation a
+// request without a token or a body. AuthMiddleware must answer before the
+// validator looks at it, with the 401 problem the spec documents.
+func TestAnonymousRequestsAreRefusedFirst(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("OPENAPI_VALIDATION", string(openapi.ModeAll))
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	r := newRouter(t)
+
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, item := range doc.Paths.Map() {
+		for method, operation := range item.Operations() {
+			if !secured(doc, operation) {
+				continue
+			}
+			path, item, method, operation := path, item, method, operation
+			t.Run(method+" "+path, func(t *testing.T) {
+				req := httptest.NewRequest(method, param.ReplaceAllString(path, "1"), nil)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+
+				err := openapi3filter.ValidateResponse(context.Background(), &openapi3filter.ResponseValidationInput{
+					RequestValidationInput: &openapi3filter.RequestValidationInput{
+						Request: req,
+						Route:   &routers.Route{Spec: doc, Path: path, PathItem: item, Method: method, Operation: operation},
+					},
+					Status:  w.Code,
+					Header:  w.Header(),
+					Body:    io.NopCloser(w.Body),
+					Options: &openapi3filter.Options{IncludeResponseStatus: true},
+				})
+				assert.NoError(t, err)
+			})
+		}
+	}
+}
+
+// secured reports whether operation requires a token, by its own security or
+// the document's
+func secured(doc *openapi3.T, operation *openapi3.Operation) bool {
+	if operation.Security != nil {
+		return len(*operation.Security) > 0
+	}
+	return len(doc.Security) > 0
+}
+
+func missing(want, have map[string]bool) []string {
+	var keys []string
+	for key := range want {
+		if !have[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := newRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"openapi":"3.0.3"`)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+}
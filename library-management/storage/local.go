@@ -0,0 +1,72 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Local keeps files in a directory, created on the first Put
+type Local struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocal stores files in dir, to be served under publicURL
+func NewLocal(dir, publicURL string) *Local {
+	return &Local{dir: dir, publicURL: publicURL}
+}
+
+// Put writes the file beside its final name and renames it into place, so a
+// failed upload never leaves a partial file under the key
+func (l *Local) Put(ctx context.Context, key, contentType string, body io.Reader, size int64) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(l.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name()) // No-op once renamed
+
+	if _, err := io.Copy(file, io.LimitReader(body, size)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(l.dir, key))
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	file, err := os.Open(filepath.Join(l.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	err := os.Remove(filepath.Join(l.dir, key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (l *Local) URL(key string) string {
+	return joinURL(l.publicURL, key)
+}
@@ -0,0 +1,125 @@
+// Package storage keeps uploaded files, such as book covers, outside the
+// database. Files are addressed by opaque keys and never change once written:
+// replacing a file stores it under a new key, so their URLs can be cached
+// forever.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned when no file is stored under a key
+	ErrNotFound = errors.New("storage: file not found")
+	// ErrInvalidKey is returned for keys NewKey could not have made
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Store holds files by key
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any file there
+	Put(ctx context.Context, key, contentType string, body io.Reader, size int64) error
+	// Open reads a stored file, ErrNotFound when there is none
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes a file, deleting a missing file is not an error
+	Delete(ctx context.Context, key string) error
+	// URL is where clients fetch the file
+	URL(key string) string
+}
+
+// keyPattern keeps keys safe as file names and URL path segments
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}(\.[a-z0-9]{1,10})?$`)
+
+// ValidKey reports whether a key is one a Store accepts
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// NewKey returns a random key ending in ext, such as ".jpg"
+func NewKey(ext string) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err) // crypto/rand does not fail on supported platforms
+	}
+	return hex.EncodeToString(b) + ext
+}
+
+// Config selects and configures a Store
+type Config struct {
+	// Backend is "local" or "s3"
+	Backend string
+	// PublicURL prefixes keys in file URLs, by default the API's own /files/
+	PublicURL string
+	// Dir is where the local backend keeps files
+	Dir string
+	S3  S3Config
+}
+
+// ConfigFromEnv reads STORAGE_BACKEND (local by default), STORAGE_PUBLIC_URL,
+// STORAGE_DIR and, for s3, S3_ENDPOINT, S3_REGION, S3_BUCKET,
+// S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY and S3_FORCE_PATH_STYLE. The AWS_
+// credential variables are used when the S3_ ones are unset.
+func ConfigFromEnv() Config {
+	config := Config{
+		Backend:   strings.ToLower(strings.TrimSpace(os.Getenv("STORAGE_BACKEND"))),
+		PublicURL: os.Getenv("STORAGE_PUBLIC_URL"),
+		Dir:       os.Getenv("STORAGE_DIR"),
+		S3: S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Region:          os.Getenv("S3_REGION"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			AccessKeyID:     envOr("S3_ACCESS_KEY_ID", "AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: envOr("S3_SECRET_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY"),
+		},
+	}
+	config.S3.PathStyle, _ = strconv.ParseBool(os.Getenv("S3_FORCE_PATH_STYLE"))
+	return config
+}
+
+func envOr(keys ...string) string {
+	for _, key := range keys {
+		if value := os.Getenv(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// New opens the Store a configuration describes
+func New(config Config) (Store, error) {
+	if config.PublicURL == "" {
+		config.PublicURL = "/files/"
+	}
+	switch config.Backend {
+	case "", "local":
+		if config.Dir == "" {
+			config.Dir = "uploads"
+		}
+		return NewLocal(config.Dir, config.PublicURL), nil
+	case "s3":
+		return NewS3(config.S3, config.PublicURL)
+	}
+	return nil, fmt.Errorf("storage: unknown backend %q, use local or s3", config.Backend)
+}
+
+// FromEnv opens the Store STORAGE_BACKEND and its settings describe
+func FromEnv() (Store, error) {
+	return New(ConfigFromEnv())
+}
+
+// joinURL joins a public URL prefix and a key
+func joinURL(prefix, key string) string {
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + key
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts a server span per request, continuing any incoming
+// traceparent, and tags it with who made the request once handlers have run
+func Middleware() gin.HandlerFunc {
+	// Scrapes and probes would drown out real traffic
+	return otelgin.Middleware(ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		switch r.URL.Path {
+		case "/metrics", "/livez", "/readyz":
+			return false
+		}
+		return true
+	}))
+}
+
+// Annotate adds the caller's identity and library to the request span. It runs
+// after the handler chain, once AuthMiddleware has stored userID and userRole.
+func Annotate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		span := trace.SpanFromContext(c.Request.Context())
+		if !span.IsRecording() {
+			return
+		}
+		if role := c.GetString("userRole"); role != "" {
+			span.SetAttributes(attribute.String("enduser.role", role))
+		}
+		if userID := c.GetUint("userID"); userID != 0 {
+			span.SetAttributes(attribute.Int64("enduser.id", int64(userID)))
+		}
+		if id := libraryIDFromRequest(c); id != 0 {
+			span.SetAttributes(attribute.Int64("library.id", int64(id)))
+		}
+		if id := c.GetString("requestID"); id != "" {
+			span.SetAttributes(attribute.String("request.id", id))
+		}
+	}
+}
+
+// SetLibraryIDs records the libraries a handler worked on, for requests that
+// span several of them such as a reader's book search
+func SetLibraryIDs(ctx context.Context, ids []uint) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	values := make([]int64, len(ids))
+	for i, id := range ids {
+		values[i] = int64(id)
+	}
+	span.SetAttributes(attribute.Int64Slice("library.ids", values))
+}
+
+func libraryIDFromRequest(c *gin.Context) uint64 {
+	for _, value := range []string{c.Param("libraryid"), c.Query("library_id"), c.Query("libraryid")} {
+		if id, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return id
+		}
+	}
+	return 0
+}
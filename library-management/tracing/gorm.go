@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// GormPlugin opens a client span around every statement. Queries only join the
+// request trace when run through db.WithContext(c.Request.Context()).
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+func (GormPlugin) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("tracing:before_create", start("create")); err != nil {
+		return err
+	}
+	if err := db.Callback().Create().After("gorm:create").Register("tracing:after_create", end); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().Before("gorm:query").Register("tracing:before_query", start("query")); err != nil {
+		return err
+	}
+	if err := db.Callback().Query().After("gorm:query").Register("tracing:after_query", end); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().Before("gorm:update").Register("tracing:before_update", start("update")); err != nil {
+		return err
+	}
+	if err := db.Callback().Update().After("gorm:update").Register("tracing:after_update", end); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().Before("gorm:delete").Register("tracing:before_delete", start("delete")); err != nil {
+		return err
+	}
+	if err := db.Callback().Delete().After("gorm:delete").Register("tracing:after_delete", end); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().Before("gorm:row").Register("tracing:before_row", start("row")); err != nil {
+		return err
+	}
+	if err := db.Callback().Row().After("gorm:row").Register("tracing:after_row", end); err != nil {
+		return err
+	}
+	if err := db.Callback().Raw().Before("gorm:raw").Register("tracing:before_raw", start("raw")); err != nil {
+		return err
+	}
+	return db.Callback().Raw().After("gorm:raw").Register("tracing:after_raw", end)
+}
+
+func start(operation string) func(*gorm.DB) {
+	tracer := otel.Tracer("library-management/gorm")
+	return func(db *gorm.DB) {
+		name := "gorm." + operation
+		if db.Statement.Table != "" {
+			name += " " + db.Statement.Table
+		}
+		ctx, span := tracer.Start(db.Statement.Context, name, trace.WithSpanKind(trace.SpanKindClient))
+		db.Statement.Context = ctx
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func end(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBSystemPostgreSQL,
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		semconv.DBCollectionName(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
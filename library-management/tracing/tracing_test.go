@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+
+	gormDB, err := gorm.Open(postgres.New(postgres.Config{Conn: db}), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, gormDB.Use(&GormPlugin{}))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(), Annotate())
+	r.GET("/libraries/:libraryid/books", func(c *gin.Context) {
+		c.Set("userRole", "admin")
+		var count int64
+		gormDB.WithContext(c.Request.Context()).Table("books").Where("library_id = ?", c.Param("libraryid")).Count(&count)
+		c.JSON(http.StatusOK, gin.H{"count": count})
+	})
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE library_id = $1`)).
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	req := httptest.NewRequest(http.MethodGet, "/libraries/7/books", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	querySpan, requestSpan := spans[0], spans[1]
+
+	assert.Equal(t, "/libraries/:libraryid/books", requestSpan.Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestSpan.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", requestSpan.Parent().SpanID().String())
+	assert.Contains(t, requestSpan.Attributes(), attribute.String("enduser.role", "admin"))
+	assert.Contains(t, requestSpan.Attributes(), attribute.Int64("library.id", 7))
+
+	assert.Equal(t, "gorm.query books", querySpan.Name())
+	assert.Equal(t, requestSpan.SpanContext().SpanID(), querySpan.Parent().SpanID())
+	assert.Contains(t, querySpan.Attributes(), attribute.String("db.query.text", `SELECT count(*) FROM "books" WHERE library_id = $1`))
+}
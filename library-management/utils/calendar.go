@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"library-management/models"
+	"time"
+)
+
+// LibraryLocation returns the library's timezone, falling back to UTC
+func LibraryLocation(library models.Library) *time.Location {
+	if library.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(library.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// IsLibraryOpen reports whether the library opens at all on the given day
+func IsLibraryOpen(library models.Library, day time.Time) bool {
+	day = day.In(LibraryLocation(library))
+
+	date := day.Format("2006-01-02")
+	for _, closure := range library.Closures {
+		if closure.Date == date {
+			return false
+		}
+	}
+
+	// No weekly schedule configured means open every day
+	if len(library.OpeningHours) == 0 {
+		return true
+	}
+	for _, hours := range library.OpeningHours {
+		if hours.Weekday == day.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+// DueDate returns when a loan issued at issuedAt falls due: loanDays later in the
+// library's timezone, moved forward to the next day the library is open
+func DueDate(library models.Library, issuedAt time.Time, loanDays int) time.Time {
+	due := issuedAt.In(LibraryLocation(library)).AddDate(0, 0, loanDays)
+
+	// A year is plenty; a library that never opens keeps the plain due date
+	for i := 0; i < 366; i++ {
+		if IsLibraryOpen(library, due) {
+			return due
+		}
+		due = due.AddDate(0, 0, 1)
+	}
+	return issuedAt.AddDate(0, 0, loanDays)
+}
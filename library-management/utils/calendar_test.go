@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"library-management/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueDate(t *testing.T) {
+	// Wednesday 1 January 2025
+	issuedAt := time.Date(2025, time.January, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("No Schedule", func(t *testing.T) {
+		due := DueDate(models.Library{}, issuedAt, 14)
+		assert.Equal(t, "2025-01-15", due.Format("2006-01-02"))
+	})
+
+	t.Run("Skips Closed Weekdays", func(t *testing.T) {
+		library := models.Library{OpeningHours: []models.OpeningHours{
+			{Weekday: time.Monday, Opens: "09:00", Closes: "17:00"},
+			{Weekday: time.Thursday, Opens: "09:00", Closes: "17:00"},
+		}}
+		due := DueDate(library, issuedAt, 14)
+		assert.Equal(t, time.Thursday, due.Weekday())
+		assert.Equal(t, "2025-01-16", due.Format("2006-01-02"))
+	})
+
+	t.Run("Skips Closures", func(t *testing.T) {
+		library := models.Library{Closures: []models.LibraryClosure{
+			{Date: "2025-01-15", Reason: "Stocktake"},
+			{Date: "2025-01-16", Reason: "Stocktake"},
+		}}
+		due := DueDate(library, issuedAt, 14)
+		assert.Equal(t, "2025-01-17", due.Format("2006-01-02"))
+	})
+
+	t.Run("Uses Library Timezone", func(t *testing.T) {
+		// 20:00 UTC on 1 January is already 2 January in Kolkata
+		library := models.Library{Timezone: "Asia/Kolkata", Closures: []models.LibraryClosure{
+			{Date: "2025-01-16", Reason: "Holiday"},
+		}}
+		due := DueDate(library, issuedAt.Add(10*time.Hour), 14)
+		assert.Equal(t, "2025-01-17", due.Format("2006-01-02"))
+	})
+
+	t.Run("Never Open", func(t *testing.T) {
+		due := DueDate(models.Library{Closures: everyDay(issuedAt, 400)}, issuedAt, 14)
+		assert.Equal(t, issuedAt.AddDate(0, 0, 14), due)
+	})
+}
+
+func everyDay(from time.Time, days int) []models.LibraryClosure {
+	closures := make([]models.LibraryClosure, days)
+	for i := range closures {
+		closures[i].Date = from.AddDate(0, 0, i).Format("2006-01-02")
+	}
+	return closures
+}
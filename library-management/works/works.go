@@ -0,0 +1,152 @@
+// Package works groups books into works. Each ISBN is one edition of a work,
+// and every library's copies of an ISBN belong to the same work, so searches can
+// show a title once with its hardcover, paperback and later editions beneath it.
+package works
+
+import (
+	"errors"
+	"library-management/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownEdition means no library holds one of the given ISBNs
+	ErrUnknownEdition = errors.New("no library holds this ISBN")
+	// ErrNotInWork means the ISBN is an edition of some other work
+	ErrNotInWork = errors.New("the ISBN is not an edition of this work")
+	// ErrLastEdition means splitting would leave the work without editions
+	ErrLastEdition = errors.New("the work has no other edition")
+)
+
+// Assign sets book.WorkID to the work other copies of its ISBN belong to, or to
+// a new work named after the book when it is the first copy catalogued
+func Assign(tx *gorm.DB, book *models.Book) error {
+	var workIDs []uint
+	if err := tx.Model(&models.Book{}).Where("isbn = ? AND work_id <> 0", book.ISBN).Limit(1).Pluck("work_id", &workIDs).Error; err != nil {
+		return err
+	}
+	if len(workIDs) > 0 {
+		book.WorkID = workIDs[0]
+		return nil
+	}
+
+	work := models.Work{Title: book.Title, Authors: book.Authors}
+	if err := tx.Create(&work).Error; err != nil {
+		return err
+	}
+	book.WorkID = work.ID
+	return nil
+}
+
+// Merge makes the given ISBNs editions of work, deleting any work left without
+// editions. Holds on a deleted work move to work with its editions.
+func Merge(db *gorm.DB, workID uint, isbns []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var found []string
+		if err := tx.Model(&models.Book{}).Where("isbn IN ?", isbns).Distinct().Pluck("isbn", &found).Error; err != nil {
+			return err
+		}
+		if len(found) != len(unique(isbns)) {
+			return ErrUnknownEdition
+		}
+
+		var previous []uint
+		if err := tx.Model(&models.Book{}).Where("isbn IN ? AND work_id <> ?", isbns, workID).Distinct().Pluck("work_id", &previous).Error; err != nil {
+			return err
+		}
+		if err := move(tx, workID, "isbn IN ?", isbns); err != nil {
+			return err
+		}
+		if err := moveHolds(tx, workID, previous); err != nil {
+			return err
+		}
+		return prune(tx, previous)
+	})
+}
+
+// Split moves one edition out of work into a new work named after it
+func Split(db *gorm.DB, workID uint, isbn string) (models.Work, error) {
+	var work models.Work
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var edition models.Book
+		if err := tx.Where("isbn = ?", isbn).First(&edition).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUnknownEdition
+			}
+			return err
+		}
+		if edition.WorkID != workID {
+			return ErrNotInWork
+		}
+
+		var others int64
+		if err := tx.Model(&models.Book{}).Where("work_id = ? AND isbn <> ?", workID, isbn).Count(&others).Error; err != nil {
+			return err
+		}
+		if others == 0 {
+			return ErrLastEdition
+		}
+
+		work = models.Work{Title: edition.Title, Authors: edition.Authors}
+		if err := tx.Create(&work).Error; err != nil {
+			return err
+		}
+		return move(tx, work.ID, "isbn = ?", isbn)
+	})
+	return work, err
+}
+
+// move points the books matching the condition at work. The revision is bumped
+// by hand since UpdateColumns skips the BeforeUpdate hook.
+func move(tx *gorm.DB, workID uint, condition string, args ...interface{}) error {
+	return tx.Model(&models.Book{}).Where(condition, args...).UpdateColumns(map[string]interface{}{
+		"work_id":  workID,
+		"revision": gorm.Expr("revision + 1"),
+	}).Error
+}
+
+// moveHolds points requests held on those of workIDs that no book belongs to
+// any more at work, before prune deletes them
+func moveHolds(tx *gorm.DB, workID uint, workIDs []uint) error {
+	if len(workIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&models.RequestEvent{}).
+		Where("work_id IN ? AND work_id NOT IN (?)", workIDs, tx.Model(&models.Book{}).Select("work_id")).
+		Update("work_id", workID).Error
+}
+
+// prune deletes those of workIDs that no book belongs to any more
+func prune(tx *gorm.DB, workIDs []uint) error {
+	if len(workIDs) == 0 {
+		return nil
+	}
+	return tx.Where("id IN ? AND id NOT IN (?)", workIDs, tx.Model(&models.Book{}).Select("work_id")).
+		Delete(&models.Work{}).Error
+}
+
+// Backfill gives every book a work, one per ISBN, for databases created before
+// books were grouped into works
+func Backfill(db *gorm.DB) error {
+	var books []models.Book
+	return db.Select("id, isbn, title, authors").Where("work_id = 0").FindInBatches(&books, 500, func(_ *gorm.DB, _ int) error {
+		for _, book := range books {
+			if err := Assign(db, &book); err != nil {
+				return err
+			}
+			if err := db.Model(&models.Book{}).Where("id = ?", book.ID).UpdateColumn("work_id", book.WorkID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func unique(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
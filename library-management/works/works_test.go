@@ -0,0 +1,155 @@
+package works
+
+import (
+	"regexp"
+	"testing"
+
+	"library-management/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func open(t *testing.T) (*gorm.DB, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{})
+	require.NoError(t, err)
+	return db, mock
+}
+
+func TestAssign(t *testing.T) {
+	t.Run("Joins The Work Of Other Copies", func(t *testing.T) {
+		db, mock := open(t)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "work_id" FROM "books" WHERE (isbn = $1 AND work_id <> 0) AND "books"."deleted_at" IS NULL LIMIT $2`)).
+			WithArgs("123", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}).AddRow(7))
+
+		book := models.Book{ISBN: "123", Title: "Dune"}
+		assert.NoError(t, Assign(db, &book))
+		assert.Equal(t, uint(7), book.WorkID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("First Copy Starts A Work", func(t *testing.T) {
+		db, mock := open(t)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT "work_id" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}))
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "works" ("created_at","updated_at","title","authors") VALUES ($1,$2,$3,$4) RETURNING "id"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Dune", "Frank Herbert").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+		mock.ExpectCommit()
+
+		book := models.Book{ISBN: "123", Title: "Dune", Authors: "Frank Herbert"}
+		assert.NoError(t, Assign(db, &book))
+		assert.Equal(t, uint(8), book.WorkID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestMerge(t *testing.T) {
+	t.Run("Moves Editions, Holds And Prunes Empty Works", func(t *testing.T) {
+		db, mock := open(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "isbn" FROM "books" WHERE isbn IN ($1,$2)`)).
+			WithArgs("111", "222").
+			WillReturnRows(sqlmock.NewRows([]string{"isbn"}).AddRow("111").AddRow("222"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "work_id" FROM "books" WHERE (isbn IN ($1,$2) AND work_id <> $3)`)).
+			WithArgs("111", "222", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}).AddRow(2))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "revision"=revision + 1,"work_id"=$1 WHERE isbn IN ($2,$3)`)).
+			WithArgs(1, "111", "222").
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "request_events" SET "work_id"=$1,"updated_at"=$2 WHERE (work_id IN ($3) AND work_id NOT IN (SELECT "work_id" FROM "books" WHERE "books"."deleted_at" IS NULL)) AND "request_events"."deleted_at" IS NULL`)).
+			WithArgs(1, sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "works" WHERE id IN ($1) AND id NOT IN (SELECT "work_id" FROM "books" WHERE "books"."deleted_at" IS NULL)`)).
+			WithArgs(2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		assert.NoError(t, Merge(db, 1, []string{"111", "222"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Already In The Work", func(t *testing.T) {
+		db, mock := open(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "isbn" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"isbn"}).AddRow("111"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "work_id" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"work_id"}))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books"`)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		// No work is emptied, so no holds move and nothing is pruned
+		assert.NoError(t, Merge(db, 1, []string{"111"}))
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Unknown ISBN", func(t *testing.T) {
+		db, mock := open(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT DISTINCT "isbn" FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"isbn"}).AddRow("111"))
+		mock.ExpectRollback()
+
+		assert.ErrorIs(t, Merge(db, 1, []string{"111", "999"}), ErrUnknownEdition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestSplit(t *testing.T) {
+	expectEdition := func(mock sqlmock.Sqlmock, workID int) {
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "books" WHERE isbn = $1`)).
+			WithArgs("222", 1).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "isbn", "title", "authors", "work_id"}).AddRow(4, "222", "Dune (Movie Tie-In)", "Frank Herbert", workID))
+	}
+
+	t.Run("Edition Gets Its Own Work", func(t *testing.T) {
+		db, mock := open(t)
+		expectEdition(mock, 1)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books" WHERE (work_id = $1 AND isbn <> $2)`)).
+			WithArgs(1, "222").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+		mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "works"`)).
+			WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Dune (Movie Tie-In)", "Frank Herbert").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(9))
+		mock.ExpectExec(regexp.QuoteMeta(`UPDATE "books" SET "revision"=revision + 1,"work_id"=$1 WHERE isbn = $2`)).
+			WithArgs(9, "222").
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		work, err := Split(db, 1, "222")
+		assert.NoError(t, err)
+		assert.Equal(t, uint(9), work.ID)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Other Work", func(t *testing.T) {
+		db, mock := open(t)
+		expectEdition(mock, 3)
+		mock.ExpectRollback()
+
+		_, err := Split(db, 1, "222")
+		assert.ErrorIs(t, err, ErrNotInWork)
+	})
+
+	t.Run("Last Edition", func(t *testing.T) {
+		db, mock := open(t)
+		expectEdition(mock, 1)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "books"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+		mock.ExpectRollback()
+
+		_, err := Split(db, 1, "222")
+		assert.ErrorIs(t, err, ErrLastEdition)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
@@ -47,7 +47,7 @@ const AdminAddBook = () => {
       const data = await response.json();
 
       if (!response.ok) {
-        throw new Error(data.error || "Failed to add the book.");
+        throw new Error(data.detail || "Failed to add the book.");
       }
 
       setSuccessMessage(data.message); // Show success message
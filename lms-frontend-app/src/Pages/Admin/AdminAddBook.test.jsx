@@ -69,7 +69,7 @@ test("handles API failure gracefully", async () => {
   // Mock failed API response
   fetch.mockResolvedValueOnce({
     ok: false,
-    json: async () => ({ error: "Failed to add book." }),
+    json: async () => ({ code: "internal_error", detail: "Failed to add book." }),
   });
 
   render(
@@ -101,7 +101,7 @@ const AdminListRequests = () => {
       console.log("📥 Response received: ", data);
 
       if (!response.ok) {
-        throw new Error(data.error || "Failed to issue book");
+        throw new Error(data.detail || "Failed to issue book");
       }
 
       alert("Book issued successfully!");
@@ -29,7 +29,7 @@ const AdminRemoveBook = () => {
       setLoading(false); // Stop loading state
 
       if (!response.ok) {
-        throw new Error(data.error || "Failed to remove book.");
+        throw new Error(data.detail || "Failed to remove book.");
       }
 
       setMessage(data.message);
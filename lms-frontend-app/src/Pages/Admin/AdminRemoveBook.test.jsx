@@ -81,7 +81,7 @@ test("submits form and displays success message on valid API response", async ()
 test("displays error message when API call fails", async () => {
   fetch.mockResolvedValueOnce({
     ok: false,
-    json: async () => ({ error: "Failed to remove book" }),
+    json: async () => ({ code: "internal_error", detail: "Failed to remove book" }),
   });
 
   render(
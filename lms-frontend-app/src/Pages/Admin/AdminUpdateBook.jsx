@@ -51,7 +51,7 @@ const AdminUpdateBook = () => {
       const data = await response.json();
 
       if (!response.ok) {
-        throw new Error(data.error || "Failed to update the book.");
+        throw new Error(data.detail || "Failed to update the book.");
       }
 
       setSuccessMessage("Book updated successfully.");
@@ -75,7 +75,7 @@ describe("AdminUpdateBook", () => {
     global.fetch = vi.fn(() =>
       Promise.resolve({
         ok: false,
-        json: () => Promise.resolve({ error: "Failed to update the book." }),
+        json: () => Promise.resolve({ code: "internal_error", detail: "Failed to update the book." }),
       })
     );
 
@@ -45,7 +45,7 @@ function Login() {
             setErrorMessage('Unknown role');
         }
       } else {
-        setErrorMessage(result.error || 'Login failed');
+        setErrorMessage(result.detail || 'Login failed');
       }
     } catch (error) {
       setErrorMessage('An error occurred during login.');
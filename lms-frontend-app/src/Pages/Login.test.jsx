@@ -43,7 +43,7 @@ describe("Login Component", () => {
     global.fetch = vi.fn(() =>
       Promise.resolve({
         ok: false,
-        json: () => Promise.resolve({ error: "Invalid credentials" }),
+        json: () => Promise.resolve({ code: "invalid_credentials", detail: "Invalid credentials" }),
       })
     );
 
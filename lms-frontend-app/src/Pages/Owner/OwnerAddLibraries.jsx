@@ -35,8 +35,8 @@ function OwnerAddLibraries() {
     })
       .then((response) => response.json())
       .then((data) => {
-        if (data.error) {
-          setError(data.error);
+        if (data.code) {
+          setError(data.detail || data.title);
           setMessage("");
         } else {
           setMessage(data.message);
@@ -82,7 +82,7 @@ test("displays error message when API returns an error", async () => {
 
   // Mock fetch to simulate an error response
   fetch.mockResolvedValueOnce({
-    json: vi.fn().mockResolvedValue({ error: "Failed to add library" }),
+    json: vi.fn().mockResolvedValue({ code: "internal_error", detail: "Failed to add library" }),
   });
 
   render(
@@ -42,8 +42,8 @@ function OwnerRegisterAdmin() {
     })
       .then((response) => response.json())
       .then((data) => {
-        if (data.error) {
-          setError(data.error);
+        if (data.code) {
+          setError(data.detail || data.title);
           setMessage("");
         } else {
           setMessage(data.message);
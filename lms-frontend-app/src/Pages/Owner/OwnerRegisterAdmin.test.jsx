@@ -44,7 +44,7 @@ describe("OwnerRegisterAdmin", () => {
 
   test("displays error message when API returns an error", async () => {
     // Mock fetch to simulate an error response
-    const errorMessage = { error: "Error registering new admin" };
+    const errorMessage = { code: "internal_error", detail: "Error registering new admin" };
     fetch.mockResolvedValueOnce({
       json: () => Promise.resolve(errorMessage),
     });
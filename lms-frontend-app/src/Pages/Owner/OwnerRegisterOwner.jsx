@@ -41,8 +41,8 @@ function OwnerRegisterOwner() {
     })
       .then((response) => response.json())
       .then((data) => {
-        if (data.error) {
-          setError(data.error);
+        if (data.code) {
+          setError(data.detail || data.title);
           setMessage("");
         } else {
           setMessage(data.message);
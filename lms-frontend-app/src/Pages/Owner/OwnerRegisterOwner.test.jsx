@@ -43,7 +43,7 @@ describe("OwnerRegisterOwner", () => {
 
   test("displays error message when API returns an error", async () => {
     // Mock fetch to simulate an error response
-    const errorMessage = { error: "Error registering new owner" };
+    const errorMessage = { code: "internal_error", detail: "Error registering new owner" };
     fetch.mockResolvedValueOnce({
       json: () => Promise.resolve(errorMessage),
     });
@@ -48,7 +48,7 @@ function Signup() {
         // Navigate to the User Portal after successful registration
         navigate('/user/userPortal');
       } else {
-        setErrorMessage(result.error || 'Sign-up failed');
+        setErrorMessage(result.detail || 'Sign-up failed');
       }
     } catch (error) {
       setErrorMessage('An error occurred while registering. Please try again.');
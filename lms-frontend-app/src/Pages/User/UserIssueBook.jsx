@@ -38,7 +38,7 @@ const UserIssueBook = () => {
 
       setMessage(response.data.message); // Display success message
     } catch (error) {
-      setMessage(error.response?.data?.error || "An error occurred");
+      setMessage(error.response?.data?.detail || "An error occurred");
     }
   };
 
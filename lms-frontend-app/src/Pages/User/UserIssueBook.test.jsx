@@ -83,7 +83,7 @@ describe("UserIssueBook Component", () => {
   });
 
   test("should display an error message if API call fails", async () => {
-    const mockError = { response: { data: { error: "Something went wrong!" } } };
+    const mockError = { response: { data: { code: "internal_error", detail: "Something went wrong!" } } };
     axios.post.mockRejectedValue(mockError); // Mock an API failure
 
     render(
@@ -34,8 +34,9 @@ const UserSearchBooks = () => {
         headers: { Authorization: `Bearer ${token}` },
       });
 
-      if (response.data && response.data.books) {
-        setBooks(response.data.books);
+      if (response.data && response.data.works) {
+        // Search returns works, list each of their editions
+        setBooks(response.data.works.flatMap((work) => work.editions));
       } else {
         setBooks([]);
         setError("No books found.");
@@ -80,8 +81,8 @@ const UserSearchBooks = () => {
         headers: { Authorization: `Bearer ${token}` },
       });
 
-      if (response.data && response.data.books) {
-        setBooks(response.data.books);
+      if (response.data && response.data.works) {
+        setBooks(response.data.works.flatMap((work) => work.editions));
       } else {
         setBooks([]);
         setError("No books found for the given search criteria.");
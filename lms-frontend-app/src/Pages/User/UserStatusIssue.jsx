@@ -28,7 +28,7 @@ const UserStatusIssue = () => {
         setError("");
       } catch (err) {
         setRequests([]); //Ensure requests are cleared on error
-        setError(err.response?.data?.error || "Unable to fetch issue status");
+        setError(err.response?.data?.detail || "Unable to fetch issue status");
       } finally {
         setLoading(false);
       }
@@ -47,7 +47,7 @@ describe("UserStatusIssue Component", () => {
   });
 
   test("should display an error message when API call fails", async () => {
-    const mockError = { response: { data: { error: "Unable to fetch issue status" } } };
+    const mockError = { response: { data: { code: "internal_error", detail: "Unable to fetch issue status" } } };
     axios.get.mockRejectedValue(mockError); // Mock an API failure
 
     render(